@@ -0,0 +1,83 @@
+package analytics
+
+import (
+	"sort"
+	"time"
+
+	"strzcam.com/broadcaster/events"
+)
+
+// Activity is the number of detection events of one class in the hour or
+// the day starting at Start. A lasting detection is logged once a second.
+type Activity struct {
+	Start time.Time `json:"start"`
+	Class int       `json:"class"`
+	Count int       `json:"count"`
+}
+
+type ActivityReport struct {
+	Hours   []Activity `json:"hours"`
+	Days    []Activity `json:"days"` // days in the time zone of the query
+	Heatmap Heatmap    `json:"heatmap"`
+}
+
+// CountActivity groups detections by hour and by day in location
+func CountActivity(detections []events.Event, location *time.Location) ([]Activity, []Activity) {
+	hours, days := []Activity{}, []Activity{}
+	hourIndex, dayIndex := map[Activity]int{}, map[Activity]int{}
+	add := func(counts []Activity, index map[Activity]int, key Activity) []Activity {
+		i, ok := index[key]
+		if !ok {
+			i = len(counts)
+			index[key] = i
+			counts = append(counts, key)
+		}
+		counts[i].Count++
+		return counts
+	}
+	for _, event := range detections {
+		hours = add(hours, hourIndex, Activity{Start: event.Time.UTC().Truncate(time.Hour), Class: event.Class})
+		local := event.Time.In(location)
+		day := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, location)
+		days = add(days, dayIndex, Activity{Start: day, Class: event.Class})
+	}
+	sortActivity(hours)
+	sortActivity(days)
+	return hours, days
+}
+
+func sortActivity(counts []Activity) {
+	sort.SliceStable(counts, func(i, j int) bool {
+		if !counts[i].Start.Equal(counts[j].Start) {
+			return counts[i].Start.Before(counts[j].Start)
+		}
+		return counts[i].Class < counts[j].Class
+	})
+}
+
+// LoadActivity reads detections from the event log and the heatmap of the
+// query, heatmapDir may be empty when heatmaps are not recorded
+func LoadActivity(eventsDir string, heatmapDir string, query Query) (ActivityReport, error) {
+	report := ActivityReport{Hours: []Activity{}, Days: []Activity{}, Heatmap: NewHeatmap(query.Camera)}
+	if err := query.Validate(); err != nil {
+		return report, err
+	}
+	location, _ := query.Location()
+	all, err := events.Read(eventsDir, query.Camera, query.Start, query.End)
+	if err != nil {
+		return report, err
+	}
+	var detections []events.Event
+	for _, event := range all {
+		if event.Type == events.TypeDetection {
+			detections = append(detections, event)
+		}
+	}
+	report.Hours, report.Days = CountActivity(detections, location)
+	if heatmapDir != "" {
+		if report.Heatmap, err = LoadHeatmap(heatmapDir, query); err != nil {
+			return report, err
+		}
+	}
+	return report, nil
+}
@@ -0,0 +1,155 @@
+package analytics
+
+import (
+	"errors"
+	"sort"
+	"time"
+
+	"strzcam.com/broadcaster/events"
+	"strzcam.com/broadcaster/frame"
+	"strzcam.com/broadcaster/zones"
+)
+
+type Crossing struct {
+	Track     int
+	Class     int
+	Tripwire  string
+	Direction string
+}
+
+// Analyzer tracks objects and reports tripwire crossings
+type Analyzer struct {
+	tracker   *Tracker
+	tripwires []zones.Tripwire
+}
+
+func NewAnalyzer(tripwires []zones.Tripwire) *Analyzer {
+	return &Analyzer{tracker: NewTracker(), tripwires: tripwires}
+}
+
+// Update tracks boxes of the frame, frames without boxes age the tracks
+func (a *Analyzer) Update(f frame.Frame) []Crossing {
+	if f.Width == 0 || f.Height == 0 {
+		return nil
+	}
+	var crossings []Crossing
+	for _, step := range a.tracker.Update(f.Boxes) {
+		from, to := center(step.From, f.Width, f.Height), center(step.To, f.Width, f.Height)
+		for _, tripwire := range a.tripwires {
+			if direction, ok := tripwire.Crossed(from, to); ok {
+				crossings = append(crossings, Crossing{
+					Track:     step.ID,
+					Class:     step.Class,
+					Tripwire:  tripwire.Name,
+					Direction: direction,
+				})
+			}
+		}
+	}
+	return crossings
+}
+
+func center(box frame.Box, width uint32, height uint32) zones.Point {
+	return zones.Point{
+		X: (float64(box.X) + float64(box.Width)/2) / float64(width),
+		Y: (float64(box.Y) + float64(box.Height)/2) / float64(height),
+	}
+}
+
+// Count is the number of crossings of a tripwire in one direction by one
+// class during the hour starting at Hour
+type Count struct {
+	Hour      time.Time `json:"hour"`
+	Tripwire  string    `json:"tripwire"`
+	Direction string    `json:"direction"`
+	Class     int       `json:"class"`
+	Count     int       `json:"count"`
+}
+
+// CountByHour groups crossing events by hour, tripwire, direction and class
+func CountByHour(crossings []events.Event) []Count {
+	index := map[Count]int{}
+	var counts []Count
+	for _, event := range crossings {
+		key := Count{Hour: event.Time.UTC().Truncate(time.Hour), Tripwire: event.Tripwire, Direction: event.Direction, Class: event.Class}
+		i, ok := index[key]
+		if !ok {
+			i = len(counts)
+			index[key] = i
+			counts = append(counts, key)
+		}
+		counts[i].Count++
+	}
+	sort.SliceStable(counts, func(i, j int) bool {
+		a, b := counts[i], counts[j]
+		if !a.Hour.Equal(b.Hour) {
+			return a.Hour.Before(b.Hour)
+		}
+		if a.Tripwire != b.Tripwire {
+			return a.Tripwire < b.Tripwire
+		}
+		if a.Direction != b.Direction {
+			return a.Direction < b.Direction
+		}
+		return a.Class < b.Class
+	})
+	return counts
+}
+
+type Query struct {
+	Camera   string    `json:"camera,omitempty"`
+	Tripwire string    `json:"tripwire,omitempty"` // all tripwires when empty
+	Start    time.Time `json:"start"`
+	End      time.Time `json:"end"`
+	TimeZone string    `json:"timeZone,omitempty"` // IANA name days are counted in, UTC when empty
+}
+
+func (q Query) Validate() error {
+	if q.Start.IsZero() || q.End.IsZero() {
+		return errors.New("start and end are required")
+	}
+	if !q.End.After(q.Start) {
+		return errors.New("end must be after start")
+	}
+	if _, err := q.Location(); err != nil {
+		return errors.New("unknown time zone")
+	}
+	return nil
+}
+
+func (q Query) Location() (*time.Location, error) {
+	if q.TimeZone == "" {
+		return time.UTC, nil
+	}
+	return time.LoadLocation(q.TimeZone)
+}
+
+type Report struct {
+	Crossings []events.Event `json:"crossings"`
+	Counts    []Count        `json:"counts"`
+}
+
+// Load reads crossings matching the query from the event log
+func Load(eventsDir string, query Query) (Report, error) {
+	report := Report{Crossings: []events.Event{}, Counts: []Count{}}
+	if err := query.Validate(); err != nil {
+		return report, err
+	}
+	all, err := events.Read(eventsDir, query.Camera, query.Start, query.End)
+	if err != nil {
+		return report, err
+	}
+	for _, event := range all {
+		if event.Type != events.TypeCrossing {
+			continue
+		}
+		if query.Tripwire != "" && event.Tripwire != query.Tripwire {
+			continue
+		}
+		report.Crossings = append(report.Crossings, event)
+	}
+	if counts := CountByHour(report.Crossings); counts != nil {
+		report.Counts = counts
+	}
+	return report, nil
+}
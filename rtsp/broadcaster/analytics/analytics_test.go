@@ -0,0 +1,151 @@
+package analytics
+
+import (
+	"testing"
+	"time"
+
+	"strzcam.com/broadcaster/events"
+	"strzcam.com/broadcaster/frame"
+	"strzcam.com/broadcaster/zones"
+)
+
+const car = 2
+
+func carAt(x int) frame.Box {
+	return frame.Box{Class: car, X: x, Y: 40, Width: 20, Height: 20}
+}
+
+func TestTracker(t *testing.T) {
+	tracker := NewTracker()
+	tracker.Update([]frame.Box{carAt(0), carAt(60)})
+	steps := tracker.Update([]frame.Box{carAt(65), carAt(5)})
+	if len(steps) != 2 {
+		t.Fatalf("Expected 2 steps, got %+v", steps)
+	}
+	for _, step := range steps {
+		if step.To.X-step.From.X != 5 {
+			t.Errorf("Expected track %d to follow its car, got %+v", step.ID, step)
+		}
+	}
+	tracker.MaxMissed = 1
+	tracker.Update(nil)
+	tracker.Update(nil)
+	if tracks := tracker.Tracks(); len(tracks) != 0 {
+		t.Errorf("Expected lost tracks to be dropped, got %+v", tracks)
+	}
+}
+
+func TestAnalyzer(t *testing.T) {
+	// vertical line in the middle, moving right crosses it from its right to its left side
+	tripwire := zones.Tripwire{Name: "driveway", From: zones.Point{X: 0.5, Y: 0}, To: zones.Point{X: 0.5, Y: 1}}
+	move := func(analyzer *Analyzer, positions ...int) []Crossing {
+		var crossings []Crossing
+		for _, x := range positions {
+			f := frame.Frame{Width: 100, Height: 100, Boxes: []frame.Box{carAt(x)}}
+			crossings = append(crossings, analyzer.Update(f)...)
+		}
+		return crossings
+	}
+
+	crossings := move(NewAnalyzer([]zones.Tripwire{tripwire}), 20, 30, 40, 50)
+	if len(crossings) != 1 || crossings[0].Direction != zones.DirectionOut || crossings[0].Class != car {
+		t.Fatalf("Expected one car going out, got %+v", crossings)
+	}
+	crossings = move(NewAnalyzer([]zones.Tripwire{tripwire}), 50, 40, 30)
+	if len(crossings) != 1 || crossings[0].Direction != zones.DirectionIn {
+		t.Fatalf("Expected one car going in, got %+v", crossings)
+	}
+	tripwire.Direction = zones.DirectionIn
+	if crossings := move(NewAnalyzer([]zones.Tripwire{tripwire}), 20, 30, 40, 50); len(crossings) != 0 {
+		t.Errorf("Expected the other direction to be ignored, got %+v", crossings)
+	}
+}
+
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+	log, err := events.NewLog(dir, "front")
+	if err != nil {
+		t.Fatal(err)
+	}
+	hour := time.Date(2025, 6, 1, 10, 0, 0, 0, time.UTC)
+	log.Record(events.Event{Time: hour.Add(5 * time.Minute), Type: events.TypeCrossing, Class: car, Track: 1, Tripwire: "driveway", Direction: zones.DirectionIn})
+	log.Record(events.Event{Time: hour.Add(50 * time.Minute), Type: events.TypeCrossing, Class: car, Track: 2, Tripwire: "driveway", Direction: zones.DirectionIn})
+	log.Record(events.Event{Time: hour.Add(70 * time.Minute), Type: events.TypeCrossing, Class: car, Track: 3, Tripwire: "driveway", Direction: zones.DirectionIn})
+	log.Record(events.Event{Time: hour.Add(10 * time.Minute), Type: events.TypeCrossing, Class: 0, Track: 4, Tripwire: "gate", Direction: zones.DirectionOut})
+	log.Record(events.Event{Time: hour.Add(20 * time.Minute), Type: events.TypeDetection, Class: car})
+
+	report, err := Load(dir, Query{Tripwire: "driveway", Start: hour, End: hour.Add(2 * time.Hour)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Crossings) != 3 {
+		t.Errorf("Expected 3 driveway crossings, got %d", len(report.Crossings))
+	}
+	expected := []Count{
+		{Hour: hour, Tripwire: "driveway", Direction: zones.DirectionIn, Class: car, Count: 2},
+		{Hour: hour.Add(time.Hour), Tripwire: "driveway", Direction: zones.DirectionIn, Class: car, Count: 1},
+	}
+	if len(report.Counts) != len(expected) {
+		t.Fatalf("Expected counts %+v, got %+v", expected, report.Counts)
+	}
+	for i := range expected {
+		if report.Counts[i] != expected[i] {
+			t.Errorf("Expected count %+v, got %+v", expected[i], report.Counts[i])
+		}
+	}
+}
+
+func TestCountActivity(t *testing.T) {
+	warsaw, _ := time.LoadLocation("Europe/Warsaw")
+	night := time.Date(2025, 6, 1, 22, 30, 0, 0, time.UTC) // after midnight in Warsaw
+	detections := []events.Event{
+		{Time: night, Type: events.TypeDetection, Class: car},
+		{Time: night.Add(time.Minute), Type: events.TypeDetection, Class: car},
+		{Time: night.Add(-2 * time.Hour), Type: events.TypeDetection, Class: 0},
+	}
+	hours, days := CountActivity(detections, warsaw)
+	if len(hours) != 2 || hours[1] != (Activity{Start: night.Truncate(time.Hour), Class: car, Count: 2}) {
+		t.Errorf("Unexpected hours %+v", hours)
+	}
+	if len(days) != 2 || !days[1].Start.Equal(time.Date(2025, 6, 2, 0, 0, 0, 0, warsaw)) || days[1].Count != 2 {
+		t.Errorf("Expected the car on the next day in Warsaw, got %+v", days)
+	}
+}
+
+func TestHeatmapRecorder(t *testing.T) {
+	dir := t.TempDir()
+	recorder, err := NewHeatmapRecorder(dir, "front")
+	if err != nil {
+		t.Fatal(err)
+	}
+	hour := time.Date(2025, 6, 1, 10, 0, 0, 0, time.UTC)
+	empty := frame.Frame{Width: 64, Height: 36, Detected: -1, Data: make([]byte, 64*36*3/2)}
+	if err := recorder.Add(empty, hour); err != nil {
+		t.Fatal(err)
+	}
+	// a box over the top left quarter
+	detected := frame.Frame{Width: 64, Height: 36, Detected: car, Boxes: []frame.Box{{Class: car, Width: 32, Height: 18}}}
+	recorder.Add(detected, hour.Add(time.Minute))
+	recorder.Add(detected, hour.Add(90*time.Minute))
+	if err := recorder.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	heatmap, err := LoadHeatmap(dir, Query{Start: hour, End: hour.Add(30 * time.Minute)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if heatmap.Camera != "front" || heatmap.Cells[0] != 1 || heatmap.Cells[HeatmapColumns/2-1] != 1 || heatmap.Cells[HeatmapColumns/2] != 0 {
+		t.Errorf("Expected the box in the first hour only, got %+v", heatmap)
+	}
+	if last := heatmap.Cells[(HeatmapRows/2)*HeatmapColumns]; last != 0 {
+		t.Errorf("Expected nothing below the box, got %d", last)
+	}
+	reference, err := LoadReference(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if overlay := heatmap.Overlay(reference); overlay.Bounds().Dx() != 64 || overlay.RGBAAt(0, 0).R == 0 && overlay.RGBAAt(0, 0).B == 0 {
+		t.Errorf("Expected the heat drawn over the reference, got %v", overlay.RGBAAt(0, 0))
+	}
+}
@@ -0,0 +1,288 @@
+package analytics
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/jpeg"
+	"math"
+	"os"
+	"path/filepath"
+	"time"
+
+	"strzcam.com/broadcaster/frame"
+)
+
+const (
+	HeatmapColumns = 32
+	HeatmapRows    = 18
+	// pending boxes are added to the day file this often
+	HeatmapFlushPeriod = time.Minute
+	// a frame without detections replaces the reference this often
+	referencePeriod = time.Hour
+	referenceFile   = "reference.jpg"
+)
+
+// Heatmap counts frames with a detection box over each cell of a low
+// resolution grid laid over the image
+type Heatmap struct {
+	Camera  string   `json:"camera,omitempty"`
+	Columns int      `json:"columns"`
+	Rows    int      `json:"rows"`
+	Cells   []uint32 `json:"cells"` // row by row from the top left
+}
+
+func NewHeatmap(camera string) Heatmap {
+	return Heatmap{Camera: camera, Columns: HeatmapColumns, Rows: HeatmapRows, Cells: make([]uint32, HeatmapColumns*HeatmapRows)}
+}
+
+// Add counts boxes of the frame in the cells they cover
+func (h Heatmap) Add(f frame.Frame) {
+	if f.Width == 0 || f.Height == 0 {
+		return
+	}
+	for _, box := range f.Boxes {
+		left, right := cellRange(box.X, box.Width, int(f.Width), h.Columns)
+		top, bottom := cellRange(box.Y, box.Height, int(f.Height), h.Rows)
+		for row := top; row <= bottom; row++ {
+			for column := left; column <= right; column++ {
+				h.Cells[row*h.Columns+column]++
+			}
+		}
+	}
+}
+
+// cellRange returns the first and last cell covered by a box side
+func cellRange(start int, length int, size int, cells int) (int, int) {
+	first := start * cells / size
+	last := (start + max(length, 1) - 1) * cells / size
+	return min(max(first, 0), cells-1), min(max(last, 0), cells-1)
+}
+
+func (h Heatmap) merge(cells []uint32) {
+	if len(cells) != len(h.Cells) {
+		return
+	}
+	for i, count := range cells {
+		h.Cells[i] += count
+	}
+}
+
+// Overlay draws the heatmap over the reference frame, a black 640x360 image
+// is used without one
+func (h Heatmap) Overlay(reference image.Image) *image.RGBA {
+	bounds := image.Rect(0, 0, 640, 360)
+	if reference != nil {
+		bounds = reference.Bounds()
+	}
+	result := image.NewRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	if reference != nil {
+		draw.Draw(result, result.Rect, reference, bounds.Min, draw.Src)
+	} else {
+		draw.Draw(result, result.Rect, image.NewUniform(color.Black), image.Point{}, draw.Src)
+	}
+	var peak uint32
+	for _, count := range h.Cells {
+		peak = max(peak, count)
+	}
+	if peak == 0 {
+		return result
+	}
+	width, height := result.Rect.Dx(), result.Rect.Dy()
+	for y := range height {
+		gy := (float64(y)+0.5)*float64(h.Rows)/float64(height) - 0.5
+		for x := range width {
+			gx := (float64(x)+0.5)*float64(h.Columns)/float64(width) - 0.5
+			value := h.sample(gx, gy) / float64(peak)
+			if value <= 0 {
+				continue
+			}
+			heat := heatColor(value)
+			alpha := 0.2 + 0.5*value
+			i := result.PixOffset(x, y)
+			result.Pix[i] = uint8(float64(result.Pix[i])*(1-alpha) + float64(heat.R)*alpha)
+			result.Pix[i+1] = uint8(float64(result.Pix[i+1])*(1-alpha) + float64(heat.G)*alpha)
+			result.Pix[i+2] = uint8(float64(result.Pix[i+2])*(1-alpha) + float64(heat.B)*alpha)
+		}
+	}
+	return result
+}
+
+// sample interpolates cells bilinearly, gx and gy are in cell units
+func (h Heatmap) sample(gx float64, gy float64) float64 {
+	x0, y0 := math.Floor(gx), math.Floor(gy)
+	fx, fy := gx-x0, gy-y0
+	cell := func(column int, row int) float64 {
+		column = min(max(column, 0), h.Columns-1)
+		row = min(max(row, 0), h.Rows-1)
+		return float64(h.Cells[row*h.Columns+column])
+	}
+	c, r := int(x0), int(y0)
+	top := cell(c, r)*(1-fx) + cell(c+1, r)*fx
+	bottom := cell(c, r+1)*(1-fx) + cell(c+1, r+1)*fx
+	return top*(1-fy) + bottom*fy
+}
+
+// heatColor goes from blue for little activity through green to red
+func heatColor(value float64) color.RGBA {
+	stops := []color.RGBA{{0, 0, 255, 255}, {0, 255, 255, 255}, {0, 255, 0, 255}, {255, 255, 0, 255}, {255, 0, 0, 255}}
+	position := min(max(value, 0), 1) * float64(len(stops)-1)
+	i := min(int(position), len(stops)-2)
+	f := position - float64(i)
+	mix := func(a uint8, b uint8) uint8 {
+		return uint8(float64(a)*(1-f) + float64(b)*f)
+	}
+	return color.RGBA{mix(stops[i].R, stops[i+1].R), mix(stops[i].G, stops[i+1].G), mix(stops[i].B, stops[i+1].B), 255}
+}
+
+// heatmapDay is the file of one UTC day, cells by hour of the day
+type heatmapDay struct {
+	Camera string           `json:"camera"`
+	Hours  map[int][]uint32 `json:"hours"`
+}
+
+func heatmapFileName(day time.Time) string {
+	return fmt.Sprintf("%s.json", day.UTC().Format("2006-01-02"))
+}
+
+func readHeatmapDay(path string) (heatmapDay, error) {
+	day := heatmapDay{Hours: map[int][]uint32{}}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return day, err
+	}
+	if err := json.Unmarshal(data, &day); err != nil {
+		return day, fmt.Errorf("invalid heatmap %s: %w", path, err)
+	}
+	if day.Hours == nil {
+		day.Hours = map[int][]uint32{}
+	}
+	return day, nil
+}
+
+// HeatmapRecorder accumulates boxes in memory and adds them to one file per
+// UTC day every HeatmapFlushPeriod, it also keeps a recent reference frame
+type HeatmapRecorder struct {
+	dir            string
+	camera         string
+	pending        map[time.Time]Heatmap // by hour
+	flushed        time.Time
+	referenceSaved time.Time
+}
+
+func NewHeatmapRecorder(dir string, camera string) (*HeatmapRecorder, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	return &HeatmapRecorder{dir: dir, camera: camera, pending: map[time.Time]Heatmap{}, flushed: time.Now()}, nil
+}
+
+func (r *HeatmapRecorder) Add(f frame.Frame, now time.Time) error {
+	if len(f.Boxes) > 0 {
+		hour := now.UTC().Truncate(time.Hour)
+		heatmap, ok := r.pending[hour]
+		if !ok {
+			heatmap = NewHeatmap(r.camera)
+			r.pending[hour] = heatmap
+		}
+		heatmap.Add(f)
+	} else if f.Detected == -1 && now.Sub(r.referenceSaved) >= referencePeriod {
+		r.referenceSaved = now
+		if err := r.saveReference(f); err != nil {
+			return err
+		}
+	}
+	if now.Sub(r.flushed) < HeatmapFlushPeriod {
+		return nil
+	}
+	r.flushed = now
+	return r.Flush()
+}
+
+// Flush adds pending boxes to the day files
+func (r *HeatmapRecorder) Flush() error {
+	for hour, heatmap := range r.pending {
+		path := filepath.Join(r.dir, heatmapFileName(hour))
+		day, err := readHeatmapDay(path)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+		day.Camera = r.camera
+		if cells, ok := day.Hours[hour.Hour()]; ok {
+			heatmap.merge(cells)
+		}
+		day.Hours[hour.Hour()] = heatmap.Cells
+		data, err := json.Marshal(day)
+		if err != nil {
+			return err
+		}
+		tmpPath := path + ".tmp"
+		if err := os.WriteFile(tmpPath, data, 0644); err != nil {
+			return err
+		}
+		if err := os.Rename(tmpPath, path); err != nil {
+			return err
+		}
+		delete(r.pending, hour)
+	}
+	return nil
+}
+
+func (r *HeatmapRecorder) saveReference(f frame.Frame) error {
+	if len(f.Data) < int(f.Width)*int(f.Height)*3/2 {
+		return errors.New("incomplete reference frame")
+	}
+	path := filepath.Join(r.dir, referenceFile)
+	tmpPath := path + ".tmp"
+	file, err := os.Create(tmpPath)
+	if err != nil {
+		return err
+	}
+	err = jpeg.Encode(file, frame.BytesToYCbCr(f.Data, int(f.Width), int(f.Height)), nil)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, path)
+}
+
+// LoadHeatmap sums hours of the query, partly covered hours count fully
+func LoadHeatmap(dir string, query Query) (Heatmap, error) {
+	result := NewHeatmap(query.Camera)
+	for day := query.Start.UTC().Truncate(24 * time.Hour); !day.After(query.End); day = day.AddDate(0, 0, 1) {
+		heatmapDay, err := readHeatmapDay(filepath.Join(dir, heatmapFileName(day)))
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return result, err
+		}
+		if query.Camera != "" && heatmapDay.Camera != query.Camera {
+			continue
+		}
+		result.Camera = heatmapDay.Camera
+		for hour, cells := range heatmapDay.Hours {
+			start := day.Add(time.Duration(hour) * time.Hour)
+			if !start.Add(time.Hour).After(query.Start) || start.After(query.End) {
+				continue
+			}
+			result.merge(cells)
+		}
+	}
+	return result, nil
+}
+
+// LoadReference returns the reference frame saved by the recorder
+func LoadReference(dir string) (image.Image, error) {
+	file, err := os.Open(filepath.Join(dir, referenceFile))
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return jpeg.Decode(file)
+}
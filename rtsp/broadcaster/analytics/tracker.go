@@ -0,0 +1,112 @@
+package analytics
+
+import (
+	"sort"
+
+	"strzcam.com/broadcaster/frame"
+)
+
+// Track follows one object across frames
+type Track struct {
+	ID     int
+	Class  int
+	Box    frame.Box
+	missed int
+}
+
+// Step is a move of a track between two frames it was seen in
+type Step struct {
+	ID    int
+	Class int
+	From  frame.Box
+	To    frame.Box
+}
+
+// Tracker assigns track ids to boxes by overlap (IoU) with the boxes of the
+// previous frame. It is cheap enough to run on every frame.
+type Tracker struct {
+	MinIoU    float64 // overlap needed to continue a track
+	MaxMissed int     // frames a track survives without a box
+	nextID    int
+	tracks    []*Track
+}
+
+func NewTracker() *Tracker {
+	return &Tracker{MinIoU: 0.2, MaxMissed: 10, nextID: 1}
+}
+
+// Update matches boxes of the next frame to tracks, it returns moves of the
+// matched tracks. Unmatched boxes start new tracks.
+func (t *Tracker) Update(boxes []frame.Box) []Step {
+	type pair struct {
+		track int
+		box   int
+		iou   float64
+	}
+	var pairs []pair
+	for i, track := range t.tracks {
+		for j, box := range boxes {
+			if box.Class != track.Class {
+				continue
+			}
+			if iou := IoU(track.Box, box); iou >= t.MinIoU {
+				pairs = append(pairs, pair{i, j, iou})
+			}
+		}
+	}
+	// greedy, best overlaps first
+	sort.Slice(pairs, func(a, b int) bool {
+		return pairs[a].iou > pairs[b].iou
+	})
+	matchedTracks := make([]bool, len(t.tracks))
+	matchedBoxes := make([]bool, len(boxes))
+	var steps []Step
+	for _, p := range pairs {
+		if matchedTracks[p.track] || matchedBoxes[p.box] {
+			continue
+		}
+		matchedTracks[p.track], matchedBoxes[p.box] = true, true
+		track := t.tracks[p.track]
+		steps = append(steps, Step{ID: track.ID, Class: track.Class, From: track.Box, To: boxes[p.box]})
+		track.Box = boxes[p.box]
+		track.missed = 0
+	}
+	tracks := t.tracks[:0]
+	for i, track := range t.tracks {
+		if !matchedTracks[i] {
+			track.missed++
+		}
+		if track.missed <= t.MaxMissed {
+			tracks = append(tracks, track)
+		}
+	}
+	for j, box := range boxes {
+		if !matchedBoxes[j] {
+			tracks = append(tracks, &Track{ID: t.nextID, Class: box.Class, Box: box})
+			t.nextID++
+		}
+	}
+	t.tracks = tracks
+	return steps
+}
+
+// Tracks returns the tracks alive after the last update
+func (t *Tracker) Tracks() []Track {
+	tracks := make([]Track, len(t.tracks))
+	for i, track := range t.tracks {
+		tracks[i] = *track
+	}
+	return tracks
+}
+
+// IoU is the intersection over union of two boxes
+func IoU(a frame.Box, b frame.Box) float64 {
+	width := min(a.X+a.Width, b.X+b.Width) - max(a.X, b.X)
+	height := min(a.Y+a.Height, b.Y+b.Height) - max(a.Y, b.Y)
+	if width <= 0 || height <= 0 {
+		return 0
+	}
+	intersection := float64(width * height)
+	union := float64(a.Width*a.Height+b.Width*b.Height) - intersection
+	return intersection / union
+}
@@ -0,0 +1,223 @@
+// Package cli is the strzcam command line, every component runs as one of
+// its subcommands with the same flags, configuration, logging and signals.
+package cli
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	golog "github.com/ipfs/go-log/v2"
+	"strzcam.com/broadcaster/encryption"
+	"strzcam.com/broadcaster/watcher"
+)
+
+// Command is a subcommand, either run with Setup or a group of Commands
+type Command struct {
+	Name    string
+	Summary string
+	// Setup registers flags of the command and returns what runs it once the
+	// flags are parsed and the configuration is loaded
+	Setup    func(flags *flag.FlagSet) func(ctx context.Context, config watcher.Config) error
+	Commands []*Command
+}
+
+// errUsage makes Run print the usage of the command
+var errUsage = errors.New("invalid usage")
+
+// errNoWebRTC is returned by WebRTC commands of builds without cgo
+var errNoWebRTC = errors.New("WebRTC is not available, build with CGO_ENABLED=1 and libvpx")
+
+func commands() []*Command {
+	return []*Command{
+		providerCommand,
+		viewerCommand,
+		signalingCommand,
+		offerorCommand,
+		nvrCommand,
+		convertCommand,
+		exportCommand,
+		inspectCommand,
+		configCommand,
+	}
+}
+
+// sharedFlags are accepted by every command
+type sharedFlags struct {
+	config          *string
+	camera          *string
+	logFile         *string
+	p2pLogLevel     *string
+	shutdownTimeout *time.Duration
+}
+
+func addSharedFlags(flags *flag.FlagSet) sharedFlags {
+	return sharedFlags{
+		config:      flags.String("config", "", "configuration file, CONFIG_FILE or ./config.yaml when not set"),
+		camera:      flags.String("camera", "", "camera section to use, overrides CAMERA_ID"),
+		logFile:     flags.String("log-file", "", "append logs to this file instead of stderr"),
+		p2pLogLevel: flags.String("p2p-log-level", "error", "log level of libp2p: debug, info, warn or error"),
+		// docker kills containers 10s after SIGTERM
+		shutdownTimeout: flags.Duration("shutdown-timeout", 8*time.Second, "time to stop after SIGINT or SIGTERM before exiting anyway, a second signal exits at once"),
+	}
+}
+
+// apply sets up logging and the environment read by watcher.LoadConfig, the
+// returned func closes the log file
+func (s sharedFlags) apply(name string) (func(), error) {
+	if *s.config != "" {
+		os.Setenv("CONFIG_FILE", *s.config)
+	}
+	if *s.camera != "" {
+		os.Setenv("CAMERA_ID", *s.camera)
+	}
+	level, err := golog.LevelFromString(*s.p2pLogLevel)
+	if err != nil {
+		return nil, fmt.Errorf("invalid -p2p-log-level: %w", err)
+	}
+	golog.SetAllLoggers(level)
+	log.SetPrefix(name + ": ")
+	log.SetFlags(log.LstdFlags | log.Lmsgprefix)
+	if *s.logFile == "" {
+		return func() {}, nil
+	}
+	file, err := os.OpenFile(*s.logFile, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, err
+	}
+	log.SetOutput(file)
+	return func() {
+		log.SetOutput(os.Stderr)
+		file.Close()
+	}, nil
+}
+
+// find returns the command named by args with its full name and remaining
+// arguments, a group without an action is returned itself
+func find(list []*Command, args []string, name string) (*Command, string, []string) {
+	if len(args) == 0 {
+		return nil, name, args
+	}
+	for _, command := range list {
+		if command.Name != args[0] {
+			continue
+		}
+		name = strings.TrimSpace(name + " " + command.Name)
+		if len(command.Commands) == 0 {
+			return command, name, args[1:]
+		}
+		if sub, subName, rest := find(command.Commands, args[1:], name); sub != nil {
+			return sub, subName, rest
+		}
+		return command, name, args[1:]
+	}
+	return nil, name, args
+}
+
+func printCommands(w io.Writer, name string, list []*Command) {
+	fmt.Fprintf(w, "Usage: %s <command> [flags]\n\nCommands:\n", name)
+	for _, command := range list {
+		fmt.Fprintf(w, "  %-10s %s\n", command.Name, command.Summary)
+	}
+	fmt.Fprintf(w, "\nRun '%s <command> -h' for flags of a command.\n", name)
+}
+
+func printConfigError(err error) {
+	var configErr *watcher.ConfigError
+	if !errors.As(err, &configErr) {
+		fmt.Fprintln(os.Stderr, err)
+		return
+	}
+	fmt.Fprintf(os.Stderr, "%s has %d problems:\n", configErr.Source, len(configErr.Problems))
+	for _, problem := range configErr.Problems {
+		fmt.Fprintf(os.Stderr, "  %s\n", problem)
+	}
+}
+
+// Run runs the command named by args and returns the exit code: 1 when the
+// command fails, 2 on invalid usage
+func Run(args []string) int {
+	if len(args) > 0 && (args[0] == "help" || args[0] == "-h" || args[0] == "--help") {
+		printCommands(os.Stdout, "strzcam", commands())
+		return 0
+	}
+	command, name, args := find(commands(), args, "")
+	if command == nil {
+		if len(args) > 0 {
+			fmt.Fprintf(os.Stderr, "Unknown command %q\n\n", args[0])
+		}
+		printCommands(os.Stderr, "strzcam", commands())
+		return 2
+	}
+	if command.Setup == nil {
+		if len(args) > 0 && args[0] != "-h" && args[0] != "--help" {
+			fmt.Fprintf(os.Stderr, "Unknown command %q\n\n", args[0])
+		}
+		printCommands(os.Stderr, "strzcam "+name, command.Commands)
+		return 2
+	}
+
+	flags := flag.NewFlagSet("strzcam "+name, flag.ContinueOnError)
+	shared := addSharedFlags(flags)
+	run := command.Setup(flags)
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: strzcam %s [flags]\n\n%s\n\nFlags:\n", name, command.Summary)
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return 0
+		}
+		return 2
+	}
+	closeLog, err := shared.apply(name)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	defer closeLog()
+	config, err := watcher.LoadConfig()
+	if err != nil {
+		printConfigError(err)
+		return 1
+	}
+	// only at startup, reloads keep the keyring the process started with
+	encryption.Configure(config.Encryption)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	done := make(chan error, 1)
+	go func() {
+		done <- run(ctx, config)
+	}()
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		// the default handler comes back, so a second signal exits at once
+		stop()
+		log.Printf("Shutting down, waiting up to %s", *shared.shutdownTimeout)
+		select {
+		case err = <-done:
+		case <-time.After(*shared.shutdownTimeout):
+			log.Printf("Shutdown did not finish in %s, exiting", *shared.shutdownTimeout)
+			return 1
+		}
+	}
+	if err != nil {
+		if errors.Is(err, errUsage) {
+			flags.Usage()
+			return 2
+		}
+		log.Printf("%v", err)
+		return 1
+	}
+	return 0
+}
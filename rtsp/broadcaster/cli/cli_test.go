@@ -0,0 +1,47 @@
+package cli
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRun(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("ZONES_FILE", filepath.Join(dir, "zones.json"))
+	t.Setenv("SCHEDULES_FILE", filepath.Join(dir, "schedules.json"))
+	t.Setenv("PRIVACY_MASKS_FILE", filepath.Join(dir, "privacy_masks.json"))
+	valid := filepath.Join(dir, "valid.yaml")
+	invalid := filepath.Join(dir, "invalid.yaml")
+	os.WriteFile(valid, []byte("camera: garden\ncameras:\n  garden: {}\n"), 0644)
+	os.WriteFile(invalid, []byte("storage:\n  chunkRot: /data\n"), 0644)
+
+	cases := []struct {
+		name string
+		args []string
+		code int
+	}{
+		{"help", []string{"help"}, 0},
+		{"no command", nil, 2},
+		{"unknown command", []string{"record"}, 2},
+		{"group without action", []string{"export"}, 2},
+		{"unknown action", []string{"export", "gif"}, 2},
+		{"command help", []string{"config", "check", "-h"}, 0},
+		{"unknown flag", []string{"config", "check", "-verbose"}, 2},
+		{"valid config", []string{"config", "check", "-config", valid}, 0},
+		{"invalid config", []string{"config", "check", "-config", invalid}, 1},
+		{"missing config", []string{"config", "check", "-config", filepath.Join(dir, "missing.yaml")}, 1},
+		{"camera without section", []string{"config", "check", "-config", valid, "-camera", "front"}, 1},
+		{"missing flags", []string{"export", "clip", "-config", valid}, 2},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			// shared flags set these for the process
+			t.Setenv("CONFIG_FILE", "")
+			t.Setenv("CAMERA_ID", "")
+			if code := Run(c.args); code != c.code {
+				t.Errorf("Expected exit code %d, got %d", c.code, code)
+			}
+		})
+	}
+}
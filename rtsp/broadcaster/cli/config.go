@@ -0,0 +1,28 @@
+package cli
+
+import (
+	"context"
+	"flag"
+	"fmt"
+
+	"strzcam.com/broadcaster/watcher"
+)
+
+var configCommand = &Command{
+	Name:    "config",
+	Summary: "check the configuration file",
+	Commands: []*Command{
+		{
+			Name:    "check",
+			Summary: "report every problem of the configuration, -camera checks the section of that camera",
+			Setup: func(flags *flag.FlagSet) func(ctx context.Context, config watcher.Config) error {
+				// an invalid configuration is reported before this runs
+				return func(ctx context.Context, config watcher.Config) error {
+					fmt.Printf("%s is valid\n", config.ConfigFile)
+					fmt.Printf("camera %s reads /dev/shm/%s, recordings in %s\n", config.Camera, config.SharedMemory, config.ChunkRoot)
+					return nil
+				}
+			},
+		},
+	},
+}
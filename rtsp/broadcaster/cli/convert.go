@@ -0,0 +1,160 @@
+package cli
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"strzcam.com/broadcaster/connection"
+	"strzcam.com/broadcaster/encryption"
+	"strzcam.com/broadcaster/video"
+	"strzcam.com/broadcaster/watcher"
+)
+
+var convertCommand = &Command{
+	Name:    "convert",
+	Summary: "convert saved frames, imported footage and encryption of recordings",
+	Commands: []*Command{
+		{
+			Name:    "chunks",
+			Summary: "convert saved frames to videos, then archive and build timelapses that are due",
+			Setup:   setupConvertChunks,
+		},
+		{
+			Name:    "import",
+			Summary: "re-encode footage from phones or other NVRs (MP4 or raw H.264) into recordings",
+			Setup:   setupImport,
+		},
+		{
+			Name:    "keys",
+			Summary: "re-encrypt recordings with the current encryption key, decrypt them when encryption is off",
+			Setup:   setupRotateKeys,
+		},
+	},
+}
+
+func setupConvertChunks(flags *flag.FlagSet) func(ctx context.Context, config watcher.Config) error {
+	fps := flags.Float64("fps", 30, "framerate of the saved frames")
+	return func(ctx context.Context, config watcher.Config) error {
+		identity, err := connection.LoadOrCreateIdentity(config.ProviderKeyPath)
+		if err != nil {
+			return fmt.Errorf("can not load provider key: %w", err)
+		}
+		converter, err := watcher.NewConverter(config)
+		if err != nil {
+			return err
+		}
+		defer converter.Close()
+		var width, height uint32
+		converter.Framerate, converter.Width, converter.Height = fps, &width, &height
+		converter.SigningKey = identity
+		// the newest chunk of today may still be written and is left
+		converter.RunUntilComplete(ctx)
+
+		archiver := watcher.NewArchiver(converter.Storage.VideoPaths(), config)
+		archiver.SigningKey = identity
+		archiver.RunUntilComplete(ctx)
+		watcher.NewTimelapser(converter.Storage, config).RunUntilComplete(ctx)
+		return nil
+	}
+}
+
+func setupImport(flags *flag.FlagSet) func(ctx context.Context, config watcher.Config) error {
+	file := flags.String("file", "", "MP4 or raw H.264 file to import")
+	start := flags.String("start", "", "time the footage starts, RFC 3339")
+	fps := flags.Float64("fps", 0, "framerate of raw H.264 files, 30 when not set")
+	return func(ctx context.Context, config watcher.Config) error {
+		startTime, err := time.Parse(time.RFC3339, *start)
+		if *file == "" || err != nil {
+			return errUsage
+		}
+		storage := watcher.NewStorage(config)
+		storage.CreateRoots()
+		request := video.ImportRequest{Camera: config.Camera, Start: startTime, Fps: *fps}
+		name, err := video.ImportVideo(ctx, *file, request, storage.VideoPaths(), storage.NewVideoPath())
+		if err != nil {
+			return fmt.Errorf("import failed: %w", err)
+		}
+		fmt.Printf("Imported %s as %s\n", *file, name)
+		return nil
+	}
+}
+
+// addKey puts a new key on top of the key file, older keys stay for decryption
+func addKey(path string) error {
+	secret, err := encryption.GenerateSecret()
+	if err != nil {
+		return err
+	}
+	existing, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return os.WriteFile(path, append([]byte(secret+"\n"), existing...), 0600)
+}
+
+func isStoredFile(name string) bool {
+	if strings.HasPrefix(name, "frame") && strings.HasSuffix(name, ".yuv") {
+		return true
+	}
+	_, _, ok := video.ParseVideoName(name)
+	return ok
+}
+
+func setupRotateKeys(flags *flag.FlagSet) func(ctx context.Context, config watcher.Config) error {
+	newKey := flags.Bool("new-key", false, "generate a new current key in the encryption key file before rotating")
+	return func(ctx context.Context, config watcher.Config) error {
+		if *newKey {
+			keyFile := config.Encryption.KeyFile
+			if keyFile == "" {
+				return fmt.Errorf("no encryption key file is configured")
+			}
+			if err := addKey(keyFile); err != nil {
+				return fmt.Errorf("can not add key: %w", err)
+			}
+		}
+		keyring, err := encryption.LoadKeyringFrom(config.Encryption)
+		if err != nil {
+			return fmt.Errorf("can not load encryption keys: %w", err)
+		}
+		if keyring.Enabled() {
+			log.Printf("Rotating recordings to key %s", keyring.Current().ID)
+		} else {
+			log.Printf("Encryption is disabled, decrypting recordings")
+		}
+
+		storage := watcher.NewStorage(config)
+		roots := append([]string{storage.ChunkRoot}, storage.VideoPaths()...)
+		rewritten, failed := 0, 0
+		seen := map[string]bool{}
+		for _, root := range roots {
+			filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+				if err != nil || info.IsDir() || seen[path] || !isStoredFile(info.Name()) {
+					return nil
+				}
+				seen[path] = true
+				changed, err := encryption.Rewrite(path, keyring)
+				if err != nil {
+					failed++
+					fmt.Printf("FAILED %s: %v\n", path, err)
+					return nil
+				}
+				if changed {
+					rewritten++
+				}
+				return nil
+			})
+		}
+		fmt.Printf("Rewrote %d files, %d failed\n", rewritten, failed)
+		if failed > 0 {
+			return fmt.Errorf("%d files were not rewritten, keep the old keys", failed)
+		}
+		fmt.Println("Old keys can be removed once no file failed")
+		return nil
+	}
+}
@@ -0,0 +1,92 @@
+package cli
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"strzcam.com/broadcaster/video"
+	"strzcam.com/broadcaster/watcher"
+)
+
+var exportCommand = &Command{
+	Name:    "export",
+	Summary: "export a time range of local recordings as a clip or an evidence bundle",
+	Commands: []*Command{
+		{
+			Name:    "clip",
+			Summary: "export a single MP4 built from all recordings overlapping the range",
+			Setup:   setupExportClip,
+		},
+		{
+			Name:    "evidence",
+			Summary: "export a ZIP with the clip, source recordings, detections and checksums",
+			Setup:   setupExportEvidence,
+		},
+	},
+}
+
+func setupExportClip(flags *flag.FlagSet) func(ctx context.Context, config watcher.Config) error {
+	return setupExport(flags, "clip.mp4", exportClip)
+}
+
+func setupExportEvidence(flags *flag.FlagSet) func(ctx context.Context, config watcher.Config) error {
+	return setupExport(flags, "evidence.zip", exportEvidence)
+}
+
+type exportFunc func(ctx context.Context, config watcher.Config, request video.ClipRequest, output *os.File) error
+
+func setupExport(flags *flag.FlagSet, defaultOutput string, export exportFunc) func(ctx context.Context, config watcher.Config) error {
+	start := flags.String("start", "", "start of the range, RFC 3339")
+	end := flags.String("end", "", "end of the range, RFC 3339")
+	outputPath := flags.String("o", defaultOutput, "file to write")
+	return func(ctx context.Context, config watcher.Config) error {
+		startTime, startErr := time.Parse(time.RFC3339, *start)
+		endTime, endErr := time.Parse(time.RFC3339, *end)
+		if startErr != nil || endErr != nil {
+			return errUsage
+		}
+		request := video.ClipRequest{Camera: config.Camera, Start: startTime, End: endTime}
+		if err := request.Validate(); err != nil {
+			return err
+		}
+		output, err := os.Create(*outputPath)
+		if err != nil {
+			return err
+		}
+		err = export(ctx, config, request, output)
+		if closeErr := output.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			os.Remove(*outputPath)
+			return fmt.Errorf("export failed: %w", err)
+		}
+		fmt.Printf("\rExported %s\n", *outputPath)
+		return nil
+	}
+}
+
+func printProgress(progress float64) {
+	fmt.Printf("\r%3.0f%%", progress*100)
+}
+
+func exportClip(ctx context.Context, config watcher.Config, request video.ClipRequest, output *os.File) error {
+	sources, err := video.FindClipSources(watcher.NewStorage(config).VideoPaths(), request)
+	if err != nil {
+		return err
+	}
+	return video.ExportClip(ctx, sources, request, output, printProgress)
+}
+
+func exportEvidence(ctx context.Context, config watcher.Config, request video.ClipRequest, output *os.File) error {
+	evidence, err := video.PrepareEvidence(ctx, watcher.NewStorage(config).VideoPaths(), config.EventsDir, request, printProgress)
+	if err != nil {
+		return err
+	}
+	defer evidence.Close()
+	_, err = evidence.WriteTo(output)
+	return err
+}
@@ -0,0 +1,126 @@
+package cli
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/crypto"
+	"strzcam.com/broadcaster/connection"
+	"strzcam.com/broadcaster/video"
+	"strzcam.com/broadcaster/watcher"
+)
+
+var inspectCommand = &Command{
+	Name:    "inspect",
+	Summary: "check signatures and integrity of recordings",
+	Commands: []*Command{
+		{
+			Name:    "verify",
+			Summary: "verify signatures and the hash chain of local or exported recordings",
+			Setup:   setupVerify,
+		},
+		{
+			Name:    "scrub",
+			Summary: "verify checksums and bitstreams of recordings",
+			Setup:   setupScrub,
+		},
+	},
+}
+
+func setupVerify(flags *flag.FlagSet) func(ctx context.Context, config watcher.Config) error {
+	chainPath := flags.String("chain", "", "verify a chain exported from /signature-chain instead of local storage")
+	videoDir := flags.String("videos", "", "directory with exported recordings to check against -chain")
+	trustedKey := flags.String("key", "", "require all recordings to be signed by this public key (base64)")
+	printKey := flags.Bool("print-key", false, "print the public key of this provider and exit")
+	return func(ctx context.Context, config watcher.Config) error {
+		if *printKey {
+			identity, err := connection.LoadOrCreateIdentity(config.ProviderKeyPath)
+			if err != nil {
+				return fmt.Errorf("can not load provider key: %w", err)
+			}
+			publicKey, err := crypto.MarshalPublicKey(identity.GetPublic())
+			if err != nil {
+				return err
+			}
+			fmt.Println(base64.StdEncoding.EncodeToString(publicKey))
+			return nil
+		}
+
+		var chain []video.ChainEntry
+		findFile := func(name string) (string, error) { return "", nil }
+		if *chainPath != "" {
+			data, err := os.ReadFile(*chainPath)
+			if err != nil {
+				return err
+			}
+			if err := json.Unmarshal(data, &chain); err != nil {
+				return fmt.Errorf("invalid chain file: %w", err)
+			}
+			if *videoDir != "" {
+				findFile = func(name string) (string, error) {
+					return filepath.Join(*videoDir, filepath.Base(name)), nil
+				}
+			}
+		} else {
+			paths := watcher.NewStorage(config).VideoPaths()
+			var err error
+			chain, err = video.GetSignatureChain(paths, time.Time{}, time.Now().AddDate(1, 0, 0))
+			if err != nil {
+				return err
+			}
+			findFile = func(name string) (string, error) { return video.FindVideo(paths, name) }
+		}
+
+		errs := video.VerifyChain(chain, *trustedKey)
+		for _, entry := range chain {
+			path, err := findFile(entry.Name)
+			if err == nil && path == "" {
+				continue
+			}
+			if err == nil {
+				err = video.VerifyFile(entry, path)
+			}
+			if err != nil {
+				errs = append(errs, err)
+			}
+		}
+		for _, err := range errs {
+			fmt.Printf("FAILED %v\n", err)
+		}
+		fmt.Printf("Verified %d signed recordings, %d problems\n", len(chain), len(errs))
+		if len(errs) > 0 {
+			return fmt.Errorf("%d recordings failed verification", len(errs))
+		}
+		return nil
+	}
+}
+
+func setupScrub(flags *flag.FlagSet) func(ctx context.Context, config watcher.Config) error {
+	quarantine := flags.Bool("quarantine", false, "move damaged recordings to the quarantine directory of their root")
+	return func(ctx context.Context, config watcher.Config) error {
+		results := video.Scrub(watcher.NewStorage(config).VideoPaths(), *quarantine)
+		damaged := 0
+		for _, result := range results {
+			if result.Err == nil {
+				continue
+			}
+			damaged++
+			if result.Quarantined {
+				fmt.Printf("DAMAGED %s (quarantined): %v\n", result.Path, result.Err)
+			} else {
+				fmt.Printf("DAMAGED %s: %v\n", result.Path, result.Err)
+			}
+		}
+		fmt.Printf("Scrubbed %d recordings, %d damaged\n", len(results), damaged)
+		if damaged > 0 {
+			return fmt.Errorf("%d recordings are damaged", damaged)
+		}
+		return nil
+	}
+}
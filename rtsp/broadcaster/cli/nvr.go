@@ -0,0 +1,170 @@
+package cli
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"sync"
+
+	"github.com/libp2p/go-libp2p/core/crypto"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"strzcam.com/broadcaster/connection"
+	frameUtils "strzcam.com/broadcaster/frame"
+	"strzcam.com/broadcaster/watcher"
+)
+
+var nvrCommand = &Command{
+	Name:    "nvr",
+	Summary: "record the camera and serve it over HTTP and WebRTC on this machine",
+	Setup: func(flags *flag.FlagSet) func(ctx context.Context, config watcher.Config) error {
+		options := nvrOptions{
+			record: flags.Bool("record", true, "record and convert frames, without it only live frames are served on the server port"),
+			webRTC: flags.Bool("webrtc", webRTCAvailable, "run the signaling server and offer the camera and recordings over WebRTC"),
+			mdns:   flags.Bool("mdns", false, "let p2p viewers on the local network find the recordings through mDNS"),
+			dht:    flags.Bool("dht", false, "let p2p viewers find the recordings through the public DHT"),
+		}
+		return func(ctx context.Context, config watcher.Config) error {
+			return runNVR(ctx, config, options)
+		}
+	},
+}
+
+type nvrOptions struct {
+	record *bool
+	webRTC *bool
+	mdns   *bool
+	dht    *bool
+}
+
+// runNVR records, converts and serves the camera in one process, the server
+// reads recordings from disk
+func runNVR(ctx context.Context, config watcher.Config, options nvrOptions) error {
+	if *options.webRTC && !webRTCAvailable {
+		return errNoWebRTC
+	}
+	identity, err := connection.LoadOrCreateIdentity(config.ProviderKeyPath)
+	if err != nil {
+		return fmt.Errorf("can not load provider key: %w", err)
+	}
+	var memory *watcher.SharedMemoryReceiver
+	var creator *watcher.VideoCreator
+	port := config.Ports.Server
+	if *options.record {
+		if memory, err = newRecordingReceiver(config); err != nil {
+			return err
+		}
+		converter, _ := watcher.NewConverter(config)
+		converter.SigningKey = identity
+		creator, _ = watcher.NewVideoCreator(memory, converter)
+		defer creator.Close()
+		port = config.Ports.VideoCreator
+	} else {
+		memory, _ = watcher.NewSharedMemoryReceiver(config)
+		defer memory.Close()
+	}
+	// background parts stop with ctx, they finish before anything is closed
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		if creator != nil {
+			creator.Run(ctx, &memory.ActualFps, &memory.FrameWidth, &memory.FrameHeight)
+			return
+		}
+		// without recording frames are only served live
+		memory.WatchSharedMemory(ctx, false)
+	}()
+
+	var reload connection.ReloadFunc
+	if creator != nil {
+		reloader, stopReloading := reloadOnHangup(config, creator)
+		defer stopReloading()
+		reload = reloader.Reload
+		if err := superviseCamera(ctx, config, &wg); err != nil {
+			return err
+		}
+	}
+
+	provider, closeHost, err := startNVRProvider(ctx, config, options, identity, memory, reload)
+	if err != nil {
+		return err
+	}
+	defer closeHost()
+
+	server, _ := watcher.NewServer(port, config)
+	server.PrepareEndpoints()
+	server.Library = connection.NewLocal(provider)
+	// only p2p viewers read frames buffered by the provider
+	p2p := *options.mdns || *options.dht
+	go func() {
+		for frame := range memory.Frames {
+			server.BroadcastFrame([]frameUtils.Frame{frame})
+			if p2p {
+				provider.BroadcastFrame(frame)
+			}
+		}
+	}()
+
+	if *options.webRTC {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := runWebRTC(ctx, config); err != nil {
+				log.Printf("WebRTC stopped: %v", err)
+				cancel()
+			}
+		}()
+	}
+	if err := server.Start(ctx); err != nil {
+		return fmt.Errorf("server stopped: %w", err)
+	}
+	return nil
+}
+
+// startNVRProvider returns the provider the server reads from, with mDNS or
+// the DHT it also listens for p2p viewers
+func startNVRProvider(ctx context.Context, config watcher.Config, options nvrOptions, identity crypto.PrivKey, memory *watcher.SharedMemoryReceiver, reload connection.ReloadFunc) (*connection.Provider, func(), error) {
+	if !*options.mdns && !*options.dht {
+		return newProvider(nil, config, memory, reload), func() {}, nil
+	}
+	if *options.dht {
+		host, kademliaDHT, err := connection.MakeEnhancedHostWithIdentity(ctx, config.Ports.Provider, false, identity)
+		if err != nil {
+			return nil, nil, fmt.Errorf("can not start p2p host: %w", err)
+		}
+		provider := newProvider(host, config, memory, reload)
+		provider.StartListening(ctx)
+		go announceDHT(ctx, kademliaDHT)
+		if *options.mdns {
+			go logMDNSPeers(ctx, connection.InitMDNS(host, connection.RendezVous))
+		}
+		return provider, func() {
+			kademliaDHT.Close()
+			host.Close()
+		}, nil
+	}
+	host, err := connection.MakeLANHostWithIdentity(config.Ports.Provider, identity)
+	if err != nil {
+		return nil, nil, fmt.Errorf("can not start p2p host: %w", err)
+	}
+	provider := newProvider(host, config, memory, reload)
+	provider.StartListening(ctx)
+	go logMDNSPeers(ctx, connection.InitMDNS(host, connection.RendezVous))
+	return provider, func() { host.Close() }, nil
+}
+
+// logMDNSPeers drains peers found on the local network, viewers connect to us
+func logMDNSPeers(ctx context.Context, peers chan peer.AddrInfo) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case found := <-peers:
+			log.Println("Found peer via MDNS:", found)
+		}
+	}
+}
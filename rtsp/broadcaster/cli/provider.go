@@ -0,0 +1,201 @@
+package cli
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	dht "github.com/libp2p/go-libp2p-kad-dht"
+	"github.com/libp2p/go-libp2p/core/host"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"strzcam.com/broadcaster/analytics"
+	"strzcam.com/broadcaster/connection"
+	"strzcam.com/broadcaster/detector"
+	"strzcam.com/broadcaster/events"
+	"strzcam.com/broadcaster/health"
+	"strzcam.com/broadcaster/watchdog"
+	"strzcam.com/broadcaster/watcher"
+)
+
+var providerCommand = &Command{
+	Name:    "provider",
+	Summary: "record the camera and send frames and recordings to viewers via p2p",
+	Setup: func(flags *flag.FlagSet) func(ctx context.Context, config watcher.Config) error {
+		return runProvider
+	},
+}
+
+// newRecordingReceiver reads frames with everything that is recorded along
+// them: events, heatmaps, image health and detector plugins
+func newRecordingReceiver(config watcher.Config) (*watcher.SharedMemoryReceiver, error) {
+	memory, _ := watcher.NewSharedMemoryReceiver(config)
+	eventLog, err := events.NewLog(config.EventsDir, config.Camera)
+	if err != nil {
+		return nil, fmt.Errorf("can not open events log: %w", err)
+	}
+	memory.Events = eventLog
+	if memory.Heatmap, err = analytics.NewHeatmapRecorder(config.HeatmapDir, config.Camera); err != nil {
+		log.Printf("Warning: %v, heatmaps are not recorded", err)
+	}
+	memory.HealthStateFile = config.HealthStateFile
+	if config.HealthChecks {
+		memory.Health = health.NewMonitor(config.Camera, config.Health)
+	}
+	if len(config.Detectors.Addresses) > 0 {
+		memory.Detectors = detector.NewScheduler(config.Detectors)
+	}
+	return memory, nil
+}
+
+// reloadOnHangup reloads the configuration on SIGHUP until the returned func is called
+func reloadOnHangup(config watcher.Config, creator *watcher.VideoCreator) (*watcher.Reloader, func()) {
+	memory := creator.SharedMemoryReceiver
+	reloader := watcher.NewReloader(config)
+	reloader.Receiver = memory
+	reloader.Creator = creator
+	reloader.Schedule = memory.Schedule
+	reloader.Watchdog = memory.Watchdog
+	hangups := make(chan os.Signal, 1)
+	signal.Notify(hangups, syscall.SIGHUP)
+	go func() {
+		for range hangups {
+			reloader.Reload()
+		}
+	}()
+	return reloader, func() { signal.Stop(hangups) }
+}
+
+// superviseCamera runs CameraCommand, when set, until ctx is done
+func superviseCamera(ctx context.Context, config watcher.Config, wg *sync.WaitGroup) error {
+	if config.CameraCommand == "" {
+		return nil
+	}
+	supervisor, err := watchdog.NewSupervisor(config.CameraCommand, config.CameraLogFile)
+	if err != nil {
+		return fmt.Errorf("can not supervise camera: %w", err)
+	}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		supervisor.Run(ctx)
+	}()
+	return nil
+}
+
+// newProvider answers with the recordings and camera state of memory, reload
+// may be nil
+func newProvider(host host.Host, config watcher.Config, memory *watcher.SharedMemoryReceiver, reload connection.ReloadFunc) *connection.Provider {
+	provider := connection.NewProvider(host, watcher.NewStorage(config).VideoPaths())
+	provider.SetEventsDir(config.EventsDir)
+	provider.SetHeatmapDir(config.HeatmapDir)
+	provider.SetSchedule(memory.Schedule)
+	provider.SetHealthStateFile(config.HealthStateFile)
+	provider.SetWatchdog(memory.Watchdog)
+	if reload != nil {
+		provider.SetReload(reload)
+	}
+	// the config was validated, ids that do not parse are not there
+	if err := provider.SetAuthorizedPeers(config.AuthPeers); err != nil {
+		log.Printf("No peer may change the provider: %v", err)
+	}
+	return provider
+}
+
+// announceDHT makes the provider findable through the DHT until ctx is done
+func announceDHT(ctx context.Context, kademliaDHT *dht.IpfsDHT) {
+	rendezVous, _ := connection.GetRendezVousCid(connection.RendezVous)
+	announced := false
+	for i := range 10 {
+		if connection.AnnounceDHT(ctx, kademliaDHT, rendezVous) {
+			announced = true
+			break
+		}
+		if i < 9 {
+			log.Printf("Failed to make initial DHT announcement attempt %d", i)
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(time.Second * time.Duration((i+1)*i)):
+			}
+		}
+	}
+
+	if !announced {
+		log.Printf("Failed to make initial DHT announcement after 10 attempts")
+	} else {
+		log.Printf("DHT announced!")
+	}
+	connection.AnnounceDHTPeriodically(ctx, kademliaDHT, rendezVous)
+}
+
+func runProvider(ctx context.Context, config watcher.Config) error {
+	identity, err := connection.LoadOrCreateIdentity(config.ProviderKeyPath)
+	if err != nil {
+		return fmt.Errorf("can not load provider key: %w", err)
+	}
+	memory, err := newRecordingReceiver(config)
+	if err != nil {
+		return err
+	}
+	converter, _ := watcher.NewConverter(config)
+	converter.SigningKey = identity
+	creator, _ := watcher.NewVideoCreator(memory, converter)
+	defer creator.Close()
+	// background parts stop with ctx, they finish before anything is closed
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		creator.Run(ctx, &memory.ActualFps, &memory.FrameWidth, &memory.FrameHeight)
+	}()
+
+	reloader, stopReloading := reloadOnHangup(config, creator)
+	defer stopReloading()
+	if err := superviseCamera(ctx, config, &wg); err != nil {
+		return err
+	}
+
+	host, kademliaDHT, _ := connection.MakeEnhancedHostWithIdentity(ctx, config.Ports.Provider, false, identity)
+	defer host.Close()
+	defer kademliaDHT.Close()
+
+	Provider := newProvider(host, config, memory, reloader.Reload)
+	Provider.StartListening(ctx)
+	if err := Provider.HandleConnectedPeers(ctx); err != nil {
+		return fmt.Errorf("can not watch peers: %w", err)
+	}
+	announceDHT(ctx, kademliaDHT)
+
+	go func() {
+		for frame := range creator.SharedMemoryReceiver.Frames {
+			Provider.BroadcastFrame(frame)
+		}
+	}()
+	mdnsPeerChan := connection.InitMDNS(host, connection.RendezVous)
+	dhtPeerChan := connection.InitDHTDiscovery(ctx, host, kademliaDHT, connection.RendezVous)
+	for {
+		var peer peer.AddrInfo
+		select {
+		case peer = <-mdnsPeerChan:
+			log.Println("Found peer via MDNS:", peer)
+		case peer = <-dhtPeerChan:
+			log.Println("Found peer via DHT:", peer)
+		case <-ctx.Done():
+			return nil
+		}
+		if peer.ID == host.ID() {
+			fmt.Println("Found peer:", peer, " id is greater than us, wait for it to connect to us")
+			continue
+		}
+		<-ctx.Done()
+	}
+}
@@ -1,29 +1,55 @@
-package main
+package cli
 
 import (
 	"context"
+	"flag"
 	"fmt"
 	"log"
+	"sync"
 	"time"
 
-	golog "github.com/ipfs/go-log/v2"
 	"github.com/libp2p/go-libp2p/core/peer"
 	"strzcam.com/broadcaster/connection"
 	"strzcam.com/broadcaster/watcher"
 )
 
-func main() {
-	server, _ := watcher.NewServer(7080)
-	server.PrepareEndpoints()
-	go server.Start()
+var viewerCommand = &Command{
+	Name:    "viewer",
+	Summary: "receive frames and recordings via p2p and serve them over HTTP",
+	Setup: func(flags *flag.FlagSet) func(ctx context.Context, config watcher.Config) error {
+		return runViewer
+	},
+}
 
-	ctx, cancel := context.WithCancel(context.Background())
+func runViewer(ctx context.Context, config watcher.Config) error {
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	ctx, cancel := context.WithCancel(ctx)
 	defer cancel()
-	golog.SetAllLoggers(golog.LevelError)
 
-	host, kademliaDHT, _ := connection.MakeEnhancedHost(ctx, 10001, false, 0)
+	server, _ := watcher.NewServer(config.Ports.ViewerHTTP, config)
+	server.PrepareEndpoints()
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		if err := server.Start(ctx); err != nil {
+			log.Printf("Server stopped: %v", err)
+			cancel()
+		}
+	}()
+
+	// providers allow changes from the peer id of this key in auth.peers
+	identity, err := connection.LoadOrCreateIdentity(config.ViewerKeyPath)
+	if err != nil {
+		return fmt.Errorf("can not load viewer key: %w", err)
+	}
+	host, kademliaDHT, err := connection.MakeEnhancedHostWithIdentity(ctx, config.Ports.Viewer, false, identity)
+	if err != nil {
+		return fmt.Errorf("can not start p2p host: %w", err)
+	}
 	defer host.Close()
 	defer kademliaDHT.Close()
+	log.Printf("Viewer peer id %s", host.ID())
 
 	mdnsPeerChan := connection.InitMDNS(host, connection.RendezVous)
 	dhtPeerChan := connection.InitDHTDiscovery(ctx, host, kademliaDHT, connection.RendezVous)
@@ -35,7 +61,7 @@ func main() {
 		case peer = <-dhtPeerChan:
 			fmt.Println("Found peer via DHT:", peer)
 		case <-ctx.Done():
-			return
+			return nil
 		}
 		if peer.ID == host.ID() {
 			fmt.Println("Found peer:", peer, " id is greater than us, wait for it to connect to us")
@@ -53,12 +79,11 @@ func main() {
 			select {
 			case <-ctx.Done():
 				log.Println("Exiting.")
-				return
+				return nil
 			case <-ticker.C:
 				frames, err := viewer.GetFrames()
 				if err != nil {
-					log.Fatal(err)
-					return
+					return err
 				}
 				frameCount := len(frames)
 				if frameCount > 0 {
@@ -0,0 +1,58 @@
+//go:build cgo
+
+package cli
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"time"
+
+	"strzcam.com/broadcaster/watcher"
+	"strzcam.com/broadcaster/web_rtc"
+)
+
+const webRTCAvailable = true
+
+var signalingCommand = &Command{
+	Name:    "signaling",
+	Summary: "run the WebRTC signaling server",
+	Setup: func(flags *flag.FlagSet) func(ctx context.Context, config watcher.Config) error {
+		return func(ctx context.Context, config watcher.Config) error {
+			return web_rtc.RunServer(ctx, config.Ports.Signaling)
+		}
+	},
+}
+
+var offerorCommand = &Command{
+	Name:    "offeror",
+	Summary: "stream the camera and recordings to WebRTC clients of the signaling server",
+	Setup: func(flags *flag.FlagSet) func(ctx context.Context, config watcher.Config) error {
+		return func(ctx context.Context, config watcher.Config) error {
+			return web_rtc.RunLive(ctx, config)
+		}
+	},
+}
+
+// runWebRTC serves signaling and offers the camera and recordings through it
+// until ctx is done, the offeror reconnects when the connection drops
+func runWebRTC(ctx context.Context, config watcher.Config) error {
+	signaling := make(chan error, 1)
+	go func() {
+		signaling <- web_rtc.RunServer(ctx, config.Ports.Signaling)
+	}()
+	config.SignalingURL = fmt.Sprintf("localhost:%d", config.Ports.Signaling)
+	for ctx.Err() == nil {
+		if err := web_rtc.RunLive(ctx, config); err != nil {
+			log.Printf("Offeror stopped: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+		case err := <-signaling:
+			return fmt.Errorf("signaling server stopped: %w", err)
+		case <-time.After(time.Second):
+		}
+	}
+	return <-signaling
+}
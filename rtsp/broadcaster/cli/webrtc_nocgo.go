@@ -0,0 +1,33 @@
+//go:build !cgo
+
+package cli
+
+import (
+	"context"
+	"flag"
+
+	"strzcam.com/broadcaster/watcher"
+)
+
+// the VP8 encoder of WebRTC needs libvpx, builds without cgo leave it out
+const webRTCAvailable = false
+
+var signalingCommand = &Command{
+	Name:    "signaling",
+	Summary: "run the WebRTC signaling server (needs a cgo build)",
+	Setup: func(flags *flag.FlagSet) func(ctx context.Context, config watcher.Config) error {
+		return func(ctx context.Context, config watcher.Config) error { return errNoWebRTC }
+	},
+}
+
+var offerorCommand = &Command{
+	Name:    "offeror",
+	Summary: "stream the camera and recordings to WebRTC clients (needs a cgo build)",
+	Setup: func(flags *flag.FlagSet) func(ctx context.Context, config watcher.Config) error {
+		return func(ctx context.Context, config watcher.Config) error { return errNoWebRTC }
+	},
+}
+
+func runWebRTC(ctx context.Context, config watcher.Config) error {
+	return errNoWebRTC
+}
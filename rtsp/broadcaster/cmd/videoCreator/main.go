@@ -1,29 +0,0 @@
-package main
-
-import (
-	"fmt"
-
-	frameUtils "strzcam.com/broadcaster/frame"
-	"strzcam.com/broadcaster/watcher"
-)
-
-func main() {
-	memory, _ := watcher.NewSharedMemoryReceiver("video_frame")
-	converter, _ := watcher.NewConverter(fmt.Sprintf("%s_video_frame", watcher.SavePath))
-	creator, _ := watcher.NewVideoCreator(memory, converter)
-	defer creator.Close()
-	go creator.StartWatchingFrames()
-	go creator.SaveFramesForLater()
-	go creator.StartConversionWorkflow(&memory.ActualFps)
-
-	server, _ := watcher.NewServer(7072)
-	server.PrepareEndpoints()
-	go func() {
-		frames := []frameUtils.Frame{}
-		for frame := range memory.Frames {
-			frames = append(frames, frame)
-		}
-		server.BroadcastFrame(frames)
-	}()
-	server.Start()
-}
@@ -1,7 +0,0 @@
-package main
-
-import "strzcam.com/broadcaster/web_rtc"
-
-func main() {
-	web_rtc.RunServer(7070)
-}
@@ -0,0 +1,125 @@
+package connection
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image/png"
+	"log"
+	"strings"
+
+	"github.com/libp2p/go-libp2p/core/network"
+	"strzcam.com/broadcaster/analytics"
+)
+
+// Activity streams send one JSON line with the request, the provider answers
+// with the JSON response or "error <message>".
+const ActivityProtocol = "/get-activity/1.0.0"
+
+type activityRequest struct {
+	Query   analytics.Query `json:"query"`
+	Overlay bool            `json:"overlay,omitempty"` // heatmap PNG instead of the report
+}
+
+type activityResponse struct {
+	Report  analytics.ActivityReport `json:"report"`
+	Overlay []byte                   `json:"overlay,omitempty"`
+}
+
+func (p *Provider) handleActivity(stream network.Stream) {
+	defer stream.Close()
+	line, err := bufio.NewReader(stream).ReadString('\n')
+	if err != nil {
+		log.Printf("Error reading activity request: %v", err)
+		return
+	}
+	var request activityRequest
+	if err := json.Unmarshal([]byte(line), &request); err != nil {
+		fmt.Fprintf(stream, "error invalid request: %v\n", err)
+		return
+	}
+	var response activityResponse
+	if request.Overlay {
+		response.Overlay, err = p.heatmapOverlay(request.Query)
+	} else {
+		response.Report, err = p.activity(request.Query)
+	}
+	if err != nil {
+		writeExportError(stream, err)
+		return
+	}
+	if err := json.NewEncoder(stream).Encode(response); err != nil {
+		log.Printf("Error sending activity: %v", err)
+	}
+}
+
+func (p *Provider) activity(query analytics.Query) (analytics.ActivityReport, error) {
+	if p.eventsDir == "" {
+		return analytics.ActivityReport{}, errors.New("events are not recorded")
+	}
+	return analytics.LoadActivity(p.eventsDir, p.heatmapDir, query)
+}
+
+func (p *Provider) heatmapOverlay(query analytics.Query) ([]byte, error) {
+	if p.heatmapDir == "" {
+		return nil, errors.New("heatmaps are not recorded")
+	}
+	if err := query.Validate(); err != nil {
+		return nil, err
+	}
+	heatmap, err := analytics.LoadHeatmap(p.heatmapDir, query)
+	if err != nil {
+		return nil, err
+	}
+	reference, err := analytics.LoadReference(p.heatmapDir)
+	if err != nil {
+		log.Printf("No heatmap reference frame: %v", err)
+	}
+	var buffer bytes.Buffer
+	if err := png.Encode(&buffer, heatmap.Overlay(reference)); err != nil {
+		return nil, err
+	}
+	return buffer.Bytes(), nil
+}
+
+func (v *Viewer) getActivity(ctx context.Context, request activityRequest) (activityResponse, error) {
+	stream, err := (*v.Host).NewStream(ctx, (*v.Info).ID, ActivityProtocol)
+	if err != nil {
+		return activityResponse{}, err
+	}
+	defer stream.Close()
+	data, err := json.Marshal(request)
+	if err != nil {
+		return activityResponse{}, err
+	}
+	if _, err := stream.Write(append(data, '\n')); err != nil {
+		return activityResponse{}, err
+	}
+	line, err := bufio.NewReader(stream).ReadString('\n')
+	if err != nil {
+		return activityResponse{}, fmt.Errorf("activity interrupted: %w", err)
+	}
+	if message, ok := strings.CutPrefix(line, "error "); ok {
+		return activityResponse{}, errors.New(strings.TrimSpace(message))
+	}
+	var response activityResponse
+	if err := json.Unmarshal([]byte(line), &response); err != nil {
+		return activityResponse{}, fmt.Errorf("invalid activity response: %w", err)
+	}
+	return response, nil
+}
+
+// GetActivity returns detection counts by hour and day and the heatmap grid
+func (v *Viewer) GetActivity(ctx context.Context, query analytics.Query) (analytics.ActivityReport, error) {
+	response, err := v.getActivity(ctx, activityRequest{Query: query})
+	return response.Report, err
+}
+
+// GetHeatmapOverlay returns the heatmap drawn over a reference frame as PNG
+func (v *Viewer) GetHeatmapOverlay(ctx context.Context, query analytics.Query) ([]byte, error) {
+	response, err := v.getActivity(ctx, activityRequest{Query: query, Overlay: true})
+	return response.Overlay, err
+}
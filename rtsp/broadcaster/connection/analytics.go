@@ -0,0 +1,75 @@
+package connection
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/libp2p/go-libp2p/core/network"
+	"strzcam.com/broadcaster/analytics"
+)
+
+// Analytics streams send one JSON line with the query, the provider answers
+// with the JSON report or "error <message>".
+const AnalyticsProtocol = "/get-analytics/1.0.0"
+
+func (p *Provider) handleAnalytics(stream network.Stream) {
+	defer stream.Close()
+	line, err := bufio.NewReader(stream).ReadString('\n')
+	if err != nil {
+		log.Printf("Error reading analytics query: %v", err)
+		return
+	}
+	var query analytics.Query
+	if err := json.Unmarshal([]byte(line), &query); err != nil {
+		fmt.Fprintf(stream, "error invalid query: %v\n", err)
+		return
+	}
+	report, err := p.analytics(query)
+	if err != nil {
+		writeExportError(stream, err)
+		return
+	}
+	if err := json.NewEncoder(stream).Encode(report); err != nil {
+		log.Printf("Error sending analytics: %v", err)
+	}
+}
+
+func (p *Provider) analytics(query analytics.Query) (analytics.Report, error) {
+	if p.eventsDir == "" {
+		return analytics.Report{}, errors.New("events are not recorded")
+	}
+	return analytics.Load(p.eventsDir, query)
+}
+
+// GetAnalytics returns crossings and hourly counts of the provider
+func (v *Viewer) GetAnalytics(ctx context.Context, query analytics.Query) (analytics.Report, error) {
+	stream, err := (*v.Host).NewStream(ctx, (*v.Info).ID, AnalyticsProtocol)
+	if err != nil {
+		return analytics.Report{}, err
+	}
+	defer stream.Close()
+	data, err := json.Marshal(query)
+	if err != nil {
+		return analytics.Report{}, err
+	}
+	if _, err := stream.Write(append(data, '\n')); err != nil {
+		return analytics.Report{}, err
+	}
+	line, err := bufio.NewReader(stream).ReadString('\n')
+	if err != nil {
+		return analytics.Report{}, fmt.Errorf("analytics interrupted: %w", err)
+	}
+	if message, ok := strings.CutPrefix(line, "error "); ok {
+		return analytics.Report{}, errors.New(strings.TrimSpace(message))
+	}
+	var report analytics.Report
+	if err := json.Unmarshal([]byte(line), &report); err != nil {
+		return analytics.Report{}, fmt.Errorf("invalid analytics response: %w", err)
+	}
+	return report, nil
+}
@@ -0,0 +1,45 @@
+package connection
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+// errUnauthorized answers changes from peers missing in the authorized peers
+var errUnauthorized = errors.New("peer is not allowed to change the provider")
+
+// SetAuthorizedPeers allows the viewers to change the mode, configuration
+// and recordings of the provider, without any every change is refused
+func (p *Provider) SetAuthorizedPeers(ids []string) error {
+	peers := make([]peer.ID, 0, len(ids))
+	for _, id := range ids {
+		decoded, err := peer.Decode(id)
+		if err != nil {
+			return fmt.Errorf("invalid peer id %q: %w", id, err)
+		}
+		peers = append(peers, decoded)
+	}
+	p.authorizedPeers = peers
+	return nil
+}
+
+func (p *Provider) peerAuthorized(id peer.ID) bool {
+	return slices.Contains(p.authorizedPeers, id)
+}
+
+// authorized answers the stream with an error unless its peer may change the
+// provider
+func (p *Provider) authorized(stream network.Stream) bool {
+	remote := stream.Conn().RemotePeer()
+	if p.peerAuthorized(remote) {
+		return true
+	}
+	log.Printf("Refused %s from %s, it is not in auth.peers", stream.Protocol(), remote)
+	writeExportError(stream, errUnauthorized)
+	return false
+}
@@ -0,0 +1,43 @@
+package connection
+
+import (
+	"crypto/rand"
+	"testing"
+
+	"github.com/libp2p/go-libp2p/core/crypto"
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+func newPeerID(t *testing.T) peer.ID {
+	t.Helper()
+	key, _, err := crypto.GenerateEd25519Key(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	id, err := peer.IDFromPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return id
+}
+
+func TestAuthorizedPeers(t *testing.T) {
+	admin := newPeerID(t)
+	stranger := newPeerID(t)
+	provider := NewProvider(nil, nil)
+	if provider.peerAuthorized(admin) {
+		t.Error("Expected changes to be refused without authorized peers")
+	}
+	if err := provider.SetAuthorizedPeers([]string{admin.String()}); err != nil {
+		t.Fatal(err)
+	}
+	if !provider.peerAuthorized(admin) {
+		t.Error("Expected the authorized peer to be allowed")
+	}
+	if provider.peerAuthorized(stranger) {
+		t.Error("Expected an unauthenticated peer to be refused")
+	}
+	if err := provider.SetAuthorizedPeers([]string{"viewer"}); err == nil {
+		t.Error("Expected an invalid peer id to fail")
+	}
+}
@@ -0,0 +1,57 @@
+package connection
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/libp2p/go-libp2p/core/network"
+	"strzcam.com/broadcaster/watchdog"
+)
+
+// Camera streams get the JSON camera state or "error <message>"
+const CameraProtocol = "/camera/1.0.0"
+
+func (p *Provider) handleCamera(stream network.Stream) {
+	defer stream.Close()
+	state, err := p.camera()
+	if err != nil {
+		writeExportError(stream, err)
+		return
+	}
+	if err := json.NewEncoder(stream).Encode(state); err != nil {
+		log.Printf("Error sending camera state: %v", err)
+	}
+}
+
+func (p *Provider) camera() (watchdog.State, error) {
+	if p.watchdog == nil {
+		return watchdog.State{}, errors.New("camera watchdog is not enabled")
+	}
+	return p.watchdog.State(), nil
+}
+
+// Camera returns whether the provider camera delivers frames
+func (v *Viewer) Camera(ctx context.Context) (watchdog.State, error) {
+	stream, err := (*v.Host).NewStream(ctx, (*v.Info).ID, CameraProtocol)
+	if err != nil {
+		return watchdog.State{}, err
+	}
+	defer stream.Close()
+	line, err := bufio.NewReader(stream).ReadString('\n')
+	if err != nil {
+		return watchdog.State{}, fmt.Errorf("camera request interrupted: %w", err)
+	}
+	if message, ok := strings.CutPrefix(line, "error "); ok {
+		return watchdog.State{}, errors.New(strings.TrimSpace(message))
+	}
+	var state watchdog.State
+	if err := json.Unmarshal([]byte(line), &state); err != nil {
+		return watchdog.State{}, fmt.Errorf("invalid camera response: %w", err)
+	}
+	return state, nil
+}
@@ -0,0 +1,176 @@
+package connection
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/protocol"
+	"strzcam.com/broadcaster/video"
+)
+
+// Export streams answer with "progress <0-1>" lines while the provider works,
+// then "done <size>" followed by the file or "error <message>". Evidence
+// bundles are written while they are sent, so they answer "stream" and the
+// bundle follows until the stream is closed.
+const (
+	ExportClipProtocol     = "/export-clip/1.0.0"
+	ExportEvidenceProtocol = "/export-evidence/1.0.0"
+)
+
+func writeExportFile(stream network.Stream, path string) error {
+	file, err := video.OpenVideo(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	if _, err := fmt.Fprintf(stream, "done %d\n", file.Size()); err != nil {
+		return err
+	}
+	_, err = io.Copy(stream, file)
+	return err
+}
+
+func readExportRequest(stream network.Stream) (video.ClipRequest, bool) {
+	var request video.ClipRequest
+	line, err := bufio.NewReader(stream).ReadString('\n')
+	if err != nil {
+		log.Printf("Error reading export request: %v", err)
+		return request, false
+	}
+	if err := json.Unmarshal([]byte(line), &request); err != nil {
+		fmt.Fprintf(stream, "error invalid request: %v\n", err)
+		return request, false
+	}
+	return request, true
+}
+
+// writeExportProgress reports progress, cancel is called once the requester went away
+func writeExportProgress(stream network.Stream, cancel context.CancelFunc) func(float64) {
+	return func(progress float64) {
+		if _, err := fmt.Fprintf(stream, "progress %.3f\n", progress); err != nil {
+			cancel()
+		}
+	}
+}
+
+func writeExportError(stream network.Stream, err error) {
+	fmt.Fprintf(stream, "error %s\n", strings.ReplaceAll(err.Error(), "\n", " "))
+}
+
+func (p *Provider) handleExportClip(stream network.Stream) {
+	defer stream.Close()
+	request, ok := readExportRequest(stream)
+	if !ok {
+		return
+	}
+	ctx, cancel := context.WithCancel(p.ctx)
+	defer cancel()
+	path, err := video.ExportClipFile(ctx, p.paths, request, writeExportProgress(stream, cancel))
+	if err != nil {
+		log.Printf("Clip export failed: %v", err)
+		writeExportError(stream, err)
+		return
+	}
+	defer os.Remove(path)
+	if err := writeExportFile(stream, path); err != nil {
+		log.Printf("Error sending clip: %v", err)
+	}
+}
+
+func (p *Provider) handleExportEvidence(stream network.Stream) {
+	defer stream.Close()
+	request, ok := readExportRequest(stream)
+	if !ok {
+		return
+	}
+	ctx, cancel := context.WithCancel(p.ctx)
+	defer cancel()
+	evidence, err := video.PrepareEvidence(ctx, p.paths, p.eventsDir, request, writeExportProgress(stream, cancel))
+	if err != nil {
+		log.Printf("Evidence export failed: %v", err)
+		writeExportError(stream, err)
+		return
+	}
+	defer evidence.Close()
+	if _, err := fmt.Fprintf(stream, "stream\n"); err != nil {
+		return
+	}
+	if _, err := evidence.WriteTo(stream); err != nil {
+		// the requester sees a truncated archive
+		log.Printf("Error sending evidence: %v", err)
+		stream.Reset()
+	}
+}
+
+// readExportResponse reports progress and copies the exported file to w
+func readExportResponse(stream io.Reader, progress func(float64), w io.Writer) error {
+	buf := bufio.NewReader(stream)
+	for {
+		line, err := buf.ReadString('\n')
+		if err != nil {
+			return fmt.Errorf("export interrupted: %w", err)
+		}
+		kind, value, _ := strings.Cut(strings.TrimSpace(line), " ")
+		switch kind {
+		case "progress":
+			if progress != nil {
+				if fraction, err := strconv.ParseFloat(value, 64); err == nil {
+					progress(fraction)
+				}
+			}
+		case "error":
+			return errors.New(value)
+		case "done":
+			size, err := strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				return fmt.Errorf("invalid size: %w", err)
+			}
+			written, err := io.CopyN(w, buf, size)
+			if err != nil {
+				return fmt.Errorf("incomplete transfer: got %d bytes, expected %d: %w", written, size, err)
+			}
+			return nil
+		case "stream":
+			if _, err := io.Copy(w, buf); err != nil {
+				return fmt.Errorf("export interrupted: %w", err)
+			}
+			return nil
+		default:
+			return fmt.Errorf("unexpected export response: %s", line)
+		}
+	}
+}
+
+func (v *Viewer) ExportClip(ctx context.Context, request video.ClipRequest, progress func(float64), w io.Writer) error {
+	return v.export(ctx, ExportClipProtocol, request, progress, w)
+}
+
+// ExportEvidence copies the evidence ZIP of the range to w
+func (v *Viewer) ExportEvidence(ctx context.Context, request video.ClipRequest, progress func(float64), w io.Writer) error {
+	return v.export(ctx, ExportEvidenceProtocol, request, progress, w)
+}
+
+func (v *Viewer) export(ctx context.Context, protocolID protocol.ID, request video.ClipRequest, progress func(float64), w io.Writer) error {
+	stream, err := (*v.Host).NewStream(ctx, (*v.Info).ID, protocolID)
+	if err != nil {
+		return err
+	}
+	defer stream.Close()
+	requestData, err := json.Marshal(request)
+	if err != nil {
+		return err
+	}
+	if _, err := stream.Write(append(requestData, '\n')); err != nil {
+		return err
+	}
+	return readExportResponse(stream, progress, w)
+}
@@ -0,0 +1,62 @@
+package connection
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/network"
+	"strzcam.com/broadcaster/health"
+)
+
+// Health streams get the JSON image health status or "error <message>"
+const HealthProtocol = "/health/1.0.0"
+
+func (p *Provider) handleHealth(stream network.Stream) {
+	defer stream.Close()
+	status, err := p.health()
+	if err != nil {
+		writeExportError(stream, err)
+		return
+	}
+	if err := json.NewEncoder(stream).Encode(status); err != nil {
+		log.Printf("Error sending health: %v", err)
+	}
+}
+
+func (p *Provider) health() (health.Status, error) {
+	if p.healthStateFile == "" {
+		return health.Status{}, errors.New("health checks are not enabled")
+	}
+	status, err := health.LoadStatus(p.healthStateFile, time.Now())
+	if err != nil {
+		return health.Status{}, fmt.Errorf("no health status yet: %w", err)
+	}
+	return status, nil
+}
+
+// Health returns the image health of the provider camera
+func (v *Viewer) Health(ctx context.Context) (health.Status, error) {
+	stream, err := (*v.Host).NewStream(ctx, (*v.Info).ID, HealthProtocol)
+	if err != nil {
+		return health.Status{}, err
+	}
+	defer stream.Close()
+	line, err := bufio.NewReader(stream).ReadString('\n')
+	if err != nil {
+		return health.Status{}, fmt.Errorf("health request interrupted: %w", err)
+	}
+	if message, ok := strings.CutPrefix(line, "error "); ok {
+		return health.Status{}, errors.New(strings.TrimSpace(message))
+	}
+	var status health.Status
+	if err := json.Unmarshal([]byte(line), &status); err != nil {
+		return health.Status{}, fmt.Errorf("invalid health response: %w", err)
+	}
+	return status, nil
+}
@@ -0,0 +1,116 @@
+package connection
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/libp2p/go-libp2p/core/network"
+	"strzcam.com/broadcaster/encryption"
+	"strzcam.com/broadcaster/video"
+)
+
+// Import streams send one JSON line with the request and the file size,
+// then the file. The provider answers "done <name>" or "error <message>",
+// peers that are not authorized get the error before the file is read.
+const ImportVideoProtocol = "/import-video/1.0.0"
+
+type importHeader struct {
+	video.ImportRequest
+	Size int64 `json:"size"`
+}
+
+func (p *Provider) handleImportVideo(stream network.Stream) {
+	defer stream.Close()
+	if !p.authorized(stream) {
+		return
+	}
+	buf := bufio.NewReader(stream)
+	line, err := buf.ReadString('\n')
+	if err != nil {
+		log.Printf("Error reading import request: %v", err)
+		return
+	}
+	var header importHeader
+	if err := json.Unmarshal([]byte(line), &header); err != nil {
+		fmt.Fprintf(stream, "error invalid request: %v\n", err)
+		return
+	}
+	if err := header.Validate(); err != nil {
+		writeExportError(stream, err)
+		return
+	}
+	name, err := p.importVideo(p.ctx, header.ImportRequest, buf, header.Size)
+	if err != nil {
+		log.Printf("Import failed: %v", err)
+		writeExportError(stream, err)
+		return
+	}
+	log.Printf("Imported %s", name)
+	fmt.Fprintf(stream, "done %s\n", name)
+}
+
+// importVideo stores size bytes of r in a temporary file, encrypted like
+// recordings, and imports them
+func (p *Provider) importVideo(ctx context.Context, request video.ImportRequest, r io.Reader, size int64) (string, error) {
+	if len(p.paths) == 0 {
+		return "", errors.New("no video roots to import into")
+	}
+	file, path, err := encryption.CreateTemp("import-*", encryption.Default())
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(path)
+	written, err := io.CopyN(file, r, size)
+	if closeErr := file.Close(); err == nil && closeErr != nil {
+		return "", closeErr
+	}
+	if err != nil {
+		return "", fmt.Errorf("import interrupted after %d of %d bytes: %w", written, size, err)
+	}
+	return video.ImportVideo(ctx, path, request, p.paths, p.paths[0])
+}
+
+// ImportVideo uploads size bytes of footage from r, it returns the stored name
+func (v *Viewer) ImportVideo(ctx context.Context, request video.ImportRequest, r io.Reader, size int64) (string, error) {
+	stream, err := (*v.Host).NewStream(ctx, (*v.Info).ID, ImportVideoProtocol)
+	if err != nil {
+		return "", err
+	}
+	defer stream.Close()
+	headerData, err := json.Marshal(importHeader{ImportRequest: request, Size: size})
+	if err != nil {
+		return "", err
+	}
+	if _, err := stream.Write(append(headerData, '\n')); err != nil {
+		return "", err
+	}
+	if _, err := io.CopyN(stream, r, size); err != nil {
+		// a refused import is answered before the upload ends
+		if line, readErr := bufio.NewReader(stream).ReadString('\n'); readErr == nil && strings.HasPrefix(line, "error ") {
+			return "", errors.New(strings.TrimSpace(strings.TrimPrefix(line, "error ")))
+		}
+		return "", fmt.Errorf("upload interrupted: %w", err)
+	}
+	if err := stream.CloseWrite(); err != nil {
+		return "", err
+	}
+	line, err := bufio.NewReader(stream).ReadString('\n')
+	if err != nil {
+		return "", fmt.Errorf("import interrupted: %w", err)
+	}
+	kind, value, _ := strings.Cut(strings.TrimSpace(line), " ")
+	switch kind {
+	case "done":
+		return value, nil
+	case "error":
+		return "", errors.New(value)
+	}
+	return "", fmt.Errorf("unexpected import response: %s", line)
+}
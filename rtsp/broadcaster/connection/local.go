@@ -0,0 +1,99 @@
+package connection
+
+import (
+	"context"
+	"io"
+	"time"
+
+	"strzcam.com/broadcaster/analytics"
+	"strzcam.com/broadcaster/health"
+	"strzcam.com/broadcaster/schedule"
+	"strzcam.com/broadcaster/search"
+	"strzcam.com/broadcaster/video"
+	"strzcam.com/broadcaster/watchdog"
+)
+
+// Local answers what a Viewer asks a provider from the disk of this process,
+// so a server next to the recorder needs no p2p connection
+type Local struct {
+	provider *Provider
+}
+
+// NewLocal serves what provider is set up with, it needs no host
+func NewLocal(provider *Provider) *Local {
+	return &Local{provider: provider}
+}
+
+func (l *Local) GetVideoList(start time.Time, end time.Time) []video.Video {
+	return l.provider.videoList(start, end)
+}
+
+func (l *Local) GetSignatureChain(start time.Time, end time.Time) []video.ChainEntry {
+	return l.provider.signatureChain(start, end)
+}
+
+// GetVideo returns nothing for missing or damaged videos like a provider
+func (l *Local) GetVideo(name string) []byte {
+	data, err := l.provider.webVideo(name)
+	if err != nil {
+		return []byte{}
+	}
+	return data
+}
+
+func (l *Local) ExportClip(ctx context.Context, request video.ClipRequest, progress func(float64), w io.Writer) error {
+	return video.WriteClip(ctx, l.provider.paths, request, progress, w)
+}
+
+func (l *Local) ExportEvidence(ctx context.Context, request video.ClipRequest, progress func(float64), w io.Writer) error {
+	evidence, err := video.PrepareEvidence(ctx, l.provider.paths, l.provider.eventsDir, request, progress)
+	if err != nil {
+		return err
+	}
+	defer evidence.Close()
+	_, err = evidence.WriteTo(w)
+	return err
+}
+
+func (l *Local) ImportVideo(ctx context.Context, request video.ImportRequest, r io.Reader, size int64) (string, error) {
+	if err := request.Validate(); err != nil {
+		return "", err
+	}
+	return l.provider.importVideo(ctx, request, r, size)
+}
+
+func (l *Local) GetAnalytics(ctx context.Context, query analytics.Query) (analytics.Report, error) {
+	return l.provider.analytics(query)
+}
+
+func (l *Local) GetActivity(ctx context.Context, query analytics.Query) (analytics.ActivityReport, error) {
+	return l.provider.activity(query)
+}
+
+func (l *Local) GetHeatmapOverlay(ctx context.Context, query analytics.Query) ([]byte, error) {
+	return l.provider.heatmapOverlay(query)
+}
+
+func (l *Local) Search(ctx context.Context, query search.Query) (search.Page, error) {
+	return l.provider.search(query)
+}
+
+func (l *Local) Health(ctx context.Context) (health.Status, error) {
+	return l.provider.health()
+}
+
+func (l *Local) Camera(ctx context.Context) (watchdog.State, error) {
+	return l.provider.camera()
+}
+
+func (l *Local) Mode(ctx context.Context) (schedule.Status, error) {
+	return l.provider.mode(schedule.Override{})
+}
+
+func (l *Local) SetMode(ctx context.Context, override schedule.Override) (schedule.Status, error) {
+	return l.provider.mode(override)
+}
+
+func (l *Local) ReloadConfig(ctx context.Context) (ReloadResult, error) {
+	return l.provider.reloadConfig()
+}
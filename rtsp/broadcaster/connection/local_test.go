@@ -0,0 +1,103 @@
+package connection
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"strzcam.com/broadcaster/schedule"
+	"strzcam.com/broadcaster/video"
+)
+
+// newLocalStorage returns a storage root with a recording on 2025-01-02 and
+// one on 2025-01-05
+func newLocalStorage(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	for _, name := range []string{"2025-01-02-1-30.mp4", "2025-01-05-1-30.mp4", "notes.txt"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte("footage"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestLocalVideoList(t *testing.T) {
+	local := NewLocal(NewProvider(nil, []string{newLocalStorage(t)}))
+	videos := local.GetVideoList(time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC), time.Date(2025, 1, 3, 0, 0, 0, 0, time.UTC))
+	if len(videos) != 1 || videos[0].Name != "2025-01-02-1-30.mp4" || videos[0].Type != video.TypeRecording {
+		t.Errorf("Expected the recording of 2025-01-02, got %+v", videos)
+	}
+	if videos := local.GetVideoList(time.Date(2025, 2, 1, 0, 0, 0, 0, time.UTC), time.Date(2025, 2, 3, 0, 0, 0, 0, time.UTC)); len(videos) != 0 {
+		t.Errorf("Expected no videos in February, got %+v", videos)
+	}
+}
+
+func TestLocalGetVideo(t *testing.T) {
+	dir := newLocalStorage(t)
+	damaged := filepath.Join(dir, "2025-01-05-1-30.mp4")
+	if err := video.SaveVideoMetadata(damaged, video.Metadata{SHA256: "0000"}); err != nil {
+		t.Fatal(err)
+	}
+	local := NewLocal(NewProvider(nil, []string{dir}))
+	for _, name := range []string{"2025-01-03-1-30.mp4", "../2025-01-02-1-30.mp4", "2025-01-05-1-30.mp4"} {
+		if data := local.GetVideo(name); len(data) != 0 {
+			t.Errorf("Expected nothing for %s, got %d bytes", name, len(data))
+		}
+	}
+
+	if _, err := exec.LookPath("ffmpeg"); err != nil {
+		t.Skip("ffmpeg is not installed")
+	}
+	recording := filepath.Join(dir, "2025-01-02-1-30.mp4")
+	output, err := exec.Command("ffmpeg", "-y", "-f", "lavfi", "-i", "testsrc=duration=1:size=320x240:rate=10",
+		"-c:v", "libx264", "-pix_fmt", "yuv420p", "-f", "h264", recording).CombinedOutput()
+	if err != nil {
+		t.Fatalf("Can not create the recording: %v\n%s", err, output)
+	}
+	data := local.GetVideo("2025-01-02-1-30.mp4")
+	if len(data) < 8 || !bytes.Equal(data[4:8], []byte("ftyp")) {
+		t.Errorf("Expected the recording as MP4, got %d bytes", len(data))
+	}
+}
+
+func TestLocalMode(t *testing.T) {
+	statePath := filepath.Join(t.TempDir(), "schedule_state.json")
+	provider := NewProvider(nil, []string{newLocalStorage(t)})
+	local := NewLocal(provider)
+	if _, err := local.Mode(context.Background()); err == nil {
+		t.Error("Expected an error without a schedule")
+	}
+
+	provider.SetSchedule(schedule.NewController(schedule.Schedule{}, time.UTC, statePath))
+	status, err := local.Mode(context.Background())
+	if err != nil || status.Mode != schedule.ModeArmed {
+		t.Errorf("Expected the default mode armed, got %+v %v", status, err)
+	}
+	if _, err := local.SetMode(context.Background(), schedule.Override{Action: "sleep"}); err == nil {
+		t.Error("Expected an unknown action to be refused")
+	}
+	status, err = local.SetMode(context.Background(), schedule.Override{Action: schedule.ActionDisarm})
+	if err != nil || status.Mode != schedule.ModeDisarmed {
+		t.Errorf("Expected disarmed, got %+v %v", status, err)
+	}
+	// the override is saved for the next start
+	restarted := NewLocal(NewProvider(nil, nil))
+	restarted.provider.SetSchedule(schedule.NewController(schedule.Schedule{}, time.UTC, statePath))
+	if status, err := restarted.Mode(context.Background()); err != nil || status.Mode != schedule.ModeDisarmed {
+		t.Errorf("Expected the override after a restart, got %+v %v", status, err)
+	}
+}
+
+func TestLocalImportWithoutRoots(t *testing.T) {
+	local := NewLocal(NewProvider(nil, nil))
+	request := video.ImportRequest{Camera: "front", Start: time.Date(2025, 1, 2, 14, 2, 10, 0, time.UTC)}
+	if _, err := local.ImportVideo(context.Background(), request, strings.NewReader("footage"), 7); err == nil {
+		t.Error("Expected an import without video roots to fail")
+	}
+}
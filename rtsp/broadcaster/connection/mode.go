@@ -0,0 +1,94 @@
+package connection
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/network"
+	"strzcam.com/broadcaster/schedule"
+)
+
+// Mode streams send one JSON line with an override, an empty action only
+// asks for the status. Overrides are only taken from authorized peers. The
+// provider answers with the JSON status or "error <message>".
+const ModeProtocol = "/mode/1.0.0"
+
+func (p *Provider) handleMode(stream network.Stream) {
+	defer stream.Close()
+	line, err := bufio.NewReader(stream).ReadString('\n')
+	if err != nil {
+		log.Printf("Error reading mode request: %v", err)
+		return
+	}
+	var override schedule.Override
+	if err := json.Unmarshal([]byte(line), &override); err != nil {
+		fmt.Fprintf(stream, "error invalid request: %v\n", err)
+		return
+	}
+	if override.Action != "" && !p.authorized(stream) {
+		return
+	}
+	status, err := p.mode(override)
+	if err != nil {
+		writeExportError(stream, err)
+		return
+	}
+	if err := json.NewEncoder(stream).Encode(status); err != nil {
+		log.Printf("Error sending mode: %v", err)
+	}
+}
+
+// mode applies override, an empty action only returns the status
+func (p *Provider) mode(override schedule.Override) (schedule.Status, error) {
+	if p.schedule == nil {
+		return schedule.Status{}, errors.New("schedules are not enabled")
+	}
+	if override.Action == "" {
+		return p.schedule.Status(time.Now()), nil
+	}
+	status, err := p.schedule.Apply(override, time.Now())
+	if err != nil {
+		return schedule.Status{}, err
+	}
+	log.Printf("Mode changed to %s by %s", status.Mode, override.Action)
+	return status, nil
+}
+
+// Mode returns the current mode of the provider
+func (v *Viewer) Mode(ctx context.Context) (schedule.Status, error) {
+	return v.SetMode(ctx, schedule.Override{})
+}
+
+// SetMode applies an override, arm, disarm, away or auto, and returns the new mode
+func (v *Viewer) SetMode(ctx context.Context, override schedule.Override) (schedule.Status, error) {
+	stream, err := (*v.Host).NewStream(ctx, (*v.Info).ID, ModeProtocol)
+	if err != nil {
+		return schedule.Status{}, err
+	}
+	defer stream.Close()
+	data, err := json.Marshal(override)
+	if err != nil {
+		return schedule.Status{}, err
+	}
+	if _, err := stream.Write(append(data, '\n')); err != nil {
+		return schedule.Status{}, err
+	}
+	line, err := bufio.NewReader(stream).ReadString('\n')
+	if err != nil {
+		return schedule.Status{}, fmt.Errorf("mode request interrupted: %w", err)
+	}
+	if message, ok := strings.CutPrefix(line, "error "); ok {
+		return schedule.Status{}, errors.New(strings.TrimSpace(message))
+	}
+	var status schedule.Status
+	if err := json.Unmarshal([]byte(line), &status); err != nil {
+		return schedule.Status{}, fmt.Errorf("invalid mode response: %w", err)
+	}
+	return status, nil
+}
@@ -5,16 +5,20 @@ import (
 	"context"
 	"encoding/binary"
 	"encoding/json"
+	"fmt"
 	"log"
-	"path/filepath"
 	"strings"
 	"time"
 
 	"github.com/libp2p/go-libp2p/core/event"
 	"github.com/libp2p/go-libp2p/core/host"
 	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/protocol"
 	"strzcam.com/broadcaster/frame"
+	"strzcam.com/broadcaster/schedule"
 	"strzcam.com/broadcaster/video"
+	"strzcam.com/broadcaster/watchdog"
 )
 
 const BufferCapacity = 30
@@ -22,21 +26,72 @@ const BufferCapacity = 30
 type Provider struct {
 	host        host.Host
 	frameBuffer []frame.Frame
-	path        string
+	paths       []string
+	eventsDir   string
+	heatmapDir  string
+	schedule    *schedule.Controller
+	// image health saved by the shared memory receiver
+	healthStateFile string
+	watchdog        *watchdog.Watchdog
+	reload          ReloadFunc
+	authorizedPeers []peer.ID
+	// cancels exports and imports when the provider stops
+	ctx       context.Context
+	protocols []protocol.ID
 }
 
-func NewProvider(host host.Host, path string) *Provider {
-	return &Provider{host: host, path: path, frameBuffer: make([]frame.Frame, 0, BufferCapacity)}
+func NewProvider(host host.Host, paths []string) *Provider {
+	return &Provider{host: host, paths: paths, frameBuffer: make([]frame.Frame, 0, BufferCapacity), ctx: context.Background()}
 }
 
-func (p *Provider) HandleConnectedPeers() {
+// SetEventsDir enables detections in evidence bundles and analytics
+func (p *Provider) SetEventsDir(dir string) {
+	p.eventsDir = dir
+}
+
+// SetHeatmapDir adds heatmaps to activity reports
+func (p *Provider) SetHeatmapDir(dir string) {
+	p.heatmapDir = dir
+}
+
+// SetSchedule enables reading and overriding the mode of the camera
+func (p *Provider) SetSchedule(controller *schedule.Controller) {
+	p.schedule = controller
+}
+
+// SetHealthStateFile enables reporting image health
+func (p *Provider) SetHealthStateFile(path string) {
+	p.healthStateFile = path
+}
+
+// SetWatchdog enables reporting whether the camera is online
+func (p *Provider) SetWatchdog(w *watchdog.Watchdog) {
+	p.watchdog = w
+}
+
+// SetReload enables reloading the configuration by viewers
+func (p *Provider) SetReload(reload ReloadFunc) {
+	p.reload = reload
+}
+
+func (p *Provider) HandleConnectedPeers(ctx context.Context) error {
 	subscription, err := p.host.EventBus().Subscribe(new(event.EvtPeerConnectednessChanged))
 	if err != nil {
-		log.Fatal(err)
+		return err
 	}
 	go func() {
 		defer subscription.Close()
-		for evt := range subscription.Out() {
+		for {
+			var evt interface{}
+			select {
+			case <-ctx.Done():
+				return
+			case received, ok := <-subscription.Out():
+				if !ok {
+					return
+				}
+				evt = received
+			}
 			connectEvent := evt.(event.EvtPeerConnectednessChanged)
 			switch connectEvent.Connectedness {
 			case network.Connected:
@@ -46,12 +101,16 @@ func (p *Provider) HandleConnectedPeers() {
 			}
 		}
 	}()
+	return nil
 }
 
+// StartListening serves viewers until ctx is done, then the handlers are
+// removed, running exports cancelled and connected peers closed
 func (p *Provider) StartListening(ctx context.Context) {
+	p.ctx = ctx
 	fullAddr := GetHostAddress(p.host)
 	log.Printf("I am %s\n", fullAddr)
-	p.host.SetStreamHandler("/get-frame/1.0.0", func(stream network.Stream) {
+	p.handle("/get-frame/1.0.0", func(stream network.Stream) {
 		framesData, err := json.Marshal(p.frameBuffer)
 		if err != nil {
 			log.Printf("Error marshaling frames: %v", err)
@@ -65,7 +124,7 @@ func (p *Provider) StartListening(ctx context.Context) {
 		stream.Close()
 		p.frameBuffer = make([]frame.Frame, 0, BufferCapacity)
 	})
-	p.host.SetStreamHandler("/get-video/1.0.0", func(stream network.Stream) {
+	p.handle("/get-video/1.0.0", func(stream network.Stream) {
 		defer stream.Close()
 		buf := bufio.NewReader(stream)
 		name, err := buf.ReadString('\n')
@@ -73,26 +132,18 @@ func (p *Provider) StartListening(ctx context.Context) {
 			log.Printf("Error reading filename: %v", err)
 			return
 		}
-		name = strings.TrimSpace(name)
-		if err := video.ValidateFilename(name); err != nil {
-			log.Printf("Invalid filename: %v", err)
+		videoBytes, err := p.webVideo(strings.TrimSpace(name))
+		if err != nil {
+			log.Println(err)
 			return
 		}
-		filePath := filepath.Join(p.path, name)
-		videoBytes, _ := video.ConvertAndGetVideoForWeb(filePath)
 		stream.Write(videoBytes)
 		stream.Close()
 	})
 
-	p.host.SetStreamHandler("/get-video-list/1.0.0", func(stream network.Stream) {
-		buf := bufio.NewReader(stream)
-		timeRangeData, _ := buf.ReadString('\n')
-		timeRangeData = strings.ReplaceAll(timeRangeData, "\n", "")
-		parts := strings.SplitN(timeRangeData, "-", 8)
-		start, _ := time.Parse("2006-01-02", strings.Join(parts[0:3], "-"))
-		end, _ := time.Parse("2006-01-02", strings.Join(parts[3:], "-"))
-		videoList, _ := video.GetVideoByDateRange(p.path, start, end)
-		jsonData, err := json.Marshal(videoList)
+	p.handle("/get-video-list/1.0.0", func(stream network.Stream) {
+		start, end := readDateRange(stream)
+		jsonData, err := json.Marshal(p.videoList(start, end))
 		if err != nil {
 			log.Printf("Error marshaling JSON: %v", err)
 			return
@@ -100,6 +151,88 @@ func (p *Provider) StartListening(ctx context.Context) {
 		stream.Write(jsonData)
 		stream.Close()
 	})
+
+	p.handle(ExportClipProtocol, p.handleExportClip)
+	p.handle(ExportEvidenceProtocol, p.handleExportEvidence)
+	p.handle(ImportVideoProtocol, p.handleImportVideo)
+	p.handle(AnalyticsProtocol, p.handleAnalytics)
+	p.handle(ActivityProtocol, p.handleActivity)
+	p.handle(SearchProtocol, p.handleSearch)
+	p.handle(ModeProtocol, p.handleMode)
+	p.handle(HealthProtocol, p.handleHealth)
+	p.handle(CameraProtocol, p.handleCamera)
+	p.handle(ConfigReloadProtocol, p.handleConfigReload)
+
+	p.handle("/get-signature-chain/1.0.0", func(stream network.Stream) {
+		defer stream.Close()
+		start, end := readDateRange(stream)
+		jsonData, err := json.Marshal(p.signatureChain(start, end))
+		if err != nil {
+			log.Printf("Error marshaling JSON: %v", err)
+			return
+		}
+		stream.Write(jsonData)
+	})
+	go func() {
+		<-ctx.Done()
+		p.stopListening()
+	}()
+}
+
+func (p *Provider) handle(id protocol.ID, handler network.StreamHandler) {
+	p.protocols = append(p.protocols, id)
+	p.host.SetStreamHandler(id, handler)
+}
+
+func (p *Provider) stopListening() {
+	for _, id := range p.protocols {
+		p.host.RemoveStreamHandler(id)
+	}
+	for _, conn := range p.host.Network().Conns() {
+		conn.Close()
+	}
+	log.Println("Provider stopped listening")
+}
+
+// webVideo returns the recording converted for browsers, damaged recordings
+// are refused
+func (p *Provider) webVideo(name string) ([]byte, error) {
+	filePath, err := video.FindVideo(p.paths, name)
+	if err != nil {
+		return nil, fmt.Errorf("invalid filename: %w", err)
+	}
+	if err := video.VerifyVideo(filePath); err != nil {
+		return nil, fmt.Errorf("refusing to serve damaged video: %w", err)
+	}
+	return video.ConvertAndGetVideoForWeb(filePath)
+}
+
+func (p *Provider) videoList(start time.Time, end time.Time) []video.Video {
+	videoList, _ := video.GetVideoByDateRange(p.paths, start, end)
+	return videoList
+}
+
+func (p *Provider) signatureChain(start time.Time, end time.Time) []video.ChainEntry {
+	chain, err := video.GetSignatureChain(p.paths, start, end)
+	if err != nil {
+		log.Printf("Error reading signature chain: %v", err)
+		return []video.ChainEntry{}
+	}
+	return chain
+}
+
+// readDateRange reads "YYYY-MM-DD-YYYY-MM-DD\n" sent by the viewer
+func readDateRange(stream network.Stream) (time.Time, time.Time) {
+	buf := bufio.NewReader(stream)
+	timeRangeData, _ := buf.ReadString('\n')
+	timeRangeData = strings.ReplaceAll(timeRangeData, "\n", "")
+	parts := strings.SplitN(timeRangeData, "-", 8)
+	if len(parts) < 6 {
+		return time.Time{}, time.Time{}
+	}
+	start, _ := time.Parse("2006-01-02", strings.Join(parts[0:3], "-"))
+	end, _ := time.Parse("2006-01-02", strings.Join(parts[3:], "-"))
+	return start, end
 }
 
 func (p *Provider) BroadcastFrame(frame frame.Frame) {
@@ -0,0 +1,68 @@
+package connection
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/libp2p/go-libp2p/core/network"
+)
+
+// ConfigReload streams get the JSON ReloadResult or "error <message>"
+const ConfigReloadProtocol = "/config-reload/1.0.0"
+
+// ReloadResult lists settings changed by reloading the configuration
+type ReloadResult struct {
+	Applied         []string `json:"applied"`         // in use already
+	RestartRequired []string `json:"restartRequired"` // changed since start, used after a restart
+}
+
+// ReloadFunc reloads the configuration, on error the previous one stays
+type ReloadFunc func() (ReloadResult, error)
+
+func (p *Provider) handleConfigReload(stream network.Stream) {
+	defer stream.Close()
+	if !p.authorized(stream) {
+		return
+	}
+	result, err := p.reloadConfig()
+	if err != nil {
+		writeExportError(stream, err)
+		return
+	}
+	if err := json.NewEncoder(stream).Encode(result); err != nil {
+		log.Printf("Error sending reload result: %v", err)
+	}
+}
+
+func (p *Provider) reloadConfig() (ReloadResult, error) {
+	if p.reload == nil {
+		return ReloadResult{}, errors.New("configuration reload is not enabled")
+	}
+	return p.reload()
+}
+
+// ReloadConfig makes the provider read its configuration again
+func (v *Viewer) ReloadConfig(ctx context.Context) (ReloadResult, error) {
+	stream, err := (*v.Host).NewStream(ctx, (*v.Info).ID, ConfigReloadProtocol)
+	if err != nil {
+		return ReloadResult{}, err
+	}
+	defer stream.Close()
+	line, err := bufio.NewReader(stream).ReadString('\n')
+	if err != nil {
+		return ReloadResult{}, fmt.Errorf("reload request interrupted: %w", err)
+	}
+	if message, ok := strings.CutPrefix(line, "error "); ok {
+		return ReloadResult{}, errors.New(strings.TrimSpace(message))
+	}
+	var result ReloadResult
+	if err := json.Unmarshal([]byte(line), &result); err != nil {
+		return ReloadResult{}, fmt.Errorf("invalid reload response: %w", err)
+	}
+	return result, nil
+}
@@ -0,0 +1,75 @@
+package connection
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/libp2p/go-libp2p/core/network"
+	"strzcam.com/broadcaster/search"
+)
+
+// Search streams send one JSON line with the query, the provider answers
+// with the JSON page or "error <message>".
+const SearchProtocol = "/search/1.0.0"
+
+func (p *Provider) handleSearch(stream network.Stream) {
+	defer stream.Close()
+	line, err := bufio.NewReader(stream).ReadString('\n')
+	if err != nil {
+		log.Printf("Error reading search query: %v", err)
+		return
+	}
+	var query search.Query
+	if err := json.Unmarshal([]byte(line), &query); err != nil {
+		fmt.Fprintf(stream, "error invalid query: %v\n", err)
+		return
+	}
+	page, err := p.search(query)
+	if err != nil {
+		writeExportError(stream, err)
+		return
+	}
+	if err := json.NewEncoder(stream).Encode(page); err != nil {
+		log.Printf("Error sending search results: %v", err)
+	}
+}
+
+func (p *Provider) search(query search.Query) (search.Page, error) {
+	if p.eventsDir == "" {
+		return search.Page{}, errors.New("events are not recorded")
+	}
+	return search.Search(p.eventsDir, p.paths, query)
+}
+
+// Search returns a page of detections recorded by the provider
+func (v *Viewer) Search(ctx context.Context, query search.Query) (search.Page, error) {
+	stream, err := (*v.Host).NewStream(ctx, (*v.Info).ID, SearchProtocol)
+	if err != nil {
+		return search.Page{}, err
+	}
+	defer stream.Close()
+	data, err := json.Marshal(query)
+	if err != nil {
+		return search.Page{}, err
+	}
+	if _, err := stream.Write(append(data, '\n')); err != nil {
+		return search.Page{}, err
+	}
+	line, err := bufio.NewReader(stream).ReadString('\n')
+	if err != nil {
+		return search.Page{}, fmt.Errorf("search interrupted: %w", err)
+	}
+	if message, ok := strings.CutPrefix(line, "error "); ok {
+		return search.Page{}, errors.New(strings.TrimSpace(message))
+	}
+	var page search.Page
+	if err := json.Unmarshal([]byte(line), &page); err != nil {
+		return search.Page{}, fmt.Errorf("invalid search response: %w", err)
+	}
+	return page, nil
+}
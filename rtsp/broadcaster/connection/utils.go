@@ -93,6 +93,11 @@ func MakeEnhancedHost(ctx context.Context, listenPort int, insecure bool, randse
 	if err != nil {
 		return nil, nil, err
 	}
+	return MakeEnhancedHostWithIdentity(ctx, listenPort, insecure, prv)
+}
+
+// MakeEnhancedHostWithIdentity keeps the peer ID stable across restarts
+func MakeEnhancedHostWithIdentity(ctx context.Context, listenPort int, insecure bool, prv crypto.PrivKey) (host.Host, *dht.IpfsDHT, error) {
 	bootstrapPeers := createDHTForPeerDiscovery()
 	opts := []libp2p.Option{
 		libp2p.ListenAddrStrings(
@@ -255,3 +260,17 @@ func InitDHTDiscovery(ctx context.Context, host host.Host, kademliaDHT *dht.Ipfs
 
 	return peerChan
 }
+
+// MakeLANHostWithIdentity listens without relays or the DHT, for peers found
+// through mDNS on the local network
+func MakeLANHostWithIdentity(listenPort int, prv crypto.PrivKey) (host.Host, error) {
+	return libp2p.New(
+		libp2p.ListenAddrStrings(
+			fmt.Sprintf("/ip4/0.0.0.0/tcp/%d", listenPort),
+			fmt.Sprintf("/ip6/::/tcp/%d", listenPort),
+		),
+		libp2p.Identity(prv),
+		libp2p.Muxer("/yamux/1.0.0", yamux.DefaultTransport),
+		libp2p.Security(tls.ID, tls.New),
+	)
+}
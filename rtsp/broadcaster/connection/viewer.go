@@ -98,6 +98,27 @@ func (v *Viewer) GetVideoList(start time.Time, end time.Time) []video.Video {
 	return videoList
 }
 
+func (v *Viewer) GetSignatureChain(start time.Time, end time.Time) []video.ChainEntry {
+	stream, err := (*v.Host).NewStream(context.Background(), (*v.Info).ID, "/get-signature-chain/1.0.0")
+	if err != nil {
+		log.Println(err)
+		return []video.ChainEntry{}
+	}
+	defer stream.Close()
+	dateRange := fmt.Sprintf("%s-%s", start.Format("2006-01-02"), end.Format("2006-01-02"))
+	stream.Write([]byte(dateRange + "\n"))
+	data, err := io.ReadAll(stream)
+	if err != nil {
+		log.Printf("Error reading stream: %v", err)
+		return []video.ChainEntry{}
+	}
+	chain := []video.ChainEntry{}
+	if err := json.Unmarshal(data, &chain); err != nil {
+		log.Printf("Error parsing signature chain: %v", err)
+	}
+	return chain
+}
+
 func (v *Viewer) GetVideo(name string) []byte {
 	stream, err := (*v.Host).NewStream(context.Background(), (*v.Info).ID, "/get-video/1.0.0")
 	if err != nil {
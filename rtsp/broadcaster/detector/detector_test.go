@@ -0,0 +1,88 @@
+package detector
+
+import (
+	"net"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"strzcam.com/broadcaster/frame"
+)
+
+const person = 0
+
+func serveFake(t *testing.T, fake *Fake) string {
+	address := filepath.Join(t.TempDir(), "detector.sock")
+	listener, err := net.Listen("unix", address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+	go Serve(listener, fake.Detect)
+	return address
+}
+
+func testFrame() frame.Frame {
+	return frame.Frame{Width: 64, Height: 36, Detected: -1, Data: make([]byte, 64*36*3/2)}
+}
+
+func TestPlugin(t *testing.T) {
+	box := frame.Box{Class: person, X: 10, Y: 5, Width: 20, Height: 30, Confidence: 0.87}
+	fake := &Fake{Boxes: []frame.Box{box}}
+	plugin := &Plugin{Address: serveFake(t, fake), Timeout: time.Second}
+	defer plugin.Close()
+	for range 2 {
+		boxes, err := plugin.Detect(testFrame())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(boxes) != 1 || !reflect.DeepEqual(boxes[0], box) {
+			t.Errorf("Expected %+v, got %+v", box, boxes)
+		}
+	}
+
+	fake.SetDelay(100 * time.Millisecond)
+	plugin.Timeout = 20 * time.Millisecond
+	if _, err := plugin.Detect(testFrame()); err == nil {
+		t.Error("Expected a slow detector to time out")
+	}
+	fake.SetDelay(0)
+	plugin.Timeout = time.Second
+	if boxes, err := plugin.Detect(testFrame()); err != nil || len(boxes) != 1 {
+		t.Errorf("Expected the plugin to reconnect, got %v %v", boxes, err)
+	}
+}
+
+func TestScheduler(t *testing.T) {
+	box := frame.Box{Class: person, Width: 10, Height: 10, Confidence: 0.9}
+	fast := &Fake{Boxes: []frame.Box{box}}
+	slow := &Fake{Boxes: []frame.Box{{Class: 2, Width: 10, Height: 10, Confidence: 0.5}}, Delay: 200 * time.Millisecond}
+	scheduler := NewScheduler(Config{
+		Addresses: []string{serveFake(t, fast), serveFake(t, slow), filepath.Join(t.TempDir(), "missing.sock")},
+		Fps:       100,
+		Timeout:   time.Second,
+		MaxAge:    time.Second,
+	})
+	defer scheduler.Close()
+	deadline := time.Now().Add(500 * time.Millisecond)
+	for time.Now().Before(deadline) {
+		scheduler.Submit(testFrame())
+		time.Sleep(time.Millisecond)
+	}
+	// frames are dropped for the slow detector instead of queuing
+	if frames := slow.Frames(); frames < 1 || frames > 3 {
+		t.Errorf("Expected the slow detector to get the latest frames only, got %d", frames)
+	}
+	if fast.Frames() <= slow.Frames() {
+		t.Errorf("Expected the slow detector not to hold back the fast one, got %d and %d", fast.Frames(), slow.Frames())
+	}
+	f := scheduler.Apply(testFrame(), time.Now())
+	if len(f.Boxes) != 2 || f.Detected != person {
+		t.Errorf("Expected boxes of both detectors and the most confident class, got %+v", f)
+	}
+	if f = scheduler.Apply(testFrame(), time.Now().Add(time.Minute)); len(f.Boxes) != 0 {
+		t.Errorf("Expected old boxes to be ignored, got %+v", f.Boxes)
+	}
+	scheduler.Close()
+}
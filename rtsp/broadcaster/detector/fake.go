@@ -0,0 +1,42 @@
+package detector
+
+import (
+	"sync"
+	"time"
+
+	"strzcam.com/broadcaster/frame"
+)
+
+// Fake is a detector for tests, serve its Detect to find Boxes in every
+// frame after Delay, change Delay with SetDelay once it serves
+type Fake struct {
+	Boxes  []frame.Box
+	Delay  time.Duration
+	mu     sync.Mutex
+	frames int
+}
+
+func (f *Fake) Detect(fr frame.Frame) []frame.Box {
+	f.mu.Lock()
+	delay := f.Delay
+	f.mu.Unlock()
+	time.Sleep(delay)
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.frames++
+	return f.Boxes
+}
+
+// SetDelay changes how long detections take
+func (f *Fake) SetDelay(delay time.Duration) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.Delay = delay
+}
+
+// Frames is the number of frames the fake got
+func (f *Fake) Frames() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.frames
+}
@@ -0,0 +1,165 @@
+package detector
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"time"
+
+	"strzcam.com/broadcaster/frame"
+)
+
+// Messages are length prefixed, every number is little endian:
+//
+//	request:  uint32 length, uint32 id, uint32 width, uint32 height, YUV 4:2:0 frame
+//	response: uint32 length, uint32 id, uint16 box count, boxes
+//
+// where length counts the bytes after it and a box is int8 class, uint16 x,
+// y, width, height in pixels and uint8 confidence in percent, the trailer
+// the camera writes to shared memory. A plugin answers requests of one
+// connection in order.
+
+// largest message accepted, a 4K frame with some room
+const maxMessageSize = 16 << 20
+
+func network(address string) string {
+	if strings.Contains(address, "/") {
+		return "unix"
+	}
+	return "tcp"
+}
+
+func writeMessage(w io.Writer, message []byte) error {
+	header := binary.LittleEndian.AppendUint32(nil, uint32(len(message)))
+	if _, err := w.Write(header); err != nil {
+		return err
+	}
+	_, err := w.Write(message)
+	return err
+}
+
+func readMessage(r io.Reader) ([]byte, error) {
+	var header [4]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return nil, err
+	}
+	length := binary.LittleEndian.Uint32(header[:])
+	if length > maxMessageSize {
+		return nil, fmt.Errorf("message of %d bytes is too large", length)
+	}
+	message := make([]byte, length)
+	_, err := io.ReadFull(r, message)
+	return message, err
+}
+
+func encodeRequest(id uint32, f frame.Frame) []byte {
+	message := make([]byte, 12, 12+len(f.Data))
+	binary.LittleEndian.PutUint32(message[0:4], id)
+	binary.LittleEndian.PutUint32(message[4:8], f.Width)
+	binary.LittleEndian.PutUint32(message[8:12], f.Height)
+	return append(message, f.Data...)
+}
+
+func decodeRequest(message []byte) (uint32, frame.Frame, error) {
+	if len(message) < 12 {
+		return 0, frame.Frame{}, errors.New("request is too short")
+	}
+	f := frame.Frame{
+		Width:    binary.LittleEndian.Uint32(message[4:8]),
+		Height:   binary.LittleEndian.Uint32(message[8:12]),
+		Data:     message[12:],
+		Detected: -1,
+	}
+	return binary.LittleEndian.Uint32(message[0:4]), f, nil
+}
+
+// Plugin is a connection to one detector, it reconnects after errors
+type Plugin struct {
+	Address string // unix socket path or host:port
+	Timeout time.Duration
+	conn    net.Conn
+	nextID  uint32
+}
+
+// Detect sends the frame and waits for its boxes at most Timeout
+func (p *Plugin) Detect(f frame.Frame) ([]frame.Box, error) {
+	boxes, err := p.detect(f)
+	if err != nil && p.conn != nil {
+		// a late answer would be read as the answer to the next frame
+		p.conn.Close()
+		p.conn = nil
+	}
+	return boxes, err
+}
+
+func (p *Plugin) detect(f frame.Frame) ([]frame.Box, error) {
+	deadline := time.Now().Add(p.Timeout)
+	if p.conn == nil {
+		conn, err := net.DialTimeout(network(p.Address), p.Address, p.Timeout)
+		if err != nil {
+			return nil, err
+		}
+		p.conn = conn
+	}
+	if err := p.conn.SetDeadline(deadline); err != nil {
+		return nil, err
+	}
+	p.nextID++
+	if err := writeMessage(p.conn, encodeRequest(p.nextID, f)); err != nil {
+		return nil, err
+	}
+	message, err := readMessage(p.conn)
+	if err != nil {
+		return nil, err
+	}
+	if len(message) < 4 || binary.LittleEndian.Uint32(message) != p.nextID {
+		return nil, errors.New("unexpected response")
+	}
+	return frame.ParseBoxes(message[4:]), nil
+}
+
+func (p *Plugin) Close() error {
+	if p.conn == nil {
+		return nil
+	}
+	err := p.conn.Close()
+	p.conn = nil
+	return err
+}
+
+// DetectFunc finds objects in a YUV 4:2:0 frame
+type DetectFunc func(f frame.Frame) []frame.Box
+
+// Serve answers detector requests on the listener until it is closed, it
+// implements the plugin side for detectors written in Go
+func Serve(listener net.Listener, detect DetectFunc) error {
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			return err
+		}
+		go serveConn(conn, detect)
+	}
+}
+
+func serveConn(conn net.Conn, detect DetectFunc) {
+	defer conn.Close()
+	for {
+		message, err := readMessage(conn)
+		if err != nil {
+			return
+		}
+		id, f, err := decodeRequest(message)
+		if err != nil {
+			return
+		}
+		response := binary.LittleEndian.AppendUint32(nil, id)
+		response = frame.AppendBoxes(response, detect(f))
+		if err := writeMessage(conn, response); err != nil {
+			return
+		}
+	}
+}
@@ -0,0 +1,136 @@
+package detector
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	"strzcam.com/broadcaster/frame"
+)
+
+type Config struct {
+	Addresses []string      // one plugin per address
+	Fps       float64       // frames sent to every plugin per second
+	Timeout   time.Duration // of one detection
+	MaxAge    time.Duration // boxes older than this are not added to frames
+}
+
+// Scheduler sends frames to plugins at the configured rate. A plugin busy
+// with a frame gets only the latest one when it is done, older frames are
+// dropped, so a slow plugin never delays the camera or other plugins.
+type Scheduler struct {
+	config  Config
+	runners []*runner
+	closed  sync.Once
+}
+
+type runner struct {
+	plugin   *Plugin
+	interval time.Duration
+	frames   chan frame.Frame // latest frame only
+	mu       sync.Mutex
+	boxes    []frame.Box
+	width    uint32
+	height   uint32
+	detected time.Time
+	failures int
+}
+
+func NewScheduler(config Config) *Scheduler {
+	scheduler := &Scheduler{config: config}
+	interval := time.Duration(float64(time.Second) / max(config.Fps, 0.01))
+	for _, address := range config.Addresses {
+		r := &runner{
+			plugin:   &Plugin{Address: address, Timeout: config.Timeout},
+			interval: interval,
+			frames:   make(chan frame.Frame, 1),
+		}
+		scheduler.runners = append(scheduler.runners, r)
+		go r.run()
+	}
+	return scheduler
+}
+
+// Submit offers the frame to every plugin without waiting, it must be called
+// from one goroutine
+func (s *Scheduler) Submit(f frame.Frame) {
+	for _, r := range s.runners {
+		select {
+		case <-r.frames:
+		default:
+		}
+		r.frames <- f
+	}
+}
+
+// Apply adds recent boxes of plugins to the frame, a frame without a
+// detection gets the class of the most confident box
+func (s *Scheduler) Apply(f frame.Frame, now time.Time) frame.Frame {
+	var added []frame.Box
+	for _, r := range s.runners {
+		r.mu.Lock()
+		if now.Sub(r.detected) <= s.config.MaxAge && r.width == f.Width && r.height == f.Height {
+			added = append(added, r.boxes...)
+		}
+		r.mu.Unlock()
+	}
+	if len(added) == 0 {
+		return f
+	}
+	f.Boxes = append(append([]frame.Box{}, f.Boxes...), added...)
+	if f.Detected == -1 {
+		best := added[0]
+		for _, box := range added[1:] {
+			if box.Confidence > best.Confidence {
+				best = box
+			}
+		}
+		f.Detected = best.Class
+	}
+	return f
+}
+
+// Close stops sending frames, plugins are disconnected, closing again does
+// nothing
+func (s *Scheduler) Close() {
+	s.closed.Do(func() {
+		for _, r := range s.runners {
+			close(r.frames)
+		}
+	})
+}
+
+func (r *runner) run() {
+	defer r.plugin.Close()
+	next := time.Now()
+	for f := range r.frames {
+		if wait := time.Until(next); wait > 0 {
+			time.Sleep(wait)
+			// a newer frame came while waiting
+			select {
+			case newer, ok := <-r.frames:
+				if !ok {
+					return
+				}
+				f = newer
+			default:
+			}
+		}
+		next = time.Now().Add(r.interval)
+		boxes, err := r.plugin.Detect(f)
+		if err != nil {
+			if r.failures == 0 {
+				log.Printf("Detector %s failed: %v", r.plugin.Address, err)
+			}
+			r.failures++
+			continue
+		}
+		if r.failures > 0 {
+			log.Printf("Detector %s recovered after %d failures", r.plugin.Address, r.failures)
+			r.failures = 0
+		}
+		r.mu.Lock()
+		r.boxes, r.width, r.height, r.detected = boxes, f.Width, f.Height, time.Now()
+		r.mu.Unlock()
+	}
+}
@@ -0,0 +1,181 @@
+package encryption
+
+import (
+	"bytes"
+	"crypto/rand"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+func newTestKey(t *testing.T) *Key {
+	secret := make([]byte, KeySize)
+	rand.Read(secret)
+	key, err := NewKey(secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func TestRoundTrip(t *testing.T) {
+	keyring := NewKeyring(newTestKey(t))
+	for _, size := range []int{0, 1, BlockSize - 1, BlockSize, BlockSize + 1, 3*BlockSize + 17} {
+		plaintext := make([]byte, size)
+		rand.Read(plaintext)
+		path := filepath.Join(t.TempDir(), "video.mp4")
+		if err := WriteFile(path, plaintext, keyring); err != nil {
+			t.Fatal(err)
+		}
+		stored, _ := os.ReadFile(path)
+		if size > 16 && bytes.Contains(stored, plaintext[:16]) {
+			t.Fatalf("Plaintext of size %d is stored in clear", size)
+		}
+		data, err := ReadFile(path, keyring)
+		if err != nil {
+			t.Fatalf("Size %d: %v", size, err)
+		}
+		if !bytes.Equal(data, plaintext) {
+			t.Errorf("Size %d: decrypted data differs", size)
+		}
+	}
+}
+
+func TestSeek(t *testing.T) {
+	keyring := NewKeyring(newTestKey(t))
+	plaintext := make([]byte, 3*BlockSize+100)
+	rand.Read(plaintext)
+	path := filepath.Join(t.TempDir(), "video.mp4")
+	if err := WriteFile(path, plaintext, keyring); err != nil {
+		t.Fatal(err)
+	}
+	file, err := Open(path, keyring)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	if file.Size() != int64(len(plaintext)) {
+		t.Fatalf("Expected size %d, got %d", len(plaintext), file.Size())
+	}
+	offset := int64(2*BlockSize - 10)
+	if _, err := file.Seek(offset, io.SeekStart); err != nil {
+		t.Fatal(err)
+	}
+	buf := make([]byte, 20)
+	if _, err := io.ReadFull(file, buf); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(buf, plaintext[offset:offset+20]) {
+		t.Error("Read across block boundary after seek returned wrong data")
+	}
+	if _, err := file.Seek(-5, io.SeekEnd); err != nil {
+		t.Fatal(err)
+	}
+	rest, _ := io.ReadAll(file)
+	if !bytes.Equal(rest, plaintext[len(plaintext)-5:]) {
+		t.Error("Read after seek from end returned wrong data")
+	}
+}
+
+func TestParallelReadAt(t *testing.T) {
+	keyring := NewKeyring(newTestKey(t))
+	plaintext := make([]byte, 4*BlockSize)
+	rand.Read(plaintext)
+	path := filepath.Join(t.TempDir(), "video.mp4")
+	if err := WriteFile(path, plaintext, keyring); err != nil {
+		t.Fatal(err)
+	}
+	file, err := Open(path, keyring)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	var wg sync.WaitGroup
+	for i := range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			buf := make([]byte, 100)
+			for j := range 50 {
+				offset := int64((i+j)%4*BlockSize + j)
+				if _, err := file.ReadAt(buf, offset); err != nil {
+					t.Error(err)
+					return
+				}
+				if !bytes.Equal(buf, plaintext[offset:offset+100]) {
+					t.Errorf("ReadAt %d returned wrong data", offset)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestTampering(t *testing.T) {
+	keyring := NewKeyring(newTestKey(t))
+	plaintext := make([]byte, 2*BlockSize+10)
+	path := filepath.Join(t.TempDir(), "video.mp4")
+	if err := WriteFile(path, plaintext, keyring); err != nil {
+		t.Fatal(err)
+	}
+	stored, _ := os.ReadFile(path)
+
+	t.Run("flipped bit", func(t *testing.T) {
+		altered := bytes.Clone(stored)
+		altered[HeaderSize+BlockSize+5] ^= 1
+		os.WriteFile(path, altered, 0644)
+		if _, err := ReadFile(path, keyring); !errors.Is(err, ErrCorrupted) {
+			t.Errorf("Expected corruption error, got %v", err)
+		}
+	})
+	t.Run("truncated at block boundary", func(t *testing.T) {
+		os.WriteFile(path, stored[:HeaderSize+2*(BlockSize+tagSize)], 0644)
+		if _, err := ReadFile(path, keyring); !errors.Is(err, ErrCorrupted) {
+			t.Errorf("Expected corruption error, got %v", err)
+		}
+	})
+	t.Run("unknown key", func(t *testing.T) {
+		os.WriteFile(path, stored, 0644)
+		if _, err := ReadFile(path, NewKeyring(newTestKey(t))); err == nil {
+			t.Error("Expected error for unknown key")
+		}
+	})
+}
+
+func TestRewrite(t *testing.T) {
+	oldKey, newKey := newTestKey(t), newTestKey(t)
+	plaintext := []byte("frame data")
+	dir := t.TempDir()
+	clear := filepath.Join(dir, "clear.yuv")
+	old := filepath.Join(dir, "old.yuv")
+	os.WriteFile(clear, plaintext, 0644)
+	if err := WriteFile(old, plaintext, NewKeyring(oldKey)); err != nil {
+		t.Fatal(err)
+	}
+	keyring := NewKeyring(newKey, oldKey)
+	for _, path := range []string{clear, old} {
+		changed, err := Rewrite(path, keyring)
+		if err != nil || !changed {
+			t.Fatalf("Expected %s to be rewritten, got %t %v", path, changed, err)
+		}
+		data, err := ReadFile(path, NewKeyring(newKey))
+		if err != nil || !bytes.Equal(data, plaintext) {
+			t.Errorf("Expected %s readable with the new key only, got %v", path, err)
+		}
+		if changed, _ := Rewrite(path, keyring); changed {
+			t.Errorf("Expected %s to be skipped on second rotation", path)
+		}
+	}
+	t.Run("plain files pass through", func(t *testing.T) {
+		path := filepath.Join(dir, "plain.yuv")
+		os.WriteFile(path, plaintext, 0644)
+		data, err := ReadFile(path, NewKeyring(nil))
+		if err != nil || !bytes.Equal(data, plaintext) {
+			t.Errorf("Expected plain file to be read as is, got %v", err)
+		}
+	})
+}
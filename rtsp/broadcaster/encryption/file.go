@@ -0,0 +1,174 @@
+package encryption
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+)
+
+// File is a plaintext view of a file on disk, encrypted or not
+type File interface {
+	io.ReadSeeker
+	io.ReaderAt
+	io.Closer
+	Size() int64
+	Encrypted() bool
+}
+
+type plainFile struct {
+	*os.File
+	size int64
+}
+
+func (f *plainFile) Size() int64 {
+	return f.size
+}
+
+func (f *plainFile) Encrypted() bool {
+	return false
+}
+
+type encryptedFile struct {
+	*Reader
+	file *os.File
+}
+
+func (f *encryptedFile) Close() error {
+	return f.file.Close()
+}
+
+func (f *encryptedFile) Encrypted() bool {
+	return true
+}
+
+// Open decrypts files written by Create, files stored in clear are passed
+// through so existing recordings stay readable after enabling encryption.
+func Open(path string, keyring *Keyring) (File, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	if !IsEncrypted(file) {
+		return &plainFile{File: file, size: info.Size()}, nil
+	}
+	reader, err := NewReader(file, info.Size(), keyring)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	return &encryptedFile{Reader: reader, file: file}, nil
+}
+
+func ReadFile(path string, keyring *Keyring) ([]byte, error) {
+	file, err := Open(path, keyring)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	data := make([]byte, file.Size())
+	if _, err := io.ReadFull(file, data); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+type encryptingFile struct {
+	*Writer
+	file *os.File
+}
+
+func (f *encryptingFile) Close() error {
+	err := f.Writer.Close()
+	return errors.Join(err, f.file.Close())
+}
+
+// Create writes with the current key, or in clear when encryption is disabled
+func Create(path string, keyring *Keyring) (io.WriteCloser, error) {
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	return encrypting(file, keyring)
+}
+
+// CreateTemp is Create for a new file in the temporary directory, it returns
+// the path next to the writer
+func CreateTemp(pattern string, keyring *Keyring) (io.WriteCloser, string, error) {
+	file, err := os.CreateTemp("", pattern)
+	if err != nil {
+		return nil, "", err
+	}
+	writer, err := encrypting(file, keyring)
+	if err != nil {
+		return nil, "", err
+	}
+	return writer, file.Name(), nil
+}
+
+func encrypting(file *os.File, keyring *Keyring) (io.WriteCloser, error) {
+	if !keyring.Enabled() {
+		return file, nil
+	}
+	writer, err := NewWriter(file, keyring.Current())
+	if err != nil {
+		file.Close()
+		os.Remove(file.Name())
+		return nil, err
+	}
+	return &encryptingFile{Writer: writer, file: file}, nil
+}
+
+func WriteFile(path string, data []byte, keyring *Keyring) error {
+	file, err := Create(path, keyring)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(file, bytes.NewReader(data))
+	return errors.Join(err, file.Close())
+}
+
+// Rewrite re-encrypts the file with the current key, or stores it in clear
+// when encryption is disabled. The modification time is kept as recording
+// time ranges are taken from it. It reports whether the file was changed.
+func Rewrite(path string, keyring *Keyring) (bool, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return false, err
+	}
+	src, err := Open(path, keyring)
+	if err != nil {
+		return false, err
+	}
+	defer src.Close()
+	if encrypted, ok := src.(*encryptedFile); ok && keyring.Enabled() && encrypted.KeyID() == keyring.Current().ID {
+		return false, nil
+	}
+	if !src.Encrypted() && !keyring.Enabled() {
+		return false, nil
+	}
+	tmpPath := path + ".rewrite.tmp"
+	dst, err := Create(tmpPath, keyring)
+	if err != nil {
+		return false, err
+	}
+	if _, err := io.Copy(dst, src); err != nil {
+		dst.Close()
+		os.Remove(tmpPath)
+		return false, err
+	}
+	if err := dst.Close(); err != nil {
+		os.Remove(tmpPath)
+		return false, err
+	}
+	if err := os.Chtimes(tmpPath, info.ModTime(), info.ModTime()); err != nil {
+		os.Remove(tmpPath)
+		return false, err
+	}
+	return true, os.Rename(tmpPath, path)
+}
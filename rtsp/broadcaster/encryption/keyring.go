@@ -0,0 +1,247 @@
+package encryption
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/scrypt"
+)
+
+const (
+	KeySize   = 32
+	KeyIDSize = 8
+	saltSize  = 16
+)
+
+type KeyID [KeyIDSize]byte
+
+func (id KeyID) String() string {
+	return hex.EncodeToString(id[:])
+}
+
+type Key struct {
+	ID   KeyID
+	aead cipher.AEAD
+}
+
+func NewKey(secret []byte) (*Key, error) {
+	if len(secret) != KeySize {
+		return nil, fmt.Errorf("key must be %d bytes, got %d", KeySize, len(secret))
+	}
+	block, err := aes.NewCipher(secret)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	// the ID tells which key sealed a file without revealing the key
+	sum := sha256.Sum256(append([]byte("strzcam-key-id"), secret...))
+	key := &Key{aead: aead}
+	copy(key.ID[:], sum[:KeyIDSize])
+	return key, nil
+}
+
+func DeriveKey(passphrase string, salt []byte) (*Key, error) {
+	secret, err := scrypt.Key([]byte(passphrase), salt, 1<<15, 8, 1, KeySize)
+	if err != nil {
+		return nil, err
+	}
+	return NewKey(secret)
+}
+
+func GenerateSecret() (string, error) {
+	secret := make([]byte, KeySize)
+	if _, err := rand.Read(secret); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(secret), nil
+}
+
+// Keyring encrypts with the current key and decrypts with any known key,
+// old keys stay in the keyring until files are rotated to the current one.
+type Keyring struct {
+	current *Key
+	keys    map[KeyID]*Key
+}
+
+func NewKeyring(current *Key, old ...*Key) *Keyring {
+	k := &Keyring{current: current, keys: map[KeyID]*Key{}}
+	for _, key := range append([]*Key{current}, old...) {
+		if key != nil {
+			k.keys[key.ID] = key
+		}
+	}
+	return k
+}
+
+func (k *Keyring) Enabled() bool {
+	return k != nil && k.current != nil
+}
+
+func (k *Keyring) Current() *Key {
+	return k.current
+}
+
+func (k *Keyring) Lookup(id KeyID) (*Key, error) {
+	if k != nil {
+		if key, ok := k.keys[id]; ok {
+			return key, nil
+		}
+	}
+	return nil, fmt.Errorf("no key %s in the keyring", id)
+}
+
+// ReadKeyFile reads hex encoded keys, one per line, the first is current
+func ReadKeyFile(path string) ([]*Key, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var keys []*Key
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		secret, err := hex.DecodeString(line)
+		if err != nil {
+			return nil, fmt.Errorf("invalid key in %s: %w", path, err)
+		}
+		key, err := NewKey(secret)
+		if err != nil {
+			return nil, fmt.Errorf("invalid key in %s: %w", path, err)
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+func readOrCreateSalt(path string) ([]byte, error) {
+	salt, err := os.ReadFile(path)
+	if err == nil {
+		return salt, nil
+	}
+	if !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	salt = make([]byte, saltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	log.Printf("Created new encryption salt %s, keep it together with the passphrase", path)
+	return salt, os.WriteFile(path, salt, 0600)
+}
+
+// Settings select the keys, encryption is disabled when neither KeyFile nor
+// Passphrase is set
+type Settings struct {
+	KeyFile        string   `yaml:"keyFile"`
+	Passphrase     string   `yaml:"passphrase"`
+	OldPassphrases []string `yaml:"oldPassphrases"` // still decrypt, never encrypt
+	SaltFile       string   `yaml:"saltFile"`       // ./encryption.salt when empty
+}
+
+// SettingsFromEnv reads ENCRYPTION_KEY_FILE, ENCRYPTION_PASSPHRASE,
+// ENCRYPTION_OLD_PASSPHRASES and ENCRYPTION_SALT_FILE
+func SettingsFromEnv() Settings {
+	var old []string
+	for _, p := range strings.Split(os.Getenv("ENCRYPTION_OLD_PASSPHRASES"), ",") {
+		if p != "" {
+			old = append(old, p)
+		}
+	}
+	return Settings{
+		KeyFile:        os.Getenv("ENCRYPTION_KEY_FILE"),
+		Passphrase:     os.Getenv("ENCRYPTION_PASSPHRASE"),
+		OldPassphrases: old,
+		SaltFile:       os.Getenv("ENCRYPTION_SALT_FILE"),
+	}
+}
+
+// LoadKeyring builds the keyring from the environment
+func LoadKeyring() (*Keyring, error) {
+	return LoadKeyringFrom(SettingsFromEnv())
+}
+
+func LoadKeyringFrom(settings Settings) (*Keyring, error) {
+	var keys, old []*Key
+	if settings.KeyFile != "" {
+		fileKeys, err := ReadKeyFile(settings.KeyFile)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, fileKeys...)
+	}
+	passphrase := settings.Passphrase
+	if passphrase != "" || len(settings.OldPassphrases) > 0 {
+		saltPath := settings.SaltFile
+		if saltPath == "" {
+			saltPath = "./encryption.salt"
+		}
+		salt, err := readOrCreateSalt(saltPath)
+		if err != nil {
+			return nil, err
+		}
+		if passphrase != "" {
+			key, err := DeriveKey(passphrase, salt)
+			if err != nil {
+				return nil, err
+			}
+			// the passphrase wins over the key file for new files
+			keys = append([]*Key{key}, keys...)
+		}
+		for _, p := range settings.OldPassphrases {
+			key, err := DeriveKey(p, salt)
+			if err != nil {
+				return nil, err
+			}
+			old = append(old, key)
+		}
+	}
+	if len(keys) == 0 {
+		// old keys alone still decrypt, new files are written in clear
+		return NewKeyring(nil, old...), nil
+	}
+	return NewKeyring(keys[0], append(keys[1:], old...)...), nil
+}
+
+var (
+	defaultKeyring  *Keyring
+	defaultOnce     sync.Once
+	defaultSettings *Settings
+)
+
+// Configure sets the settings of the Default keyring, it must be called
+// before the keyring is first used
+func Configure(settings Settings) {
+	defaultSettings = &settings
+}
+
+// Default returns the configured keyring, the environment is used when
+// Configure was not called. A broken configuration stops the process
+// instead of writing recordings in clear.
+func Default() *Keyring {
+	defaultOnce.Do(func() {
+		settings := SettingsFromEnv()
+		if defaultSettings != nil {
+			settings = *defaultSettings
+		}
+		keyring, err := LoadKeyringFrom(settings)
+		if err != nil {
+			log.Fatalf("Can not load encryption keys: %v", err)
+		}
+		defaultKeyring = keyring
+	})
+	return defaultKeyring
+}
@@ -0,0 +1,256 @@
+package encryption
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// Encrypted files start with a header followed by AES-GCM sealed blocks of
+// BlockSize plaintext bytes. Every block can be opened on its own, which
+// makes seeking cheap. The last block is sealed as final so truncation at a
+// block boundary is detected.
+const (
+	BlockSize  = 64 * 1024
+	magic      = "STZCENC1"
+	nonceSize  = 12
+	tagSize    = 16
+	HeaderSize = len(magic) + KeyIDSize + nonceSize
+)
+
+var ErrCorrupted = errors.New("encrypted file is corrupted or truncated")
+
+func blockNonce(base []byte, index uint64) []byte {
+	nonce := make([]byte, nonceSize)
+	copy(nonce, base)
+	var counter [8]byte
+	binary.BigEndian.PutUint64(counter[:], index)
+	for i := range counter {
+		nonce[nonceSize-8+i] ^= counter[i]
+	}
+	return nonce
+}
+
+func blockAAD(header []byte, index uint64, final bool) []byte {
+	aad := make([]byte, 0, len(header)+9)
+	aad = append(aad, header...)
+	aad = binary.BigEndian.AppendUint64(aad, index)
+	if final {
+		return append(aad, 1)
+	}
+	return append(aad, 0)
+}
+
+type Writer struct {
+	w      io.Writer
+	key    *Key
+	header []byte
+	buf    []byte
+	index  uint64
+	closed bool
+}
+
+// NewWriter writes the header immediately, Close must be called to seal
+// the final block.
+func NewWriter(w io.Writer, key *Key) (*Writer, error) {
+	header := make([]byte, 0, HeaderSize)
+	header = append(header, magic...)
+	header = append(header, key.ID[:]...)
+	base := make([]byte, nonceSize)
+	if _, err := rand.Read(base); err != nil {
+		return nil, err
+	}
+	header = append(header, base...)
+	if _, err := w.Write(header); err != nil {
+		return nil, err
+	}
+	return &Writer{w: w, key: key, header: header, buf: make([]byte, 0, BlockSize)}, nil
+}
+
+func (w *Writer) seal(plaintext []byte, final bool) error {
+	base := w.header[len(magic)+KeyIDSize:]
+	sealed := w.key.aead.Seal(nil, blockNonce(base, w.index), plaintext, blockAAD(w.header, w.index, final))
+	w.index++
+	_, err := w.w.Write(sealed)
+	return err
+}
+
+func (w *Writer) Write(p []byte) (int, error) {
+	if w.closed {
+		return 0, errors.New("write to closed encryption writer")
+	}
+	written := 0
+	for len(p) > 0 {
+		// a full block is sealed only once more data arrives, the last one is final
+		if len(w.buf) == BlockSize {
+			if err := w.seal(w.buf, false); err != nil {
+				return written, err
+			}
+			w.buf = w.buf[:0]
+		}
+		n := min(BlockSize-len(w.buf), len(p))
+		w.buf = append(w.buf, p[:n]...)
+		p = p[n:]
+		written += n
+	}
+	return written, nil
+}
+
+func (w *Writer) Close() error {
+	if w.closed {
+		return nil
+	}
+	w.closed = true
+	return w.seal(w.buf, true)
+}
+
+// Reader decrypts an encrypted file, it supports Read, ReadAt and Seek over
+// the plaintext. ReadAt may be called in parallel like on any io.ReaderAt.
+type Reader struct {
+	r          io.ReaderAt
+	key        *Key
+	header     []byte
+	cipherSize int64
+	blocks     int64
+	size       int64
+	pos        int64
+	cacheMux   sync.Mutex // guards the last opened block
+	cacheIndex int64
+	cache      []byte
+}
+
+func NewReader(r io.ReaderAt, fileSize int64, keyring *Keyring) (*Reader, error) {
+	header := make([]byte, HeaderSize)
+	if _, err := r.ReadAt(header, 0); err != nil {
+		return nil, ErrCorrupted
+	}
+	if !bytes.Equal(header[:len(magic)], []byte(magic)) {
+		return nil, errors.New("not an encrypted file")
+	}
+	var id KeyID
+	copy(id[:], header[len(magic):])
+	key, err := keyring.Lookup(id)
+	if err != nil {
+		return nil, err
+	}
+	cipherSize := fileSize - int64(HeaderSize)
+	if cipherSize < tagSize {
+		return nil, ErrCorrupted
+	}
+	sealedBlock := int64(BlockSize + tagSize)
+	blocks := (cipherSize + sealedBlock - 1) / sealedBlock
+	if cipherSize-(blocks-1)*sealedBlock < tagSize {
+		return nil, ErrCorrupted
+	}
+	return &Reader{
+		r:          r,
+		key:        key,
+		header:     header,
+		cipherSize: cipherSize,
+		blocks:     blocks,
+		size:       cipherSize - blocks*tagSize,
+		cacheIndex: -1,
+	}, nil
+}
+
+// Size returns the plaintext size
+func (r *Reader) Size() int64 {
+	return r.size
+}
+
+func (r *Reader) KeyID() KeyID {
+	return r.key.ID
+}
+
+// block returns the plaintext of a block, callers must not modify it
+func (r *Reader) block(index int64) ([]byte, error) {
+	r.cacheMux.Lock()
+	if index == r.cacheIndex {
+		cache := r.cache
+		r.cacheMux.Unlock()
+		return cache, nil
+	}
+	r.cacheMux.Unlock()
+	sealedBlock := int64(BlockSize + tagSize)
+	offset := index * sealedBlock
+	length := min(sealedBlock, r.cipherSize-offset)
+	sealed := make([]byte, length)
+	if _, err := r.r.ReadAt(sealed, int64(HeaderSize)+offset); err != nil && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+	base := r.header[len(magic)+KeyIDSize:]
+	final := index == r.blocks-1
+	plaintext, err := r.key.aead.Open(sealed[:0], blockNonce(base, uint64(index)), sealed, blockAAD(r.header, uint64(index), final))
+	if err != nil {
+		return nil, fmt.Errorf("%w: block %d", ErrCorrupted, index)
+	}
+	r.cacheMux.Lock()
+	r.cacheIndex = index
+	r.cache = plaintext
+	r.cacheMux.Unlock()
+	return plaintext, nil
+}
+
+func (r *Reader) ReadAt(p []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, errors.New("negative offset")
+	}
+	n := 0
+	for n < len(p) {
+		if off >= r.size {
+			return n, io.EOF
+		}
+		plaintext, err := r.block(off / BlockSize)
+		if err != nil {
+			return n, err
+		}
+		copied := copy(p[n:], plaintext[off%BlockSize:])
+		n += copied
+		off += int64(copied)
+	}
+	return n, nil
+}
+
+func (r *Reader) Read(p []byte) (int, error) {
+	if r.pos >= r.size {
+		return 0, io.EOF
+	}
+	n, err := r.ReadAt(p, r.pos)
+	r.pos += int64(n)
+	if errors.Is(err, io.EOF) && n > 0 {
+		err = nil
+	}
+	return n, err
+}
+
+func (r *Reader) Seek(offset int64, whence int) (int64, error) {
+	var pos int64
+	switch whence {
+	case io.SeekStart:
+		pos = offset
+	case io.SeekCurrent:
+		pos = r.pos + offset
+	case io.SeekEnd:
+		pos = r.size + offset
+	default:
+		return 0, errors.New("invalid whence")
+	}
+	if pos < 0 {
+		return 0, errors.New("negative position")
+	}
+	r.pos = pos
+	return pos, nil
+}
+
+// IsEncrypted checks the header without needing a key
+func IsEncrypted(r io.ReaderAt) bool {
+	header := make([]byte, len(magic))
+	if _, err := r.ReadAt(header, 0); err != nil {
+		return false
+	}
+	return bytes.Equal(header, []byte(magic))
+}
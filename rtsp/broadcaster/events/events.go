@@ -0,0 +1,117 @@
+package events
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	TypeDetection = "detection"
+	TypeCrossing  = "crossing" // tracked object crossed a tripwire
+	TypeHealth    = "health"   // image health condition raised or cleared
+	TypeCamera    = "camera"   // camera stopped sending frames or came back
+)
+
+type Event struct {
+	Time   time.Time `json:"time"`
+	Camera string    `json:"camera"`
+	Type   string    `json:"type"`
+	Class  int       `json:"class"`
+	Zones  []string  `json:"zones,omitempty"`
+	// highest box confidence of the class in detection events
+	Confidence float64 `json:"confidence,omitempty"`
+	// crossing events
+	Track     int    `json:"track,omitempty"`
+	Tripwire  string `json:"tripwire,omitempty"`
+	Direction string `json:"direction,omitempty"`
+	// health events
+	Condition string `json:"condition,omitempty"`
+	State     string `json:"state,omitempty"` // raised or cleared, online or offline for camera events
+}
+
+// Log appends events to one JSON lines file per day
+type Log struct {
+	mu     sync.Mutex
+	dir    string
+	Camera string
+}
+
+func NewLog(dir string, camera string) (*Log, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	return &Log{dir: dir, Camera: camera}, nil
+}
+
+func fileName(day time.Time) string {
+	return fmt.Sprintf("%s.jsonl", day.UTC().Format("2006-01-02"))
+}
+
+func (l *Log) Record(event Event) error {
+	if event.Camera == "" {
+		event.Camera = l.Camera
+	}
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+	event.Time = event.Time.UTC()
+	line, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	file, err := os.OpenFile(filepath.Join(l.dir, fileName(event.Time)), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	_, err = file.Write(append(line, '\n'))
+	return err
+}
+
+// Read returns events of the camera between start and end, oldest first.
+// An empty camera matches all of them.
+func Read(dir string, camera string, start time.Time, end time.Time) ([]Event, error) {
+	result := []Event{}
+	for day := start.UTC().Truncate(24 * time.Hour); !day.After(end); day = day.AddDate(0, 0, 1) {
+		file, err := os.Open(filepath.Join(dir, fileName(day)))
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		scanner := bufio.NewScanner(file)
+		for scanner.Scan() {
+			var event Event
+			if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+				// a line cut by a crash while appending
+				continue
+			}
+			if camera != "" && event.Camera != camera {
+				continue
+			}
+			if event.Time.Before(start) || event.Time.After(end) {
+				continue
+			}
+			result = append(result, event)
+		}
+		err = scanner.Err()
+		file.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].Time.Before(result[j].Time)
+	})
+	return result, nil
+}
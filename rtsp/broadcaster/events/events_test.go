@@ -0,0 +1,38 @@
+package events
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestRecordAndRead(t *testing.T) {
+	dir := t.TempDir()
+	log, err := NewLog(dir, "cam1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	midnight := time.Date(2025, 1, 2, 0, 0, 0, 0, time.UTC)
+	log.Record(Event{Time: midnight.Add(-time.Minute), Type: TypeDetection, Class: 1})
+	log.Record(Event{Time: midnight.Add(time.Minute), Type: TypeDetection, Class: 2})
+	log.Record(Event{Time: midnight.Add(2 * time.Minute), Camera: "cam2", Type: TypeDetection, Class: 3})
+	log.Record(Event{Time: midnight.Add(time.Hour), Type: TypeDetection, Class: 4})
+
+	// a line cut while appending is skipped
+	file, _ := os.OpenFile(filepath.Join(dir, "2025-01-02.jsonl"), os.O_APPEND|os.O_WRONLY, 0644)
+	file.WriteString(`{"time":"2025-01-02T00:03`)
+	file.Close()
+
+	found, err := Read(dir, "cam1", midnight.Add(-2*time.Minute), midnight.Add(10*time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(found) != 2 || found[0].Class != 1 || found[1].Class != 2 {
+		t.Errorf("Expected classes 1 and 2 across midnight, got %v", found)
+	}
+	all, _ := Read(dir, "", midnight, midnight.Add(10*time.Minute))
+	if len(all) != 2 {
+		t.Errorf("Expected events of all cameras, got %v", all)
+	}
+}
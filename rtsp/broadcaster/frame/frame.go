@@ -1,9 +1,74 @@
 package frame
 
+import "math"
+
 type Frame struct {
 	Data     []byte
 	Width    uint32
 	Height   uint32
 	Detected int
 	Fps      float64
+	Boxes    []Box
+}
+
+// Box is a detected object in pixels of the frame
+type Box struct {
+	Class      int
+	X          int
+	Y          int
+	Width      int
+	Height     int
+	Confidence float64  // 0-1, 0 when the detector did not send it
+	Zones      []string // include zones the box overlaps
+}
+
+// box record after the frame in shared memory: int8 class, uint16 x, y, w, h
+// and since confidence was added uint8 confidence in percent
+const (
+	boxSize               = 9
+	boxWithConfidenceSize = 10
+)
+
+// ParseBoxes reads the box trailer, a uint16 count followed by records.
+// Frames written before boxes were added have no trailer.
+func ParseBoxes(data []byte) []Box {
+	if len(data) < 2 {
+		return nil
+	}
+	count := int(uint16(data[0]) | uint16(data[1])<<8)
+	data = data[2:]
+	size := boxSize
+	if count > 0 && len(data) >= count*boxWithConfidenceSize {
+		size = boxWithConfidenceSize
+	}
+	boxes := make([]Box, 0, count)
+	for i := 0; i < count && len(data) >= size; i++ {
+		box := Box{
+			Class:  int(int8(data[0])),
+			X:      int(uint16(data[1]) | uint16(data[2])<<8),
+			Y:      int(uint16(data[3]) | uint16(data[4])<<8),
+			Width:  int(uint16(data[5]) | uint16(data[6])<<8),
+			Height: int(uint16(data[7]) | uint16(data[8])<<8),
+		}
+		if size == boxWithConfidenceSize {
+			box.Confidence = float64(data[9]) / 100
+		}
+		boxes = append(boxes, box)
+		data = data[size:]
+	}
+	return boxes
+}
+
+// AppendBoxes writes the box trailer read by ParseBoxes, with confidence
+func AppendBoxes(data []byte, boxes []Box) []byte {
+	data = append(data, byte(len(boxes)), byte(len(boxes)>>8))
+	for _, box := range boxes {
+		data = append(data, byte(int8(box.Class)))
+		for _, value := range []int{box.X, box.Y, box.Width, box.Height} {
+			value = min(max(value, 0), 0xFFFF)
+			data = append(data, byte(value), byte(value>>8))
+		}
+		data = append(data, byte(min(max(math.Round(box.Confidence*100), 0), 100)))
+	}
+	return data
 }
@@ -0,0 +1,221 @@
+package health
+
+import (
+	"bytes"
+	"math"
+	"time"
+
+	"strzcam.com/broadcaster/frame"
+)
+
+const (
+	ConditionCovered     = "covered"      // black or uniform image
+	ConditionSceneChange = "scene_change" // camera moved or view blocked
+	ConditionBlur        = "blur"         // sharpness dropped, out of focus
+	ConditionFrozen      = "frozen"       // the same image for FrozenAfter
+)
+
+var conditions = []string{ConditionCovered, ConditionSceneChange, ConditionBlur, ConditionFrozen}
+
+// sampled columns of the luma plane, keeps 1080p analysis cheap
+const sampleColumns = 320
+
+// scene cells compared against the reference
+const sceneColumns, sceneRows = 16, 9
+
+// warmup analyses before blur and scene change are checked
+const warmup = 10
+
+type Config struct {
+	Interval       time.Duration // between analysed frames
+	DarkLuma       float64       // mean luma of a black image
+	UniformStdDev  float64       // luma deviation of a uniform image
+	SceneThreshold float64       // luma change of a changed cell
+	SceneChange    float64       // changed cells fraction of a scene change
+	BlurRatio      float64       // sharpness below this part of the usual one is blur
+	FrozenAfter    time.Duration
+	RaiseAfter     time.Duration // a condition is raised after holding this long
+	ClearAfter     time.Duration // and cleared after being gone this long
+}
+
+func DefaultConfig() Config {
+	return Config{
+		Interval:       200 * time.Millisecond,
+		DarkLuma:       20,
+		UniformStdDev:  6,
+		SceneThreshold: 30,
+		SceneChange:    0.5,
+		BlurRatio:      0.35,
+		FrozenAfter:    30 * time.Second,
+		RaiseAfter:     5 * time.Second,
+		ClearAfter:     10 * time.Second,
+	}
+}
+
+type Metrics struct {
+	Mean          float64 `json:"mean"`
+	StdDev        float64 `json:"stdDev"`
+	Sharpness     float64 `json:"sharpness"`
+	BaseSharpness float64 `json:"baseSharpness"`
+	SceneChanged  float64 `json:"sceneChanged"` // fraction of changed cells
+}
+
+// Change of a condition after hysteresis
+type Change struct {
+	Condition string
+	Active    bool
+}
+
+// state applies hysteresis to a raw condition
+type state struct {
+	active  bool
+	pending time.Time // when the raw value started to differ from active
+}
+
+func (s *state) update(raw bool, now time.Time, raiseAfter time.Duration, clearAfter time.Duration) bool {
+	if raw == s.active {
+		s.pending = time.Time{}
+		return false
+	}
+	if s.pending.IsZero() {
+		s.pending = now
+	}
+	wait := raiseAfter
+	if s.active {
+		wait = clearAfter
+	}
+	if now.Sub(s.pending) < wait {
+		return false
+	}
+	s.active, s.pending = raw, time.Time{}
+	return true
+}
+
+// Monitor analyses the luma plane of incoming frames
+type Monitor struct {
+	camera string
+	config Config
+
+	lastAnalysis time.Time
+	analyses     int
+	samples      []byte
+	lastChange   time.Time // of the sampled image
+	reference    []float64 // scene cells relative to the mean
+	metrics      Metrics
+	states       map[string]*state
+	updated      time.Time
+}
+
+func NewMonitor(camera string, config Config) *Monitor {
+	states := map[string]*state{}
+	for _, condition := range conditions {
+		states[condition] = &state{}
+	}
+	return &Monitor{camera: camera, config: config, states: states}
+}
+
+// Analyze checks the frame when Interval passed since the last one and
+// returns conditions raised or cleared by it
+func (m *Monitor) Analyze(f frame.Frame, now time.Time) []Change {
+	width, height := int(f.Width), int(f.Height)
+	if width < 3 || height < 3 || len(f.Data) < width*height {
+		return nil
+	}
+	if now.Sub(m.lastAnalysis) < m.config.Interval {
+		return nil
+	}
+	m.lastAnalysis, m.updated = now, now
+	m.analyses++
+	step := max(1, width/sampleColumns)
+	columns, rows := (width-2)/step, (height-2)/step
+	samples := make([]byte, 0, columns*rows)
+	cells := make([]float64, sceneColumns*sceneRows)
+	cellCounts := make([]int, len(cells))
+	var sum, sumSquares, laplacianSum, laplacianSquares float64
+	for row := 0; row < rows; row++ {
+		y := 1 + row*step
+		for column := 0; column < columns; column++ {
+			x := 1 + column*step
+			i := y*width + x
+			value := f.Data[i]
+			samples = append(samples, value)
+			luma := float64(value)
+			sum += luma
+			sumSquares += luma * luma
+			laplacian := 4*luma - float64(f.Data[i-1]) - float64(f.Data[i+1]) - float64(f.Data[i-width]) - float64(f.Data[i+width])
+			laplacianSum += laplacian
+			laplacianSquares += laplacian * laplacian
+			cell := (row*sceneRows/rows)*sceneColumns + column*sceneColumns/columns
+			cells[cell] += luma
+			cellCounts[cell]++
+		}
+	}
+	count := float64(len(samples))
+	mean := sum / count
+	m.metrics.Mean = mean
+	m.metrics.StdDev = math.Sqrt(max(0, sumSquares/count-mean*mean))
+	laplacianMean := laplacianSum / count
+	m.metrics.Sharpness = max(0, laplacianSquares/count-laplacianMean*laplacianMean)
+	for i := range cells {
+		if cellCounts[i] > 0 {
+			cells[i] = cells[i]/float64(cellCounts[i]) - mean
+		}
+	}
+
+	if m.samples == nil || !bytes.Equal(samples, m.samples) {
+		m.lastChange = now
+	}
+	m.samples = samples
+	covered := mean < m.config.DarkLuma || m.metrics.StdDev < m.config.UniformStdDev
+	raw := map[string]bool{
+		ConditionCovered: covered,
+		ConditionFrozen:  now.Sub(m.lastChange) >= m.config.FrozenAfter,
+	}
+	if m.analyses <= warmup {
+		m.metrics.BaseSharpness += (m.metrics.Sharpness - m.metrics.BaseSharpness) / float64(m.analyses)
+		m.reference = cells
+	} else {
+		raw[ConditionBlur] = !covered && m.metrics.Sharpness < m.config.BlurRatio*m.metrics.BaseSharpness
+		raw[ConditionSceneChange] = !covered && m.sceneChanged(cells) > m.config.SceneChange
+		// the usual sharpness follows slowly, much slower while blurred
+		rate := 0.01
+		if raw[ConditionBlur] {
+			rate = 0.001
+		}
+		if !covered {
+			m.metrics.BaseSharpness += rate * (m.metrics.Sharpness - m.metrics.BaseSharpness)
+		}
+	}
+	var changes []Change
+	for _, condition := range conditions {
+		if m.states[condition].update(raw[condition], now, m.config.RaiseAfter, m.config.ClearAfter) {
+			changes = append(changes, Change{Condition: condition, Active: m.states[condition].active})
+		}
+	}
+	return changes
+}
+
+// sceneChanged returns the fraction of changed cells and slowly adapts the
+// reference, lighting changes are removed by comparing cells to the mean
+func (m *Monitor) sceneChanged(cells []float64) float64 {
+	changed := 0
+	for i, value := range cells {
+		if math.Abs(value-m.reference[i]) > m.config.SceneThreshold {
+			changed++
+		}
+		m.reference[i] += 0.005 * (value - m.reference[i])
+	}
+	m.metrics.SceneChanged = float64(changed) / float64(len(cells))
+	return m.metrics.SceneChanged
+}
+
+func (m *Monitor) Status() Status {
+	status := Status{Camera: m.camera, Active: []string{}, Metrics: m.metrics, Updated: m.updated}
+	for _, condition := range conditions {
+		if m.states[condition].active {
+			status.Active = append(status.Active, condition)
+		}
+	}
+	status.Healthy = len(status.Active) == 0
+	return status
+}
@@ -0,0 +1,131 @@
+package health
+
+import (
+	"math/rand"
+	"testing"
+	"time"
+
+	"strzcam.com/broadcaster/frame"
+)
+
+const width, height = 160, 90
+
+func testConfig() Config {
+	config := DefaultConfig()
+	config.Interval = 0
+	config.FrozenAfter = 10 * time.Second
+	config.RaiseAfter = 2 * time.Second
+	config.ClearAfter = 3 * time.Second
+	return config
+}
+
+// texturedFrame is a random scene of bright and dark areas with fine
+// detail and sensor noise
+func texturedFrame(seed int64, noise *rand.Rand) frame.Frame {
+	scene := rand.New(rand.NewSource(seed))
+	areas := make([]int, 64)
+	for i := range areas {
+		areas[i] = 40 + scene.Intn(140)
+	}
+	data := make([]byte, width*height*3/2)
+	for y := range height {
+		for x := range width {
+			data[y*width+x] = byte(areas[(y/12)*8+x/20] + scene.Intn(30) + noise.Intn(3))
+		}
+	}
+	return frame.Frame{Data: data, Width: width, Height: height}
+}
+
+func flatFrame(value byte) frame.Frame {
+	data := make([]byte, width*height*3/2)
+	for i := range data {
+		data[i] = value
+	}
+	return frame.Frame{Data: data, Width: width, Height: height}
+}
+
+// blurredFrame is a smooth gradient, lit like the textured scene
+func blurredFrame(noise *rand.Rand) frame.Frame {
+	data := make([]byte, width*height*3/2)
+	for y := range height {
+		for x := range width {
+			data[y*width+x] = byte(60 + x/2 + y/2 + noise.Intn(2))
+		}
+	}
+	return frame.Frame{Data: data, Width: width, Height: height}
+}
+
+// run feeds frames made by next once per second and returns the last status
+// with all changes
+func run(monitor *Monitor, start time.Time, seconds int, next func() frame.Frame) ([]Change, time.Time) {
+	var changes []Change
+	now := start
+	for range seconds {
+		now = now.Add(time.Second)
+		changes = append(changes, monitor.Analyze(next(), now)...)
+	}
+	return changes, now
+}
+
+func TestMonitor(t *testing.T) {
+	start := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	noise := rand.New(rand.NewSource(1))
+	healthy := func() frame.Frame { return texturedFrame(7, noise) }
+
+	t.Run("healthy scene", func(t *testing.T) {
+		monitor := NewMonitor("front", testConfig())
+		changes, _ := run(monitor, start, 60, healthy)
+		if len(changes) != 0 || !monitor.Status().Healthy {
+			t.Errorf("Expected a healthy camera, got %+v %+v", changes, monitor.Status())
+		}
+	})
+	t.Run("covered with hysteresis", func(t *testing.T) {
+		monitor := NewMonitor("front", testConfig())
+		_, now := run(monitor, start, 20, healthy)
+		// a one second dropout is not reported
+		changes, now := run(monitor, now, 1, func() frame.Frame { return flatFrame(5) })
+		changes2, now := run(monitor, now, 1, healthy)
+		if len(append(changes, changes2...)) != 0 {
+			t.Errorf("Expected a short dropout to be ignored, got %+v", changes)
+		}
+		changes, now = run(monitor, now, 5, func() frame.Frame { return flatFrame(5) })
+		if len(changes) != 1 || changes[0] != (Change{ConditionCovered, true}) {
+			t.Fatalf("Expected covered to be raised, got %+v", changes)
+		}
+		if status := monitor.Status(); status.Healthy || !status.IsActive(ConditionCovered) || status.Camera != "front" {
+			t.Errorf("Expected covered status, got %+v", status)
+		}
+		changes, _ = run(monitor, now, 5, healthy)
+		if len(changes) != 1 || changes[0] != (Change{ConditionCovered, false}) {
+			t.Errorf("Expected covered to be cleared, got %+v", changes)
+		}
+	})
+	t.Run("blur", func(t *testing.T) {
+		monitor := NewMonitor("front", testConfig())
+		_, now := run(monitor, start, 20, healthy)
+		changes, _ := run(monitor, now, 5, func() frame.Frame { return blurredFrame(noise) })
+		raised := false
+		for _, change := range changes {
+			raised = raised || change == (Change{ConditionBlur, true})
+		}
+		if !raised {
+			t.Errorf("Expected blur to be raised, got %+v", changes)
+		}
+	})
+	t.Run("scene change", func(t *testing.T) {
+		monitor := NewMonitor("front", testConfig())
+		_, now := run(monitor, start, 20, healthy)
+		changes, _ := run(monitor, now, 5, func() frame.Frame { return texturedFrame(8, noise) })
+		if len(changes) != 1 || changes[0] != (Change{ConditionSceneChange, true}) {
+			t.Errorf("Expected scene change to be raised, got %+v", changes)
+		}
+	})
+	t.Run("frozen", func(t *testing.T) {
+		monitor := NewMonitor("front", testConfig())
+		still := texturedFrame(7, noise)
+		changes, _ := run(monitor, start, 15, func() frame.Frame { return still })
+		if len(changes) != 1 || changes[0] != (Change{ConditionFrozen, true}) {
+			t.Errorf("Expected frozen to be raised, got %+v", changes)
+		}
+	})
+}
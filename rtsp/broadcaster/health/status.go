@@ -0,0 +1,58 @@
+package health
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+)
+
+// status older than this means frames stopped coming
+const staleAfter = time.Minute
+
+type Status struct {
+	Camera  string    `json:"camera"`
+	Healthy bool      `json:"healthy"`
+	Active  []string  `json:"active"` // raised conditions
+	Stale   bool      `json:"stale,omitempty"`
+	Metrics Metrics   `json:"metrics"`
+	Updated time.Time `json:"updated"`
+}
+
+// IsActive tells if the condition is raised
+func (s Status) IsActive(condition string) bool {
+	return slices.Contains(s.Active, condition)
+}
+
+// SaveStatus writes the status for other processes of the camera
+func SaveStatus(path string, status Status) error {
+	data, err := json.Marshal(status)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	tmpPath := path + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, path)
+}
+
+// LoadStatus reads the saved status, it is stale when not updated recently
+func LoadStatus(path string, now time.Time) (Status, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Status{}, err
+	}
+	var status Status
+	if err := json.Unmarshal(data, &status); err != nil {
+		return Status{}, err
+	}
+	if now.Sub(status.Updated) > staleAfter {
+		status.Stale, status.Healthy = true, false
+	}
+	return status, nil
+}
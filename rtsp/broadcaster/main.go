@@ -1,9 +1,11 @@
 package main
 
 import (
-	"fmt"
+	"os"
+
+	"strzcam.com/broadcaster/cli"
 )
 
 func main() {
-	fmt.Println("App is in progress...")
+	os.Exit(cli.Run(os.Args[1:]))
 }
@@ -0,0 +1,122 @@
+package motion
+
+import (
+	"math"
+
+	"strzcam.com/broadcaster/frame"
+)
+
+// Class is set in Frame.Detected for motion, detector classes are lower
+const Class = 127
+
+// background adapts to light changes at this rate per frame
+const learningRate = 0.05
+
+type Config struct {
+	Scale       int // frame is downscaled to cells of Scale x Scale pixels
+	Threshold   int // luma difference of a changed cell, 0-255
+	MinArea     int // changed pixels needed for motion
+	NoiseFilter int // changed neighbours a cell needs, removes single cell noise
+	MinFrames   int // consecutive frames with motion before it is reported
+}
+
+// Detector finds motion by differencing the Y plane of frames against a
+// slowly adapting background.
+type Detector struct {
+	config     Config
+	width      uint32
+	height     uint32
+	background []float64
+	cells      []float64
+	changed    []bool
+	frames     int
+}
+
+func NewDetector(config Config) *Detector {
+	if config.Scale < 1 {
+		config.Scale = 1
+	}
+	if config.MinFrames < 1 {
+		config.MinFrames = 1
+	}
+	return &Detector{config: config}
+}
+
+// Detect reports motion in the frame, the first frame only sets the background
+func (d *Detector) Detect(f frame.Frame) bool {
+	scale := d.config.Scale
+	columns, rows := int(f.Width)/scale, int(f.Height)/scale
+	if columns == 0 || rows == 0 || len(f.Data) < int(f.Width)*int(f.Height) {
+		return false
+	}
+	if f.Width != d.width || f.Height != d.height {
+		d.width, d.height = f.Width, f.Height
+		d.background = nil
+		d.cells = make([]float64, columns*rows)
+		d.changed = make([]bool, columns*rows)
+		d.frames = 0
+	}
+	d.downscale(f.Data, columns, rows)
+	if d.background == nil {
+		d.background = append([]float64{}, d.cells...)
+		return false
+	}
+	for i, value := range d.cells {
+		d.changed[i] = math.Abs(value-d.background[i]) > float64(d.config.Threshold)
+		d.background[i] += learningRate * (value - d.background[i])
+	}
+	area := d.changedCells(columns, rows) * scale * scale
+	if area < d.config.MinArea {
+		d.frames = 0
+		return false
+	}
+	d.frames++
+	return d.frames >= d.config.MinFrames
+}
+
+// downscale averages luma of every cell
+func (d *Detector) downscale(y []byte, columns int, rows int) {
+	scale := d.config.Scale
+	width := int(d.width)
+	pixels := float64(scale * scale)
+	for row := 0; row < rows; row++ {
+		for column := 0; column < columns; column++ {
+			sum := 0
+			for py := row * scale; py < (row+1)*scale; py++ {
+				line := y[py*width+column*scale : py*width+(column+1)*scale]
+				for _, value := range line {
+					sum += int(value)
+				}
+			}
+			d.cells[row*columns+column] = float64(sum) / pixels
+		}
+	}
+}
+
+// changedCells counts changed cells having at least NoiseFilter changed neighbours
+func (d *Detector) changedCells(columns int, rows int) int {
+	count := 0
+	for row := 0; row < rows; row++ {
+		for column := 0; column < columns; column++ {
+			if !d.changed[row*columns+column] {
+				continue
+			}
+			neighbours := 0
+			for dy := -1; dy <= 1; dy++ {
+				for dx := -1; dx <= 1; dx++ {
+					y, x := row+dy, column+dx
+					if (dx == 0 && dy == 0) || y < 0 || y >= rows || x < 0 || x >= columns {
+						continue
+					}
+					if d.changed[y*columns+x] {
+						neighbours++
+					}
+				}
+			}
+			if neighbours >= d.config.NoiseFilter {
+				count++
+			}
+		}
+	}
+	return count
+}
@@ -0,0 +1,67 @@
+package motion
+
+import (
+	"testing"
+
+	"strzcam.com/broadcaster/frame"
+)
+
+const width, height = 64, 48
+
+func newFrame(fill byte) frame.Frame {
+	data := make([]byte, width*height*3/2)
+	for i := range width * height {
+		data[i] = fill
+	}
+	return frame.Frame{Data: data, Width: width, Height: height, Detected: -1}
+}
+
+func drawSquare(f frame.Frame, x0 int, y0 int, size int, value byte) {
+	for y := y0; y < y0+size; y++ {
+		for x := x0; x < x0+size; x++ {
+			f.Data[y*width+x] = value
+		}
+	}
+}
+
+func TestDetect(t *testing.T) {
+	config := Config{Scale: 4, Threshold: 25, MinArea: 100, NoiseFilter: 2, MinFrames: 2}
+
+	t.Run("static scene", func(t *testing.T) {
+		detector := NewDetector(config)
+		for range 5 {
+			if detector.Detect(newFrame(100)) {
+				t.Fatal("Expected no motion in a static scene")
+			}
+		}
+	})
+	t.Run("moving object after min frames", func(t *testing.T) {
+		detector := NewDetector(config)
+		detector.Detect(newFrame(100))
+		results := []bool{}
+		for i := range 3 {
+			f := newFrame(100)
+			drawSquare(f, 8+i*4, 8, 16, 220)
+			results = append(results, detector.Detect(f))
+		}
+		if results[0] || !results[1] || !results[2] {
+			t.Errorf("Expected motion from the second frame, got %v", results)
+		}
+	})
+	t.Run("isolated noise", func(t *testing.T) {
+		detector := NewDetector(Config{Scale: 4, Threshold: 25, MinArea: 16, NoiseFilter: 2, MinFrames: 1})
+		detector.Detect(newFrame(100))
+		f := newFrame(100)
+		drawSquare(f, 4, 4, 4, 255)
+		drawSquare(f, 40, 20, 4, 255)
+		if detector.Detect(f) {
+			t.Error("Expected single changed cells to be filtered")
+		}
+	})
+	t.Run("short frame", func(t *testing.T) {
+		detector := NewDetector(config)
+		if detector.Detect(frame.Frame{Data: []byte{1}, Width: width, Height: height}) {
+			t.Error("Expected incomplete frame to be ignored")
+		}
+	})
+}
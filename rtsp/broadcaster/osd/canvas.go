@@ -0,0 +1,112 @@
+package osd
+
+import (
+	"image"
+
+	"strzcam.com/broadcaster/frame"
+)
+
+type yuv struct {
+	y, u, v byte
+}
+
+// canvas draws on the planes of a YUV 4:2:0 frame
+type canvas struct {
+	width, height int
+	y, u, v       []byte
+}
+
+func newCanvas(f frame.Frame) canvas {
+	width, height := int(f.Width), int(f.Height)
+	lumaSize := width * height
+	chromaSize := (width / 2) * (height / 2)
+	return canvas{
+		width:  width,
+		height: height,
+		y:      f.Data[:lumaSize],
+		u:      f.Data[lumaSize : lumaSize+chromaSize],
+		v:      f.Data[lumaSize+chromaSize : lumaSize+2*chromaSize],
+	}
+}
+
+// fill paints the rectangle clipped to the frame
+func (c canvas) fill(x0 int, y0 int, x1 int, y1 int, color yuv) {
+	x0, y0 = max(0, x0), max(0, y0)
+	x1, y1 = min(c.width, x1), min(c.height, y1)
+	for y := y0; y < y1; y++ {
+		row := c.y[y*c.width:]
+		for x := x0; x < x1; x++ {
+			row[x] = color.y
+		}
+	}
+	chromaWidth := c.width / 2
+	for y := y0 / 2; y < (y1+1)/2 && y < c.height/2; y++ {
+		for x := x0 / 2; x < (x1+1)/2 && x < chromaWidth; x++ {
+			c.u[y*chromaWidth+x] = color.u
+			c.v[y*chromaWidth+x] = color.v
+		}
+	}
+}
+
+func (c canvas) rectangle(x int, y int, width int, height int, thickness int, color yuv) {
+	c.fill(x, y, x+width, y+thickness, color)
+	c.fill(x, y+height-thickness, x+width, y+height, color)
+	c.fill(x, y, x+thickness, y+height, color)
+	c.fill(x+width-thickness, y, x+width, y+height, color)
+}
+
+// text draws the mask at x, y in white over a background, the background
+// is the given color or the dimmed frame
+func (c canvas) text(mask *image.Alpha, x int, y int, background *yuv) {
+	bounds := mask.Rect
+	x0, y0 := max(0, x), max(0, y)
+	x1, y1 := min(c.width, x+bounds.Dx()), min(c.height, y+bounds.Dy())
+	if background != nil {
+		c.fill(x0, y0, x1, y1, *background)
+	} else {
+		c.dim(x0, y0, x1, y1)
+	}
+	for py := y0; py < y1; py++ {
+		row := c.y[py*c.width:]
+		alphaRow := mask.Pix[(py-y)*mask.Stride:]
+		for px := x0; px < x1; px++ {
+			alpha := int(alphaRow[px-x])
+			if alpha == 0 {
+				continue
+			}
+			value := int(row[px])
+			row[px] = byte(value + (textLuma-value)*alpha/255)
+		}
+	}
+}
+
+// dim darkens the area and halves its saturation
+func (c canvas) dim(x0 int, y0 int, x1 int, y1 int) {
+	for y := y0; y < y1; y++ {
+		row := c.y[y*c.width:]
+		for x := x0; x < x1; x++ {
+			row[x] /= 2
+		}
+	}
+	chromaWidth := c.width / 2
+	for y := y0 / 2; y < (y1+1)/2 && y < c.height/2; y++ {
+		for x := x0 / 2; x < (x1+1)/2 && x < chromaWidth; x++ {
+			i := y*chromaWidth + x
+			c.u[i] = byte(128 + (int(c.u[i])-128)/2)
+			c.v[i] = byte(128 + (int(c.v[i])-128)/2)
+		}
+	}
+}
+
+// rgbToYUV uses BT.601 full range like the camera conversion
+func rgbToYUV(r int, g int, b int) yuv {
+	clamp := func(value float64) byte {
+		return byte(min(255, max(0, value+0.5)))
+	}
+	y := 0.299*float64(r) + 0.587*float64(g) + 0.114*float64(b)
+	return yuv{
+		y: clamp(y),
+		u: clamp(128 + (float64(b)-y)*0.564),
+		v: clamp(128 + (float64(r)-y)*0.713),
+	}
+}
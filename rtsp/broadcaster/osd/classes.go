@@ -0,0 +1,42 @@
+package osd
+
+import (
+	"strconv"
+
+	"strzcam.com/broadcaster/motion"
+)
+
+// classNames are the COCO classes of the YOLO detector
+var classNames = []string{
+	"person", "bicycle", "car", "motorcycle", "airplane", "bus", "train", "truck", "boat",
+	"traffic light", "fire hydrant", "stop sign", "parking meter", "bench", "bird", "cat",
+	"dog", "horse", "sheep", "cow", "elephant", "bear", "zebra", "giraffe", "backpack",
+	"umbrella", "handbag", "tie", "suitcase", "frisbee", "skis", "snowboard", "sports ball",
+	"kite", "baseball bat", "baseball glove", "skateboard", "surfboard", "tennis racket",
+	"bottle", "wine glass", "cup", "fork", "knife", "spoon", "bowl", "banana", "apple",
+	"sandwich", "orange", "broccoli", "carrot", "hot dog", "pizza", "donut", "cake", "chair",
+	"couch", "potted plant", "bed", "dining table", "toilet", "tv", "laptop", "mouse",
+	"remote", "keyboard", "cell phone", "microwave", "oven", "toaster", "sink",
+	"refrigerator", "book", "clock", "vase", "scissors", "teddy bear", "hair drier",
+	"toothbrush",
+}
+
+var palette = [][3]int{
+	{230, 25, 75}, {60, 180, 75}, {255, 225, 25}, {0, 130, 200}, {245, 130, 48},
+	{145, 30, 180}, {70, 240, 240}, {240, 50, 230}, {210, 245, 60}, {250, 190, 212},
+}
+
+func ClassName(class int) string {
+	if class == motion.Class {
+		return "motion"
+	}
+	if class >= 0 && class < len(classNames) {
+		return classNames[class]
+	}
+	return strconv.Itoa(class)
+}
+
+func classColor(class int) yuv {
+	color := palette[((class%len(palette))+len(palette))%len(palette)]
+	return rgbToYUV(color[0], color[1], color[2])
+}
@@ -0,0 +1,176 @@
+package osd
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"log"
+	"os"
+	"time"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/basicfont"
+	"golang.org/x/image/font/opentype"
+	"golang.org/x/image/math/fixed"
+	"strzcam.com/broadcaster/frame"
+)
+
+const (
+	StageOff       = "off"
+	StageRecording = "recording" // only stored frames carry the overlay
+	StageAll       = "all"       // live frames too
+)
+
+const (
+	PositionTopLeft     = "top-left"
+	PositionTopRight    = "top-right"
+	PositionBottomLeft  = "bottom-left"
+	PositionBottomRight = "bottom-right"
+)
+
+// luma of the text, the top of the video range
+const textLuma = 235
+
+type Config struct {
+	Stage      string
+	TimeFormat string // Go time layout
+	Label      string // camera name before the time
+	Position   string
+	FontPath   string  // TrueType font, a small built-in font when empty
+	FontSize   float64 // pixels, scaled to the frame height when 0
+	Boxes      bool    // detection boxes with class labels
+}
+
+// Renderer draws the overlay on YUV 4:2:0 frames. Rendered text is cached so
+// a frame costs a copy and blending of a few small areas.
+type Renderer struct {
+	config Config
+	font   *opentype.Font
+
+	height     uint32 // face is sized for frames of this height
+	face       font.Face
+	text       string
+	textMask   *image.Alpha
+	labelMasks map[string]*image.Alpha
+}
+
+func NewRenderer(config Config) (*Renderer, error) {
+	if config.TimeFormat == "" {
+		config.TimeFormat = "2006-01-02 15:04:05"
+	}
+	if config.Position == "" {
+		config.Position = PositionTopLeft
+	}
+	switch config.Position {
+	case PositionTopLeft, PositionTopRight, PositionBottomLeft, PositionBottomRight:
+	default:
+		return nil, fmt.Errorf("invalid OSD position %q", config.Position)
+	}
+	r := &Renderer{config: config}
+	if config.FontPath != "" {
+		data, err := os.ReadFile(config.FontPath)
+		if err != nil {
+			return nil, err
+		}
+		if r.font, err = opentype.Parse(data); err != nil {
+			return nil, fmt.Errorf("invalid font %s: %w", config.FontPath, err)
+		}
+	}
+	return r, nil
+}
+
+func (r *Renderer) Stage() string {
+	return r.config.Stage
+}
+
+// Render returns a copy of the frame with the overlay, the frame is not changed
+func (r *Renderer) Render(f frame.Frame, now time.Time) frame.Frame {
+	width, height := int(f.Width), int(f.Height)
+	if width == 0 || height == 0 || len(f.Data) < width*height*3/2 {
+		return f
+	}
+	if f.Height != r.height {
+		r.setFace(f.Height)
+	}
+	f.Data = bytes.Clone(f.Data)
+	canvas := newCanvas(f)
+	margin := max(4, height/100)
+	if r.config.Boxes {
+		thickness := max(2, height/360)
+		for _, box := range f.Boxes {
+			color := classColor(box.Class)
+			canvas.rectangle(box.X, box.Y, box.Width, box.Height, thickness, color)
+			label := r.labelMask(ClassName(box.Class))
+			labelY := box.Y - label.Rect.Dy()
+			if labelY < 0 {
+				labelY = box.Y
+			}
+			canvas.text(label, box.X, labelY, &color)
+		}
+	}
+	text := now.Format(r.config.TimeFormat)
+	if r.config.Label != "" {
+		text = r.config.Label + "  " + text
+	}
+	if text != r.text {
+		r.text, r.textMask = text, r.renderText(text)
+	}
+	textWidth, textHeight := r.textMask.Rect.Dx(), r.textMask.Rect.Dy()
+	x, y := margin, margin
+	switch r.config.Position {
+	case PositionTopRight:
+		x = width - margin - textWidth
+	case PositionBottomLeft:
+		y = height - margin - textHeight
+	case PositionBottomRight:
+		x, y = width-margin-textWidth, height-margin-textHeight
+	}
+	canvas.text(r.textMask, x, y, nil)
+	return f
+}
+
+func (r *Renderer) setFace(height uint32) {
+	r.height = height
+	r.text, r.textMask = "", nil
+	r.labelMasks = map[string]*image.Alpha{}
+	size := r.config.FontSize
+	if size <= 0 {
+		size = max(12, float64(height)/30)
+	}
+	r.face = basicfont.Face7x13
+	if r.font == nil {
+		return
+	}
+	face, err := opentype.NewFace(r.font, &opentype.FaceOptions{Size: size, DPI: 72, Hinting: font.HintingFull})
+	if err != nil {
+		log.Printf("Can not create OSD font face, using built-in font: %v", err)
+		return
+	}
+	r.face = face
+}
+
+func (r *Renderer) labelMask(name string) *image.Alpha {
+	mask, ok := r.labelMasks[name]
+	if !ok {
+		mask = r.renderText(name)
+		r.labelMasks[name] = mask
+	}
+	return mask
+}
+
+// renderText rasterizes the text with padding around it
+func (r *Renderer) renderText(text string) *image.Alpha {
+	metrics := r.face.Metrics()
+	padding := max(2, metrics.Height.Ceil()/6)
+	width := font.MeasureString(r.face, text).Ceil() + 2*padding
+	height := metrics.Height.Ceil() + 2*padding
+	mask := image.NewAlpha(image.Rect(0, 0, width, height))
+	drawer := font.Drawer{
+		Dst:  mask,
+		Src:  image.Opaque,
+		Face: r.face,
+		Dot:  fixed.P(padding, padding+metrics.Ascent.Ceil()),
+	}
+	drawer.DrawString(text)
+	return mask
+}
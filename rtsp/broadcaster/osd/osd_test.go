@@ -0,0 +1,87 @@
+package osd
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"strzcam.com/broadcaster/frame"
+)
+
+func newFrame(width int, height int) frame.Frame {
+	data := make([]byte, width*height*3/2)
+	for i := range data {
+		data[i] = 128
+	}
+	return frame.Frame{Data: data, Width: uint32(width), Height: uint32(height)}
+}
+
+// changed tells if any luma pixel of the area differs from the background
+func changed(f frame.Frame, x0 int, y0 int, x1 int, y1 int) bool {
+	for y := y0; y < y1; y++ {
+		for x := x0; x < x1; x++ {
+			if f.Data[y*int(f.Width)+x] != 128 {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func TestRender(t *testing.T) {
+	now := time.Date(2025, 6, 1, 12, 30, 0, 0, time.UTC)
+	t.Run("text in the chosen corner", func(t *testing.T) {
+		renderer, err := NewRenderer(Config{Label: "front", Position: PositionBottomRight})
+		if err != nil {
+			t.Fatal(err)
+		}
+		original := newFrame(640, 360)
+		rendered := renderer.Render(original, now)
+		if !bytes.Equal(original.Data, newFrame(640, 360).Data) {
+			t.Fatal("Expected the original frame to be unchanged")
+		}
+		if !changed(rendered, 320, 300, 640, 360) {
+			t.Error("Expected text in the bottom right corner")
+		}
+		if changed(rendered, 0, 0, 320, 180) {
+			t.Error("Expected nothing drawn in the top left corner")
+		}
+	})
+	t.Run("detection boxes", func(t *testing.T) {
+		renderer, _ := NewRenderer(Config{Boxes: true})
+		f := newFrame(640, 360)
+		f.Boxes = []frame.Box{{Class: 2, X: 300, Y: 200, Width: 100, Height: 80}}
+		rendered := renderer.Render(f, now)
+		if rendered.Data[250*640+300] == 128 {
+			t.Error("Expected the box edge to be drawn")
+		}
+		if changed(rendered, 320, 220, 380, 260) {
+			t.Error("Expected the box inside to be untouched")
+		}
+	})
+	t.Run("TrueType font", func(t *testing.T) {
+		renderer, err := NewRenderer(Config{FontPath: "../../../arial.ttf"})
+		if err != nil {
+			t.Skipf("font not available: %v", err)
+		}
+		if !changed(renderer.Render(newFrame(1920, 1080), now), 0, 0, 960, 200) {
+			t.Error("Expected text in the top left corner")
+		}
+	})
+	if _, err := NewRenderer(Config{Position: "center"}); err == nil {
+		t.Error("Expected an error for an invalid position")
+	}
+}
+
+func BenchmarkRender1080p(b *testing.B) {
+	renderer, err := NewRenderer(Config{Label: "front", FontPath: "../../../arial.ttf", Boxes: true})
+	if err != nil {
+		b.Skipf("font not available: %v", err)
+	}
+	f := newFrame(1920, 1080)
+	f.Boxes = []frame.Box{{Class: 0, X: 400, Y: 300, Width: 200, Height: 400}, {Class: 2, X: 1000, Y: 500, Width: 500, Height: 300}}
+	start := time.Now()
+	for i := 0; i < b.N; i++ {
+		renderer.Render(f, start.Add(time.Duration(i)*time.Second/30))
+	}
+}
@@ -0,0 +1,213 @@
+package privacy
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+
+	"strzcam.com/broadcaster/frame"
+	"strzcam.com/broadcaster/zones"
+)
+
+const (
+	StyleSolid = "solid" // black fill
+	StyleBlur  = "blur"  // coarse pixelation, details can not be recovered
+)
+
+const defaultBlockSize = 16
+
+// Mask hides a polygon of the frame, points are relative to the frame (0-1)
+type Mask struct {
+	Name      string        `json:"name"`
+	Points    []zones.Point `json:"points"`
+	Style     string        `json:"style,omitempty"`                      // solid when empty
+	BlockSize int           `json:"blockSize,omitempty" yaml:"blockSize"` // blur block in pixels, 16 when not set
+}
+
+func (m Mask) Validate() error {
+	if len(m.Points) < 3 {
+		return fmt.Errorf("mask %q needs at least 3 points", m.Name)
+	}
+	if m.Style != "" && m.Style != StyleSolid && m.Style != StyleBlur {
+		return fmt.Errorf("mask %q has invalid style %q", m.Name, m.Style)
+	}
+	return nil
+}
+
+// Load reads privacy masks of all cameras keyed by camera id, a missing file
+// means no masks
+func Load(path string) (map[string][]Mask, error) {
+	cameras := map[string][]Mask{}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return cameras, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &cameras); err != nil {
+		return nil, fmt.Errorf("invalid privacy masks file %s: %w", path, err)
+	}
+	for camera, masks := range cameras {
+		for _, mask := range masks {
+			if err := mask.Validate(); err != nil {
+				return nil, fmt.Errorf("privacy masks of %s: %w", camera, err)
+			}
+		}
+	}
+	return cameras, nil
+}
+
+// region is the part of a plane covered by a mask
+type region struct {
+	x0, y0, width, height int
+	inside                []bool
+}
+
+type compiledMask struct {
+	style     string
+	blockSize int
+	luma      region
+	chroma    region // of both U and V planes
+}
+
+// Masker applies masks to YUV 4:2:0 frames. Masks are rasterized once per
+// frame size.
+type Masker struct {
+	masks    []Mask
+	width    uint32
+	height   uint32
+	compiled []compiledMask
+}
+
+func NewMasker(masks []Mask) *Masker {
+	return &Masker{masks: masks}
+}
+
+// Apply masks the frame data in place. Frames it can not mask are refused so
+// they are never stored or sent unmasked.
+func (m *Masker) Apply(f frame.Frame) error {
+	width, height := int(f.Width), int(f.Height)
+	lumaSize := width * height
+	if width == 0 || height == 0 || len(f.Data) < lumaSize*3/2 {
+		return fmt.Errorf("can not mask a %dx%d frame of %d bytes", width, height, len(f.Data))
+	}
+	if f.Width != m.width || f.Height != m.height {
+		m.compile(width, height)
+	}
+	chromaWidth := width / 2
+	chromaSize := chromaWidth * (height / 2)
+	y := f.Data[:lumaSize]
+	u := f.Data[lumaSize : lumaSize+chromaSize]
+	v := f.Data[lumaSize+chromaSize : lumaSize+2*chromaSize]
+	for _, mask := range m.compiled {
+		if mask.style == StyleBlur {
+			pixelate(y, width, mask.luma, mask.blockSize)
+			pixelate(u, chromaWidth, mask.chroma, mask.blockSize/2)
+			pixelate(v, chromaWidth, mask.chroma, mask.blockSize/2)
+			continue
+		}
+		fill(y, width, mask.luma, 0)
+		fill(u, chromaWidth, mask.chroma, 128)
+		fill(v, chromaWidth, mask.chroma, 128)
+	}
+	return nil
+}
+
+func (m *Masker) compile(width int, height int) {
+	m.width, m.height = uint32(width), uint32(height)
+	m.compiled = make([]compiledMask, 0, len(m.masks))
+	for _, mask := range m.masks {
+		compiled := compiledMask{style: mask.Style, blockSize: mask.BlockSize}
+		if compiled.blockSize < 2 {
+			compiled.blockSize = defaultBlockSize
+		}
+		compiled.luma = rasterize(mask.Points, width, height, 1)
+		// a chroma sample is masked when any of its luma pixels is
+		compiled.chroma = rasterize(mask.Points, width/2, height/2, 2)
+		m.compiled = append(m.compiled, compiled)
+	}
+}
+
+// rasterize marks pixels of a width x height plane touched by the polygon,
+// each pixel checks scale x scale points of the luma plane
+func rasterize(points []zones.Point, width int, height int, scale int) region {
+	minX, minY, maxX, maxY := 1.0, 1.0, 0.0, 0.0
+	for _, point := range points {
+		minX, maxX = min(minX, point.X), max(maxX, point.X)
+		minY, maxY = min(minY, point.Y), max(maxY, point.Y)
+	}
+	x0 := max(0, int(minX*float64(width)))
+	y0 := max(0, int(minY*float64(height)))
+	x1 := min(width, int(maxX*float64(width))+1)
+	y1 := min(height, int(maxY*float64(height))+1)
+	if x1 <= x0 || y1 <= y0 {
+		return region{}
+	}
+	r := region{x0: x0, y0: y0, width: x1 - x0, height: y1 - y0}
+	r.inside = make([]bool, r.width*r.height)
+	lumaWidth, lumaHeight := float64(width*scale), float64(height*scale)
+	for py := 0; py < r.height; py++ {
+		for px := 0; px < r.width; px++ {
+			for sy := 0; sy < scale && !r.inside[py*r.width+px]; sy++ {
+				for sx := 0; sx < scale; sx++ {
+					center := zones.Point{
+						X: (float64((x0+px)*scale+sx) + 0.5) / lumaWidth,
+						Y: (float64((y0+py)*scale+sy) + 0.5) / lumaHeight,
+					}
+					if zones.Contains(points, center) {
+						r.inside[py*r.width+px] = true
+						break
+					}
+				}
+			}
+		}
+	}
+	return r
+}
+
+func fill(plane []byte, stride int, r region, value byte) {
+	for py := 0; py < r.height; py++ {
+		row := plane[(r.y0+py)*stride+r.x0:]
+		for px := 0; px < r.width; px++ {
+			if r.inside[py*r.width+px] {
+				row[px] = value
+			}
+		}
+	}
+}
+
+// pixelate replaces masked pixels of every block with their average, blocks
+// are aligned to the plane so they do not move with the mask
+func pixelate(plane []byte, stride int, r region, blockSize int) {
+	blockSize = max(blockSize, 1)
+	startX, startY := r.x0/blockSize*blockSize, r.y0/blockSize*blockSize
+	for by := startY; by < r.y0+r.height; by += blockSize {
+		for bx := startX; bx < r.x0+r.width; bx += blockSize {
+			sum, count := 0, 0
+			forEachInside(r, bx, by, blockSize, func(x int, y int) {
+				sum += int(plane[y*stride+x])
+				count++
+			})
+			if count == 0 {
+				continue
+			}
+			average := byte(sum / count)
+			forEachInside(r, bx, by, blockSize, func(x int, y int) {
+				plane[y*stride+x] = average
+			})
+		}
+	}
+}
+
+// forEachInside calls fn for masked pixels of the block at bx, by
+func forEachInside(r region, bx int, by int, blockSize int, fn func(x int, y int)) {
+	for y := max(by, r.y0); y < min(by+blockSize, r.y0+r.height); y++ {
+		for x := max(bx, r.x0); x < min(bx+blockSize, r.x0+r.width); x++ {
+			if r.inside[(y-r.y0)*r.width+(x-r.x0)] {
+				fn(x, y)
+			}
+		}
+	}
+}
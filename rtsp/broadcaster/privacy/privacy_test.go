@@ -0,0 +1,71 @@
+package privacy
+
+import (
+	"testing"
+
+	"strzcam.com/broadcaster/frame"
+	"strzcam.com/broadcaster/zones"
+)
+
+const width, height = 32, 16
+
+var leftHalf = []zones.Point{{X: 0, Y: 0}, {X: 0.5, Y: 0}, {X: 0.5, Y: 1}, {X: 0, Y: 1}}
+
+// newFrame has a horizontal luma gradient and constant chroma
+func newFrame() frame.Frame {
+	data := make([]byte, width*height*3/2)
+	for y := range height {
+		for x := range width {
+			data[y*width+x] = byte(100 + x)
+		}
+	}
+	for i := width * height; i < len(data); i++ {
+		data[i] = 90
+	}
+	return frame.Frame{Data: data, Width: width, Height: height}
+}
+
+func TestSolidMask(t *testing.T) {
+	f := newFrame()
+	if err := NewMasker([]Mask{{Name: "window", Points: leftHalf}}).Apply(f); err != nil {
+		t.Fatal(err)
+	}
+	for y := range height {
+		for x := range width {
+			value := f.Data[y*width+x]
+			if x < width/2 && value != 0 {
+				t.Fatalf("Expected masked pixel %d,%d to be black, got %d", x, y, value)
+			}
+			if x >= width/2 && value != byte(100+x) {
+				t.Fatalf("Expected pixel %d,%d to be untouched, got %d", x, y, value)
+			}
+		}
+	}
+	u := f.Data[width*height:]
+	if u[0] != 128 || u[width/2-1] != 90 {
+		t.Errorf("Expected only masked chroma to be neutral, got %d and %d", u[0], u[width/2-1])
+	}
+}
+
+func TestBlurMask(t *testing.T) {
+	f := newFrame()
+	masker := NewMasker([]Mask{{Name: "window", Points: leftHalf, Style: StyleBlur, BlockSize: 8}})
+	if err := masker.Apply(f); err != nil {
+		t.Fatal(err)
+	}
+	// the first block averages x 0-7
+	if value := f.Data[3*width+5]; value != 103 {
+		t.Errorf("Expected block average 103, got %d", value)
+	}
+	if value := f.Data[3*width+20]; value != 120 {
+		t.Errorf("Expected pixel outside the mask to be untouched, got %d", value)
+	}
+}
+
+func TestApplyRefusesIncompleteFrame(t *testing.T) {
+	f := newFrame()
+	f.Data = f.Data[:width*height]
+	if err := NewMasker([]Mask{{Name: "window", Points: leftHalf}}).Apply(f); err == nil {
+		t.Error("Expected an error for a frame without chroma planes")
+	}
+}
@@ -0,0 +1,200 @@
+package schedule
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+const (
+	ActionArm    = "arm"
+	ActionDisarm = "disarm"
+	ActionAway   = "away" // continuous recording until a time
+	ActionAuto   = "auto" // back to the schedule
+)
+
+// the state file is shared by processes of the camera, it is checked for
+// changes at most once per this period
+const stateCheckPeriod = time.Second
+
+// Override replaces the schedule until Until, or until it is cleared when
+// Until is zero
+type Override struct {
+	Action string    `json:"action"`
+	Until  time.Time `json:"until"`
+}
+
+func (o Override) Mode() string {
+	switch o.Action {
+	case ActionArm:
+		return ModeArmed
+	case ActionDisarm:
+		return ModeDisarmed
+	case ActionAway:
+		return ModeContinuous
+	}
+	return ""
+}
+
+func (o Override) Validate(now time.Time) error {
+	switch o.Action {
+	case ActionArm, ActionDisarm, ActionAuto:
+	case ActionAway:
+		if o.Until.IsZero() {
+			return errors.New("away needs an end time")
+		}
+	default:
+		return fmt.Errorf("invalid action %q, expected arm, disarm, away or auto", o.Action)
+	}
+	if !o.Until.IsZero() && !o.Until.After(now) {
+		return errors.New("end time is in the past")
+	}
+	return nil
+}
+
+func (o Override) isActive(now time.Time) bool {
+	return o.Until.IsZero() || now.Before(o.Until)
+}
+
+type Status struct {
+	Mode     string    `json:"mode"`
+	Source   string    `json:"source"` // schedule or override
+	Override *Override `json:"override,omitempty"`
+	TimeZone string    `json:"timeZone"`
+}
+
+// Controller evaluates the schedule with the manual override kept in a state
+// file, so every process of the camera sees the same mode
+type Controller struct {
+	schedule  Schedule
+	location  *time.Location
+	statePath string
+
+	mu        sync.Mutex
+	override  *Override
+	modTime   time.Time
+	checkedAt time.Time
+}
+
+func NewController(schedule Schedule, location *time.Location, statePath string) *Controller {
+	if location == nil {
+		location = time.Local
+	}
+	c := &Controller{schedule: schedule, location: location, statePath: statePath}
+	c.reload(time.Now())
+	return c
+}
+
+// reload reads the override when the state file changed, c.mu is held or
+// the controller is not shared yet
+func (c *Controller) reload(now time.Time) {
+	c.checkedAt = now
+	if c.statePath == "" {
+		return
+	}
+	info, err := os.Stat(c.statePath)
+	if errors.Is(err, os.ErrNotExist) {
+		c.override, c.modTime = nil, time.Time{}
+		return
+	}
+	if err != nil || info.ModTime().Equal(c.modTime) {
+		return
+	}
+	data, err := os.ReadFile(c.statePath)
+	if err != nil {
+		log.Printf("Can not read schedule state: %v", err)
+		return
+	}
+	var override Override
+	if err := json.Unmarshal(data, &override); err != nil {
+		log.Printf("Invalid schedule state %s: %v", c.statePath, err)
+		return
+	}
+	c.override, c.modTime = &override, info.ModTime()
+}
+
+// Status returns the mode at now and where it comes from
+func (c *Controller) Status(now time.Time) Status {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if now.Sub(c.checkedAt) >= stateCheckPeriod || now.Before(c.checkedAt) {
+		c.reload(now)
+	}
+	status := Status{TimeZone: c.location.String()}
+	if c.override != nil && c.override.isActive(now) {
+		override := *c.override
+		status.Mode, status.Source, status.Override = override.Mode(), "override", &override
+		return status
+	}
+	status.Mode, status.Source = c.schedule.Mode(now.In(c.location)), "schedule"
+	return status
+}
+
+// SetSchedule replaces the schedule, the override stays
+func (c *Controller) SetSchedule(schedule Schedule, location *time.Location) {
+	if location == nil {
+		location = time.Local
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.schedule, c.location = schedule, location
+}
+
+func (c *Controller) Mode(now time.Time) string {
+	return c.Status(now).Mode
+}
+
+// Apply sets or, with the auto action, clears the override
+func (c *Controller) Apply(override Override, now time.Time) (Status, error) {
+	if err := override.Validate(now); err != nil {
+		return Status{}, err
+	}
+	c.mu.Lock()
+	err := c.save(override)
+	c.mu.Unlock()
+	if err != nil {
+		return Status{}, err
+	}
+	return c.Status(now), nil
+}
+
+func (c *Controller) save(override Override) error {
+	if override.Action == ActionAuto {
+		c.override = nil
+		if c.statePath == "" {
+			return nil
+		}
+		if err := os.Remove(c.statePath); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+		c.modTime = time.Time{}
+		return nil
+	}
+	c.override = &override
+	if c.statePath == "" {
+		return nil
+	}
+	data, err := json.Marshal(override)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(c.statePath), 0755); err != nil {
+		return err
+	}
+	tmpPath := c.statePath + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0644); err != nil {
+		return err
+	}
+	if err := os.Rename(tmpPath, c.statePath); err != nil {
+		return err
+	}
+	if info, err := os.Stat(c.statePath); err == nil {
+		c.modTime = info.ModTime()
+	}
+	return nil
+}
@@ -0,0 +1,155 @@
+package schedule
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	ModeDisarmed   = "disarmed"   // detections are ignored
+	ModeArmed      = "armed"      // detections are recorded and logged
+	ModeContinuous = "continuous" // every frame is recorded, detections are logged
+)
+
+var dayNames = map[string][]time.Weekday{
+	"mon":      {time.Monday},
+	"tue":      {time.Tuesday},
+	"wed":      {time.Wednesday},
+	"thu":      {time.Thursday},
+	"fri":      {time.Friday},
+	"sat":      {time.Saturday},
+	"sun":      {time.Sunday},
+	"weekdays": {time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday},
+	"weekends": {time.Saturday, time.Sunday},
+}
+
+// Rule sets Mode on Days from Start to End, "HH:MM" in the schedule time
+// zone. A rule ending before it starts runs past midnight and belongs to the
+// day it starts on, "00:00" to "00:00" is the whole day.
+type Rule struct {
+	Days  []string `json:"days,omitempty"` // mon-sun, weekdays or weekends, every day when empty
+	Start string   `json:"start"`
+	End   string   `json:"end"`
+	Mode  string   `json:"mode"`
+}
+
+// Schedule of a camera, the first matching rule wins
+type Schedule struct {
+	Default string `json:"default,omitempty"` // mode outside rules, armed when empty
+	Rules   []Rule `json:"rules,omitempty"`
+}
+
+func IsMode(mode string) bool {
+	return mode == ModeDisarmed || mode == ModeArmed || mode == ModeContinuous
+}
+
+// parseClock returns minutes since midnight of "HH:MM"
+func parseClock(value string) (int, error) {
+	clock, err := time.Parse("15:04", value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time %q, expected HH:MM", value)
+	}
+	return clock.Hour()*60 + clock.Minute(), nil
+}
+
+func (r Rule) Validate() error {
+	if !IsMode(r.Mode) {
+		return fmt.Errorf("invalid mode %q", r.Mode)
+	}
+	for _, day := range r.Days {
+		if _, ok := dayNames[strings.ToLower(day)]; !ok {
+			return fmt.Errorf("invalid day %q", day)
+		}
+	}
+	if _, err := parseClock(r.Start); err != nil {
+		return err
+	}
+	_, err := parseClock(r.End)
+	return err
+}
+
+func (r Rule) onDay(day time.Weekday) bool {
+	if len(r.Days) == 0 {
+		return true
+	}
+	for _, name := range r.Days {
+		for _, weekday := range dayNames[strings.ToLower(name)] {
+			if weekday == day {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Matches tells if the rule covers t, t is in the schedule time zone
+func (r Rule) Matches(t time.Time) bool {
+	start, err := parseClock(r.Start)
+	if err != nil {
+		return false
+	}
+	end, err := parseClock(r.End)
+	if err != nil {
+		return false
+	}
+	minute := t.Hour()*60 + t.Minute()
+	if start < end {
+		return r.onDay(t.Weekday()) && minute >= start && minute < end
+	}
+	// past midnight, the part after midnight belongs to the day before
+	if minute >= start {
+		return r.onDay(t.Weekday())
+	}
+	return minute < end && r.onDay(t.AddDate(0, 0, -1).Weekday())
+}
+
+func (s Schedule) Validate() error {
+	if s.Default != "" && !IsMode(s.Default) {
+		return fmt.Errorf("invalid default mode %q", s.Default)
+	}
+	for i, rule := range s.Rules {
+		if err := rule.Validate(); err != nil {
+			return fmt.Errorf("rule %d: %w", i+1, err)
+		}
+	}
+	return nil
+}
+
+// Mode returns the scheduled mode at t, t is in the schedule time zone
+func (s Schedule) Mode(t time.Time) string {
+	for _, rule := range s.Rules {
+		if rule.Matches(t) {
+			return rule.Mode
+		}
+	}
+	if s.Default != "" {
+		return s.Default
+	}
+	return ModeArmed
+}
+
+// Load reads schedules of all cameras keyed by camera id, a missing file means
+// cameras are always armed
+func Load(path string) (map[string]Schedule, error) {
+	schedules := map[string]Schedule{}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return schedules, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &schedules); err != nil {
+		return nil, fmt.Errorf("invalid schedules file %s: %w", path, err)
+	}
+	for camera, schedule := range schedules {
+		if err := schedule.Validate(); err != nil {
+			return nil, fmt.Errorf("schedule of %s: %w", camera, err)
+		}
+	}
+	return schedules, nil
+}
@@ -0,0 +1,79 @@
+package schedule
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestScheduleMode(t *testing.T) {
+	// alerts only at night on weekdays, continuous recording on weekends
+	schedule := Schedule{
+		Default: ModeDisarmed,
+		Rules: []Rule{
+			{Days: []string{"weekdays"}, Start: "22:00", End: "06:00", Mode: ModeArmed},
+			{Days: []string{"weekends"}, Start: "00:00", End: "00:00", Mode: ModeContinuous},
+		},
+	}
+	if err := schedule.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	at := func(day int, hour int) time.Time {
+		// 2025-06-02 is a Monday
+		return time.Date(2025, 6, day, hour, 30, 0, 0, time.UTC)
+	}
+	tests := []struct {
+		name string
+		time time.Time
+		mode string
+	}{
+		{"monday evening", at(2, 23), ModeArmed},
+		{"tuesday early morning", at(3, 5), ModeArmed},
+		{"tuesday noon", at(3, 12), ModeDisarmed},
+		{"friday night", at(6, 23), ModeArmed},
+		{"saturday after friday night", at(7, 5), ModeArmed},
+		{"saturday noon", at(7, 12), ModeContinuous},
+		{"monday after sunday", at(9, 5), ModeDisarmed},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if mode := schedule.Mode(test.time); mode != test.mode {
+				t.Errorf("Expected %s, got %s", test.mode, mode)
+			}
+		})
+	}
+	if err := (Rule{Start: "25:00", End: "06:00", Mode: ModeArmed}).Validate(); err == nil {
+		t.Error("Expected an error for an invalid time")
+	}
+}
+
+func TestController(t *testing.T) {
+	statePath := filepath.Join(t.TempDir(), "state.json")
+	location, _ := time.LoadLocation("UTC")
+	controller := NewController(Schedule{}, location, statePath)
+	now := time.Date(2025, 6, 2, 12, 0, 0, 0, time.UTC)
+	if status := controller.Status(now); status.Mode != ModeArmed || status.Source != "schedule" {
+		t.Fatalf("Expected armed by schedule, got %+v", status)
+	}
+	if _, err := controller.Apply(Override{Action: ActionAway}, now); err == nil {
+		t.Error("Expected away without an end time to fail")
+	}
+	status, err := controller.Apply(Override{Action: ActionAway, Until: now.Add(time.Hour)}, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status.Mode != ModeContinuous || status.Source != "override" {
+		t.Errorf("Expected continuous override, got %+v", status)
+	}
+	// another process reads the override from the state file
+	other := NewController(Schedule{}, location, statePath)
+	if mode := other.Mode(now); mode != ModeContinuous {
+		t.Errorf("Expected the other controller to see the override, got %s", mode)
+	}
+	if mode := other.Mode(now.Add(2 * time.Hour)); mode != ModeArmed {
+		t.Errorf("Expected the schedule after the override ends, got %s", mode)
+	}
+	if status, _ := controller.Apply(Override{Action: ActionAuto}, now); status.Source != "schedule" {
+		t.Errorf("Expected auto to clear the override, got %+v", status)
+	}
+}
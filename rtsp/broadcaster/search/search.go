@@ -0,0 +1,224 @@
+package search
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"time"
+
+	"strzcam.com/broadcaster/events"
+	"strzcam.com/broadcaster/video"
+)
+
+const (
+	DefaultLimit = 50
+	MaxLimit     = 500
+	// detections of a class closer than this are one occurrence
+	occurrenceGap = 5 * time.Second
+	// a lasting detection is logged once per this period
+	eventPeriod = time.Second
+)
+
+// Query selects detections, empty fields match everything. After and Before
+// are "HH:MM" in TimeZone, a window ending before it starts runs past midnight.
+type Query struct {
+	Camera        string    `json:"camera,omitempty"`
+	Classes       []int     `json:"classes,omitempty"`
+	Zone          string    `json:"zone,omitempty"`
+	MinConfidence float64   `json:"minConfidence,omitempty"` // 0-1
+	Start         time.Time `json:"start"`
+	End           time.Time `json:"end"`
+	After         string    `json:"after,omitempty"`
+	Before        string    `json:"before,omitempty"`
+	TimeZone      string    `json:"timeZone,omitempty"`    // IANA name, UTC when empty
+	MinDuration   float64   `json:"minDuration,omitempty"` // seconds
+	Offset        int       `json:"offset,omitempty"`
+	Limit         int       `json:"limit,omitempty"` // DefaultLimit when 0
+}
+
+func (q Query) Validate() error {
+	if q.Start.IsZero() || q.End.IsZero() {
+		return errors.New("start and end are required")
+	}
+	if !q.End.After(q.Start) {
+		return errors.New("end must be after start")
+	}
+	if q.MinConfidence < 0 || q.MinConfidence > 1 {
+		return errors.New("minimum confidence must be between 0 and 1")
+	}
+	if q.Offset < 0 || q.Limit < 0 || q.Limit > MaxLimit {
+		return fmt.Errorf("offset must not be negative and limit at most %d", MaxLimit)
+	}
+	if (q.After == "") != (q.Before == "") {
+		return errors.New("time of day window needs both after and before")
+	}
+	if _, _, err := q.window(); err != nil {
+		return err
+	}
+	if _, err := q.location(); err != nil {
+		return errors.New("unknown time zone")
+	}
+	return nil
+}
+
+func (q Query) location() (*time.Location, error) {
+	if q.TimeZone == "" {
+		return time.UTC, nil
+	}
+	return time.LoadLocation(q.TimeZone)
+}
+
+// window returns After and Before as durations since midnight
+func (q Query) window() (time.Duration, time.Duration, error) {
+	if q.After == "" {
+		return 0, 0, nil
+	}
+	after, err := time.Parse("15:04", q.After)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid after %q, expected HH:MM", q.After)
+	}
+	before, err := time.Parse("15:04", q.Before)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid before %q, expected HH:MM", q.Before)
+	}
+	return sinceMidnight(after), sinceMidnight(before), nil
+}
+
+func sinceMidnight(t time.Time) time.Duration {
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute + time.Duration(t.Second())*time.Second
+}
+
+// Result is one occurrence of a class, Video and Offset point to the start
+// of it in the recording, Video is empty when it was not recorded
+type Result struct {
+	Camera     string    `json:"camera"`
+	Class      int       `json:"class"`
+	Start      time.Time `json:"start"`
+	End        time.Time `json:"end"`
+	Duration   float64   `json:"duration"` // seconds
+	Confidence float64   `json:"confidence,omitempty"`
+	Zones      []string  `json:"zones,omitempty"`
+	Video      string    `json:"video,omitempty"`
+	Offset     float64   `json:"offset,omitempty"` // seconds into Video
+}
+
+type Page struct {
+	Results []Result `json:"results"`
+	Total   int      `json:"total"`
+	Offset  int      `json:"offset"`
+	Limit   int      `json:"limit"`
+}
+
+// Search groups matching detection events into occurrences, oldest first,
+// and finds the recording of every occurrence on the page
+func Search(eventsDir string, videoPaths []string, query Query) (Page, error) {
+	page := Page{Results: []Result{}, Offset: query.Offset, Limit: query.Limit}
+	if page.Limit == 0 {
+		page.Limit = DefaultLimit
+	}
+	if err := query.Validate(); err != nil {
+		return page, err
+	}
+	all, err := events.Read(eventsDir, query.Camera, query.Start, query.End)
+	if err != nil {
+		return page, err
+	}
+	location, _ := query.location()
+	after, before, _ := query.window()
+	var occurrences []Result
+	open := map[string]int{} // camera and class to its last occurrence
+	for _, event := range all {
+		if event.Type != events.TypeDetection || !query.matches(event, location, after, before) {
+			continue
+		}
+		key := fmt.Sprintf("%s/%d", event.Camera, event.Class)
+		if i, ok := open[key]; ok && event.Time.Sub(occurrences[i].End) <= occurrenceGap {
+			occurrence := &occurrences[i]
+			occurrence.End = event.Time.Add(eventPeriod)
+			occurrence.Confidence = max(occurrence.Confidence, event.Confidence)
+			for _, zone := range event.Zones {
+				if !slices.Contains(occurrence.Zones, zone) {
+					occurrence.Zones = append(occurrence.Zones, zone)
+				}
+			}
+			continue
+		}
+		open[key] = len(occurrences)
+		occurrences = append(occurrences, Result{
+			Camera:     event.Camera,
+			Class:      event.Class,
+			Start:      event.Time,
+			End:        event.Time.Add(eventPeriod),
+			Confidence: event.Confidence,
+			Zones:      slices.Clone(event.Zones),
+		})
+	}
+	var results []Result
+	for _, occurrence := range occurrences {
+		occurrence.Duration = occurrence.End.Sub(occurrence.Start).Seconds()
+		if occurrence.Duration < query.MinDuration || occurrence.Confidence < query.MinConfidence {
+			continue
+		}
+		results = append(results, occurrence)
+	}
+	page.Total = len(results)
+	if page.Offset < len(results) {
+		page.Results = results[page.Offset:min(page.Offset+page.Limit, len(results))]
+	}
+	findVideos(videoPaths, query.Camera, page.Results)
+	return page, nil
+}
+
+func (q Query) matches(event events.Event, location *time.Location, after time.Duration, before time.Duration) bool {
+	if len(q.Classes) > 0 && !slices.Contains(q.Classes, event.Class) {
+		return false
+	}
+	if q.Zone != "" && !slices.Contains(event.Zones, q.Zone) {
+		return false
+	}
+	if q.After == "" {
+		return true
+	}
+	clock := sinceMidnight(event.Time.In(location))
+	if after <= before {
+		return clock >= after && clock < before
+	}
+	return clock >= after || clock < before
+}
+
+// findVideos sets the recording and offset of results, recordings of the
+// whole page are listed once
+func findVideos(videoPaths []string, camera string, results []Result) {
+	if len(results) == 0 {
+		return
+	}
+	start, end := results[0].Start, results[0].End
+	for _, result := range results {
+		end = maxTime(end, result.End)
+	}
+	sources, err := video.FindClipSources(videoPaths, video.ClipRequest{Camera: camera, Start: start, End: end})
+	if err != nil {
+		return
+	}
+	for i := range results {
+		for _, source := range sources {
+			if results[i].Camera != "" && source.Metadata.Camera != "" && source.Metadata.Camera != results[i].Camera {
+				continue
+			}
+			if source.Metadata.End.Before(results[i].Start) || source.Metadata.Start.After(results[i].End) {
+				continue
+			}
+			results[i].Video = filepath.Base(source.Path)
+			results[i].Offset = max(results[i].Start.Sub(source.Metadata.Start).Seconds(), 0)
+			break
+		}
+	}
+}
+
+func maxTime(a time.Time, b time.Time) time.Time {
+	if a.After(b) {
+		return a
+	}
+	return b
+}
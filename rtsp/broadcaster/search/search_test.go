@@ -0,0 +1,72 @@
+package search
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"strzcam.com/broadcaster/events"
+	"strzcam.com/broadcaster/video"
+)
+
+const person = 0
+
+func TestSearch(t *testing.T) {
+	eventsDir, videoDir := t.TempDir(), t.TempDir()
+	log, err := events.NewLog(eventsDir, "front")
+	if err != nil {
+		t.Fatal(err)
+	}
+	night := time.Date(2025, 6, 1, 23, 0, 0, 0, time.UTC)
+	// a person at the gate for 4 seconds, a short one and one during the day
+	for i := range 4 {
+		log.Record(events.Event{Time: night.Add(time.Duration(i) * time.Second), Type: events.TypeDetection, Class: person, Zones: []string{"gate"}, Confidence: 0.5 + float64(i)/10})
+	}
+	log.Record(events.Event{Time: night.Add(time.Minute), Type: events.TypeDetection, Class: person, Zones: []string{"gate"}, Confidence: 0.9})
+	log.Record(events.Event{Time: night.Add(-12 * time.Hour), Type: events.TypeDetection, Class: person, Zones: []string{"gate"}, Confidence: 0.9})
+	log.Record(events.Event{Time: night, Type: events.TypeDetection, Class: 2, Zones: []string{"gate"}, Confidence: 0.9})
+
+	recording := filepath.Join(videoDir, "2025-06-01-1.mp4")
+	os.WriteFile(recording, []byte("video"), 0644)
+	video.SaveVideoMetadata(recording, video.Metadata{Tier: video.TierOriginal, Codec: video.CodecH264, Camera: "front", Start: night.Add(-10 * time.Second), End: night.Add(30 * time.Second)})
+
+	query := Query{
+		Camera:  "front",
+		Classes: []int{person},
+		Zone:    "gate",
+		Start:   night.Add(-24 * time.Hour),
+		End:     night.Add(time.Hour),
+		After:   "21:00",
+		Before:  "05:00",
+	}
+	page, err := Search(eventsDir, []string{videoDir}, query)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if page.Total != 2 || len(page.Results) != 2 {
+		t.Fatalf("Expected 2 occurrences at night, got %+v", page)
+	}
+	first := page.Results[0]
+	if first.Duration != 4 || first.Confidence != 0.8 || first.Video != "2025-06-01-1.mp4" || first.Offset != 10 {
+		t.Errorf("Unexpected first occurrence %+v", first)
+	}
+	if page.Results[1].Video != "" {
+		t.Errorf("Expected the second occurrence without recording, got %+v", page.Results[1])
+	}
+
+	query.MinDuration = 3
+	query.MinConfidence = 0.7
+	if page, _ = Search(eventsDir, []string{videoDir}, query); page.Total != 1 {
+		t.Errorf("Expected only the long occurrence, got %+v", page)
+	}
+	query.MinDuration, query.MinConfidence = 0, 0
+	query.Offset, query.Limit = 1, 1
+	if page, _ = Search(eventsDir, []string{videoDir}, query); page.Total != 2 || len(page.Results) != 1 || !page.Results[0].Start.Equal(night.Add(time.Minute)) {
+		t.Errorf("Expected the second page to have the later occurrence, got %+v", page)
+	}
+	query.Before = ""
+	if err := query.Validate(); err == nil {
+		t.Error("Expected a window without its end to be rejected")
+	}
+}
@@ -0,0 +1,92 @@
+package video
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Videos are also hashed in segments so damage can be located without
+// comparing the whole file.
+const SegmentSize = 4 * 1024 * 1024
+
+type SegmentChecksum struct {
+	Offset int64  `json:"offset"`
+	Length int64  `json:"length"`
+	SHA256 string `json:"sha256"`
+}
+
+func ComputeChecksums(path string) (string, []SegmentChecksum, error) {
+	file, err := OpenVideo(path)
+	if err != nil {
+		return "", nil, err
+	}
+	defer file.Close()
+
+	fileHash := sha256.New()
+	segments := []SegmentChecksum{}
+	buffer := make([]byte, SegmentSize)
+	var offset int64
+	for {
+		n, err := io.ReadFull(file, buffer)
+		if n > 0 {
+			fileHash.Write(buffer[:n])
+			segmentHash := sha256.Sum256(buffer[:n])
+			segments = append(segments, SegmentChecksum{
+				Offset: offset,
+				Length: int64(n),
+				SHA256: hex.EncodeToString(segmentHash[:]),
+			})
+			offset += int64(n)
+		}
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			break
+		}
+		if err != nil {
+			return "", nil, fmt.Errorf("failed to read %s: %w", path, err)
+		}
+	}
+	return hex.EncodeToString(fileHash.Sum(nil)), segments, nil
+}
+
+// SetChecksums fills checksums of the finished video in its metadata
+func SetChecksums(path string, metadata *Metadata) error {
+	sum, segments, err := ComputeChecksums(path)
+	if err != nil {
+		return err
+	}
+	metadata.SHA256 = sum
+	metadata.Segments = segments
+	return nil
+}
+
+// VerifyVideo compares the video with checksums stored in its metadata.
+// Videos recorded before checksums were introduced can not be verified and pass.
+func VerifyVideo(path string) error {
+	metadata, err := ReadVideoMetadata(path)
+	if err != nil {
+		return err
+	}
+	if metadata.SHA256 == "" {
+		return nil
+	}
+	sum, segments, err := ComputeChecksums(path)
+	if err != nil {
+		return err
+	}
+	if sum == metadata.SHA256 {
+		return nil
+	}
+	var damaged []string
+	for i, segment := range metadata.Segments {
+		if i >= len(segments) || segments[i].SHA256 != segment.SHA256 || segments[i].Length != segment.Length {
+			damaged = append(damaged, fmt.Sprintf("%d-%d", segment.Offset, segment.Offset+segment.Length))
+		}
+	}
+	if len(segments) > len(metadata.Segments) {
+		damaged = append(damaged, "trailing data")
+	}
+	return fmt.Errorf("checksum mismatch for %s, damaged segments: %s", path, strings.Join(damaged, ", "))
+}
@@ -0,0 +1,76 @@
+package video
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func createTestVideo(t *testing.T, dir string, data []byte) string {
+	path := filepath.Join(dir, "2025-01-01-1.mp4")
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+	metadata := Metadata{Tier: TierOriginal, Codec: CodecH264}
+	if err := SetChecksums(path, &metadata); err != nil {
+		t.Fatal(err)
+	}
+	if err := SaveVideoMetadata(path, metadata); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestVerifyVideo(t *testing.T) {
+	t.Run("untouched video", func(t *testing.T) {
+		path := createTestVideo(t, t.TempDir(), make([]byte, SegmentSize+10))
+		if err := VerifyVideo(path); err != nil {
+			t.Errorf("Expected video to pass verification, got %v", err)
+		}
+	})
+	t.Run("damaged segment", func(t *testing.T) {
+		path := createTestVideo(t, t.TempDir(), make([]byte, SegmentSize+10))
+		file, _ := os.OpenFile(path, os.O_WRONLY, 0644)
+		file.WriteAt([]byte{1}, SegmentSize+1)
+		file.Close()
+		err := VerifyVideo(path)
+		if err == nil {
+			t.Fatal("Expected checksum mismatch")
+		}
+		expected := "4194304-4194314"
+		if !strings.Contains(err.Error(), expected) {
+			t.Errorf("Expected damaged segment %s in %v", expected, err)
+		}
+	})
+}
+
+func TestScrub(t *testing.T) {
+	root := t.TempDir()
+	stream := []byte{0, 0, 0, 1, 0x67, 0x42, 0, 0, 0, 1, 0x65, 0x88, 0x84}
+	good := createTestVideo(t, root, stream)
+	bad := filepath.Join(root, "2025-01-02-1.mp4")
+	if err := os.WriteFile(bad, []byte("not a video"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	results := Scrub([]string{root}, true)
+	if len(results) != 2 {
+		t.Fatalf("Expected 2 results, got %d", len(results))
+	}
+	for _, result := range results {
+		switch result.Path {
+		case good:
+			if result.Err != nil {
+				t.Errorf("Expected valid video, got %v", result.Err)
+			}
+		case bad:
+			if result.Err == nil || !result.Quarantined {
+				t.Errorf("Expected damaged video to be quarantined, got %v", result)
+			}
+		}
+	}
+	if _, err := os.Stat(filepath.Join(root, QuarantineDir, "2025-01-02-1.mp4")); err != nil {
+		t.Errorf("Expected video in quarantine: %v", err)
+	}
+}
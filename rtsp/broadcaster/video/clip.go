@@ -0,0 +1,301 @@
+package video
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"strzcam.com/broadcaster/encryption"
+)
+
+type ClipRequest struct {
+	Camera string    `json:"camera"`
+	Start  time.Time `json:"start"`
+	End    time.Time `json:"end"`
+}
+
+func (r ClipRequest) Validate() error {
+	if !r.End.After(r.Start) {
+		return errors.New("clip end must be after its start")
+	}
+	if r.End.Sub(r.Start) > 24*time.Hour {
+		return errors.New("clip can not be longer than a day")
+	}
+	return nil
+}
+
+// ClipSource is a recording overlapping the requested time range
+type ClipSource struct {
+	Path     string
+	Metadata Metadata
+}
+
+// FindClipSources returns recordings of the camera overlapping the range,
+// oldest first. Recordings without a time range in metadata are skipped.
+func FindClipSources(paths []string, request ClipRequest) ([]ClipSource, error) {
+	// a recording may have started the day before
+	startDate := request.Start.UTC().Truncate(24*time.Hour).AddDate(0, 0, -1)
+	endDate := request.End.UTC().Truncate(24 * time.Hour)
+	videos, err := GetVideoByDateRange(paths, startDate, endDate)
+	if err != nil {
+		return nil, err
+	}
+	var sources []ClipSource
+	for _, v := range videos {
+		if !IsFootage(v.Type) {
+			continue
+		}
+		path, err := FindVideo(paths, v.Name)
+		if err != nil {
+			continue
+		}
+		metadata, err := ReadVideoMetadata(path)
+		if err != nil || metadata.Start.IsZero() {
+			continue
+		}
+		if request.Camera != "" && metadata.Camera != request.Camera {
+			continue
+		}
+		if metadata.End.Before(request.Start) || metadata.Start.After(request.End) {
+			continue
+		}
+		sources = append(sources, ClipSource{Path: path, Metadata: metadata})
+	}
+	sort.Slice(sources, func(i, j int) bool {
+		return sources[i].Metadata.Start.Before(sources[j].Metadata.Start)
+	})
+	if len(sources) == 0 {
+		return nil, errors.New("no recordings in the requested range")
+	}
+	return sources, nil
+}
+
+// ExportClip cuts every overlapping recording exactly at the range edges and
+// joins the parts into a single fragmented MP4 written to w. Parts are
+// encoded with one H.264 preset so recordings of different tiers and codecs
+// can be concatenated. Nothing is written to disk, recordings may be
+// encrypted and the clip would be stored in clear.
+func ExportClip(ctx context.Context, sources []ClipSource, request ClipRequest, w io.Writer, progress func(float64)) error {
+	if len(sources) == 0 {
+		return errors.New("no recordings to export")
+	}
+	parts := clipParts(sources, request)
+	if len(parts) == 0 {
+		return errors.New("no recordings in the requested range")
+	}
+
+	// MPEG-TS parts can be joined by appending them, one ffmpeg muxes the
+	// joined stream into MP4
+	partsOutput, muxerInput, err := os.Pipe()
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	var stderr strings.Builder
+	muxer := exec.CommandContext(ctx, "ffmpeg",
+		"-f", "mpegts",
+		"-i", "pipe:0",
+		"-c", "copy",
+		// a pipe can not be seeked to write the index at the start
+		"-movflags", "frag_keyframe+empty_moov+default_base_moof",
+		"-f", "mp4",
+		"pipe:1",
+	)
+	muxer.Stdin = partsOutput
+	muxer.Stdout = w
+	muxer.Stderr = &stderr
+	err = muxer.Start()
+	partsOutput.Close()
+	if err != nil {
+		muxerInput.Close()
+		return err
+	}
+
+	scale, fps := outputFormat(sources[0].Metadata)
+	var total float64
+	for _, part := range parts {
+		total += part.duration.Seconds()
+	}
+	var done time.Duration
+	var partsErr error
+	for _, part := range parts {
+		partsErr = exportPart(ctx, part, done, scale, fps, muxerInput, func(seconds float64) {
+			if progress != nil && total > 0 {
+				progress(min((done.Seconds()+seconds)/total, 0.99))
+			}
+		})
+		if partsErr != nil {
+			partsErr = fmt.Errorf("failed to cut %s: %w", filepath.Base(part.source.Path), partsErr)
+			cancel()
+			break
+		}
+		done += part.duration
+	}
+	muxerInput.Close()
+	if err := muxer.Wait(); partsErr == nil && err != nil {
+		return fmt.Errorf("ffmpeg muxing failed: %w\n%s", err, stderr.String())
+	}
+	if partsErr != nil {
+		return partsErr
+	}
+	if progress != nil {
+		progress(1)
+	}
+	return nil
+}
+
+// clipPart is the range of a recording that goes into a clip, in video time
+type clipPart struct {
+	source   ClipSource
+	offset   time.Duration
+	duration time.Duration
+}
+
+// clipParts maps the request to the video time of every source, pauses in
+// recordings are not in the videos
+func clipParts(sources []ClipSource, request ClipRequest) []clipPart {
+	var parts []clipPart
+	for _, source := range sources {
+		from := maxTime(request.Start, source.Metadata.Start)
+		to := minTime(request.End, source.Metadata.End)
+		if !to.After(from) {
+			continue
+		}
+		offset := source.Metadata.VideoOffset(from)
+		if duration := source.Metadata.VideoOffset(to) - offset; duration > 0 {
+			parts = append(parts, clipPart{source: source, offset: offset, duration: duration})
+		}
+	}
+	return parts
+}
+
+// outputFormat keeps size and framerate of the first recording so parts of
+// different tiers can be joined
+func outputFormat(first Metadata) (string, float64) {
+	fps := first.Fps
+	if fps <= 0 {
+		fps = 30
+	}
+	scale := "scale=trunc(iw/2)*2:trunc(ih/2)*2"
+	if first.Width > 0 && first.Height > 0 {
+		scale = fmt.Sprintf("scale=%d:%d", first.Width-first.Width%2, first.Height-first.Height%2)
+	}
+	return scale, fps
+}
+
+// exportPart appends the part as MPEG-TS to w, its timestamps start at start
+// so the parts play one after another
+func exportPart(ctx context.Context, part clipPart, start time.Duration, scale string, fps float64, w *os.File, progress func(float64)) error {
+	input, err := OpenVideo(part.source.Path)
+	if err != nil {
+		return err
+	}
+	defer input.Close()
+	args := []string{"-f", InputFormat(part.source.Metadata.Codec)}
+	if part.source.Metadata.Fps > 0 {
+		args = append(args, "-framerate", fmt.Sprintf("%f", part.source.Metadata.Fps))
+	}
+	args = append(args,
+		"-i", "pipe:0",
+		"-ss", fmt.Sprintf("%.3f", part.offset.Seconds()),
+		"-t", fmt.Sprintf("%.3f", part.duration.Seconds()),
+		"-vf", fmt.Sprintf("%s,fps=%f", scale, fps),
+		"-c:v", "libx264",
+		"-preset", "veryfast",
+		"-profile:v", "baseline",
+		"-pix_fmt", "yuv420p",
+		"-bf", "0",
+		"-g", fmt.Sprintf("%d", int(fps)),
+		"-output_ts_offset", fmt.Sprintf("%.3f", start.Seconds()),
+		// stdout carries the video, progress goes to the first extra file
+		"-progress", "pipe:3",
+		"-nostats",
+		"-f", "mpegts",
+		"pipe:1",
+	)
+	progressOutput, progressInput, err := os.Pipe()
+	if err != nil {
+		return err
+	}
+	defer progressOutput.Close()
+	cmd := exec.CommandContext(ctx, "ffmpeg", args...)
+	cmd.Stdin = input
+	cmd.Stdout = w
+	cmd.ExtraFiles = []*os.File{progressInput}
+	var stderr strings.Builder
+	cmd.Stderr = &stderr
+	err = cmd.Start()
+	progressInput.Close()
+	if err != nil {
+		return err
+	}
+	scanner := bufio.NewScanner(progressOutput)
+	for scanner.Scan() {
+		value, ok := strings.CutPrefix(scanner.Text(), "out_time_us=")
+		if !ok {
+			continue
+		}
+		if us, err := strconv.ParseInt(value, 10, 64); err == nil && us > 0 {
+			progress(float64(us) / 1e6)
+		}
+	}
+	if err := cmd.Wait(); err != nil {
+		return fmt.Errorf("%w\n%s", err, stderr.String())
+	}
+	return nil
+}
+
+func maxTime(a time.Time, b time.Time) time.Time {
+	if a.After(b) {
+		return a
+	}
+	return b
+}
+
+func minTime(a time.Time, b time.Time) time.Time {
+	if a.Before(b) {
+		return a
+	}
+	return b
+}
+
+// WriteClip exports the clip of the stored recordings to w
+func WriteClip(ctx context.Context, paths []string, request ClipRequest, progress func(float64), w io.Writer) error {
+	if err := request.Validate(); err != nil {
+		return err
+	}
+	sources, err := FindClipSources(paths, request)
+	if err != nil {
+		return err
+	}
+	return ExportClip(ctx, sources, request, w, progress)
+}
+
+// ExportClipFile exports the clip to a temporary file for senders that need
+// its size first. It is encrypted like recordings, read it with OpenVideo and
+// remove it when done.
+func ExportClipFile(ctx context.Context, paths []string, request ClipRequest, progress func(float64)) (string, error) {
+	file, path, err := encryption.CreateTemp("clip-*.mp4", encryption.Default())
+	if err != nil {
+		return "", err
+	}
+	err = WriteClip(ctx, paths, request, progress, file)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(path)
+		return "", err
+	}
+	return path, nil
+}
@@ -0,0 +1,115 @@
+package video
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestFindClipSources(t *testing.T) {
+	dir := t.TempDir()
+	base := time.Date(2025, 1, 2, 14, 0, 0, 0, time.UTC)
+	recordings := []struct {
+		name   string
+		camera string
+		start  time.Time
+		end    time.Time
+	}{
+		{"2025-01-01-1.mp4", "cam2", base.Add(-time.Hour), base.Add(2 * time.Minute)},
+		{"2025-01-02-1.mp4", "cam2", base.Add(2 * time.Minute), base.Add(4 * time.Minute)},
+		{"2025-01-02-2.mp4", "cam1", base.Add(2 * time.Minute), base.Add(4 * time.Minute)},
+		{"2025-01-02-3.mp4", "cam2", base.Add(4 * time.Minute), base.Add(6 * time.Minute)},
+		{"2025-01-02-4.mp4", "cam2", base.Add(10 * time.Minute), base.Add(12 * time.Minute)},
+	}
+	for _, r := range recordings {
+		path := filepath.Join(dir, r.name)
+		if err := os.WriteFile(path, []byte("video"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		metadata := Metadata{Tier: TierOriginal, Codec: CodecH264, Camera: r.camera, Start: r.start, End: r.end}
+		if err := SaveVideoMetadata(path, metadata); err != nil {
+			t.Fatal(err)
+		}
+	}
+	request := ClipRequest{Camera: "cam2", Start: base.Add(2*time.Minute + 10*time.Second), End: base.Add(5*time.Minute + 40*time.Second)}
+	sources, err := FindClipSources([]string{dir}, request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, source := range sources {
+		names = append(names, filepath.Base(source.Path))
+	}
+	if len(names) != 2 || names[0] != "2025-01-02-1.mp4" || names[1] != "2025-01-02-3.mp4" {
+		t.Errorf("Expected recordings 1 and 3 of 2025-01-02, got %v", names)
+	}
+
+	t.Run("range crossing midnight recording", func(t *testing.T) {
+		request := ClipRequest{Camera: "cam2", Start: base.Add(time.Minute), End: base.Add(3 * time.Minute)}
+		sources, err := FindClipSources([]string{dir}, request)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(sources) != 2 || filepath.Base(sources[0].Path) != "2025-01-01-1.mp4" {
+			t.Errorf("Expected the recording from the previous day first, got %v", sources)
+		}
+	})
+	t.Run("invalid range", func(t *testing.T) {
+		if err := (ClipRequest{Start: base, End: base}).Validate(); err == nil {
+			t.Error("Expected empty range to be rejected")
+		}
+	})
+}
+
+func TestClipPartsSkipPauses(t *testing.T) {
+	base := time.Date(2025, 1, 2, 14, 0, 0, 0, time.UTC)
+	// a chunk of two events a minute apart, 10 frames each at 10 fps
+	var captured []time.Time
+	for _, event := range []time.Time{base, base.Add(time.Minute)} {
+		for i := 0; i < 10; i++ {
+			captured = append(captured, event.Add(time.Duration(i)*100*time.Millisecond))
+		}
+	}
+	metadata := Metadata{Fps: 10, Start: captured[0], End: captured[len(captured)-1], Spans: NewSpans(captured, 10)}
+	if len(metadata.Spans) != 2 || metadata.Spans[1].Offset != 1 {
+		t.Fatalf("Expected two spans, the second at 1s, got %+v", metadata.Spans)
+	}
+	sources := []ClipSource{{Path: "2025-01-02-1.mp4", Metadata: metadata}}
+	near := func(d time.Duration, seconds float64) bool {
+		return math.Abs(d.Seconds()-seconds) < 0.001
+	}
+
+	parts := clipParts(sources, ClipRequest{Start: base.Add(450 * time.Millisecond), End: base.Add(time.Minute + 450*time.Millisecond)})
+	if len(parts) != 1 || !near(parts[0].offset, 0.5) || !near(parts[0].duration, 1) {
+		t.Errorf("Expected the second half of the first event and the first half of the second, got %+v", parts)
+	}
+	parts = clipParts(sources, ClipRequest{Start: base.Add(30 * time.Second), End: base.Add(time.Minute + 900*time.Millisecond)})
+	if len(parts) != 1 || !near(parts[0].offset, 1) || !near(parts[0].duration, 1) {
+		t.Errorf("Expected a start in the pause to cut at the second event, got %+v", parts)
+	}
+	if parts = clipParts(sources, ClipRequest{Start: base.Add(10 * time.Second), End: base.Add(50 * time.Second)}); len(parts) != 0 {
+		t.Errorf("Expected nothing recorded in the pause, got %+v", parts)
+	}
+	// recordings without spans keep wall clock offsets
+	sources[0].Metadata.Spans = nil
+	if parts = clipParts(sources, ClipRequest{Start: base.Add(10 * time.Second), End: base.Add(50 * time.Second)}); len(parts) != 1 || !near(parts[0].offset, 10) || !near(parts[0].duration, 40) {
+		t.Errorf("Expected wall clock offsets without spans, got %+v", parts)
+	}
+}
+
+func TestVideoType(t *testing.T) {
+	cases := map[string]string{
+		"2025-01-02-1.mp4":          TypeRecording,
+		"2025-01-02-12-300.mp4":     TypeRecording,
+		"2025-01-02-timelapse.mp4":  TypeTimelapse,
+		"2025-01-02-timelapse.json": "",
+		"notes.txt":                 "",
+	}
+	for name, expected := range cases {
+		if videoType := VideoType(name); videoType != expected {
+			t.Errorf("Expected type %q of %s, got %q", expected, name, videoType)
+		}
+	}
+}
@@ -0,0 +1,230 @@
+package video
+
+import (
+	"archive/zip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"strzcam.com/broadcaster/encryption"
+	"strzcam.com/broadcaster/events"
+)
+
+const (
+	EvidenceClipName      = "clip.mp4"
+	EvidenceMetadataName  = "metadata.json"
+	EvidenceChecksumsName = "checksums.sha256"
+	EvidenceManifestName  = "MANIFEST.txt"
+	evidenceSourcesDir    = "sources"
+)
+
+type EvidenceSource struct {
+	Name     string   `json:"name"`
+	Metadata Metadata `json:"metadata"`
+}
+
+// EvidenceMetadata is stored in the bundle as metadata.json
+type EvidenceMetadata struct {
+	Camera     string           `json:"camera"`
+	Start      time.Time        `json:"start"`
+	End        time.Time        `json:"end"`
+	CreatedAt  time.Time        `json:"createdAt"`
+	Sources    []EvidenceSource `json:"sources"`
+	Detections []events.Event   `json:"detections"`
+}
+
+// Evidence is a prepared bundle, the clip waits on disk until it is written
+type Evidence struct {
+	clipPath string
+	sources  []ClipSource
+	metadata EvidenceMetadata
+}
+
+type evidenceFile struct {
+	name   string
+	size   int64
+	sha256 string
+}
+
+// PrepareEvidence exports the clip and collects detections of the range.
+// Close removes the exported clip.
+func PrepareEvidence(ctx context.Context, paths []string, eventsDir string, request ClipRequest, progress func(float64)) (*Evidence, error) {
+	if err := request.Validate(); err != nil {
+		return nil, err
+	}
+	sources, err := FindClipSources(paths, request)
+	if err != nil {
+		return nil, err
+	}
+	detections := []events.Event{}
+	if eventsDir != "" {
+		detections, err = events.Read(eventsDir, request.Camera, request.Start, request.End)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read events: %w", err)
+		}
+	}
+	// the clip waits for WriteTo encrypted like the recordings it comes from
+	clip, clipPath, err := encryption.CreateTemp("evidence-clip-*.mp4", encryption.Default())
+	if err != nil {
+		return nil, err
+	}
+	err = ExportClip(ctx, sources, request, clip, progress)
+	if closeErr := clip.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(clipPath)
+		return nil, err
+	}
+	metadata := EvidenceMetadata{
+		Camera:     request.Camera,
+		Start:      request.Start.UTC(),
+		End:        request.End.UTC(),
+		CreatedAt:  time.Now().UTC(),
+		Detections: detections,
+	}
+	for _, source := range sources {
+		metadata.Sources = append(metadata.Sources, EvidenceSource{
+			Name:     filepath.Base(source.Path),
+			Metadata: source.Metadata,
+		})
+	}
+	return &Evidence{clipPath: clipPath, sources: sources, metadata: metadata}, nil
+}
+
+func (e *Evidence) Close() error {
+	return os.Remove(e.clipPath)
+}
+
+// WriteTo streams the bundle as a ZIP archive. Files are hashed while they
+// are written, so w does not need to be seekable.
+func (e *Evidence) WriteTo(w io.Writer) (int64, error) {
+	counter := &countingWriter{w: w}
+	archive := zip.NewWriter(counter)
+	var files []evidenceFile
+
+	add := func(name string, r io.Reader) error {
+		entry, err := archive.CreateHeader(&zip.FileHeader{
+			Name:     name,
+			Method:   zip.Deflate,
+			Modified: e.metadata.CreatedAt,
+		})
+		if err != nil {
+			return err
+		}
+		hash := sha256.New()
+		size, err := io.Copy(io.MultiWriter(entry, hash), r)
+		if err != nil {
+			return fmt.Errorf("failed to add %s: %w", name, err)
+		}
+		files = append(files, evidenceFile{name: name, size: size, sha256: hex.EncodeToString(hash.Sum(nil))})
+		return nil
+	}
+	addFile := func(name string, open func() (io.ReadCloser, error)) error {
+		file, err := open()
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		return add(name, file)
+	}
+
+	err := addFile(EvidenceClipName, func() (io.ReadCloser, error) { return OpenVideo(e.clipPath) })
+	if err != nil {
+		return counter.n, err
+	}
+	for _, source := range e.sources {
+		name := path.Join(evidenceSourcesDir, filepath.Base(source.Path))
+		// sources are stored decrypted so their signatures can be checked
+		err := addFile(name, func() (io.ReadCloser, error) { return OpenVideo(source.Path) })
+		if err != nil {
+			return counter.n, err
+		}
+		metadataPath := MetadataPath(source.Path)
+		if _, err := os.Stat(metadataPath); err == nil {
+			err := addFile(path.Join(evidenceSourcesDir, filepath.Base(metadataPath)), func() (io.ReadCloser, error) {
+				return os.Open(metadataPath)
+			})
+			if err != nil {
+				return counter.n, err
+			}
+		}
+	}
+	metadata, err := json.MarshalIndent(e.metadata, "", "  ")
+	if err != nil {
+		return counter.n, err
+	}
+	if err := add(EvidenceMetadataName, strings.NewReader(string(metadata))); err != nil {
+		return counter.n, err
+	}
+
+	var checksums strings.Builder
+	for _, file := range files {
+		fmt.Fprintf(&checksums, "%s  %s\n", file.sha256, file.name)
+	}
+	manifest := e.manifest(files)
+	if err := add(EvidenceChecksumsName, strings.NewReader(checksums.String())); err != nil {
+		return counter.n, err
+	}
+	if err := add(EvidenceManifestName, strings.NewReader(manifest)); err != nil {
+		return counter.n, err
+	}
+	err = archive.Close()
+	return counter.n, err
+}
+
+func (e *Evidence) manifest(files []evidenceFile) string {
+	var b strings.Builder
+	camera := e.metadata.Camera
+	if camera == "" {
+		camera = "all"
+	}
+	fmt.Fprintf(&b, "Evidence bundle\n\n")
+	fmt.Fprintf(&b, "Camera:     %s\n", camera)
+	fmt.Fprintf(&b, "From:       %s\n", e.metadata.Start.Format(time.RFC3339))
+	fmt.Fprintf(&b, "To:         %s\n", e.metadata.End.Format(time.RFC3339))
+	fmt.Fprintf(&b, "Created:    %s\n", e.metadata.CreatedAt.Format(time.RFC3339))
+	fmt.Fprintf(&b, "Detections: %d\n\n", len(e.metadata.Detections))
+	fmt.Fprintf(&b, "Source recordings:\n")
+	for _, source := range e.metadata.Sources {
+		signed := "unsigned"
+		if source.Metadata.Signature != nil {
+			signed = "signed"
+		}
+		fmt.Fprintf(&b, "  %s  %s - %s  %s %s, %s\n",
+			source.Name,
+			source.Metadata.Start.UTC().Format(time.RFC3339),
+			source.Metadata.End.UTC().Format(time.RFC3339),
+			source.Metadata.Tier,
+			source.Metadata.Codec,
+			signed,
+		)
+	}
+	fmt.Fprintf(&b, "\nFiles:\n")
+	for _, file := range files {
+		fmt.Fprintf(&b, "  %-40s %12d bytes  sha256 %s\n", file.name, file.size, file.sha256)
+	}
+	fmt.Fprintf(&b, "\n%s is the requested range re-encoded to H.264.\n", EvidenceClipName)
+	fmt.Fprintf(&b, "%s/ holds the stored recordings, decrypted, with their metadata and signatures.\n", evidenceSourcesDir)
+	fmt.Fprintf(&b, "Check the files with: sha256sum -c %s\n", EvidenceChecksumsName)
+	return b.String()
+}
+
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
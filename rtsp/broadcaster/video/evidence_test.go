@@ -0,0 +1,63 @@
+package video
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEvidenceWriteTo(t *testing.T) {
+	dir := t.TempDir()
+	clipPath := filepath.Join(dir, "clip.mp4")
+	os.WriteFile(clipPath, []byte("clip"), 0644)
+	sourcePath := filepath.Join(dir, "2025-01-02-1.mp4")
+	os.WriteFile(sourcePath, []byte("source"), 0644)
+	metadata := Metadata{Tier: TierOriginal, Codec: CodecH264, Camera: "cam1", Start: time.Now(), End: time.Now()}
+	SaveVideoMetadata(sourcePath, metadata)
+	evidence := &Evidence{
+		clipPath: clipPath,
+		sources:  []ClipSource{{Path: sourcePath, Metadata: metadata}},
+		metadata: EvidenceMetadata{Camera: "cam1", CreatedAt: time.Now()},
+	}
+	var out bytes.Buffer
+	size, err := evidence.WriteTo(&out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if size != int64(out.Len()) {
+		t.Errorf("Expected written size %d, got %d", out.Len(), size)
+	}
+	archive, err := zip.NewReader(bytes.NewReader(out.Bytes()), size)
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := map[string][]byte{}
+	for _, file := range archive.File {
+		r, _ := file.Open()
+		files[file.Name], _ = io.ReadAll(r)
+		r.Close()
+	}
+	for _, name := range []string{EvidenceClipName, "sources/2025-01-02-1.mp4", "sources/2025-01-02-1.json", EvidenceMetadataName, EvidenceChecksumsName, EvidenceManifestName} {
+		if _, ok := files[name]; !ok {
+			t.Errorf("Expected %s in the bundle", name)
+		}
+	}
+	lines := strings.Split(strings.TrimSpace(string(files[EvidenceChecksumsName])), "\n")
+	if len(lines) != 4 {
+		t.Errorf("Expected checksums of 4 files, got %v", lines)
+	}
+	for _, line := range lines {
+		sum, name, _ := strings.Cut(line, "  ")
+		expected := sha256.Sum256(files[name])
+		if sum != hex.EncodeToString(expected[:]) {
+			t.Errorf("Checksum of %s does not match its content", name)
+		}
+	}
+}
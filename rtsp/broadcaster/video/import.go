@@ -0,0 +1,278 @@
+package video
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"strzcam.com/broadcaster/encryption"
+)
+
+type ImportRequest struct {
+	Camera string    `json:"camera"`
+	Start  time.Time `json:"start"`
+	Fps    float64   `json:"fps,omitempty"` // raw H.264 has no timing, 30 when not set
+	Source string    `json:"source,omitempty"`
+}
+
+func (r ImportRequest) Validate() error {
+	if r.Camera == "" {
+		return errors.New("camera is required")
+	}
+	if r.Start.IsZero() {
+		return errors.New("start time is required")
+	}
+	if r.Start.After(time.Now()) {
+		return errors.New("start time is in the future")
+	}
+	return nil
+}
+
+type probeOutput struct {
+	Streams []struct {
+		CodecType  string `json:"codec_type"`
+		Width      uint32 `json:"width"`
+		Height     uint32 `json:"height"`
+		RFrameRate string `json:"r_frame_rate"`
+	} `json:"streams"`
+	Format struct {
+		FormatName string `json:"format_name"`
+	} `json:"format"`
+}
+
+// probe describes the container and the first video stream of a file
+type probe struct {
+	format string
+	width  uint32
+	height uint32
+	fps    float64
+}
+
+func probeImport(path string) (probe, error) {
+	output, err := exec.Command("ffprobe",
+		"-v", "quiet",
+		"-print_format", "json",
+		"-show_streams",
+		"-show_format",
+		"-select_streams", "v:0",
+		path,
+	).Output()
+	if err != nil {
+		return probe{}, fmt.Errorf("ffprobe failed: %w", err)
+	}
+	return parseProbe(output)
+}
+
+// parseProbe reads the JSON printed by ffprobe
+func parseProbe(output []byte) (probe, error) {
+	var parsed probeOutput
+	if err := json.Unmarshal(output, &parsed); err != nil {
+		return probe{}, err
+	}
+	if len(parsed.Streams) == 0 {
+		return probe{}, errors.New("no video stream found")
+	}
+	stream := parsed.Streams[0]
+	var num, den float64
+	fmt.Sscanf(stream.RFrameRate, "%f/%f", &num, &den)
+	fps := 0.0
+	if den > 0 {
+		fps = num / den
+	}
+	return probe{format: parsed.Format.FormatName, width: stream.Width, height: stream.Height, fps: fps}, nil
+}
+
+// inputArgs returns the ffmpeg input options and frame rate of an import.
+// Raw H.264 has no timing, it plays at the requested rate or 30.
+func inputArgs(input probe, requestFps float64) ([]string, float64, error) {
+	switch {
+	case input.format == "h264":
+		fps := requestFps
+		if fps <= 0 {
+			fps = 30
+		}
+		return []string{"-f", "h264", "-framerate", fmt.Sprintf("%f", fps)}, fps, nil
+	case strings.Contains(input.format, "mp4"):
+		fps := input.fps
+		if fps <= 0 || fps > 120 {
+			fps = 30
+		}
+		return nil, fps, nil
+	}
+	return nil, 0, fmt.Errorf("unsupported format %s, expected MP4 or H.264", input.format)
+}
+
+func ImportName(start time.Time) string {
+	start = start.Local()
+	return fmt.Sprintf("%s-%s-%s.mp4", start.Format("2006-01-02"), TypeImport, start.Format("150405"))
+}
+
+// ImportVideo re-encodes MP4 or raw H.264 footage to the format of converted
+// recordings and stores it with metadata in dir. It returns the video name.
+func ImportVideo(ctx context.Context, inputPath string, request ImportRequest, paths []string, dir string) (string, error) {
+	if err := request.Validate(); err != nil {
+		return "", err
+	}
+	name := ImportName(request.Start)
+	if _, err := FindVideo(paths, name); err == nil {
+		return "", fmt.Errorf("%s is already imported", name)
+	}
+	source, stopServing, err := importSource(inputPath, encryption.Default())
+	if err != nil {
+		return "", err
+	}
+	defer stopServing()
+	input, err := probeImport(source)
+	if err != nil {
+		return "", err
+	}
+	args, fps, err := inputArgs(input, request.Fps)
+	if err != nil {
+		return "", err
+	}
+	args = append([]string{"-y"}, args...)
+	args = append(args,
+		"-i", source,
+		"-an",
+		"-vf", "scale=trunc(iw/2)*2:trunc(ih/2)*2",
+		"-r", fmt.Sprintf("%f", fps),
+		"-c:v", "libx264",
+		"-preset", "medium",
+		"-profile:v", "baseline",
+		"-pix_fmt", "yuv420p",
+		"-bf", "0", // keep it playable by StaticVideoTrack
+		"-g", fmt.Sprintf("%d", int(fps)),
+		"-b:v", "2M",
+		"-maxrate", "2M",
+		"-bufsize", "4M",
+		"-bsf:v", "h264_mp4toannexb",
+		"-progress", "pipe:2",
+		"-nostats",
+		"-loglevel", "error",
+		"-f", "h264",
+		"pipe:1",
+	)
+	outputPath := filepath.Join(dir, name)
+	tmpPath := outputPath + ".import.tmp"
+	output, err := encryption.Create(tmpPath, encryption.Default())
+	if err != nil {
+		return "", err
+	}
+	cmd := exec.CommandContext(ctx, "ffmpeg", args...)
+	cmd.Stdout = output
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		output.Close()
+		os.Remove(tmpPath)
+		return "", err
+	}
+	if err := cmd.Start(); err != nil {
+		output.Close()
+		os.Remove(tmpPath)
+		return "", err
+	}
+	// progress lines are mixed with errors
+	var duration time.Duration
+	var errorLines []string
+	scanner := bufio.NewScanner(stderr)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if value, ok := strings.CutPrefix(line, "out_time_us="); ok {
+			if us, err := strconv.ParseInt(value, 10, 64); err == nil && us > 0 {
+				duration = time.Duration(us) * time.Microsecond
+			}
+		} else if !strings.Contains(line, "=") {
+			errorLines = append(errorLines, line)
+		}
+	}
+	err = cmd.Wait()
+	if closeErr := output.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tmpPath)
+		return "", fmt.Errorf("ffmpeg import failed: %w\n%s", err, strings.Join(errorLines, "\n"))
+	}
+	metadata := Metadata{
+		Tier:     TierOriginal,
+		Codec:    CodecH264,
+		Width:    input.width - input.width%2,
+		Height:   input.height - input.height%2,
+		Fps:      fps,
+		Bitrate:  "2M",
+		Camera:   request.Camera,
+		Start:    request.Start.UTC(),
+		End:      request.Start.Add(duration).UTC(),
+		Imported: request.Source,
+	}
+	if metadata.Imported == "" {
+		metadata.Imported = filepath.Base(inputPath)
+	}
+	if err := SetChecksums(tmpPath, &metadata); err != nil {
+		os.Remove(tmpPath)
+		return "", fmt.Errorf("failed to compute checksums: %w", err)
+	}
+	// metadata goes first so the video is never read with defaults
+	if err := SaveVideoMetadata(outputPath, metadata); err != nil {
+		os.Remove(tmpPath)
+		return "", err
+	}
+	if err := os.Rename(tmpPath, outputPath); err != nil {
+		os.Remove(tmpPath)
+		os.Remove(MetadataPath(outputPath))
+		return "", err
+	}
+	return name, nil
+}
+
+// importSource returns what ffmpeg reads the footage from. Encrypted uploads
+// are served decrypted on loopback HTTP, ffmpeg seeks in them like in a file
+// so MP4s with the index at the end import without a clear copy on disk.
+func importSource(path string, keyring *encryption.Keyring) (string, func(), error) {
+	file, err := encryption.Open(path, keyring)
+	if err != nil {
+		return "", nil, err
+	}
+	encrypted := file.Encrypted()
+	file.Close()
+	if !encrypted {
+		return path, func() {}, nil
+	}
+	secret := make([]byte, 16)
+	if _, err := rand.Read(secret); err != nil {
+		return "", nil, err
+	}
+	name := "/" + hex.EncodeToString(secret)
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return "", nil, err
+	}
+	server := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != name {
+			http.NotFound(w, r)
+			return
+		}
+		// ffmpeg opens a connection for every seek
+		file, err := encryption.Open(path, keyring)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		defer file.Close()
+		http.ServeContent(w, r, "", time.Time{}, file)
+	})}
+	go server.Serve(listener)
+	return "http://" + listener.Addr().String() + name, func() { server.Close() }, nil
+}
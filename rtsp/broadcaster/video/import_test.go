@@ -0,0 +1,216 @@
+package video
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"strzcam.com/broadcaster/encryption"
+)
+
+func TestImportName(t *testing.T) {
+	cases := []struct {
+		start    time.Time
+		expected string
+	}{
+		{time.Date(2025, 1, 2, 14, 2, 10, 0, time.Local), "2025-01-02-import-140210.mp4"},
+		{time.Date(2025, 1, 2, 0, 0, 0, 0, time.Local), "2025-01-02-import-000000.mp4"},
+		{time.Date(2025, 12, 31, 23, 59, 59, 999, time.Local), "2025-12-31-import-235959.mp4"},
+	}
+	for _, c := range cases {
+		name := ImportName(c.start)
+		if name != c.expected {
+			t.Errorf("Expected %s for %s, got %s", c.expected, c.start, name)
+		}
+		// listings have to find imports again
+		date, _, ok := ParseVideoName(name)
+		if !ok || VideoType(name) != TypeImport || date.Format("2006-01-02") != c.start.Format("2006-01-02") {
+			t.Errorf("%s is not parsed as an import of %s", name, c.start.Format("2006-01-02"))
+		}
+	}
+}
+
+func TestParseProbe(t *testing.T) {
+	cases := []struct {
+		name     string
+		output   string
+		expected probe
+		fails    bool
+	}{
+		{
+			name:     "mp4",
+			output:   `{"streams": [{"codec_type": "video", "width": 1920, "height": 1080, "r_frame_rate": "30000/1001"}], "format": {"format_name": "mov,mp4,m4a,3gp,3g2,mj2"}}`,
+			expected: probe{format: "mov,mp4,m4a,3gp,3g2,mj2", width: 1920, height: 1080, fps: 30000.0 / 1001},
+		},
+		{
+			name:     "raw h264",
+			output:   `{"streams": [{"codec_type": "video", "width": 640, "height": 480, "r_frame_rate": "25/1"}], "format": {"format_name": "h264"}}`,
+			expected: probe{format: "h264", width: 640, height: 480, fps: 25},
+		},
+		{
+			name:     "unknown frame rate",
+			output:   `{"streams": [{"codec_type": "video", "width": 640, "height": 480, "r_frame_rate": "0/0"}], "format": {"format_name": "h264"}}`,
+			expected: probe{format: "h264", width: 640, height: 480},
+		},
+		{name: "no video stream", output: `{"streams": [], "format": {"format_name": "mp3"}}`, fails: true},
+		{name: "not json", output: `ffprobe: invalid option`, fails: true},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			parsed, err := parseProbe([]byte(c.output))
+			if c.fails {
+				if err == nil {
+					t.Errorf("Expected an error, got %+v", parsed)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if parsed != c.expected {
+				t.Errorf("Expected %+v, got %+v", c.expected, parsed)
+			}
+		})
+	}
+}
+
+func TestInputArgs(t *testing.T) {
+	cases := []struct {
+		name       string
+		input      probe
+		requestFps float64
+		args       []string
+		fps        float64
+		fails      bool
+	}{
+		{name: "raw h264 at the requested rate", input: probe{format: "h264", fps: 25}, requestFps: 15, args: []string{"-f", "h264", "-framerate", "15.000000"}, fps: 15},
+		{name: "raw h264 without a rate", input: probe{format: "h264", fps: 25}, args: []string{"-f", "h264", "-framerate", "30.000000"}, fps: 30},
+		{name: "mp4 keeps its rate", input: probe{format: "mov,mp4,m4a,3gp,3g2,mj2", fps: 25}, requestFps: 15, fps: 25},
+		{name: "mp4 with a bogus rate", input: probe{format: "mov,mp4,m4a,3gp,3g2,mj2", fps: 90000}, fps: 30},
+		{name: "mp4 without a rate", input: probe{format: "mov,mp4,m4a,3gp,3g2,mj2"}, fps: 30},
+		{name: "unsupported", input: probe{format: "matroska,webm", fps: 25}, fails: true},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			args, fps, err := inputArgs(c.input, c.requestFps)
+			if c.fails {
+				if err == nil {
+					t.Error("Expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(args, c.args) || fps != c.fps {
+				t.Errorf("Expected %v at %v fps, got %v at %v fps", c.args, c.fps, args, fps)
+			}
+		})
+	}
+}
+
+func TestImportVideoRoundTrip(t *testing.T) {
+	for _, tool := range []string{"ffmpeg", "ffprobe"} {
+		if _, err := exec.LookPath(tool); err != nil {
+			t.Skipf("%s is not installed", tool)
+		}
+	}
+	dir := t.TempDir()
+	source := filepath.Join(t.TempDir(), "phone.mp4")
+	output, err := exec.Command("ffmpeg", "-f", "lavfi", "-i", "testsrc=duration=2:size=320x240:rate=10",
+		"-c:v", "libx264", "-pix_fmt", "yuv420p", source).CombinedOutput()
+	if err != nil {
+		t.Fatalf("Can not create the source video: %v\n%s", err, output)
+	}
+	start := time.Date(2025, 1, 2, 14, 2, 10, 0, time.Local)
+	request := ImportRequest{Camera: "front", Start: start}
+	name, err := ImportVideo(context.Background(), source, request, []string{dir}, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if name != ImportName(start) {
+		t.Errorf("Expected %s, got %s", ImportName(start), name)
+	}
+	path := filepath.Join(dir, name)
+	metadata, err := ReadVideoMetadata(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if metadata.Camera != "front" || metadata.Imported != "phone.mp4" || metadata.Width != 320 || metadata.Height != 240 || metadata.Fps != 10 {
+		t.Errorf("Unexpected metadata %+v", metadata)
+	}
+	if duration := metadata.End.Sub(metadata.Start); duration < 1500*time.Millisecond || duration > 2500*time.Millisecond {
+		t.Errorf("Expected about 2s of footage, got %s", duration)
+	}
+	if err := ScrubVideo(path); err != nil {
+		t.Errorf("Imported video does not verify: %v", err)
+	}
+	if _, err := ImportVideo(context.Background(), source, request, []string{dir}, dir); err == nil {
+		t.Error("Expected a second import of the same start to fail")
+	}
+}
+
+func TestImportSource(t *testing.T) {
+	key, err := encryption.NewKey(bytes.Repeat([]byte{3}, encryption.KeySize))
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyring := encryption.NewKeyring(key)
+	dir := t.TempDir()
+	footage := bytes.Repeat([]byte("footage "), 10000)
+
+	plain := filepath.Join(dir, "plain.mp4")
+	if err := os.WriteFile(plain, footage, 0644); err != nil {
+		t.Fatal(err)
+	}
+	source, stop, err := importSource(plain, keyring)
+	if err != nil || source != plain {
+		t.Errorf("Expected a clear file read directly, got %s %v", source, err)
+	}
+	stop()
+
+	encrypted := filepath.Join(dir, "upload")
+	file, err := encryption.Create(encrypted, keyring)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file.Write(footage)
+	if err := file.Close(); err != nil {
+		t.Fatal(err)
+	}
+	source, stop, err = importSource(encrypted, keyring)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// ffmpeg seeks with ranges
+	request, _ := http.NewRequest(http.MethodGet, source, nil)
+	request.Header.Set("Range", "bytes=40000-40015")
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := io.ReadAll(response.Body)
+	response.Body.Close()
+	if response.StatusCode != http.StatusPartialContent || !bytes.Equal(data, footage[40000:40016]) {
+		t.Errorf("Expected the decrypted range, got %d %q", response.StatusCode, data)
+	}
+	if response, err := http.Get(strings.TrimRight(source, "0123456789abcdef")); err == nil {
+		response.Body.Close()
+		if response.StatusCode != http.StatusNotFound {
+			t.Errorf("Expected other paths refused, got %d", response.StatusCode)
+		}
+	}
+	stop()
+	if response, err := http.Get(source); err == nil {
+		response.Body.Close()
+		t.Error("Expected the footage no longer served after stopping")
+	}
+}
@@ -0,0 +1,172 @@
+package video
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+)
+
+const (
+	TierOriginal = "original"
+	TierArchive  = "archive"
+)
+
+const (
+	CodecH264 = "h264"
+	CodecH265 = "h265"
+	CodecVP9  = "vp9"
+)
+
+// Metadata is kept next to every converted video as <name>.json
+type Metadata struct {
+	Tier    string  `json:"tier"`
+	Codec   string  `json:"codec"`
+	Width   uint32  `json:"width,omitempty"`
+	Height  uint32  `json:"height,omitempty"`
+	Fps     float64 `json:"fps,omitempty"`
+	Bitrate string  `json:"bitrate,omitempty"`
+
+	SHA256   string            `json:"sha256,omitempty"`
+	Segments []SegmentChecksum `json:"segments,omitempty"`
+
+	Camera           string     `json:"camera,omitempty"`
+	Start            time.Time  `json:"start"`
+	End              time.Time  `json:"end"`
+	Signature        *Signature `json:"signature,omitempty"`
+	ArchiveSignature *Signature `json:"archiveSignature,omitempty"`
+	Imported         string     `json:"imported,omitempty"` // original file name of imported footage
+	Spans            []Span     `json:"spans,omitempty"`    // recorded with pauses when set
+}
+
+func MetadataPath(videoPath string) string {
+	return strings.TrimSuffix(videoPath, filepath.Ext(videoPath)) + ".json"
+}
+
+// Videos converted before metadata was introduced are original H.264 recordings.
+func ReadVideoMetadata(videoPath string) (Metadata, error) {
+	metadata := Metadata{Tier: TierOriginal, Codec: CodecH264}
+	data, err := os.ReadFile(MetadataPath(videoPath))
+	if errors.Is(err, os.ErrNotExist) {
+		return metadata, nil
+	}
+	if err != nil {
+		return metadata, err
+	}
+	if err := json.Unmarshal(data, &metadata); err != nil {
+		return metadata, fmt.Errorf("invalid metadata for %s: %w", videoPath, err)
+	}
+	return metadata, nil
+}
+
+func SaveVideoMetadata(videoPath string, metadata Metadata) error {
+	path := MetadataPath(videoPath)
+	tmpPath := path + ".tmp"
+	if err := WriteMetadata(tmpPath, metadata); err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, path)
+}
+
+// WriteMetadata writes metadata to any path, callers stage it there before
+// renaming it next to its video
+func WriteMetadata(path string, metadata Metadata) error {
+	data, err := json.MarshalIndent(metadata, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+func RemoveVideo(videoPath string) error {
+	if err := os.Remove(videoPath); err != nil {
+		return err
+	}
+	if err := os.Remove(MetadataPath(videoPath)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// MoveVideo moves the video with its metadata to another storage root,
+// copying when the root is on a different disk.
+func MoveVideo(videoPath string, dstDir string) (string, error) {
+	if err := os.MkdirAll(dstDir, 0755); err != nil {
+		return "", err
+	}
+	dstPath := filepath.Join(dstDir, filepath.Base(videoPath))
+	metadataPath := MetadataPath(videoPath)
+	hasMetadata := true
+	if _, err := os.Stat(metadataPath); errors.Is(err, os.ErrNotExist) {
+		hasMetadata = false
+	}
+	// metadata goes first so the moved video is never read with defaults
+	if hasMetadata {
+		if err := copyFile(metadataPath, MetadataPath(dstPath)); err != nil {
+			return "", err
+		}
+	}
+	if err := moveFile(videoPath, dstPath); err != nil {
+		return "", err
+	}
+	if hasMetadata {
+		os.Remove(metadataPath)
+	}
+	return dstPath, nil
+}
+
+func moveFile(src string, dst string) error {
+	err := os.Rename(src, dst)
+	if err == nil || !errors.Is(err, syscall.EXDEV) {
+		return err
+	}
+	if err := copyFile(src, dst); err != nil {
+		return err
+	}
+	return os.Remove(src)
+}
+
+func copyFile(src string, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	tmpPath := dst + ".tmp"
+	out, err := os.Create(tmpPath)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		os.Remove(tmpPath)
+		return err
+	}
+	if err := out.Sync(); err != nil {
+		out.Close()
+		os.Remove(tmpPath)
+		return err
+	}
+	if err := out.Close(); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	return os.Rename(tmpPath, dst)
+}
+
+// ffmpeg demuxer used to read a stored video of the given codec
+func InputFormat(codec string) string {
+	switch codec {
+	case CodecH265:
+		return "hevc"
+	case CodecVP9:
+		return "mp4" // fragmented, written by the archiver
+	default:
+		return "h264"
+	}
+}
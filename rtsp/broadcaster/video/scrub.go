@@ -0,0 +1,109 @@
+package video
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/pion/webrtc/v3/pkg/media/h264reader"
+)
+
+// Damaged recordings are moved here, listings skip this directory
+const QuarantineDir = "quarantine"
+
+type ScrubResult struct {
+	Path        string
+	Err         error
+	Quarantined bool
+}
+
+// VerifyStream checks that the stored bitstream can be parsed
+func VerifyStream(path string, codec string) error {
+	file, err := OpenVideo(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	if codec == CodecVP9 {
+		// MP4 starts with the size and type of its ftyp box
+		header := make([]byte, 8)
+		if _, err := io.ReadFull(file, header); err != nil || !bytes.Equal(header[4:], []byte("ftyp")) {
+			return fmt.Errorf("missing MP4 header")
+		}
+		return nil
+	}
+	// H.264 and H.265 are both Annex B byte streams
+	reader, err := h264reader.NewReader(file)
+	if err != nil {
+		return err
+	}
+	nalCount := 0
+	for {
+		nal, err := reader.NextNAL()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("failed to parse NAL %d: %w", nalCount, err)
+		}
+		if nal.ForbiddenZeroBit {
+			return fmt.Errorf("corrupted NAL %d, forbidden bit is set", nalCount)
+		}
+		nalCount++
+	}
+	if nalCount == 0 {
+		return fmt.Errorf("no NAL units found")
+	}
+	return nil
+}
+
+func ScrubVideo(path string) error {
+	metadata, err := ReadVideoMetadata(path)
+	if err != nil {
+		return err
+	}
+	if err := VerifyVideo(path); err != nil {
+		return err
+	}
+	return VerifyStream(path, metadata.Codec)
+}
+
+// Scrub verifies every video in the given roots. When quarantine is set
+// damaged videos are moved to the quarantine directory of their root.
+func Scrub(paths []string, quarantine bool) []ScrubResult {
+	var results []ScrubResult
+	for _, root := range paths {
+		err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if info.IsDir() {
+				if info.Name() == QuarantineDir {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if _, _, ok := ParseVideoName(info.Name()); !ok {
+				return nil
+			}
+			result := ScrubResult{Path: path, Err: ScrubVideo(path)}
+			if result.Err != nil && quarantine {
+				if _, err := MoveVideo(path, filepath.Join(root, QuarantineDir)); err != nil {
+					log.Printf("Can not quarantine %s: %v", path, err)
+				} else {
+					result.Quarantined = true
+				}
+			}
+			results = append(results, result)
+			return nil
+		})
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			results = append(results, ScrubResult{Path: root, Err: err})
+		}
+	}
+	return results
+}
@@ -0,0 +1,198 @@
+package video
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/crypto"
+)
+
+const signatureVersion = "strzcam-signature-v1"
+
+// Signature binds the hash of a recording to its camera, time range and the
+// hash of the previous recording of the same camera, forming a hash chain.
+type Signature struct {
+	Camera     string    `json:"camera"`
+	Start      time.Time `json:"start"`
+	End        time.Time `json:"end"`
+	SHA256     string    `json:"sha256"`
+	PrevSHA256 string    `json:"prevSha256"`
+	PublicKey  string    `json:"publicKey"`
+	Signature  string    `json:"signature"`
+}
+
+// ChainEntry is what a third party needs to check a recording offline.
+// ArchiveSignature links a re-encoded archive to the original recording.
+type ChainEntry struct {
+	Name             string     `json:"name"`
+	Signature        Signature  `json:"signature"`
+	ArchiveSignature *Signature `json:"archiveSignature,omitempty"`
+}
+
+func (s Signature) payload() []byte {
+	return []byte(strings.Join([]string{
+		signatureVersion,
+		s.Camera,
+		s.Start.UTC().Format(time.RFC3339Nano),
+		s.End.UTC().Format(time.RFC3339Nano),
+		s.SHA256,
+		s.PrevSHA256,
+	}, "\n"))
+}
+
+func Sign(key crypto.PrivKey, signature Signature) (Signature, error) {
+	publicKey, err := crypto.MarshalPublicKey(key.GetPublic())
+	if err != nil {
+		return signature, err
+	}
+	sig, err := key.Sign(signature.payload())
+	if err != nil {
+		return signature, err
+	}
+	signature.PublicKey = base64.StdEncoding.EncodeToString(publicKey)
+	signature.Signature = base64.StdEncoding.EncodeToString(sig)
+	return signature, nil
+}
+
+func (s Signature) Verify() error {
+	publicKeyData, err := base64.StdEncoding.DecodeString(s.PublicKey)
+	if err != nil {
+		return fmt.Errorf("invalid public key encoding: %w", err)
+	}
+	publicKey, err := crypto.UnmarshalPublicKey(publicKeyData)
+	if err != nil {
+		return fmt.Errorf("invalid public key: %w", err)
+	}
+	sig, err := base64.StdEncoding.DecodeString(s.Signature)
+	if err != nil {
+		return fmt.Errorf("invalid signature encoding: %w", err)
+	}
+	ok, err := publicKey.Verify(s.payload(), sig)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return errors.New("signature does not match")
+	}
+	return nil
+}
+
+// The chain head keeps the hash of the last signed recording of a camera
+func chainHeadPath(dir string, camera string) string {
+	return filepath.Join(dir, fmt.Sprintf("chain_head_%s", camera))
+}
+
+func ReadChainHead(dir string, camera string) (string, error) {
+	data, err := os.ReadFile(chainHeadPath(dir, camera))
+	if errors.Is(err, os.ErrNotExist) {
+		return "", nil
+	}
+	return strings.TrimSpace(string(data)), err
+}
+
+func WriteChainHead(dir string, camera string, sha string) error {
+	path := chainHeadPath(dir, camera)
+	if err := os.WriteFile(path+".tmp", []byte(sha), 0644); err != nil {
+		return err
+	}
+	return os.Rename(path+".tmp", path)
+}
+
+// SignVideo signs a finished recording as the next one of the camera chain.
+// The chain head is left alone, callers move it with WriteChainHead once the
+// signature is saved so a failed save does not break the chain.
+func SignVideo(key crypto.PrivKey, chainDir string, metadata Metadata) (Signature, error) {
+	prev, err := ReadChainHead(chainDir, metadata.Camera)
+	if err != nil {
+		return Signature{}, err
+	}
+	return Sign(key, Signature{
+		Camera:     metadata.Camera,
+		Start:      metadata.Start,
+		End:        metadata.End,
+		SHA256:     metadata.SHA256,
+		PrevSHA256: prev,
+	})
+}
+
+// GetSignatureChain returns signed recordings of the date range, oldest
+// first, across all storage roots. Dates are inclusive like in the video list.
+func GetSignatureChain(paths []string, start time.Time, end time.Time) ([]ChainEntry, error) {
+	videos, err := GetVideoByDateRange(paths, start, end)
+	if err != nil {
+		return nil, err
+	}
+	entries := []ChainEntry{}
+	for _, v := range videos {
+		path, err := FindVideo(paths, v.Name)
+		if err != nil {
+			continue
+		}
+		metadata, err := ReadVideoMetadata(path)
+		if err != nil || metadata.Signature == nil {
+			continue
+		}
+		entries = append(entries, ChainEntry{
+			Name:             v.Name,
+			Signature:        *metadata.Signature,
+			ArchiveSignature: metadata.ArchiveSignature,
+		})
+	}
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].Signature.Start.Before(entries[j].Signature.Start)
+	})
+	return entries, nil
+}
+
+// VerifyChain checks every signature and that each recording points to the
+// previous one of the same camera. When trustedKey is set all entries must
+// be signed by it.
+func VerifyChain(entries []ChainEntry, trustedKey string) []error {
+	var errs []error
+	last := map[string]string{}
+	for _, entry := range entries {
+		signature := entry.Signature
+		if trustedKey != "" && signature.PublicKey != trustedKey {
+			errs = append(errs, fmt.Errorf("%s: signed by an untrusted key", entry.Name))
+		}
+		if err := signature.Verify(); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", entry.Name, err))
+		}
+		if prev, ok := last[signature.Camera]; ok && prev != signature.PrevSHA256 {
+			errs = append(errs, fmt.Errorf("%s: chain broken, previous recording is missing or was altered", entry.Name))
+		}
+		last[signature.Camera] = signature.SHA256
+		if entry.ArchiveSignature != nil {
+			archive := entry.ArchiveSignature
+			if err := archive.Verify(); err != nil {
+				errs = append(errs, fmt.Errorf("%s archive: %w", entry.Name, err))
+			}
+			if archive.PrevSHA256 != signature.SHA256 || archive.PublicKey != signature.PublicKey {
+				errs = append(errs, fmt.Errorf("%s: archive is not derived from the signed recording", entry.Name))
+			}
+		}
+	}
+	return errs
+}
+
+// VerifyFile checks that the file on disk is the one covered by the entry
+func VerifyFile(entry ChainEntry, path string) error {
+	sum, _, err := ComputeChecksums(path)
+	if err != nil {
+		return err
+	}
+	expected := entry.Signature.SHA256
+	if entry.ArchiveSignature != nil {
+		expected = entry.ArchiveSignature.SHA256
+	}
+	if sum != expected {
+		return fmt.Errorf("%s: file hash %s does not match signed hash %s", entry.Name, sum, expected)
+	}
+	return nil
+}
@@ -0,0 +1,90 @@
+package video
+
+import (
+	"crypto/rand"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/crypto"
+)
+
+func TestSignatureChain(t *testing.T) {
+	key, _, err := crypto.GenerateKeyPairWithReader(crypto.Ed25519, -1, rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	videoDir := t.TempDir()
+	chainDir := t.TempDir()
+	start := time.Date(2025, 1, 1, 10, 0, 0, 0, time.UTC)
+	for i, name := range []string{"2025-01-01-1.mp4", "2025-01-01-2.mp4", "2025-01-01-3.mp4"} {
+		path := filepath.Join(videoDir, name)
+		if err := os.WriteFile(path, []byte(name), 0644); err != nil {
+			t.Fatal(err)
+		}
+		metadata := Metadata{
+			Tier:   TierOriginal,
+			Codec:  CodecH264,
+			Camera: "front",
+			Start:  start.Add(time.Duration(i) * time.Hour),
+			End:    start.Add(time.Duration(i+1) * time.Hour),
+		}
+		if err := SetChecksums(path, &metadata); err != nil {
+			t.Fatal(err)
+		}
+		signature, err := SignVideo(key, chainDir, metadata)
+		if err != nil {
+			t.Fatal(err)
+		}
+		metadata.Signature = &signature
+		if err := SaveVideoMetadata(path, metadata); err != nil {
+			t.Fatal(err)
+		}
+		if err := WriteChainHead(chainDir, metadata.Camera, metadata.SHA256); err != nil {
+			t.Fatal(err)
+		}
+	}
+	day := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	chain, err := GetSignatureChain([]string{videoDir}, day, day)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(chain) != 3 {
+		t.Fatalf("Expected 3 chain entries, got %d", len(chain))
+	}
+	if errs := VerifyChain(chain, ""); len(errs) != 0 {
+		t.Errorf("Expected valid chain, got %v", errs)
+	}
+
+	t.Run("missing recording breaks the chain", func(t *testing.T) {
+		errs := VerifyChain([]ChainEntry{chain[0], chain[2]}, "")
+		if len(errs) != 1 {
+			t.Errorf("Expected one error, got %v", errs)
+		}
+	})
+	t.Run("altered time range", func(t *testing.T) {
+		altered := append([]ChainEntry{}, chain...)
+		altered[1].Signature.End = altered[1].Signature.End.Add(time.Minute)
+		if errs := VerifyChain(altered, ""); len(errs) == 0 {
+			t.Error("Expected altered signature to fail")
+		}
+	})
+	t.Run("untrusted key", func(t *testing.T) {
+		if errs := VerifyChain(chain, "other"); len(errs) != 3 {
+			t.Errorf("Expected 3 errors, got %v", errs)
+		}
+	})
+	t.Run("altered file", func(t *testing.T) {
+		path := filepath.Join(videoDir, chain[0].Name)
+		if err := VerifyFile(chain[0], path); err != nil {
+			t.Fatalf("Expected file to match, got %v", err)
+		}
+		if err := os.WriteFile(path, []byte("edited"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := VerifyFile(chain[0], path); err == nil {
+			t.Error("Expected altered file to fail")
+		}
+	})
+}
@@ -0,0 +1,60 @@
+package video
+
+import "time"
+
+// SpanGap is the longest pause between frames of one span
+const SpanGap = time.Second
+
+// Span is a run of frames captured without a pause. Chunks are recorded on
+// events and encoded at a fixed frame rate, video time follows wall clock
+// time only inside a span.
+type Span struct {
+	Start  time.Time `json:"start"`  // capture time of the first frame
+	End    time.Time `json:"end"`    // capture time of the last frame
+	Offset float64   `json:"offset"` // seconds into the video of the first frame
+	Length float64   `json:"length"` // seconds of video
+}
+
+// NewSpans groups capture times of the frames of a video encoded at fps
+func NewSpans(captured []time.Time, fps float64) []Span {
+	if fps <= 0 {
+		return nil
+	}
+	var spans []Span
+	for i, t := range captured {
+		if i == 0 || t.Sub(captured[i-1]) > SpanGap {
+			spans = append(spans, Span{Start: t, Offset: float64(i) / fps})
+		}
+		span := &spans[len(spans)-1]
+		span.End = t
+		span.Length = float64(i+1)/fps - span.Offset
+	}
+	return spans
+}
+
+// VideoOffset maps a wall clock time to the time in the video. Times in a
+// pause map to the start of the next span. Videos without spans are taken as
+// recorded without pauses.
+func (m Metadata) VideoOffset(t time.Time) time.Duration {
+	if len(m.Spans) == 0 {
+		return min(max(t.Sub(m.Start), 0), m.End.Sub(m.Start))
+	}
+	for _, span := range m.Spans {
+		if t.Before(span.Start) {
+			return seconds(span.Offset)
+		}
+		if !t.After(span.End) {
+			position := 0.0
+			if wall := span.End.Sub(span.Start); wall > 0 {
+				position = float64(t.Sub(span.Start)) / float64(wall)
+			}
+			return seconds(span.Offset + position*span.Length)
+		}
+	}
+	last := m.Spans[len(m.Spans)-1]
+	return seconds(last.Offset + last.Length)
+}
+
+func seconds(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
+}
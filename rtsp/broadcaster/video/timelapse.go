@@ -0,0 +1,201 @@
+package video
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"strzcam.com/broadcaster/encryption"
+)
+
+type TimeRange struct {
+	Start time.Time
+	End   time.Time
+}
+
+type TimelapseOptions struct {
+	Speedup          int // one of Speedup frames is kept
+	DetectionSpeedup int // used during detections, 0 keeps Speedup
+}
+
+// frameStep converts a speed-up at the output framerate to a frame step of the source
+func frameStep(speedup int, sourceFps float64, outputFps float64) int {
+	if sourceFps <= 0 {
+		sourceFps = outputFps
+	}
+	return max(1, int(math.Round(float64(speedup)*sourceFps/outputFps)))
+}
+
+// timelapseSelect keeps every step frame, detections are sampled more often.
+// Detection times are mapped to video time, pauses are not in recordings.
+func timelapseSelect(source ClipSource, detections []TimeRange, step int, detectionStep int) string {
+	var during []string
+	if detectionStep != step {
+		for _, detection := range detections {
+			from := source.Metadata.VideoOffset(detection.Start).Seconds()
+			to := source.Metadata.VideoOffset(detection.End).Seconds()
+			if to <= from {
+				continue
+			}
+			during = append(during, fmt.Sprintf(`between(t\,%.3f\,%.3f)`, from, to))
+		}
+	}
+	if len(during) == 0 {
+		return fmt.Sprintf(`select='not(mod(n\,%d))'`, step)
+	}
+	return fmt.Sprintf(`select='if(%s\,not(mod(n\,%d))\,not(mod(n\,%d)))'`,
+		strings.Join(during, "+"), detectionStep, step)
+}
+
+// BuildTimelapse speeds up the recordings into one H.264 stream stored like
+// converted recordings. The returned metadata describes the encoding.
+func BuildTimelapse(ctx context.Context, sources []ClipSource, detections []TimeRange, options TimelapseOptions, outputPath string) (Metadata, error) {
+	if len(sources) == 0 {
+		return Metadata{}, errors.New("no recordings for the timelapse")
+	}
+	if options.Speedup < 1 {
+		return Metadata{}, errors.New("timelapse speed-up must be at least 1")
+	}
+	if options.DetectionSpeedup < 1 {
+		options.DetectionSpeedup = options.Speedup
+	}
+	first := sources[0].Metadata
+	output, err := encryption.Create(outputPath, encryption.Default())
+	if err != nil {
+		return Metadata{}, err
+	}
+	_, fps := outputFormat(first)
+	err = writeTimelapse(ctx, sources, detections, options, output)
+	if closeErr := output.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(outputPath)
+		return Metadata{}, err
+	}
+	metadata := Metadata{Tier: TierOriginal, Codec: CodecH264, Fps: fps}
+	if first.Width > 0 && first.Height > 0 {
+		metadata.Width = first.Width - first.Width%2
+		metadata.Height = first.Height - first.Height%2
+	}
+	return metadata, nil
+}
+
+// writeTimelapse joins sped up recordings into raw H.264 written to w. Like
+// clips, parts go through a pipe as MPEG-TS, recordings may be encrypted and
+// nothing is stored in clear.
+func writeTimelapse(ctx context.Context, sources []ClipSource, detections []TimeRange, options TimelapseOptions, w io.Writer) error {
+	partsOutput, concatInput, err := os.Pipe()
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	var stderr strings.Builder
+	concat := exec.CommandContext(ctx, "ffmpeg",
+		"-f", "mpegts",
+		"-i", "pipe:0",
+		"-c", "copy",
+		"-f", "h264",
+		"pipe:1",
+	)
+	concat.Stdin = partsOutput
+	concat.Stdout = w
+	concat.Stderr = &stderr
+	err = concat.Start()
+	partsOutput.Close()
+	if err != nil {
+		concatInput.Close()
+		return err
+	}
+
+	scale, fps := outputFormat(sources[0].Metadata)
+	var done time.Duration
+	var partsErr error
+	for _, source := range sources {
+		step := frameStep(options.Speedup, source.Metadata.Fps, fps)
+		detectionStep := frameStep(options.DetectionSpeedup, source.Metadata.Fps, fps)
+		filter := fmt.Sprintf("%s,setpts=N/(%f*TB),%s", timelapseSelect(source, detections, step, detectionStep), fps, scale)
+		frames, err := timelapsePart(ctx, source, filter, fps, done, concatInput)
+		if err != nil {
+			partsErr = fmt.Errorf("failed to speed up %s: %w", filepath.Base(source.Path), err)
+			cancel()
+			break
+		}
+		done += seconds(float64(frames) / fps)
+	}
+	concatInput.Close()
+	if err := concat.Wait(); partsErr == nil && err != nil {
+		return fmt.Errorf("ffmpeg concat failed: %w\n%s", err, stderr.String())
+	}
+	return partsErr
+}
+
+// timelapsePart appends the sped up recording as MPEG-TS to w, its timestamps
+// start at start. It returns the number of frames written.
+func timelapsePart(ctx context.Context, source ClipSource, filter string, fps float64, start time.Duration, w *os.File) (int, error) {
+	input, err := OpenVideo(source.Path)
+	if err != nil {
+		return 0, err
+	}
+	defer input.Close()
+	args := []string{"-f", InputFormat(source.Metadata.Codec)}
+	if source.Metadata.Fps > 0 {
+		args = append(args, "-framerate", fmt.Sprintf("%f", source.Metadata.Fps))
+	}
+	args = append(args,
+		"-i", "pipe:0",
+		"-vf", filter,
+		"-r", fmt.Sprintf("%f", fps),
+		"-c:v", "libx264",
+		"-preset", "veryfast",
+		"-profile:v", "baseline",
+		"-pix_fmt", "yuv420p",
+		"-bf", "0", // keep it playable by StaticVideoTrack
+		"-g", fmt.Sprintf("%d", int(fps)),
+		"-output_ts_offset", fmt.Sprintf("%.3f", start.Seconds()),
+		// stdout carries the video, progress goes to the first extra file
+		"-progress", "pipe:3",
+		"-nostats",
+		"-f", "mpegts",
+		"pipe:1",
+	)
+	progressOutput, progressInput, err := os.Pipe()
+	if err != nil {
+		return 0, err
+	}
+	defer progressOutput.Close()
+	cmd := exec.CommandContext(ctx, "ffmpeg", args...)
+	cmd.Stdin = input
+	cmd.Stdout = w
+	cmd.ExtraFiles = []*os.File{progressInput}
+	var stderr strings.Builder
+	cmd.Stderr = &stderr
+	err = cmd.Start()
+	progressInput.Close()
+	if err != nil {
+		return 0, err
+	}
+	frames := 0
+	scanner := bufio.NewScanner(progressOutput)
+	for scanner.Scan() {
+		if value, ok := strings.CutPrefix(scanner.Text(), "frame="); ok {
+			if n, err := strconv.Atoi(value); err == nil {
+				frames = n
+			}
+		}
+	}
+	if err := cmd.Wait(); err != nil {
+		return 0, fmt.Errorf("%w\n%s", err, stderr.String())
+	}
+	return frames, nil
+}
@@ -0,0 +1,26 @@
+package video
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTimelapseSelectSkipsPauses(t *testing.T) {
+	base := time.Date(2025, 1, 2, 14, 0, 0, 0, time.UTC)
+	// two events a minute apart, 10 frames each at 10 fps
+	var captured []time.Time
+	for _, event := range []time.Time{base, base.Add(time.Minute)} {
+		for i := 0; i < 10; i++ {
+			captured = append(captured, event.Add(time.Duration(i)*100*time.Millisecond))
+		}
+	}
+	source := ClipSource{Metadata: Metadata{Fps: 10, Start: captured[0], End: captured[len(captured)-1], Spans: NewSpans(captured, 10)}}
+	detections := []TimeRange{
+		{Start: base.Add(time.Minute), End: base.Add(time.Minute + 450*time.Millisecond)},
+		{Start: base.Add(10 * time.Second), End: base.Add(20 * time.Second)}, // nothing recorded
+	}
+	expected := `select='if(between(t\,1.000\,1.500)\,not(mod(n\,2))\,not(mod(n\,8)))'`
+	if filter := timelapseSelect(source, detections, 8, 2); filter != expected {
+		t.Errorf("Expected the detection at the second event in video time\n%s, got\n%s", expected, filter)
+	}
+}
@@ -18,11 +18,24 @@ import (
 	"strings"
 
 	"github.com/libp2p/go-libp2p/core/network"
+	"strzcam.com/broadcaster/encryption"
 )
 
+const (
+	TypeRecording = "recording"
+	TypeTimelapse = "timelapse"
+	TypeImport    = "import"
+)
+
+// IsFootage tells camera footage, recorded or imported, from derived videos
+func IsFootage(videoType string) bool {
+	return videoType == TypeRecording || videoType == TypeImport
+}
+
 type Video struct {
 	Name string
 	Size int64
+	Type string
 }
 
 func GetVideoByPath(path string) ([]byte, error) {
@@ -30,13 +43,7 @@ func GetVideoByPath(path string) ([]byte, error) {
 		return nil, fmt.Errorf("path does not exist: %s", path)
 	}
 
-	file, err := os.Open(path)
-	if err != nil {
-		return nil, fmt.Errorf("error opening file: %v", err)
-	}
-	defer file.Close()
-
-	data, err := os.ReadFile(path)
+	data, err := encryption.ReadFile(path, encryption.Default())
 	if err != nil {
 		return nil, fmt.Errorf("error reading file: %v", err)
 	}
@@ -44,59 +51,104 @@ func GetVideoByPath(path string) ([]byte, error) {
 	return data, nil
 }
 
-func GetVideoByDateRange(path string, start time.Time, end time.Time) ([]Video, error) {
-	if _, err := os.Stat(path); os.IsNotExist(err) {
-		return nil, fmt.Errorf("path does not exist: %s", path)
+// OpenVideo returns the plaintext of a stored video, decrypting it when needed
+func OpenVideo(path string) (encryption.File, error) {
+	return encryption.Open(path, encryption.Default())
+}
+
+// date Y-m-d-part-video_length for recordings, Y-m-d-timelapse for summaries
+// and Y-m-d-import-HHMMSS for imported footage
+var videoNamePattern = regexp.MustCompile(`^(\d{4}-\d{2}-\d{2})-(?:(\d+)(?:-\d+)?|(timelapse)|(import)-\d{6})\.mp4$`)
+
+// ParseVideoName returns the date and part of a stored video, timelapses
+// and imports have part 0 so they follow recordings of their day.
+func ParseVideoName(name string) (time.Time, int, bool) {
+	date, part, _, ok := parseVideoName(name)
+	return date, part, ok
+}
+
+// VideoType returns the type of a stored video, empty for other files
+func VideoType(name string) string {
+	_, _, videoType, _ := parseVideoName(name)
+	return videoType
+}
+
+func TimelapseName(date time.Time) string {
+	return fmt.Sprintf("%s-%s.mp4", date.Format("2006-01-02"), TypeTimelapse)
+}
+
+func parseVideoName(name string) (time.Time, int, string, bool) {
+	matches := videoNamePattern.FindStringSubmatch(name)
+	if matches == nil {
+		return time.Time{}, 0, "", false
 	}
-	var videoList []Video = []Video{}
-	//date Y-m-d-part-video_length
-	pattern := regexp.MustCompile(`^(\d{4}-\d{2}-\d{2})-(\d+)(?:-\d+)?\.mp4$`)
+	date, err := time.Parse("2006-01-02", matches[1])
+	if err != nil {
+		return time.Time{}, 0, "", false
+	}
+	if matches[3] != "" {
+		return date, 0, TypeTimelapse, true
+	}
+	if matches[4] != "" {
+		return date, 0, TypeImport, true
+	}
+	part, _ := strconv.Atoi(matches[2])
+	return date, part, TypeRecording, true
+}
 
-	err := filepath.Walk(path, func(pathWalk string, info os.FileInfo, err error) error {
-		if err != nil {
-			return fmt.Errorf("error accessing file %s: %v", pathWalk, err)
+func GetVideoByDateRange(paths []string, start time.Time, end time.Time) ([]Video, error) {
+	var videoList []Video = []Video{}
+	seen := map[string]bool{}
+	existing := 0
+	for _, path := range paths {
+		if _, err := os.Stat(path); os.IsNotExist(err) {
+			log.Printf("Skipping missing storage root: %s", path)
+			continue
 		}
-		if info.IsDir() {
+		existing++
+		err := filepath.Walk(path, func(pathWalk string, info os.FileInfo, err error) error {
+			if err != nil {
+				return fmt.Errorf("error accessing file %s: %v", pathWalk, err)
+			}
+			if info.IsDir() {
+				if info.Name() == QuarantineDir {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			fileName := info.Name()
+			fileDate, _, videoType, ok := parseVideoName(fileName)
+			if !ok || seen[fileName] {
+				return nil // Skip files that don't match pattern or are being moved between roots
+			}
+			if (fileDate.Equal(start) || fileDate.After(start)) &&
+				(fileDate.Equal(end) || fileDate.Before(end)) {
+				stat, _ := info.Sys().(*syscall.Stat_t)
+				seen[fileName] = true
+				videoList = append(videoList, Video{
+					Name: fileName,
+					Size: int64(stat.Blocks) * 512,
+					Type: videoType,
+				})
+			}
+
 			return nil
-		}
-		fileName := info.Name()
-		matches := pattern.FindStringSubmatch(fileName)
-		if matches == nil {
-			return nil // Skip files that don't match pattern
-		}
-		dateStr := matches[1] // YYYY-MM-DD part
-		fileDate, err := time.Parse("2006-01-02", dateStr)
+		})
 		if err != nil {
-			return nil // Skip files with invalid date format
+			return nil, err
 		}
-		if (fileDate.Equal(start) || fileDate.After(start)) &&
-			(fileDate.Equal(end) || fileDate.Before(end)) {
-			stat, _ := info.Sys().(*syscall.Stat_t)
-			videoList = append(videoList, Video{
-				Name: fileName,
-				Size: int64(stat.Blocks) * 512,
-			})
-		}
-
-		return nil
-	})
-
-	if err != nil {
-		return nil, err
+	}
+	if existing == 0 {
+		return nil, fmt.Errorf("path does not exist: %s", strings.Join(paths, ", "))
 	}
 
 	// Sort by date (newest first), then by part number (highest first) for same dates
 	sort.Slice(videoList, func(i, j int) bool {
-		matchesA := pattern.FindStringSubmatch(videoList[i].Name)
-		matchesB := pattern.FindStringSubmatch(videoList[j].Name)
-
-		dateA, _ := time.Parse("2006-01-02", matchesA[1])
-		dateB, _ := time.Parse("2006-01-02", matchesB[1])
+		dateA, partA, _ := ParseVideoName(videoList[i].Name)
+		dateB, partB, _ := ParseVideoName(videoList[j].Name)
 
 		if dateA.Equal(dateB) {
 			// For same date, sort by part number (highest first)
-			partA, _ := strconv.Atoi(matchesA[2])
-			partB, _ := strconv.Atoi(matchesB[2])
 			return partA > partB
 		}
 		return dateA.After(dateB)
@@ -105,21 +157,30 @@ func GetVideoByDateRange(path string, start time.Time, end time.Time) ([]Video,
 	return videoList, nil
 }
 
+// FindVideo returns the path of the video in the first root that has it
+func FindVideo(paths []string, name string) (string, error) {
+	if err := ValidateFilename(name); err != nil {
+		return "", err
+	}
+	for _, path := range paths {
+		filePath := filepath.Join(path, name)
+		if _, err := os.Stat(filePath); err == nil {
+			return filePath, nil
+		}
+	}
+	return "", fmt.Errorf("video %s not found", name)
+}
+
 // Stream file directly from disk to network without loading into memory
 func StreamFileToNetwork(stream network.Stream, filePath string) error {
 	// Open file
-	file, err := os.Open(filePath)
+	file, err := OpenVideo(filePath)
 	if err != nil {
 		return fmt.Errorf("failed to open file: %w", err)
 	}
 	defer file.Close()
 
-	// Get file info for size
-	fileInfo, err := file.Stat()
-	if err != nil {
-		return fmt.Errorf("failed to get file info: %w", err)
-	}
-	sizeHeader := fmt.Sprintf("%d\n", fileInfo.Size())
+	sizeHeader := fmt.Sprintf("%d\n", file.Size())
 	if _, err := stream.Write([]byte(sizeHeader)); err != nil {
 		return fmt.Errorf("failed to write size header: %w", err)
 	}
@@ -195,18 +256,13 @@ func ValidateFilename(filename string) error {
 
 // Optional: Progress tracking for large files
 func StreamFileWithProgress(stream network.Stream, filePath string) error {
-	file, err := os.Open(filePath)
+	file, err := OpenVideo(filePath)
 	if err != nil {
 		return fmt.Errorf("failed to open file: %w", err)
 	}
 	defer file.Close()
 
-	fileInfo, err := file.Stat()
-	if err != nil {
-		return fmt.Errorf("failed to get file info: %w", err)
-	}
-
-	fileSize := fileInfo.Size()
+	fileSize := file.Size()
 
 	// Send size header
 	sizeHeader := fmt.Sprintf("%d\n", fileSize)
@@ -254,16 +310,26 @@ func ConvertAndGetVideoForWeb(filePath string) ([]byte, error) {
 		os.Remove(tempFile.Name())
 	}()
 
+	metadata, err := ReadVideoMetadata(filePath)
+	if err != nil {
+		return nil, err
+	}
+	file, err := OpenVideo(filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
 	cmd := exec.Command("ffmpeg",
-		"-y",         // Force overwrite without asking
-		"-f", "h264", // Force input format to H264
-		"-i", filePath, // Input file
+		"-y",                              // Force overwrite without asking
+		"-f", InputFormat(metadata.Codec), // Force input format of stored codec
+		"-i", "pipe:0", // Decrypted input file
 		"-c:v", "copy", // Copy video stream without re-encoding
 		"-f", "mp4", // Force MP4 container
 		"-movflags", "+faststart", // Enable fast start for streaming
 		tempFile.Name())
 
 	var stderr bytes.Buffer
+	cmd.Stdin = file
 	cmd.Stderr = &stderr
 
 	if err := cmd.Run(); err != nil {
@@ -0,0 +1,92 @@
+package watchdog
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// Supervisor keeps the camera command running, restarting it with
+// exponential backoff when it exits
+type Supervisor struct {
+	Command     []string
+	LogPath     string // command output is appended here, empty logs it with a prefix
+	MinBackoff  time.Duration
+	MaxBackoff  time.Duration
+	StableAfter time.Duration // a run this long resets the backoff
+}
+
+func NewSupervisor(command string, logPath string) (*Supervisor, error) {
+	fields := strings.Fields(command)
+	if len(fields) == 0 {
+		return nil, errors.New("empty camera command")
+	}
+	return &Supervisor{
+		Command:     fields,
+		LogPath:     logPath,
+		MinBackoff:  time.Second,
+		MaxBackoff:  time.Minute,
+		StableAfter: time.Minute,
+	}, nil
+}
+
+// Run blocks until ctx is done, the command is stopped with SIGTERM then
+func (s *Supervisor) Run(ctx context.Context) {
+	backoff := s.MinBackoff
+	for {
+		started := time.Now()
+		err := s.run(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		if time.Since(started) >= s.StableAfter {
+			backoff = s.MinBackoff
+		}
+		log.Printf("Camera command exited: %v, restarting in %s", err, backoff)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+		backoff = min(backoff*2, s.MaxBackoff)
+	}
+}
+
+func (s *Supervisor) run(ctx context.Context) error {
+	cmd := exec.CommandContext(ctx, s.Command[0], s.Command[1:]...)
+	cmd.Cancel = func() error {
+		return cmd.Process.Signal(syscall.SIGTERM)
+	}
+	cmd.WaitDelay = 10 * time.Second
+	if s.LogPath != "" {
+		file, err := os.OpenFile(s.LogPath, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		cmd.Stdout = file
+		cmd.Stderr = file
+	} else {
+		reader, writer := io.Pipe()
+		defer writer.Close()
+		cmd.Stdout = writer
+		cmd.Stderr = writer
+		go func() {
+			scanner := bufio.NewScanner(reader)
+			for scanner.Scan() {
+				log.Printf("[camera] %s", scanner.Text())
+			}
+			// keep the command writing after a line too long to scan
+			io.Copy(io.Discard, reader)
+		}()
+	}
+	log.Printf("Starting camera command: %s", strings.Join(s.Command, " "))
+	return cmd.Run()
+}
@@ -0,0 +1,98 @@
+package watchdog
+
+import (
+	"sync"
+	"time"
+)
+
+// State tells whether the camera still delivers frames
+type State struct {
+	Camera    string    `json:"camera"`
+	Online    bool      `json:"online"`
+	LastFrame time.Time `json:"lastFrame"` // zero until the first frame
+	Since     time.Time `json:"since"`     // when Online last changed
+}
+
+// Watchdog marks the camera offline when no frame arrived within Timeout.
+// A camera is offline until its first frame.
+type Watchdog struct {
+	Timeout     time.Duration
+	mu          sync.Mutex
+	state       State
+	subscribers []chan State
+}
+
+func New(camera string, timeout time.Duration, now time.Time) *Watchdog {
+	return &Watchdog{Timeout: timeout, state: State{Camera: camera, Since: now}}
+}
+
+// Frame records a frame, it returns true when the camera came back online
+func (w *Watchdog) Frame(now time.Time) bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.state.LastFrame = now
+	if w.state.Online {
+		return false
+	}
+	w.state.Online = true
+	w.state.Since = now
+	w.publish()
+	return true
+}
+
+// Check returns true when the camera just went offline
+func (w *Watchdog) Check(now time.Time) bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if !w.state.Online || now.Sub(w.state.LastFrame) < w.Timeout {
+		return false
+	}
+	w.state.Online = false
+	w.state.Since = now
+	w.publish()
+	return true
+}
+
+// SetTimeout changes the timeout of a running watchdog
+func (w *Watchdog) SetTimeout(timeout time.Duration) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.Timeout = timeout
+}
+
+func (w *Watchdog) State() State {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.state
+}
+
+// Subscribe returns a channel getting the state on every change. A slow
+// subscriber only gets the latest state. Call the returned function to stop.
+func (w *Watchdog) Subscribe() (<-chan State, func()) {
+	subscriber := make(chan State, 1)
+	w.mu.Lock()
+	w.subscribers = append(w.subscribers, subscriber)
+	w.mu.Unlock()
+	return subscriber, func() {
+		w.mu.Lock()
+		defer w.mu.Unlock()
+		for i, s := range w.subscribers {
+			if s == subscriber {
+				w.subscribers = append(w.subscribers[:i], w.subscribers[i+1:]...)
+				close(subscriber)
+				return
+			}
+		}
+	}
+}
+
+// publish is called with the lock held, it is the only sender
+func (w *Watchdog) publish() {
+	for _, subscriber := range w.subscribers {
+		select {
+		case <-subscriber:
+		default:
+		}
+		subscriber <- w.state
+	}
+}
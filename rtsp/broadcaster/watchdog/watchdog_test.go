@@ -0,0 +1,60 @@
+package watchdog
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWatchdog(t *testing.T) {
+	start := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	w := New("front", 10*time.Second, start)
+	states, unsubscribe := w.Subscribe()
+	if w.Check(start.Add(time.Minute)) {
+		t.Error("Expected a camera without frames to stay offline without a change")
+	}
+	if !w.Frame(start.Add(time.Second)) || w.Frame(start.Add(2*time.Second)) {
+		t.Error("Expected only the first frame to bring the camera online")
+	}
+	if state := <-states; !state.Online {
+		t.Errorf("Expected online state, got %+v", state)
+	}
+	if w.Check(start.Add(11 * time.Second)) {
+		t.Error("Expected the camera to be online within the timeout")
+	}
+	if !w.Check(start.Add(12 * time.Second)) {
+		t.Fatal("Expected the camera to go offline after the timeout")
+	}
+	state := <-states
+	if state.Online || !state.Since.Equal(start.Add(12*time.Second)) || !state.LastFrame.Equal(start.Add(2*time.Second)) {
+		t.Errorf("Unexpected offline state %+v", state)
+	}
+	unsubscribe()
+	w.Frame(start.Add(time.Minute))
+	if _, ok := <-states; ok {
+		t.Error("Expected the channel to be closed after unsubscribing")
+	}
+}
+
+func TestSupervisorRestarts(t *testing.T) {
+	logPath := filepath.Join(t.TempDir(), "camera.log")
+	s, err := NewSupervisor("echo started", logPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.MinBackoff = 10 * time.Millisecond
+	s.MaxBackoff = 20 * time.Millisecond
+	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancel()
+	s.Run(ctx)
+	output, err := os.ReadFile(logPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if runs := strings.Count(string(output), "started"); runs < 3 {
+		t.Errorf("Expected the command to be restarted, it ran %d times", runs)
+	}
+}
@@ -0,0 +1,305 @@
+package watcher
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/crypto"
+	"strzcam.com/broadcaster/encryption"
+	"strzcam.com/broadcaster/video"
+)
+
+// Archiver re-encodes old recordings to a smaller preset so more days fit in
+// ConvertedVideoSpace before size-based deletion kicks in.
+type Archiver struct {
+	paths      []string
+	Config     Config
+	SigningKey crypto.PrivKey
+	mux        sync.Mutex
+	pending    atomic.Pointer[Config] // set by Reconfigure
+}
+
+func NewArchiver(paths []string, config Config) *Archiver {
+	return &Archiver{paths: paths, Config: config}
+}
+
+func (a *Archiver) IsEnabled() bool {
+	return a.Config.ArchiveAfterDays > 0
+}
+
+// GetArchiveCandidates returns paths of original videos older than
+// ArchiveAfterDays from all storage roots, oldest first.
+func (a *Archiver) GetArchiveCandidates(now time.Time) ([]string, error) {
+	var candidates []string
+	for _, path := range a.paths {
+		rootCandidates, err := a.getRootArchiveCandidates(path, now)
+		if err != nil {
+			return nil, err
+		}
+		candidates = append(candidates, rootCandidates...)
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		return filepath.Base(candidates[i]) < filepath.Base(candidates[j])
+	})
+	return candidates, nil
+}
+
+func (a *Archiver) getRootArchiveCandidates(rootPath string, now time.Time) ([]string, error) {
+	files, err := os.ReadDir(rootPath)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	year, month, day := now.Date()
+	today := time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+	olderThan := today.AddDate(0, 0, -a.Config.ArchiveAfterDays)
+	var candidates []string
+	for _, file := range files {
+		if file.IsDir() {
+			continue
+		}
+		date, _, ok := video.ParseVideoName(file.Name())
+		if !ok || !date.Before(olderThan) {
+			continue
+		}
+		if !video.IsFootage(video.VideoType(file.Name())) {
+			// timelapses are small already
+			continue
+		}
+		path := filepath.Join(rootPath, file.Name())
+		metadata, err := video.ReadVideoMetadata(path)
+		if err != nil {
+			log.Printf("Skipping %s from archiving: %v", path, err)
+			continue
+		}
+		if metadata.Tier == video.TierArchive {
+			continue
+		}
+		candidates = append(candidates, path)
+	}
+	return candidates, nil
+}
+
+func (a *Archiver) encoderArgs() ([]string, error) {
+	gop := fmt.Sprintf("%d", a.Config.ArchiveFps)
+	switch a.Config.ArchiveCodec {
+	case video.CodecH264:
+		return []string{
+			"-c:v", "libx264",
+			"-preset", "medium",
+			"-profile:v", "baseline",
+			"-bf", "0", // keep it playable by StaticVideoTrack
+			"-g", gop,
+			"-bsf:v", "h264_mp4toannexb",
+			"-f", "h264",
+		}, nil
+	case video.CodecH265:
+		return []string{
+			"-c:v", "libx265",
+			"-preset", "medium",
+			"-g", gop,
+			"-f", "hevc",
+		}, nil
+	case video.CodecVP9:
+		return []string{
+			"-c:v", "libvpx-vp9",
+			"-deadline", "good",
+			"-g", gop,
+			// a pipe can not be seeked to write the index at the start
+			"-f", "mp4",
+			"-movflags", "frag_keyframe+empty_moov+default_base_moof",
+		}, nil
+	}
+	return nil, fmt.Errorf("unsupported archive codec: %s", a.Config.ArchiveCodec)
+}
+
+// ArchiveVideo transcodes the video next to the original and atomically
+// swaps it in, so readers never see a partially written file.
+func (a *Archiver) ArchiveVideo(ctx context.Context, path string) error {
+	metadata, err := video.ReadVideoMetadata(path)
+	if err != nil {
+		return err
+	}
+	encoder, err := a.encoderArgs()
+	if err != nil {
+		return err
+	}
+	tmpPath := path + ".archive.tmp"
+	args := []string{"-y", "-f", video.InputFormat(metadata.Codec)}
+	if metadata.Fps > 0 {
+		args = append(args, "-framerate", fmt.Sprintf("%f", metadata.Fps))
+	}
+	args = append(args,
+		"-i", "pipe:0",
+		"-vf", fmt.Sprintf("scale=-2:%d,fps=%d", a.Config.ArchiveHeight, a.Config.ArchiveFps),
+		"-pix_fmt", "yuv420p",
+		"-b:v", a.Config.ArchiveBitrate,
+		"-maxrate", a.Config.ArchiveBitrate,
+	)
+	args = append(args, encoder...)
+	args = append(args, "pipe:1")
+
+	// recordings may be encrypted, ffmpeg only sees plaintext through pipes
+	input, err := video.OpenVideo(path)
+	if err != nil {
+		return err
+	}
+	defer input.Close()
+	output, err := encryption.Create(tmpPath, encryption.Default())
+	if err != nil {
+		return err
+	}
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, "ffmpeg", args...)
+	cmd.Cancel = func() error {
+		return cmd.Process.Signal(syscall.SIGTERM)
+	}
+	cmd.WaitDelay = 3 * time.Second
+	cmd.Stdin = input
+	cmd.Stdout = output
+	cmd.Stderr = &stderr
+	err = cmd.Run()
+	if closeErr := output.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("ffmpeg archiving failed: %w\n%s", err, stderr.String())
+	}
+	archived := video.Metadata{
+		Tier:    video.TierArchive,
+		Codec:   a.Config.ArchiveCodec,
+		Height:  uint32(a.Config.ArchiveHeight),
+		Fps:     float64(a.Config.ArchiveFps),
+		Bitrate: a.Config.ArchiveBitrate,
+		Camera:  metadata.Camera,
+		Start:   metadata.Start,
+		End:     metadata.End,
+		Spans:   metadata.Spans,
+		// the chain covers original recordings, the archive is linked to it
+		Signature: metadata.Signature,
+	}
+	if metadata.Width > 0 && metadata.Height > 0 {
+		// same as ffmpeg scale=-2:h, width rounded to an even number
+		width := uint32(a.Config.ArchiveHeight) * metadata.Width / metadata.Height
+		archived.Width = width - width%2
+	}
+	if err := video.SetChecksums(tmpPath, &archived); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to compute checksums: %w", err)
+	}
+	if metadata.Signature != nil {
+		if a.SigningKey == nil {
+			os.Remove(tmpPath)
+			return fmt.Errorf("signed recording can not be archived without the provider key")
+		}
+		archiveSignature, err := video.Sign(a.SigningKey, video.Signature{
+			Camera:     metadata.Camera,
+			Start:      metadata.Start,
+			End:        metadata.End,
+			SHA256:     archived.SHA256,
+			PrevSHA256: metadata.Signature.SHA256,
+		})
+		if err != nil {
+			os.Remove(tmpPath)
+			return fmt.Errorf("failed to sign archive: %w", err)
+		}
+		archived.ArchiveSignature = &archiveSignature
+	}
+	return replaceVideo(path, tmpPath, archived)
+}
+
+// replaceVideo swaps in the video at tmpPath with its metadata. The original
+// is kept aside until both are renamed and put back when either fails.
+func replaceVideo(path string, tmpPath string, metadata video.Metadata) error {
+	metadataTmpPath := video.MetadataPath(path) + ".archive.tmp"
+	if err := video.WriteMetadata(metadataTmpPath, metadata); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("cannot write archive metadata: %w", err)
+	}
+	removeTemps := func() {
+		os.Remove(tmpPath)
+		os.Remove(metadataTmpPath)
+	}
+	originalPath := path + ".original.tmp"
+	if err := os.Rename(path, originalPath); err != nil {
+		removeTemps()
+		if errors.Is(err, os.ErrNotExist) {
+			// removed by retention while we were encoding
+			return nil
+		}
+		return fmt.Errorf("cannot move original video aside: %w", err)
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		os.Rename(originalPath, path)
+		removeTemps()
+		return fmt.Errorf("cannot replace original video: %w", err)
+	}
+	if err := os.Rename(metadataTmpPath, video.MetadataPath(path)); err != nil {
+		os.Rename(originalPath, path)
+		removeTemps()
+		return fmt.Errorf("cannot replace original metadata: %w", err)
+	}
+	return os.Remove(originalPath)
+}
+
+// Reconfigure takes effect from the next run
+func (a *Archiver) Reconfigure(config Config) {
+	a.pending.Store(&config)
+}
+
+// RunUntilComplete archives due videos, on cancellation the original of the
+// video in progress is kept
+func (a *Archiver) RunUntilComplete(ctx context.Context) {
+	a.mux.Lock()
+	defer a.mux.Unlock()
+	if config := a.pending.Swap(nil); config != nil {
+		a.Config = *config
+		a.paths = NewStorage(*config).VideoPaths()
+	}
+	if !a.IsEnabled() {
+		return
+	}
+	candidates, err := a.GetArchiveCandidates(time.Now())
+	if err != nil {
+		log.Printf("Can not list videos to archive: %v", err)
+		return
+	}
+	for _, path := range candidates {
+		if ctx.Err() != nil {
+			return
+		}
+		log.Printf("Archiving %s", path)
+		if err := a.ArchiveVideo(ctx, path); err != nil {
+			log.Printf("Archiving %s failed: %v", path, err)
+		}
+	}
+}
+
+// Watch runs every interval, also when disabled so Reconfigure can enable it
+func (a *Archiver) Watch(ctx context.Context, interval time.Duration) {
+	a.RunUntilComplete(ctx)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			a.RunUntilComplete(ctx)
+		}
+	}
+}
@@ -0,0 +1,119 @@
+package watcher
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"strzcam.com/broadcaster/video"
+)
+
+func TestGetArchiveCandidates(t *testing.T) {
+	t.Run("only old original videos", func(t *testing.T) {
+		tempDir := t.TempDir()
+		for _, name := range []string{"2025-01-01-1.mp4", "2025-01-01-2.mp4", "2025-01-09-1.mp4", "2025-01-10-1.mp4", "notes.txt"} {
+			if err := os.WriteFile(filepath.Join(tempDir, name), make([]byte, 10), 0644); err != nil {
+				t.Fatalf("Failed to create test file: %v", err)
+			}
+		}
+		archived := filepath.Join(tempDir, "2025-01-01-2.mp4")
+		if err := video.SaveVideoMetadata(archived, video.Metadata{Tier: video.TierArchive, Codec: video.CodecH264}); err != nil {
+			t.Fatal(err)
+		}
+		archiver := NewArchiver([]string{tempDir}, Config{ArchiveAfterDays: 1})
+		now := time.Date(2025, 1, 10, 12, 0, 0, 0, time.UTC)
+		candidates, err := archiver.GetArchiveCandidates(now)
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected := []string{filepath.Join(tempDir, "2025-01-01-1.mp4")}
+		if !reflect.DeepEqual(candidates, expected) {
+			t.Errorf("Expected candidates %v, got %v", expected, candidates)
+		}
+	})
+	t.Run("disabled archiving", func(t *testing.T) {
+		archiver := NewArchiver([]string{t.TempDir()}, Config{ArchiveAfterDays: 0})
+		if archiver.IsEnabled() {
+			t.Error("Archiver should be disabled")
+		}
+	})
+}
+
+func TestReplaceVideo(t *testing.T) {
+	setup := func(t *testing.T) (string, string) {
+		tempDir := t.TempDir()
+		path := filepath.Join(tempDir, "2025-01-01-1.mp4")
+		if err := os.WriteFile(path, []byte("original"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := video.SaveVideoMetadata(path, video.Metadata{Tier: video.TierOriginal, Codec: video.CodecH264}); err != nil {
+			t.Fatal(err)
+		}
+		tmpPath := path + ".archive.tmp"
+		if err := os.WriteFile(tmpPath, []byte("archive"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return tempDir, path
+	}
+	archived := video.Metadata{Tier: video.TierArchive, Codec: video.CodecVP9}
+	expectFiles := func(t *testing.T, dir string, expected ...string) {
+		t.Helper()
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var names []string
+		for _, entry := range entries {
+			names = append(names, entry.Name())
+		}
+		if !reflect.DeepEqual(names, expected) {
+			t.Errorf("Expected files %v, got %v", expected, names)
+		}
+	}
+
+	t.Run("swaps video and metadata", func(t *testing.T) {
+		dir, path := setup(t)
+		if err := replaceVideo(path, path+".archive.tmp", archived); err != nil {
+			t.Fatal(err)
+		}
+		data, _ := os.ReadFile(path)
+		if string(data) != "archive" {
+			t.Errorf("Expected the archive, got %q", data)
+		}
+		metadata, err := video.ReadVideoMetadata(path)
+		if err != nil || metadata.Tier != video.TierArchive {
+			t.Errorf("Expected archive metadata, got %+v, %v", metadata, err)
+		}
+		expectFiles(t, dir, "2025-01-01-1.json", "2025-01-01-1.mp4")
+	})
+	t.Run("original removed while encoding", func(t *testing.T) {
+		dir, path := setup(t)
+		os.Remove(path)
+		os.Remove(video.MetadataPath(path))
+		if err := replaceVideo(path, path+".archive.tmp", archived); err != nil {
+			t.Fatal(err)
+		}
+		expectFiles(t, dir)
+	})
+	t.Run("keeps the original when metadata can not be replaced", func(t *testing.T) {
+		dir, path := setup(t)
+		// a directory can not be replaced by a file
+		os.Remove(video.MetadataPath(path))
+		if err := os.Mkdir(video.MetadataPath(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(video.MetadataPath(path), "keep"), nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := replaceVideo(path, path+".archive.tmp", archived); err == nil {
+			t.Fatal("Expected an error")
+		}
+		data, _ := os.ReadFile(path)
+		if string(data) != "original" {
+			t.Errorf("Expected the original, got %q", data)
+		}
+		expectFiles(t, dir, "2025-01-01-1.json", "2025-01-01-1.mp4")
+	})
+}
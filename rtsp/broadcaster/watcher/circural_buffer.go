@@ -1,8 +1,11 @@
 package watcher
 
+import "time"
+
 // CircularBuffer holds a fixed number of []byte elements
 type CircularBuffer struct {
 	data     [][]byte
+	added    []time.Time // when each element was added
 	size     int
 	capacity int
 	head     int
@@ -12,6 +15,7 @@ type CircularBuffer struct {
 func NewCircularBuffer(capacity int) *CircularBuffer {
 	return &CircularBuffer{
 		data:     make([][]byte, capacity),
+		added:    make([]time.Time, capacity),
 		capacity: capacity,
 		head:     0,
 		size:     0,
@@ -21,6 +25,7 @@ func NewCircularBuffer(capacity int) *CircularBuffer {
 // Add appends a new []byte element, replacing the oldest if at capacity
 func (cb *CircularBuffer) Add(item []byte) {
 	cb.data[cb.head] = item
+	cb.added[cb.head] = time.Now()
 	cb.head = (cb.head + 1) % cb.capacity
 
 	if cb.size < cb.capacity {
@@ -49,6 +54,25 @@ func (cb *CircularBuffer) GetAll() [][]byte {
 	return result
 }
 
+// GetAddedTimes returns when the elements of GetAll were added
+func (cb *CircularBuffer) GetAddedTimes() []time.Time {
+	if cb.size == 0 {
+		return nil
+	}
+
+	result := make([]time.Time, cb.size)
+
+	if cb.size < cb.capacity {
+		copy(result, cb.added[:cb.size])
+	} else {
+		tail := cb.head
+		copy(result, cb.added[tail:])
+		copy(result[cb.capacity-tail:], cb.added[:tail])
+	}
+
+	return result
+}
+
 // Size returns current number of elements
 func (cb *CircularBuffer) Size() int {
 	return cb.size
@@ -0,0 +1,539 @@
+package watcher
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+	"gopkg.in/yaml.v3"
+	"strzcam.com/broadcaster/encryption"
+	"strzcam.com/broadcaster/health"
+	"strzcam.com/broadcaster/osd"
+	"strzcam.com/broadcaster/privacy"
+	"strzcam.com/broadcaster/schedule"
+	"strzcam.com/broadcaster/zones"
+)
+
+// fileConfig is the layout of the config file. Sizes are in bytes and other
+// units are named like the environment variables overriding them.
+type fileConfig struct {
+	Camera     string                   `yaml:"camera"` // section used by this process
+	Storage    storageSection           `yaml:"storage"`
+	Recording  recordingSection         `yaml:"recording"`
+	Archive    archiveSection           `yaml:"archive"`
+	Timelapse  timelapseSection         `yaml:"timelapse"`
+	Provider   providerSection          `yaml:"provider"`
+	Viewer     viewerSection            `yaml:"viewer"`
+	Ports      Ports                    `yaml:"ports"`
+	Server     serverSection            `yaml:"server"`
+	WebRTC     webRTCSection            `yaml:"webrtc"`
+	Encryption encryption.Settings      `yaml:"encryption"`
+	Auth       authSection              `yaml:"auth"`
+	Cameras    map[string]cameraSection `yaml:"cameras"`
+}
+
+// empty paths and sizes are derived from the chunk root and chunk size
+type storageSection struct {
+	ChunkRoot               string        `yaml:"chunkRoot"`
+	VideoRoots              []StorageRoot `yaml:"videoRoots"`
+	SaveChunkSize           int           `yaml:"saveChunkSize"`
+	ConvertedVideoSpace     int           `yaml:"convertedVideoSpace"`
+	SaveDirMaxSize          int           `yaml:"saveDirMaxSize"`
+	ConvertFramesBeforeDays int           `yaml:"convertFramesBeforeDays"`
+	EventsDir               string        `yaml:"eventsDir"`
+	HeatmapDir              string        `yaml:"heatmapDir"`
+	ScheduleStateFile       string        `yaml:"scheduleStateFile"`
+	HealthStateFile         string        `yaml:"healthStateFile"`
+}
+
+type recordingSection struct {
+	ShowWhatWasBefore int `yaml:"showWhatWasBefore"` // frames
+	ShowWhatWasAfter  int `yaml:"showWhatWasAfter"`
+}
+
+type archiveSection struct {
+	AfterDays int    `yaml:"afterDays"`
+	Height    int    `yaml:"height"`
+	Fps       int    `yaml:"fps"`
+	Bitrate   string `yaml:"bitrate"`
+	Codec     string `yaml:"codec"`
+}
+
+type timelapseSection struct {
+	Speedup          int `yaml:"speedup"`
+	DetectionSpeedup int `yaml:"detectionSpeedup"`
+}
+
+type providerSection struct {
+	KeyPath string `yaml:"keyPath"`
+}
+
+// Ports every command listens on
+type Ports struct {
+	Provider     int `yaml:"provider"`     // libp2p
+	Viewer       int `yaml:"viewer"`       // libp2p
+	ViewerHTTP   int `yaml:"viewerHttp"`   // http server of the viewer
+	Server       int `yaml:"server"`       // local http server
+	VideoCreator int `yaml:"videoCreator"` // http server of the video creator
+	Signaling    int `yaml:"signaling"`
+}
+
+type serverSection struct {
+	JpegSkipChunk  int `yaml:"jpegSkipChunk"`
+	JpegSkipFrames int `yaml:"jpegSkipFrames"`
+}
+
+type authSection struct {
+	Token string   `yaml:"token"` // bearer token of the HTTP server
+	Peers []string `yaml:"peers"` // ids of p2p viewers allowed to change the provider
+}
+
+type viewerSection struct {
+	KeyPath string `yaml:"keyPath"` // its peer id goes to auth.peers of providers
+}
+
+type ICEServer struct {
+	URLs       []string `yaml:"urls"`
+	Username   string   `yaml:"username"`
+	Credential string   `yaml:"credential"`
+}
+
+type webRTCSection struct {
+	SignalingURL string      `yaml:"signalingUrl"` // host:port of the signaling server
+	Live         bool        `yaml:"live"`         // stream the camera, recordings only when false
+	ICEServers   []ICEServer `yaml:"iceServers"`
+}
+
+type cameraSection struct {
+	SharedMemory           string            `yaml:"sharedMemory"` // the camera id when empty
+	Zones                  zones.CameraZones `yaml:"zones"`
+	Schedule               schedule.Schedule `yaml:"schedule"`
+	TimeZone               string            `yaml:"timeZone"` // of the schedule
+	PrivacyMasks           []privacy.Mask    `yaml:"privacyMasks"`
+	OSD                    osdSection        `yaml:"osd"`
+	Motion                 motionSection     `yaml:"motion"`
+	Health                 healthSection     `yaml:"health"`
+	WatchdogTimeoutSeconds int               `yaml:"watchdogTimeoutSeconds"`
+	Command                string            `yaml:"command"`
+	LogFile                string            `yaml:"logFile"`
+	Detectors              detectorsSection  `yaml:"detectors"`
+}
+
+type osdSection struct {
+	Stage      string `yaml:"stage"`
+	TimeFormat string `yaml:"timeFormat"`
+	Label      string `yaml:"label"` // the camera id when empty
+	Position   string `yaml:"position"`
+	Font       string `yaml:"font"`
+	FontSize   int    `yaml:"fontSize"`
+	Boxes      bool   `yaml:"boxes"`
+}
+
+type motionSection struct {
+	Enabled     bool `yaml:"enabled"`
+	Scale       int  `yaml:"scale"`
+	Threshold   int  `yaml:"threshold"`
+	MinArea     int  `yaml:"minArea"`
+	NoiseFilter int  `yaml:"noiseFilter"`
+	MinFrames   int  `yaml:"minFrames"`
+}
+
+type healthSection struct {
+	Enabled            bool `yaml:"enabled"`
+	DarkLuma           int  `yaml:"darkLuma"`
+	UniformStdDev      int  `yaml:"uniformStdDev"`
+	SceneChangePercent int  `yaml:"sceneChangePercent"`
+	BlurPercent        int  `yaml:"blurPercent"`
+	FrozenSeconds      int  `yaml:"frozenSeconds"`
+	RaiseSeconds       int  `yaml:"raiseSeconds"`
+	ClearSeconds       int  `yaml:"clearSeconds"`
+}
+
+type detectorsSection struct {
+	Addresses []string `yaml:"addresses"`
+	Fps       int      `yaml:"fps"`
+	TimeoutMs int      `yaml:"timeoutMs"`
+	MaxAgeMs  int      `yaml:"maxAgeMs"`
+}
+
+var defaultICEServers = []ICEServer{
+	{
+		URLs: []string{
+			"stun:stun.l.google.com:19302",
+			"stun:stun2.l.google.com:19302",
+			"stun:stun3.l.google.com:19302",
+			"stun:stun.1und1.de:3478",
+			"stun:stun.avigora.com:3478",
+			"stun:stun.avigora.fr:3478",
+		},
+	},
+	{
+		URLs:       []string{"turn:global.turn.twilio.com:3478?transport=udp"},
+		Username:   "dc2d2894d5a9023620c467b0e71cfa6a35457e6679785ed6ae9856fe5bdfa269",
+		Credential: "tE2DajzSbc123",
+	},
+	{
+		URLs:       []string{"turn:openrelay.metered.ca:80", "turn:openrelay.metered.ca:443"},
+		Username:   "openrelayproject",
+		Credential: "openrelayproject",
+	},
+	{
+		URLs:       []string{"turn:openrelay.metered.ca:443?transport=tcp"},
+		Username:   "openrelayproject",
+		Credential: "openrelayproject",
+	},
+}
+
+func defaultFileConfig() fileConfig {
+	return fileConfig{
+		Storage: storageSection{
+			SaveChunkSize:           1024 * 1024 * 1024,
+			ConvertFramesBeforeDays: 1,
+		},
+		Recording: recordingSection{
+			ShowWhatWasBefore: 30 * 60 * 1, // FPS * seconds * minutes
+			ShowWhatWasAfter:  30 * 60 * 1,
+		},
+		Archive:  archiveSection{Height: 480, Fps: 10, Bitrate: "500k", Codec: "h264"},
+		Provider: providerSection{KeyPath: "./provider.key"},
+		Viewer:   viewerSection{KeyPath: "./viewer.key"},
+		Ports: Ports{
+			Provider:     10000,
+			Viewer:       10001,
+			ViewerHTTP:   7080,
+			Server:       7071,
+			VideoCreator: 7072,
+			Signaling:    7070,
+		},
+		Server: serverSection{JpegSkipChunk: 4, JpegSkipFrames: 10},
+		WebRTC: webRTCSection{SignalingURL: "localhost:7070", ICEServers: defaultICEServers},
+	}
+}
+
+func defaultCameraSection() cameraSection {
+	config := health.DefaultConfig()
+	return cameraSection{
+		TimeZone: "Local",
+		OSD: osdSection{
+			Stage:      osd.StageOff,
+			TimeFormat: "2006-01-02 15:04:05",
+			Position:   osd.PositionTopLeft,
+		},
+		Motion: motionSection{Scale: 8, Threshold: 25, MinArea: 500, NoiseFilter: 2, MinFrames: 2},
+		Health: healthSection{
+			Enabled:            true,
+			DarkLuma:           int(config.DarkLuma),
+			UniformStdDev:      int(config.UniformStdDev),
+			SceneChangePercent: int(config.SceneChange * 100),
+			BlurPercent:        int(config.BlurRatio * 100),
+			FrozenSeconds:      int(config.FrozenAfter.Seconds()),
+			RaiseSeconds:       int(config.RaiseAfter.Seconds()),
+			ClearSeconds:       int(config.ClearAfter.Seconds()),
+		},
+		WatchdogTimeoutSeconds: 10,
+		Detectors:              detectorsSection{Fps: 5, TimeoutMs: 1000, MaxAgeMs: 1000},
+	}
+}
+
+// readConfigFile decodes the file over defaults, unknown keys are errors so
+// typos do not silently fall back to defaults. A missing file is no error
+// when it is optional.
+func readConfigFile(path string, optional bool) (fileConfig, error) {
+	file := defaultFileConfig()
+	data, err := os.ReadFile(path)
+	if optional && errors.Is(err, os.ErrNotExist) {
+		return file, nil
+	}
+	if err != nil {
+		return file, fmt.Errorf("can not read config file: %w", err)
+	}
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&file); err != nil && !errors.Is(err, io.EOF) {
+		return file, fmt.Errorf("invalid config file %s: %w", path, err)
+	}
+	// camera sections start from their own defaults, the strict pass above
+	// decoded them over zero values
+	var cameras struct {
+		Cameras map[string]yaml.Node `yaml:"cameras"`
+	}
+	if err := yaml.Unmarshal(data, &cameras); err != nil {
+		return file, fmt.Errorf("invalid config file %s: %w", path, err)
+	}
+	for camera, node := range cameras.Cameras {
+		section := defaultCameraSection()
+		if err := node.Decode(&section); err != nil {
+			return file, fmt.Errorf("invalid config file %s, camera %s: %w", path, camera, err)
+		}
+		file.Cameras[camera] = section
+	}
+	return file, nil
+}
+
+func (f fileConfig) cameraNames() []string {
+	names := make([]string, 0, len(f.Cameras))
+	for name := range f.Cameras {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// env applies environment overrides and collects values that do not parse
+type env struct {
+	problems []string
+}
+
+func (e *env) string(key string, value *string) {
+	if v := os.Getenv(key); v != "" {
+		*value = v
+	}
+}
+
+func (e *env) int(key string, value *int) {
+	v := os.Getenv(key)
+	if v == "" {
+		return
+	}
+	parsed, err := strconv.Atoi(strings.TrimSpace(v))
+	if err != nil {
+		e.problems = append(e.problems, fmt.Sprintf("%s: %q is not a whole number", key, v))
+		return
+	}
+	*value = parsed
+}
+
+func (e *env) bool(key string, value *bool) {
+	v := os.Getenv(key)
+	if v == "" {
+		return
+	}
+	parsed, err := strconv.ParseBool(v)
+	if err != nil {
+		e.problems = append(e.problems, fmt.Sprintf("%s: %q is not true or false", key, v))
+		return
+	}
+	*value = parsed
+}
+
+func (e *env) list(key string, value *[]string) {
+	if v := os.Getenv(key); v != "" {
+		*value = splitList(v)
+	}
+}
+
+func (e *env) applyFile(file *fileConfig) {
+	e.string("CAMERA_ID", &file.Camera)
+	storage := &file.Storage
+	e.string("STORAGE_CHUNK_ROOT", &storage.ChunkRoot)
+	if v := os.Getenv("STORAGE_VIDEO_ROOTS"); v != "" {
+		roots, err := ParseStorageRoots(v)
+		if err != nil {
+			e.problems = append(e.problems, fmt.Sprintf("STORAGE_VIDEO_ROOTS: %v", err))
+		}
+		storage.VideoRoots = roots
+	}
+	e.int("SAVE_CHUNK_SIZE", &storage.SaveChunkSize)
+	e.int("CONVERTED_VIDEO_SPACE", &storage.ConvertedVideoSpace)
+	e.int("SAVE_DIR_MAX_SIZE", &storage.SaveDirMaxSize)
+	e.int("CONVERT_FRAMES_BEFORE_DAYS", &storage.ConvertFramesBeforeDays)
+	e.string("EVENTS_DIR", &storage.EventsDir)
+	e.string("HEATMAP_DIR", &storage.HeatmapDir)
+	e.string("SCHEDULE_STATE_FILE", &storage.ScheduleStateFile)
+	e.string("HEALTH_STATE_FILE", &storage.HealthStateFile)
+	e.int("SHOW_WHAT_WAS_BEFORE", &file.Recording.ShowWhatWasBefore)
+	e.int("SHOW_WHAT_WAS_AFTER", &file.Recording.ShowWhatWasAfter)
+	e.int("ARCHIVE_AFTER_DAYS", &file.Archive.AfterDays)
+	e.int("ARCHIVE_HEIGHT", &file.Archive.Height)
+	e.int("ARCHIVE_FPS", &file.Archive.Fps)
+	e.string("ARCHIVE_BITRATE", &file.Archive.Bitrate)
+	e.string("ARCHIVE_CODEC", &file.Archive.Codec)
+	e.int("TIMELAPSE_SPEEDUP", &file.Timelapse.Speedup)
+	e.int("TIMELAPSE_DETECTION_SPEEDUP", &file.Timelapse.DetectionSpeedup)
+	e.string("PROVIDER_KEY_PATH", &file.Provider.KeyPath)
+	e.string("VIEWER_KEY_PATH", &file.Viewer.KeyPath)
+	e.int("SERVER_CONVERSION_TO_JPEG_SKIP_CHUNK", &file.Server.JpegSkipChunk)
+	e.int("SERVER_CONVERSION_TO_JPEG_SKIP_FRAMES", &file.Server.JpegSkipFrames)
+	e.string("SIGNALING_URL", &file.WebRTC.SignalingURL)
+	e.bool("WEBRTC_STREAM_LIVE", &file.WebRTC.Live)
+	e.string("ENCRYPTION_KEY_FILE", &file.Encryption.KeyFile)
+	e.string("ENCRYPTION_PASSPHRASE", &file.Encryption.Passphrase)
+	if os.Getenv("ENCRYPTION_OLD_PASSPHRASES") != "" {
+		file.Encryption.OldPassphrases = encryption.SettingsFromEnv().OldPassphrases
+	}
+	e.string("ENCRYPTION_SALT_FILE", &file.Encryption.SaltFile)
+	e.string("AUTH_TOKEN", &file.Auth.Token)
+	e.list("AUTH_PEERS", &file.Auth.Peers)
+}
+
+func (e *env) applyCamera(section *cameraSection) {
+	e.string("VIDEO_FRAME", &section.SharedMemory)
+	e.string("SCHEDULE_TIMEZONE", &section.TimeZone)
+	e.string("OSD", &section.OSD.Stage)
+	e.string("OSD_TIME_FORMAT", &section.OSD.TimeFormat)
+	e.string("OSD_LABEL", &section.OSD.Label)
+	e.string("OSD_POSITION", &section.OSD.Position)
+	e.string("OSD_FONT", &section.OSD.Font)
+	e.int("OSD_FONT_SIZE", &section.OSD.FontSize)
+	e.bool("OSD_BOXES", &section.OSD.Boxes)
+	e.bool("MOTION_DETECTION", &section.Motion.Enabled)
+	e.int("MOTION_SCALE", &section.Motion.Scale)
+	e.int("MOTION_THRESHOLD", &section.Motion.Threshold)
+	e.int("MOTION_MIN_AREA", &section.Motion.MinArea)
+	e.int("MOTION_NOISE_FILTER", &section.Motion.NoiseFilter)
+	e.int("MOTION_MIN_FRAMES", &section.Motion.MinFrames)
+	e.bool("HEALTH_CHECKS", &section.Health.Enabled)
+	e.int("HEALTH_DARK_LUMA", &section.Health.DarkLuma)
+	e.int("HEALTH_UNIFORM_STDDEV", &section.Health.UniformStdDev)
+	e.int("HEALTH_SCENE_CHANGE_PERCENT", &section.Health.SceneChangePercent)
+	e.int("HEALTH_BLUR_PERCENT", &section.Health.BlurPercent)
+	e.int("HEALTH_FROZEN_SECONDS", &section.Health.FrozenSeconds)
+	e.int("HEALTH_RAISE_SECONDS", &section.Health.RaiseSeconds)
+	e.int("HEALTH_CLEAR_SECONDS", &section.Health.ClearSeconds)
+	e.int("WATCHDOG_TIMEOUT_SECONDS", &section.WatchdogTimeoutSeconds)
+	e.string("CAMERA_COMMAND", &section.Command)
+	e.string("CAMERA_LOG_FILE", &section.LogFile)
+	e.list("DETECTORS", &section.Detectors.Addresses)
+	e.int("DETECTOR_FPS", &section.Detectors.Fps)
+	e.int("DETECTOR_TIMEOUT_MS", &section.Detectors.TimeoutMs)
+	e.int("DETECTOR_MAX_AGE_MS", &section.Detectors.MaxAgeMs)
+}
+
+// ConfigError lists every problem of a configuration
+type ConfigError struct {
+	Source   string
+	Problems []string
+}
+
+func (e *ConfigError) Error() string {
+	return fmt.Sprintf("invalid configuration %s:\n  %s", e.Source, strings.Join(e.Problems, "\n  "))
+}
+
+type problems []string
+
+func (p *problems) add(field string, format string, args ...any) {
+	*p = append(*p, field+": "+fmt.Sprintf(format, args...))
+}
+
+func (p *problems) check(field string, err error) {
+	if err != nil {
+		p.add(field, "%v", err)
+	}
+}
+
+func (p *problems) positive(field string, value int) {
+	if value <= 0 {
+		p.add(field, "must be greater than 0, got %d", value)
+	}
+}
+
+func (p *problems) notNegative(field string, value int) {
+	if value < 0 {
+		p.add(field, "must not be negative, got %d", value)
+	}
+}
+
+func (p *problems) percent(field string, value int) {
+	if value < 0 || value > 100 {
+		p.add(field, "must be between 0 and 100, got %d", value)
+	}
+}
+
+func (p *problems) oneOf(field string, value string, allowed ...string) {
+	if !slices.Contains(allowed, value) {
+		p.add(field, "%q is not one of %s", value, strings.Join(allowed, ", "))
+	}
+}
+
+func (p *problems) validateFile(file fileConfig) {
+	if len(file.Cameras) > 0 {
+		if _, ok := file.Cameras[file.Camera]; !ok {
+			p.add("camera", "%q has no section in cameras, defined are %s", file.Camera, strings.Join(file.cameraNames(), ", "))
+		}
+	}
+	p.positive("storage.saveChunkSize", file.Storage.SaveChunkSize)
+	p.notNegative("storage.convertedVideoSpace", file.Storage.ConvertedVideoSpace)
+	p.notNegative("storage.saveDirMaxSize", file.Storage.SaveDirMaxSize)
+	p.notNegative("storage.convertFramesBeforeDays", file.Storage.ConvertFramesBeforeDays)
+	for i, root := range file.Storage.VideoRoots {
+		field := fmt.Sprintf("storage.videoRoots[%d]", i)
+		if root.Path == "" {
+			p.add(field, "path is required")
+		}
+		p.notNegative(field+".maxSize", root.MaxSize)
+		p.notNegative(field+".maxAgeDays", root.MaxAgeDays)
+	}
+	p.notNegative("recording.showWhatWasBefore", file.Recording.ShowWhatWasBefore)
+	p.notNegative("recording.showWhatWasAfter", file.Recording.ShowWhatWasAfter)
+	p.notNegative("archive.afterDays", file.Archive.AfterDays)
+	if file.Archive.AfterDays > 0 {
+		p.positive("archive.height", file.Archive.Height)
+		p.positive("archive.fps", file.Archive.Fps)
+		p.oneOf("archive.codec", file.Archive.Codec, "h264", "h265", "vp9")
+	}
+	p.notNegative("timelapse.speedup", file.Timelapse.Speedup)
+	p.notNegative("timelapse.detectionSpeedup", file.Timelapse.DetectionSpeedup)
+	ports := []struct {
+		field string
+		port  int
+	}{
+		{"ports.provider", file.Ports.Provider},
+		{"ports.viewer", file.Ports.Viewer},
+		{"ports.viewerHttp", file.Ports.ViewerHTTP},
+		{"ports.server", file.Ports.Server},
+		{"ports.videoCreator", file.Ports.VideoCreator},
+		{"ports.signaling", file.Ports.Signaling},
+	}
+	for _, port := range ports {
+		if port.port < 1 || port.port > 65535 {
+			p.add(port.field, "%d is not a port number", port.port)
+		}
+	}
+	p.positive("server.jpegSkipChunk", file.Server.JpegSkipChunk)
+	p.positive("server.jpegSkipFrames", file.Server.JpegSkipFrames)
+	for i, id := range file.Auth.Peers {
+		if _, err := peer.Decode(id); err != nil {
+			p.add(fmt.Sprintf("auth.peers[%d]", i), "%q is not a peer id", id)
+		}
+	}
+	for i, server := range file.WebRTC.ICEServers {
+		if len(server.URLs) == 0 {
+			p.add(fmt.Sprintf("webrtc.iceServers[%d].urls", i), "at least one url is required")
+		}
+	}
+}
+
+func (p *problems) validateCamera(prefix string, section cameraSection) {
+	p.check(prefix+".zones", section.Zones.Validate())
+	p.check(prefix+".schedule", section.Schedule.Validate())
+	for i, mask := range section.PrivacyMasks {
+		p.check(fmt.Sprintf("%s.privacyMasks[%d]", prefix, i), mask.Validate())
+	}
+	p.oneOf(prefix+".osd.stage", section.OSD.Stage, osd.StageOff, osd.StageRecording, osd.StageAll)
+	p.oneOf(prefix+".osd.position", section.OSD.Position, osd.PositionTopLeft, osd.PositionTopRight, osd.PositionBottomLeft, osd.PositionBottomRight)
+	p.notNegative(prefix+".osd.fontSize", section.OSD.FontSize)
+	if section.Motion.Enabled {
+		p.positive(prefix+".motion.scale", section.Motion.Scale)
+		p.positive(prefix+".motion.minFrames", section.Motion.MinFrames)
+		if section.Motion.Threshold < 0 || section.Motion.Threshold > 255 {
+			p.add(prefix+".motion.threshold", "must be between 0 and 255, got %d", section.Motion.Threshold)
+		}
+	}
+	if section.Health.Enabled {
+		p.percent(prefix+".health.sceneChangePercent", section.Health.SceneChangePercent)
+		p.percent(prefix+".health.blurPercent", section.Health.BlurPercent)
+		p.positive(prefix+".health.frozenSeconds", section.Health.FrozenSeconds)
+	}
+	p.notNegative(prefix+".watchdogTimeoutSeconds", section.WatchdogTimeoutSeconds)
+	if len(section.Detectors.Addresses) > 0 {
+		p.positive(prefix+".detectors.fps", section.Detectors.Fps)
+		p.positive(prefix+".detectors.timeoutMs", section.Detectors.TimeoutMs)
+		p.positive(prefix+".detectors.maxAgeMs", section.Detectors.MaxAgeMs)
+	}
+}
@@ -0,0 +1,150 @@
+package watcher
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeConfig(t *testing.T, content string) string {
+	t.Helper()
+	dir := t.TempDir()
+	t.Setenv("ZONES_FILE", filepath.Join(dir, "zones.json"))
+	t.Setenv("SCHEDULES_FILE", filepath.Join(dir, "schedules.json"))
+	t.Setenv("PRIVACY_MASKS_FILE", filepath.Join(dir, "privacy_masks.json"))
+	path := filepath.Join(dir, "config.yaml")
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadConfigFile(t *testing.T) {
+	t.Run("camera section over defaults", func(t *testing.T) {
+		path := writeConfig(t, `
+camera: garden
+storage:
+  chunkRoot: /data/chunks
+ports:
+  viewerHttp: 8080
+cameras:
+  garden:
+    zones:
+      minBoxWidth: 20
+    motion:
+      enabled: true
+      threshold: 40
+    detectors:
+      addresses: [/tmp/detector.sock]
+  front:
+    sharedMemory: front_frame
+`)
+		config, err := LoadConfigFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if config.Camera != "garden" || config.SharedMemory != "garden" {
+			t.Errorf("Expected camera and shared memory garden, got %s and %s", config.Camera, config.SharedMemory)
+		}
+		if config.Zones.MinBoxWidth != 20 || !config.MotionDetection || config.Motion.Threshold != 40 || config.Motion.Scale != 8 {
+			t.Errorf("Camera section not applied over defaults: %+v %+v", config.Zones, config.Motion)
+		}
+		if config.Ports.ViewerHTTP != 8080 || config.Ports.Provider != 10000 {
+			t.Errorf("Unexpected ports %+v", config.Ports)
+		}
+		if config.EventsDir != filepath.Join("/data/chunks", "events") {
+			t.Errorf("Expected events in the chunk root, got %s", config.EventsDir)
+		}
+		if !config.HealthChecks || config.Detectors.Timeout != time.Second {
+			t.Errorf("Expected default health checks and detector timeout, got %v %v", config.HealthChecks, config.Detectors.Timeout)
+		}
+		if len(config.ICEServers) == 0 {
+			t.Error("Expected default ICE servers")
+		}
+	})
+	t.Run("environment overrides the file", func(t *testing.T) {
+		path := writeConfig(t, `
+camera: garden
+cameras:
+  garden: {}
+  front:
+    sharedMemory: front_frame
+`)
+		t.Setenv("CAMERA_ID", "front")
+		t.Setenv("MOTION_DETECTION", "true")
+		config, err := LoadConfigFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if config.Camera != "front" || config.SharedMemory != "front_frame" || !config.MotionDetection {
+			t.Errorf("Expected environment to select front with motion, got %s %s %v", config.Camera, config.SharedMemory, config.MotionDetection)
+		}
+	})
+	t.Run("missing file", func(t *testing.T) {
+		writeConfig(t, "")
+		_, err := LoadConfigFile(filepath.Join(t.TempDir(), "missing.yaml"))
+		if !errors.Is(err, os.ErrNotExist) {
+			t.Errorf("Expected a missing file to be an error, got %v", err)
+		}
+	})
+	t.Run("missing default file uses defaults", func(t *testing.T) {
+		writeConfig(t, "")
+		config, err := loadConfigFile(filepath.Join(t.TempDir(), "missing.yaml"), true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if config.Camera != "video_frame" || config.ChunkRoot != "./saved_video_frame" {
+			t.Errorf("Unexpected defaults %s %s", config.Camera, config.ChunkRoot)
+		}
+	})
+	t.Run("unknown key", func(t *testing.T) {
+		path := writeConfig(t, "storage:\n  chunkRot: /data\n")
+		_, err := LoadConfigFile(path)
+		if err == nil || !strings.Contains(err.Error(), "line 2") || !strings.Contains(err.Error(), "chunkRot") {
+			t.Errorf("Expected an unknown field error with its line, got %v", err)
+		}
+	})
+	t.Run("all problems are reported", func(t *testing.T) {
+		path := writeConfig(t, `
+camera: garden
+archive:
+  afterDays: 7
+  codec: av1
+auth:
+  peers: [viewer]
+cameras:
+  garden:
+    osd:
+      position: middle
+    zones:
+      zones:
+        - name: door
+          points: [{x: 0, y: 0}, {x: 1, y: 1}]
+`)
+		t.Setenv("DETECTOR_FPS", "fast")
+		_, err := LoadConfigFile(path)
+		var configErr *ConfigError
+		if !errors.As(err, &configErr) {
+			t.Fatalf("Expected a ConfigError, got %v", err)
+		}
+		expected := []string{"DETECTOR_FPS", "archive.codec", "auth.peers[0]", "cameras.garden.zones", "cameras.garden.osd.position"}
+		if len(configErr.Problems) != len(expected) {
+			t.Fatalf("Expected %d problems, got %v", len(expected), configErr.Problems)
+		}
+		for i, field := range expected {
+			if !strings.HasPrefix(configErr.Problems[i], field+": ") {
+				t.Errorf("Expected problem of %s, got %s", field, configErr.Problems[i])
+			}
+		}
+	})
+	t.Run("camera without section", func(t *testing.T) {
+		path := writeConfig(t, "camera: back\ncameras:\n  front: {}\n  garden: {}\n")
+		_, err := LoadConfigFile(path)
+		if err == nil || !strings.Contains(err.Error(), "defined are front, garden") {
+			t.Errorf("Expected the defined cameras in the error, got %v", err)
+		}
+	})
+}
@@ -1,44 +1,286 @@
 package watcher
 
 import (
+	"fmt"
 	"log"
 	"os"
-	"strconv"
+	"path/filepath"
+	"strings"
+	"time"
 
 	"github.com/joho/godotenv"
+	"strzcam.com/broadcaster/detector"
+	"strzcam.com/broadcaster/encryption"
+	"strzcam.com/broadcaster/health"
+	"strzcam.com/broadcaster/motion"
+	"strzcam.com/broadcaster/osd"
+	"strzcam.com/broadcaster/privacy"
+	"strzcam.com/broadcaster/schedule"
+	"strzcam.com/broadcaster/watchdog"
+	"strzcam.com/broadcaster/zones"
 )
 
 const SavePath = "./saved"
 
 type Config struct { // Sizes in GB
-	ConvertFramesBeforeDays int
-	SaveChunkSize           int
-	ConvertedVideoSpace     int
-	SaveDirMaxSize          int
-	ShowWhatWasBefore       int
-	ShowWhatWasAfter        int
+	ConvertFramesBeforeDays   int
+	SaveChunkSize             int
+	ConvertedVideoSpace       int
+	SaveDirMaxSize            int
+	ShowWhatWasBefore         int
+	ShowWhatWasAfter          int
+	ArchiveAfterDays          int // 0 disables archival transcoding
+	ArchiveHeight             int
+	ArchiveFps                int
+	ArchiveBitrate            string
+	ArchiveCodec              string // h264, h265 or vp9
+	ChunkRoot                 string
+	VideoRoots                []StorageRoot
+	Camera                    string // identifies recordings in the signature chain
+	SharedMemory              string // name of the frames file in /dev/shm
+	ProviderKeyPath           string
+	ViewerKeyPath             string
+	EventsDir                 string
+	HeatmapDir                string
+	TimelapseSpeedup          int  // 0 disables daily timelapses
+	TimelapseDetectionSpeedup int  // speed-up during detections, 0 uses TimelapseSpeedup
+	MotionDetection           bool // marks frames with motion when the camera sets no detection
+	Motion                    motion.Config
+	Zones                     zones.CameraZones // zones of Camera
+	Schedule                  schedule.Schedule // arm/disarm schedule of Camera
+	ScheduleLocation          *time.Location    // time zone schedules are evaluated in
+	ScheduleStateFile         string            // manual override shared by processes
+	PrivacyMasks              []privacy.Mask    // privacy masks of Camera
+	OSD                       osd.Config
+	HealthChecks              bool // tamper and image health detection in the provider
+	Health                    health.Config
+	HealthStateFile           string
+	WatchdogTimeout           time.Duration   // camera is offline without frames this long, 0 disables
+	CameraCommand             string          // supervised by the provider when set
+	CameraLogFile             string          // output of CameraCommand, empty logs it
+	Detectors                 detector.Config // external detector plugins run by the provider
+	Ports                     Ports
+	ServerJpegSkipChunk       int
+	ServerJpegSkipFrames      int
+	SignalingURL              string // host:port of the signaling server
+	WebRTCLive                bool   // the offeror streams the camera
+	ICEServers                []ICEServer
+	Encryption                encryption.Settings
+	AuthToken                 string   // required by the HTTP server for secrets and changes, empty refuses them
+	AuthPeers                 []string // p2p viewers allowed to change the provider
+	ConfigFile                string
 }
 
+// NewConfig loads the configuration and sets up encryption with it, an
+// invalid one stops the process
 func NewConfig() Config {
+	config, err := LoadConfig()
+	if err != nil {
+		log.Fatal(err)
+	}
+	encryption.Configure(config.Encryption)
+	return config
+}
+
+// LoadConfig reads .env and CONFIG_FILE (./config.yaml), environment
+// variables override values of the file. Only ./config.yaml may be missing,
+// a CONFIG_FILE that does not exist is an error.
+func LoadConfig() (Config, error) {
 	if err := godotenv.Load(); err != nil {
 		log.Printf("Warning: Error loading .env file: %v", err)
 	}
-	saveChunkSize := getEnvAsInt("SAVE_CHUNK_SIZE", 1024*1024*1024)
-	return Config{
-		ConvertFramesBeforeDays: getEnvAsInt("CONVERT_FRAMES_BEFORE_DAYS", 1),
-		SaveChunkSize:           saveChunkSize,
-		ConvertedVideoSpace:     getEnvAsInt("CONVERTED_VIDEO_SPACE", saveChunkSize*10),
-		SaveDirMaxSize:          getEnvAsInt("SAVE_DIR_MAX_SIZE", saveChunkSize*100),
-		ShowWhatWasBefore:       getEnvAsInt("SHOW_WHAT_WAS_BEFORE", 30*60*1), // FPS * seconds * minutes
-		ShowWhatWasAfter:        getEnvAsInt("SHOW_WHAT_WAS_AFTER", 30*60*1),
+	if path := os.Getenv("CONFIG_FILE"); path != "" {
+		return LoadConfigFile(path)
 	}
+	return loadConfigFile("./config.yaml", true)
 }
 
-func getEnvAsInt(key string, defaultValue int) int {
-	if val := os.Getenv(key); val != "" {
-		if parsed, err := strconv.Atoi(val); err == nil {
-			return parsed
+// LoadConfigFile builds the configuration of the camera selected by the file
+// or CAMERA_ID. Zones, schedules and privacy masks of a camera without a
+// section in the file come from ZONES_FILE, SCHEDULES_FILE and
+// PRIVACY_MASKS_FILE. Every problem found is reported in a ConfigError.
+func LoadConfigFile(path string) (Config, error) {
+	return loadConfigFile(path, false)
+}
+
+// loadConfigFile uses defaults for a missing file when it is optional
+func loadConfigFile(path string, optional bool) (Config, error) {
+	file, err := readConfigFile(path, optional)
+	if err != nil {
+		return Config{}, err
+	}
+	var e env
+	e.applyFile(&file)
+	if file.Camera == "" {
+		file.Camera = getEnvAsString("VIDEO_FRAME", "video_frame")
+	}
+	camera := file.Camera
+	section, hasSection := file.Cameras[camera]
+	if !hasSection {
+		section = defaultCameraSection()
+	}
+	e.applyCamera(&section)
+	if section.SharedMemory == "" {
+		section.SharedMemory = "video_frame"
+		if hasSection {
+			section.SharedMemory = camera
 		}
 	}
+
+	p := problems(e.problems)
+	p.validateFile(file)
+	for _, name := range file.cameraNames() {
+		if name != camera {
+			p.validateCamera("cameras."+name, file.Cameras[name])
+		}
+	}
+	p.validateCamera("cameras."+camera, section)
+	if !hasSection {
+		zonesFile := getEnvAsString("ZONES_FILE", "./zones.json")
+		cameraZones, err := zones.Load(zonesFile)
+		p.check("ZONES_FILE", err)
+		section.Zones = cameraZones[camera]
+		schedules, err := schedule.Load(getEnvAsString("SCHEDULES_FILE", "./schedules.json"))
+		p.check("SCHEDULES_FILE", err)
+		section.Schedule = schedules[camera]
+		privacyMasks, err := privacy.Load(getEnvAsString("PRIVACY_MASKS_FILE", "./privacy_masks.json"))
+		p.check("PRIVACY_MASKS_FILE", err)
+		section.PrivacyMasks = privacyMasks[camera]
+	}
+	location, err := time.LoadLocation(section.TimeZone)
+	if err != nil {
+		p.add("cameras."+camera+".timeZone", "unknown time zone %q", section.TimeZone)
+	}
+	if len(p) > 0 {
+		return Config{}, &ConfigError{Source: path, Problems: p}
+	}
+
+	storage := file.Storage
+	if storage.ChunkRoot == "" {
+		storage.ChunkRoot = fmt.Sprintf("%s_%s", SavePath, section.SharedMemory)
+	}
+	if storage.ConvertedVideoSpace == 0 {
+		storage.ConvertedVideoSpace = storage.SaveChunkSize * 10
+	}
+	if storage.SaveDirMaxSize == 0 {
+		storage.SaveDirMaxSize = storage.SaveChunkSize * 100
+	}
+	if len(storage.VideoRoots) == 0 {
+		storage.VideoRoots = []StorageRoot{{Path: storage.ChunkRoot, MaxSize: storage.ConvertedVideoSpace}}
+	}
+	inChunkRoot := func(value string, name string) string {
+		if value == "" {
+			return filepath.Join(storage.ChunkRoot, name)
+		}
+		return value
+	}
+	if section.OSD.Label == "" {
+		section.OSD.Label = camera
+	}
+	healthConfig := health.DefaultConfig()
+	healthConfig.DarkLuma = float64(section.Health.DarkLuma)
+	healthConfig.UniformStdDev = float64(section.Health.UniformStdDev)
+	healthConfig.SceneChange = float64(section.Health.SceneChangePercent) / 100
+	healthConfig.BlurRatio = float64(section.Health.BlurPercent) / 100
+	healthConfig.FrozenAfter = time.Duration(section.Health.FrozenSeconds) * time.Second
+	healthConfig.RaiseAfter = time.Duration(section.Health.RaiseSeconds) * time.Second
+	healthConfig.ClearAfter = time.Duration(section.Health.ClearSeconds) * time.Second
+	config := Config{
+		ConfigFile:                path,
+		ConvertFramesBeforeDays:   storage.ConvertFramesBeforeDays,
+		SaveChunkSize:             storage.SaveChunkSize,
+		ConvertedVideoSpace:       storage.ConvertedVideoSpace,
+		SaveDirMaxSize:            storage.SaveDirMaxSize,
+		ShowWhatWasBefore:         file.Recording.ShowWhatWasBefore,
+		ShowWhatWasAfter:          file.Recording.ShowWhatWasAfter,
+		ArchiveAfterDays:          file.Archive.AfterDays,
+		ArchiveHeight:             file.Archive.Height,
+		ArchiveFps:                file.Archive.Fps,
+		ArchiveBitrate:            file.Archive.Bitrate,
+		ArchiveCodec:              file.Archive.Codec,
+		ChunkRoot:                 storage.ChunkRoot,
+		VideoRoots:                storage.VideoRoots,
+		Camera:                    camera,
+		SharedMemory:              section.SharedMemory,
+		ProviderKeyPath:           file.Provider.KeyPath,
+		ViewerKeyPath:             file.Viewer.KeyPath,
+		EventsDir:                 inChunkRoot(storage.EventsDir, "events"),
+		HeatmapDir:                inChunkRoot(storage.HeatmapDir, "heatmaps"),
+		TimelapseSpeedup:          file.Timelapse.Speedup,
+		TimelapseDetectionSpeedup: file.Timelapse.DetectionSpeedup,
+		MotionDetection:           section.Motion.Enabled,
+		Motion: motion.Config{
+			Scale:       section.Motion.Scale,
+			Threshold:   section.Motion.Threshold,
+			MinArea:     section.Motion.MinArea,
+			NoiseFilter: section.Motion.NoiseFilter,
+			MinFrames:   section.Motion.MinFrames,
+		},
+		Zones:             section.Zones,
+		Schedule:          section.Schedule,
+		ScheduleLocation:  location,
+		ScheduleStateFile: inChunkRoot(storage.ScheduleStateFile, "schedule_state.json"),
+		PrivacyMasks:      section.PrivacyMasks,
+		OSD: osd.Config{
+			Stage:      section.OSD.Stage,
+			TimeFormat: section.OSD.TimeFormat,
+			Label:      section.OSD.Label,
+			Position:   section.OSD.Position,
+			FontPath:   section.OSD.Font,
+			FontSize:   float64(section.OSD.FontSize),
+			Boxes:      section.OSD.Boxes,
+		},
+		HealthChecks:    section.Health.Enabled,
+		Health:          healthConfig,
+		HealthStateFile: inChunkRoot(storage.HealthStateFile, "health.json"),
+		WatchdogTimeout: time.Duration(section.WatchdogTimeoutSeconds) * time.Second,
+		CameraCommand:   section.Command,
+		CameraLogFile:   section.LogFile,
+		Detectors: detector.Config{
+			Addresses: section.Detectors.Addresses,
+			Fps:       float64(section.Detectors.Fps),
+			Timeout:   time.Duration(section.Detectors.TimeoutMs) * time.Millisecond,
+			MaxAge:    time.Duration(section.Detectors.MaxAgeMs) * time.Millisecond,
+		},
+		Ports:                file.Ports,
+		ServerJpegSkipChunk:  file.Server.JpegSkipChunk,
+		ServerJpegSkipFrames: file.Server.JpegSkipFrames,
+		SignalingURL:         file.WebRTC.SignalingURL,
+		WebRTCLive:           file.WebRTC.Live,
+		ICEServers:           file.WebRTC.ICEServers,
+		Encryption:           file.Encryption,
+		AuthToken:            file.Auth.Token,
+		AuthPeers:            file.Auth.Peers,
+	}
+	return config, nil
+}
+
+func (c Config) NewScheduleController() *schedule.Controller {
+	return schedule.NewController(c.Schedule, c.ScheduleLocation, c.ScheduleStateFile)
+}
+
+// NewWatchdog returns nil when the watchdog is disabled
+func (c Config) NewWatchdog() *watchdog.Watchdog {
+	if c.WatchdogTimeout <= 0 {
+		return nil
+	}
+	return watchdog.New(c.Camera, c.WatchdogTimeout, time.Now())
+}
+
+// splitList returns comma separated values without empty ones
+func splitList(value string) []string {
+	var values []string
+	for _, v := range strings.Split(value, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			values = append(values, v)
+		}
+	}
+	return values
+}
+
+func getEnvAsString(key string, defaultValue string) string {
+	if val := os.Getenv(key); val != "" {
+		return val
+	}
 	return defaultValue
 }
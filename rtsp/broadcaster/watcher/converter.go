@@ -2,7 +2,10 @@ package watcher
 
 import (
 	"bytes"
+	"context"
+	"errors"
 	"fmt"
+	"log"
 	"os"
 	"os/exec"
 	"path/filepath"
@@ -10,9 +13,13 @@ import (
 	"strconv"
 	"strings"
 	"sync"
+	"syscall"
 	"time"
 
 	"github.com/fsnotify/fsnotify"
+	"github.com/libp2p/go-libp2p/core/crypto"
+	"strzcam.com/broadcaster/encryption"
+	"strzcam.com/broadcaster/video"
 )
 
 type Converter struct {
@@ -25,9 +32,14 @@ type Converter struct {
 	Width        *uint32
 	Height       *uint32
 	Config       Config
+	Storage      Storage
+	settingsMux  sync.RWMutex   // guards Config and Storage once running
+	SigningKey   crypto.PrivKey // recordings are left unsigned when nil
 }
 
-func NewConverter(saveVideoPath string) (*Converter, error) {
+func NewConverter(config Config) (*Converter, error) {
+	storage := NewStorage(config)
+	saveVideoPath := storage.ChunkRoot
 	watcher, err := fsnotify.NewWatcher()
 	if err != nil {
 		return nil, err
@@ -39,8 +51,10 @@ func NewConverter(saveVideoPath string) (*Converter, error) {
 		hasJob:       false,
 		watchingDirs: []string{saveVideoPath},
 		Framerate:    &frameRate,
-		Config:       NewConfig(),
+		Config:       config,
+		Storage:      storage,
 	}
+	storage.CreateRoots()
 	c.AddToWatch(saveVideoPath)
 	dateDirs, _ := GetDateDirNames(saveVideoPath, []string{})
 	fmt.Printf("Watching directories: %v\n", dateDirs)
@@ -49,9 +63,14 @@ func NewConverter(saveVideoPath string) (*Converter, error) {
 	}
 	return c, nil
 }
-func (c *Converter) Watch() {
+
+// Watch converts chunks as new ones are started until ctx is done, a running
+// conversion is cancelled and its chunk is kept for the next start
+func (c *Converter) Watch(ctx context.Context) {
 	for {
 		select {
+		case <-ctx.Done():
+			return
 		case event, ok := <-c.watcher.Events:
 			if !ok {
 				return
@@ -64,9 +83,10 @@ func (c *Converter) Watch() {
 					if !c.hasJob {
 						skipDates := c.GetSkipDates()
 						for {
-							RemoveOldestDirs(c.savePath, skipDates, c.Config.SaveChunkSize, c.Config.SaveDirMaxSize)
-							RemoveOldestVideoFiles(c.savePath, skipDates, c.Config.ConvertedVideoSpace, c.Config.SaveChunkSize)
-							c.hasJob = c.convertLastChunkToVideo(c.savePath)
+							config, _ := c.settings()
+							RemoveOldestDirs(c.savePath, skipDates, config.SaveChunkSize, config.SaveDirMaxSize)
+							c.rebalanceVideos(skipDates)
+							c.hasJob = c.convertLastChunkToVideo(ctx, c.savePath)
 							if !c.hasJob {
 								break
 							}
@@ -91,10 +111,27 @@ func (c *Converter) AddToWatch(path string) {
 	c.watchingDirs = append(c.watchingDirs, path)
 	c.watcher.Add(path)
 }
+
+// settings returns the configuration in use, Reconfigure may change it
+func (c *Converter) settings() (Config, Storage) {
+	c.settingsMux.RLock()
+	defer c.settingsMux.RUnlock()
+	return c.Config, c.Storage
+}
+
+// Reconfigure changes retention limits from the next cleanup on
+func (c *Converter) Reconfigure(config Config) {
+	c.settingsMux.Lock()
+	defer c.settingsMux.Unlock()
+	c.Config = config
+	c.Storage = NewStorage(config)
+}
+
 func (c *Converter) GetSkipDates() []string {
+	config, _ := c.settings()
 	now := time.Now()
 	skipDates := []string{now.Format("2006-01-02")}
-	for i := 1; i <= c.Config.ConvertFramesBeforeDays; i++ {
+	for i := 1; i <= config.ConvertFramesBeforeDays; i++ {
 		pastDate := now.AddDate(0, 0, -i) // Subtract i days
 		skipDates = append(skipDates, pastDate.Format("2006-01-02"))
 	}
@@ -111,8 +148,14 @@ func RemoveOldestVideoFiles(savePath string, skipDates []string, convertedVideoS
 	}
 }
 
-func (c *Converter) convert(chunkPath string) error {
-	fmt.Printf("Starting FFmpeg conversion... %d\n", *c.Width)
+func (c *Converter) rebalanceVideos(skipDates []string) {
+	config, storage := c.settings()
+	storage.MoveOldVideos(time.Now())
+	storage.EnforceCapacity(skipDates, config.SaveChunkSize)
+}
+
+func (c *Converter) convert(ctx context.Context, chunkPath string) error {
+	log.Printf("Starting FFmpeg conversion... %d", *c.Width)
 	if *c.Width == 0 || *c.Height == 0 {
 		width, height, err := ReadMetadata(chunkPath)
 		if err != nil {
@@ -122,17 +165,21 @@ func (c *Converter) convert(chunkPath string) error {
 		*c.Height = height
 	}
 	patches := strings.Split(chunkPath, "/")
-	inputPattern := filepath.Join(chunkPath, "frame%d.yuv")
 	dateDirName, chunkDirName := patches[len(patches)-2], patches[len(patches)-1]
-	fmt.Printf("[FPS:%f] Converting frames in %s %v\n", *c.Framerate, dateDirName, patches)
-	outputPath := filepath.Join(append(patches[:len(patches)-2], fmt.Sprintf("%s-%s.mp4", dateDirName, chunkDirName))...)
+	log.Printf("[FPS:%f] Converting frames in %s %v", *c.Framerate, dateDirName, patches)
+	config, storage := c.settings()
+	outputPath := filepath.Join(storage.NewVideoPath(), fmt.Sprintf("%s-%s.mp4", dateDirName, chunkDirName))
+	start, end, err := GetChunkTimeRange(chunkPath)
+	if err != nil {
+		return fmt.Errorf("failed to read chunk time range: %w", err)
+	}
+	// frames may be encrypted, ffmpeg gets them decrypted through a pipe
 	args := []string{
-		"-f", "image2",
-		"-c:v", "rawvideo",
+		"-f", "rawvideo",
 		"-video_size", fmt.Sprintf("%dx%d", *c.Width, *c.Height),
 		"-pix_fmt", "yuv420p",
 		"-framerate", fmt.Sprintf("%f", *c.Framerate),
-		"-i", inputPattern,
+		"-i", "pipe:0",
 		"-c:v", "libx264",
 		"-preset", "medium",
 		"-tune", "zerolatency",
@@ -148,21 +195,89 @@ func (c *Converter) convert(chunkPath string) error {
 		"-bufsize", "4M",
 		"-bsf:v", "h264_mp4toannexb", // Ensure Annex B format with SPS/PPS
 		"-f", "h264",
-		outputPath,
+		"pipe:1",
+	}
+	output, err := encryption.Create(outputPath, encryption.Default())
+	if err != nil {
+		return fmt.Errorf("failed to create video: %w", err)
 	}
 	var stderr bytes.Buffer
 
-	cmd := exec.Command("ffmpeg", args...)
-	cmd.Stdout = os.Stdout
+	cmd := exec.CommandContext(ctx, "ffmpeg", args...)
+	cmd.Cancel = func() error {
+		return cmd.Process.Signal(syscall.SIGTERM)
+	}
+	cmd.WaitDelay = 3 * time.Second
+	// the video has no pauses, when frames were captured is kept in metadata
+	var captured []time.Time
+	cmd.Stdin = NewChunkFrameReader(chunkPath, int(*c.Width)*int(*c.Height)*3/2, func(t time.Time) {
+		captured = append(captured, t)
+	})
+	cmd.Stdout = output
 	cmd.Stderr = &stderr
 
-	if err := cmd.Run(); err != nil {
+	err = cmd.Run()
+	if closeErr := output.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(outputPath)
 		return fmt.Errorf("ffmpeg conversion failed: %w", err)
 	}
 	duration := parseDurationFromFFmpegOutput(stderr.String())
-	fmt.Printf("FFmpeg conversion succeeded: %s (%.2f seconds)\n", outputPath, duration)
-	return nil
+	log.Printf("FFmpeg conversion succeeded: %s (%.2f seconds)", outputPath, duration)
+	metadata := video.Metadata{
+		Tier:    video.TierOriginal,
+		Codec:   video.CodecH264,
+		Width:   *c.Width,
+		Height:  *c.Height,
+		Fps:     *c.Framerate,
+		Bitrate: "2M",
+		Camera:  config.Camera,
+		Start:   start,
+		End:     end,
+		Spans:   video.NewSpans(captured, *c.Framerate),
+	}
+	return c.saveConverted(outputPath, metadata, storage.ChunkRoot)
 }
+
+// errVideoNotSaved wraps failures after ffmpeg succeeded, the video is removed
+// and its chunk kept to convert it again
+var errVideoNotSaved = errors.New("converted video not saved")
+
+// saveConverted adds checksums and the signature to the metadata of the
+// converted video, a video left without them could never be verified
+func (c *Converter) saveConverted(outputPath string, metadata video.Metadata, chainDir string) error {
+	err := func() error {
+		if err := video.SetChecksums(outputPath, &metadata); err != nil {
+			return fmt.Errorf("failed to compute checksums: %w", err)
+		}
+		if c.SigningKey == nil {
+			return video.SaveVideoMetadata(outputPath, metadata)
+		}
+		signature, err := video.SignVideo(c.SigningKey, chainDir, metadata)
+		if err != nil {
+			return fmt.Errorf("failed to sign video: %w", err)
+		}
+		metadata.Signature = &signature
+		if err := video.SaveVideoMetadata(outputPath, metadata); err != nil {
+			return err
+		}
+		// only a saved signature may become the previous one of the next video
+		if err := video.WriteChainHead(chainDir, metadata.Camera, metadata.SHA256); err != nil {
+			return fmt.Errorf("failed to advance the signature chain: %w", err)
+		}
+		return nil
+	}()
+	if err == nil {
+		return nil
+	}
+	if removeErr := video.RemoveVideo(outputPath); removeErr != nil {
+		log.Printf("Can not remove unsaved video %s: %v", outputPath, removeErr)
+	}
+	return fmt.Errorf("%w: %w", errVideoNotSaved, err)
+}
+
 func parseDurationFromFFmpegOutput(output string) float64 {
 	re := regexp.MustCompile(`Duration: (\d{2}):(\d{2}):(\d{2}\.\d{2})`)
 	matches := re.FindStringSubmatch(output)
@@ -174,47 +289,58 @@ func parseDurationFromFFmpegOutput(output string) float64 {
 	}
 	return 0
 }
-func (c *Converter) convertLastChunkToVideo(savePath string) bool {
+func (c *Converter) convertLastChunkToVideo(ctx context.Context, savePath string) bool {
 	dirCount := CountChunksInDateDir(savePath, []string{})
-	fmt.Printf("Number of chunks in date dir: %d\n", dirCount)
+	log.Printf("Number of chunks in date dir: %d", dirCount)
 	chunkPath := GetOldestChunkInDateDir(savePath, []string{})
 	if chunkPath == "" {
-		fmt.Println("No chunk found to convert.")
+		log.Println("No chunk found to convert.")
 		return false
 	}
-	fmt.Printf("Converting last chunk: %s\n", chunkPath)
+	log.Printf("Converting last chunk: %s", chunkPath)
 	parts := strings.Split(chunkPath, "/")
 	dateDir := parts[len(parts)-2]
 	now := time.Now()
-	fmt.Printf("dir count %d, date dir %s, now %s\n", dirCount, dateDir, now.Format("2006-01-02"))
+	log.Printf("dir count %d, date dir %s, now %s", dirCount, dateDir, now.Format("2006-01-02"))
 	if dirCount < 2 && dateDir == now.Format("2006-01-02") {
 		// For now just skip last chunk, idea for changing this is to save size and
 		// convert all frames, then create a new video from it. Then concatenate
 		// the videos together with adding size. When the size is close to the limit
 		// just create a new chunk and remove the old one.
 		// This will allow to convert the last chunk and not wait for the next one.
-		fmt.Println("There is only one chunk that can be busy.")
+		log.Println("There is only one chunk that can be busy.")
 		return false
 	}
-	c.convert(chunkPath)
+	if err := c.convert(ctx, chunkPath); err != nil {
+		if ctx.Err() != nil {
+			log.Printf("Conversion of %s cancelled, the chunk is kept", chunkPath)
+			return false
+		}
+		if errors.Is(err, errVideoNotSaved) {
+			log.Printf("Error saving video of chunk %s, the chunk is kept: %v", chunkPath, err)
+			return false
+		}
+		log.Printf("Error converting chunk %s: %v", chunkPath, err)
+	}
 	err := os.RemoveAll(chunkPath)
 	if err != nil {
 		// another gorouting is writing file to the channel
-		fmt.Printf("Error removing chunk directory: %v\n", err)
+		log.Printf("Error removing chunk directory: %v", err)
 		err := os.RemoveAll(chunkPath)
-		fmt.Printf("Re-try removing chunk directory: %v\n", err)
+		log.Printf("Re-try removing chunk directory: %v", err)
 	}
 	return true
 }
 
-func (c *Converter) RunUntilComplete() {
+func (c *Converter) RunUntilComplete(ctx context.Context) {
 	skipDates := c.GetSkipDates()
 	c.mux.Lock()
 	defer c.mux.Unlock()
-	for {
-		RemoveOldestDirs(c.savePath, skipDates, c.Config.SaveChunkSize, c.Config.SaveDirMaxSize)
-		RemoveOldestVideoFiles(c.savePath, skipDates, c.Config.ConvertedVideoSpace, c.Config.SaveChunkSize)
-		c.hasJob = c.convertLastChunkToVideo(c.savePath)
+	for ctx.Err() == nil {
+		config, _ := c.settings()
+		RemoveOldestDirs(c.savePath, skipDates, config.SaveChunkSize, config.SaveDirMaxSize)
+		c.rebalanceVideos(skipDates)
+		c.hasJob = c.convertLastChunkToVideo(ctx, c.savePath)
 		if !c.hasJob {
 			break
 		}
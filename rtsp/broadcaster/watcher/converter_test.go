@@ -0,0 +1,63 @@
+package watcher
+
+import (
+	"crypto/rand"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/crypto"
+	"strzcam.com/broadcaster/video"
+)
+
+func TestSaveConverted(t *testing.T) {
+	key, _, err := crypto.GenerateEd25519Key(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	converter := &Converter{SigningKey: key}
+	setup := func(t *testing.T) (string, string, video.Metadata) {
+		dir := t.TempDir()
+		path := filepath.Join(dir, "2025-01-02-1-30.mp4")
+		if err := os.WriteFile(path, []byte("footage"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		start := time.Date(2025, 1, 2, 14, 0, 0, 0, time.UTC)
+		return dir, path, video.Metadata{Tier: video.TierOriginal, Codec: video.CodecH264, Camera: "front", Start: start, End: start.Add(time.Minute)}
+	}
+
+	t.Run("signs and advances the chain", func(t *testing.T) {
+		dir, path, metadata := setup(t)
+		if err := converter.saveConverted(path, metadata, dir); err != nil {
+			t.Fatal(err)
+		}
+		saved, err := video.ReadVideoMetadata(path)
+		if err != nil || saved.SHA256 == "" || saved.Signature == nil {
+			t.Fatalf("Expected signed metadata with a checksum, got %+v %v", saved, err)
+		}
+		if head, _ := video.ReadChainHead(dir, "front"); head != saved.SHA256 {
+			t.Errorf("Expected the chain head %s, got %s", saved.SHA256, head)
+		}
+	})
+	t.Run("failure removes the video", func(t *testing.T) {
+		dir, path, metadata := setup(t)
+		// the chain head can not be written
+		if err := os.Mkdir(filepath.Join(dir, "chain_head_front.tmp"), 0755); err != nil {
+			t.Fatal(err)
+		}
+		err := converter.saveConverted(path, metadata, dir)
+		if !errors.Is(err, errVideoNotSaved) {
+			t.Fatalf("Expected the video not saved, got %v", err)
+		}
+		for _, leftover := range []string{path, video.MetadataPath(path)} {
+			if _, err := os.Stat(leftover); !errors.Is(err, os.ErrNotExist) {
+				t.Errorf("Expected %s removed so the chunk is converted again, got %v", filepath.Base(leftover), err)
+			}
+		}
+		if head, _ := video.ReadChainHead(dir, "front"); head != "" {
+			t.Errorf("Expected the chain head unchanged, got %s", head)
+		}
+	})
+}
@@ -3,6 +3,7 @@ package watcher
 import (
 	"errors"
 	"fmt"
+	"io"
 	"log"
 	"os"
 	"path/filepath"
@@ -11,16 +12,28 @@ import (
 	"strconv"
 	"strings"
 	"syscall"
+	"time"
+
+	"strzcam.com/broadcaster/encryption"
+	"strzcam.com/broadcaster/video"
 )
 
-func SaveFrame(i int, b []byte, path string) {
+// SaveFrame writes a frame with its capture time as modification time, the
+// converter takes recording times from it
+func SaveFrame(i int, b []byte, path string, captured time.Time) {
 	//log.Printf("Saving frame to %s/frame%d\n", path, i)
-	f, err := os.Create(fmt.Sprintf("%s/frame%d.yuv", path, i))
+	// frames are encrypted at rest when a key is configured
+	framePath := fmt.Sprintf("%s/frame%d.yuv", path, i)
+	err := encryption.WriteFile(framePath, b, encryption.Default())
 	if err != nil {
 		panic(fmt.Sprintf("Cant create file: %v", err))
 	}
-	defer f.Close()
-	f.Write(b)
+	if captured.IsZero() {
+		return
+	}
+	if err := os.Chtimes(framePath, captured, captured); err != nil {
+		log.Printf("Can not set capture time of %s: %v", framePath, err)
+	}
 }
 func SaveMetadata(width, height uint32, path string) {
 	log.Printf("Saving frame to %s/meta.txt\n", path)
@@ -51,6 +64,98 @@ func ReadMetadata(path string) (uint32, uint32, error) {
 	}
 	return uint32(width), uint32(height), nil
 }
+
+// GetChunkFrames returns frame files of a chunk in recording order
+func GetChunkFrames(path string) ([]string, error) {
+	files, err := os.ReadDir(path)
+	if err != nil {
+		return nil, err
+	}
+	numbers := []int{}
+	for _, file := range files {
+		name := file.Name()
+		if file.IsDir() || !strings.HasPrefix(name, "frame") || !strings.HasSuffix(name, ".yuv") {
+			continue
+		}
+		number, err := strconv.Atoi(strings.TrimSuffix(strings.TrimPrefix(name, "frame"), ".yuv"))
+		if err != nil {
+			continue
+		}
+		numbers = append(numbers, number)
+	}
+	sort.Ints(numbers)
+	frames := make([]string, len(numbers))
+	for i, number := range numbers {
+		frames[i] = filepath.Join(path, fmt.Sprintf("frame%d.yuv", number))
+	}
+	return frames, nil
+}
+
+// NewChunkFrameReader streams decrypted frames of a chunk as raw video,
+// frames of unexpected size are skipped so the stream stays aligned. written
+// gets the capture time of every streamed frame.
+func NewChunkFrameReader(path string, frameSize int, written func(captured time.Time)) io.Reader {
+	reader, writer := io.Pipe()
+	go func() {
+		frames, err := GetChunkFrames(path)
+		if err != nil {
+			writer.CloseWithError(err)
+			return
+		}
+		keyring := encryption.Default()
+		for _, framePath := range frames {
+			info, err := os.Stat(framePath)
+			if err != nil {
+				log.Printf("Skipping unreadable frame %s: %v", framePath, err)
+				continue
+			}
+			data, err := encryption.ReadFile(framePath, keyring)
+			if err != nil {
+				log.Printf("Skipping unreadable frame %s: %v", framePath, err)
+				continue
+			}
+			if len(data) != frameSize {
+				log.Printf("Skipping frame %s of size %d, expected %d", framePath, len(data), frameSize)
+				continue
+			}
+			written(info.ModTime().UTC())
+			if _, err := writer.Write(data); err != nil {
+				return
+			}
+		}
+		writer.Close()
+	}()
+	return reader
+}
+
+// GetChunkTimeRange returns when the first and the last frame of a chunk were saved
+func GetChunkTimeRange(path string) (time.Time, time.Time, error) {
+	var start, end time.Time
+	files, err := os.ReadDir(path)
+	if err != nil {
+		return start, end, err
+	}
+	for _, file := range files {
+		if file.IsDir() || !strings.HasSuffix(file.Name(), ".yuv") {
+			continue
+		}
+		info, err := file.Info()
+		if err != nil {
+			return start, end, err
+		}
+		modTime := info.ModTime().UTC()
+		if start.IsZero() || modTime.Before(start) {
+			start = modTime
+		}
+		if modTime.After(end) {
+			end = modTime
+		}
+	}
+	if start.IsZero() {
+		return start, end, fmt.Errorf("no frames in %s", path)
+	}
+	return start, end, nil
+}
 func DirSize(path string) (int64, error) {
 	var size int64
 	err := filepath.Walk(path, func(_ string, info os.FileInfo, err error) error {
@@ -298,16 +403,14 @@ func IsCloseToVideoSize(path string, extensions []string, convertedVideoSpace in
 	}
 	return size >= limit
 }
-func RemoveOldestVideo(path string, extensions []string, skipDates []string, convertedVideoSpace int, saveChunkSize int) bool {
-	isClose := IsCloseToVideoSize(path, extensions, convertedVideoSpace, saveChunkSize)
-	if !isClose {
-		return false
-	}
-
+func GetOldestVideo(path string, extensions []string, skipDates []string) string {
 	var videos []string
 	files, _ := os.ReadDir(path)
 	for _, file := range files {
 		parts := strings.Split(file.Name(), "-")
+		if file.IsDir() || len(parts) < 3 {
+			continue
+		}
 		fileDate := fmt.Sprintf("%s-%s-%s", parts[0], parts[1], parts[2])
 		ext := filepath.Ext(file.Name())
 		if slices.Contains(extensions, ext) && !slices.Contains(skipDates, fileDate) {
@@ -315,14 +418,25 @@ func RemoveOldestVideo(path string, extensions []string, skipDates []string, con
 		}
 	}
 	if len(videos) == 0 {
+		return ""
+	}
+	sort.Strings(videos) // Natural sort works for this format
+	return videos[0]
+}
+func RemoveOldestVideo(path string, extensions []string, skipDates []string, convertedVideoSpace int, saveChunkSize int) bool {
+	isClose := IsCloseToVideoSize(path, extensions, convertedVideoSpace, saveChunkSize)
+	if !isClose {
+		return false
+	}
+
+	oldest := GetOldestVideo(path, extensions, skipDates)
+	if oldest == "" {
 		fmt.Println("No video files found")
 		return false
 	}
-	sort.Strings(videos) // Natural sort works for this format
-	oldest := videos[0]
 
 	fmt.Printf("Deleting oldest: %s/%s\n", path, oldest)
-	os.Remove(fmt.Sprintf("%s/%s", path, oldest))
+	video.RemoveVideo(fmt.Sprintf("%s/%s", path, oldest))
 	return true
 }
 
@@ -1,9 +1,13 @@
 package watcher
 
 import (
+	"io"
 	"os"
 	"path/filepath"
 	"testing"
+	"time"
+
+	"strzcam.com/broadcaster/video"
 )
 
 func TestIsCloseToVideoSize(t *testing.T) {
@@ -87,3 +91,36 @@ func TestDirIndex(t *testing.T) {
 		}
 	})
 }
+
+func TestChunkFrameCaptureTimes(t *testing.T) {
+	dir := t.TempDir()
+	event := time.Date(2025, 1, 2, 14, 0, 0, 0, time.UTC)
+	// pre-roll saved with the event keeps its capture times, a frame of the
+	// wrong size is skipped, the next event comes a minute later
+	frames := []struct {
+		data     string
+		captured time.Time
+	}{
+		{"aaaa", event.Add(-200 * time.Millisecond)},
+		{"bbbb", event.Add(-100 * time.Millisecond)},
+		{"cccc", event},
+		{"dd", event.Add(100 * time.Millisecond)},
+		{"eeee", event.Add(time.Minute)},
+	}
+	for i, f := range frames {
+		SaveFrame(i, []byte(f.data), dir, f.captured)
+	}
+	start, end, err := GetChunkTimeRange(dir)
+	if err != nil || !start.Equal(frames[0].captured) || !end.Equal(frames[4].captured) {
+		t.Errorf("Expected the chunk range from the capture times, got %v %v %v", start, end, err)
+	}
+	var captured []time.Time
+	data, err := io.ReadAll(NewChunkFrameReader(dir, 4, func(t time.Time) { captured = append(captured, t) }))
+	if err != nil || string(data) != "aaaabbbbcccceeee" {
+		t.Fatalf("Expected the frames of the right size, got %q %v", data, err)
+	}
+	spans := video.NewSpans(captured, 10)
+	if len(spans) != 2 || !spans[0].End.Equal(event) || !spans[1].Start.Equal(event.Add(time.Minute)) || spans[1].Offset != 0.3 {
+		t.Errorf("Expected the second event 0.3s into the video, got %+v", spans)
+	}
+}
@@ -0,0 +1,140 @@
+package watcher
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"strzcam.com/broadcaster/encryption"
+)
+
+const (
+	ExportRunning = "running"
+	ExportDone    = "done"
+	ExportFailed  = "failed"
+)
+
+// finished exports are kept for download this long
+const exportJobTTL = time.Hour
+
+type ExportJob struct {
+	ID       string  `json:"id"`
+	State    string  `json:"state"`
+	Progress float64 `json:"progress"`
+	Error    string  `json:"error,omitempty"`
+	path     string
+	finished time.Time
+}
+
+// ExportJobs runs long exports in the background so HTTP clients can poll
+// their progress and download the result later.
+type ExportJobs struct {
+	mu      sync.Mutex
+	jobs    map[string]*ExportJob
+	ctx     context.Context
+	cancel  context.CancelFunc
+	running sync.WaitGroup
+	keyring *encryption.Keyring // of the files
+}
+
+func NewExportJobs() *ExportJobs {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &ExportJobs{jobs: map[string]*ExportJob{}, ctx: ctx, cancel: cancel, keyring: encryption.Default()}
+}
+
+type ExportFunc func(ctx context.Context, progress func(float64), w io.Writer) error
+
+func (e *ExportJobs) Start(pattern string, export ExportFunc) (ExportJob, error) {
+	e.removeExpired(time.Now())
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		return ExportJob{}, err
+	}
+	// encrypted like recordings, clips must not sit in clear in /tmp
+	file, path, err := encryption.CreateTemp(pattern, e.keyring)
+	if err != nil {
+		return ExportJob{}, err
+	}
+	job := &ExportJob{ID: hex.EncodeToString(id), State: ExportRunning, path: path}
+	e.mu.Lock()
+	e.jobs[job.ID] = job
+	e.mu.Unlock()
+	e.running.Add(1)
+	go func() {
+		defer e.running.Done()
+		err := export(e.ctx, func(progress float64) {
+			e.mu.Lock()
+			job.Progress = progress
+			e.mu.Unlock()
+		}, file)
+		// closing writes the last encrypted block
+		if closeErr := file.Close(); err == nil {
+			err = closeErr
+		}
+		e.mu.Lock()
+		defer e.mu.Unlock()
+		job.finished = time.Now()
+		if err != nil {
+			log.Printf("Export %s failed: %v", job.ID, err)
+			job.State = ExportFailed
+			job.Error = err.Error()
+			os.Remove(job.path)
+			return
+		}
+		job.State = ExportDone
+		job.Progress = 1
+	}()
+	return *job, nil
+}
+
+func (e *ExportJobs) Get(id string) (ExportJob, bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	job, ok := e.jobs[id]
+	if !ok {
+		return ExportJob{}, false
+	}
+	return *job, true
+}
+
+// Open returns the decrypted result of a finished export with its job
+func (e *ExportJobs) Open(id string) (encryption.File, ExportJob, error) {
+	job, ok := e.Get(id)
+	if !ok {
+		return nil, job, fmt.Errorf("export %s not found", id)
+	}
+	if job.State != ExportDone {
+		return nil, job, fmt.Errorf("export %s is %s", id, job.State)
+	}
+	file, err := encryption.Open(job.path, e.keyring)
+	return file, job, err
+}
+
+func (e *ExportJobs) removeExpired(now time.Time) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for id, job := range e.jobs {
+		if job.State != ExportRunning && now.Sub(job.finished) > exportJobTTL {
+			os.Remove(job.path)
+			delete(e.jobs, id)
+		}
+	}
+}
+
+// Stop cancels running exports and removes the files of all of them
+func (e *ExportJobs) Stop() {
+	e.cancel()
+	e.running.Wait()
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for id, job := range e.jobs {
+		os.Remove(job.path)
+		delete(e.jobs, id)
+	}
+}
@@ -0,0 +1,59 @@
+package watcher
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"testing"
+	"time"
+
+	"strzcam.com/broadcaster/encryption"
+)
+
+func TestExportJobsEncrypted(t *testing.T) {
+	key, err := encryption.NewKey(bytes.Repeat([]byte{7}, encryption.KeySize))
+	if err != nil {
+		t.Fatal(err)
+	}
+	jobs := NewExportJobs()
+	jobs.keyring = encryption.NewKeyring(key)
+	footage := []byte("clear footage")
+	started, err := jobs.Start("clip-*.mp4", func(ctx context.Context, progress func(float64), w io.Writer) error {
+		_, err := w.Write(footage)
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for job, _ := jobs.Get(started.ID); job.State == ExportRunning; job, _ = jobs.Get(started.ID) {
+		if time.Now().After(deadline) {
+			t.Fatal("Timeout waiting for the export")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	stored, err := os.ReadFile(started.path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(stored, footage) {
+		t.Error("Expected the export encrypted in the temporary directory")
+	}
+	file, job, err := jobs.Open(started.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := io.ReadAll(file)
+	file.Close()
+	if err != nil || !bytes.Equal(data, footage) || job.State != ExportDone {
+		t.Errorf("Expected the decrypted export, got %q %v %s", data, err, job.State)
+	}
+
+	jobs.Stop()
+	if _, err := os.Stat(started.path); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("Expected the export removed when stopping, got %v", err)
+	}
+}
@@ -1,6 +1,8 @@
 package watcher
 
 import (
+	"context"
+	"crypto/rand"
 	"fmt"
 	"io"
 	"log"
@@ -9,7 +11,9 @@ import (
 	"os/exec"
 	"path/filepath"
 	"sync"
+	"time"
 
+	"strzcam.com/broadcaster/encryption"
 	"strzcam.com/broadcaster/frame"
 )
 
@@ -24,8 +28,19 @@ type HLSConverter struct {
 	width         int
 	height        int
 	fps           float64
+	segmentKey    []byte
+	keyDir        string // holds the key file ffmpeg reads, removed on stop
 }
 
+// players fetch the key relative to the playlist
+const (
+	HLSPlaylistName = "stream.m3u8"
+	HLSKeyName      = "stream.key"
+)
+
+// ffmpeg gets this long to write the last segment before it is killed
+const hlsStopTimeout = 3 * time.Second
+
 func NewHLSConverter(outputDir string, frames chan []frame.Frame) (*HLSConverter, error) {
 	if _, err := os.Stat(outputDir); os.IsNotExist(err) {
 		if err := os.MkdirAll(outputDir, 0755); err != nil {
@@ -34,7 +49,7 @@ func NewHLSConverter(outputDir string, frames chan []frame.Frame) (*HLSConverter
 	}
 	return &HLSConverter{
 		segmentDir:   outputDir,
-		playlistPath: filepath.Join(outputDir, "stream.m3u8"),
+		playlistPath: filepath.Join(outputDir, HLSPlaylistName),
 		Frames:       frames,
 		width:        0,  // Will be set as frame received
 		height:       0,  // Will be set as frame received
@@ -42,14 +57,15 @@ func NewHLSConverter(outputDir string, frames chan []frame.Frame) (*HLSConverter
 	}, nil
 }
 
-func (h *HLSConverter) Start() error {
-	go h.processFrames()
+// Start converts frames until ctx is done or Frames is closed
+func (h *HLSConverter) Start(ctx context.Context) error {
+	go h.processFrames(ctx)
 	log.Println("HLS converter started, waiting for first frame...")
 	return nil
 }
 
 func (h *HLSConverter) startFFmpeg() error {
-	h.ffmpegCmd = exec.Command("ffmpeg",
+	args := []string{
 		"-f", "rawvideo",
 		"-pixel_format", "yuv420p",
 		"-video_size", fmt.Sprintf("%dx%d", h.width, h.height),
@@ -74,8 +90,16 @@ func (h *HLSConverter) startFFmpeg() error {
 		"-hls_flags", "delete_segments+omit_endlist",
 		"-hls_segment_type", "mpegts",
 		"-hls_segment_filename", filepath.Join(h.segmentDir, "segment_%03d.ts"),
-		h.playlistPath,
-	)
+	}
+	if encryption.Default().Enabled() {
+		keyInfoPath, err := h.createSegmentKey()
+		if err != nil {
+			return fmt.Errorf("failed to create segment key: %w", err)
+		}
+		args = append(args, "-hls_key_info_file", keyInfoPath)
+	}
+	args = append(args, h.playlistPath)
+	h.ffmpegCmd = exec.Command("ffmpeg", args...)
 	h.ffmpegCmd.Stderr = os.Stderr
 	h.ffmpegCmd.Stdout = os.Stdout
 
@@ -93,8 +117,65 @@ func (h *HLSConverter) startFFmpeg() error {
 	return nil
 }
 
-func (h *HLSConverter) processFrames() {
-	for frameSet := range h.Frames {
+// Segments are encrypted with standard HLS AES-128 so players decrypt them
+// on their own. ffmpeg reads the key from a temporary directory outside the
+// output directory, a new one replaces it on every ffmpeg start and it is
+// removed when the converter stops.
+func (h *HLSConverter) createSegmentKey() (string, error) {
+	h.removeSegmentKey()
+	key := make([]byte, 16)
+	if _, err := rand.Read(key); err != nil {
+		return "", err
+	}
+	keyDir, err := os.MkdirTemp("", "hls-key-")
+	if err != nil {
+		return "", err
+	}
+	h.keyDir = keyDir
+	keyPath := filepath.Join(keyDir, HLSKeyName)
+	if err := os.WriteFile(keyPath, key, 0600); err != nil {
+		return "", err
+	}
+	keyInfoPath := filepath.Join(keyDir, "key_info")
+	if err := os.WriteFile(keyInfoPath, []byte(HLSKeyName+"\n"+keyPath+"\n"), 0600); err != nil {
+		return "", err
+	}
+	h.mu.Lock()
+	h.segmentKey = key
+	h.mu.Unlock()
+	return keyInfoPath, nil
+}
+
+// removeSegmentKey deletes the key file of the previous ffmpeg run
+func (h *HLSConverter) removeSegmentKey() {
+	if h.keyDir == "" {
+		return
+	}
+	if err := os.RemoveAll(h.keyDir); err != nil {
+		log.Printf("Can not remove HLS key: %v", err)
+	}
+	h.keyDir = ""
+}
+
+func (h *HLSConverter) SegmentKey() []byte {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.segmentKey
+}
+
+func (h *HLSConverter) processFrames(ctx context.Context) {
+	defer h.Stop()
+	for {
+		var frameSet []frame.Frame
+		select {
+		case <-ctx.Done():
+			return
+		case frames, ok := <-h.Frames:
+			if !ok {
+				return
+			}
+			frameSet = frames
+		}
 		var combinedData []byte
 		for _, f := range frameSet {
 			log.Printf("Processing frame: %dx%d @ %.2f fps", f.Width, f.Height, f.Fps)
@@ -114,7 +195,6 @@ func (h *HLSConverter) processFrames() {
 					log.Printf("Failed to start FFmpeg: %v", err)
 					return
 				}
-				defer h.frameWriter.Close()
 			}
 			combinedData = append(combinedData, f.Data...)
 		}
@@ -128,15 +208,27 @@ func (h *HLSConverter) processFrames() {
 	}
 }
 
+// Stop closes the input of ffmpeg so it finishes the playlist, it is killed
+// when it does not exit in time. The key file is removed once ffmpeg exited.
 func (h *HLSConverter) Stop() error {
-	if h.frameWriter != nil {
-		_ = h.frameWriter.Close()
-		h.frameWriter = nil
+	defer h.removeSegmentKey()
+	if h.frameWriter == nil {
+		return nil
 	}
-	if h.ffmpegCmd != nil && h.ffmpegCmd.Process != nil {
-		return h.ffmpegCmd.Process.Kill()
+	_ = h.frameWriter.Close()
+	h.frameWriter = nil
+	done := make(chan error, 1)
+	go func() {
+		done <- h.ffmpegCmd.Wait()
+	}()
+	select {
+	case err := <-done:
+		return err
+	case <-time.After(hlsStopTimeout):
+		log.Println("FFmpeg did not stop in time, killing it")
+		h.ffmpegCmd.Process.Kill()
+		return <-done
 	}
-	return nil
 }
 
 func (h *HLSConverter) SetFpsAndSize(fps float64, width int, height int) {
@@ -0,0 +1,30 @@
+package watcher
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSegmentKeyRemoved(t *testing.T) {
+	converter, _ := NewHLSConverter(t.TempDir(), nil)
+	first, err := converter.createSegmentKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := converter.createSegmentKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Dir(first)); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("Expected the key of the previous ffmpeg start removed, got %v", err)
+	}
+	if _, err := os.Stat(second); err != nil {
+		t.Fatalf("Expected the current key info, got %v", err)
+	}
+	converter.Stop()
+	if _, err := os.Stat(filepath.Dir(second)); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("Expected the key removed on stop, got %v", err)
+	}
+}
@@ -0,0 +1,286 @@
+package watcher
+
+import (
+	"errors"
+	"log"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+
+	"strzcam.com/broadcaster/analytics"
+	"strzcam.com/broadcaster/connection"
+	"strzcam.com/broadcaster/detector"
+	"strzcam.com/broadcaster/health"
+	"strzcam.com/broadcaster/motion"
+	"strzcam.com/broadcaster/osd"
+	"strzcam.com/broadcaster/privacy"
+	"strzcam.com/broadcaster/schedule"
+	"strzcam.com/broadcaster/watchdog"
+)
+
+// ErrRestartRequired is returned by settings that can not change while running
+var ErrRestartRequired = errors.New("restart required")
+
+// setting is a group of config fields applied together. prepare checks and
+// builds everything that can fail, commit only swaps, so a reload is applied
+// completely or not at all. Settings without prepare need a restart.
+type setting struct {
+	name    string
+	value   func(c Config) any
+	prepare func(r *Reloader, old Config, config Config) (commit, error)
+}
+
+type commit struct {
+	betweenFrames func(smr *SharedMemoryReceiver) // run by the receiver watch loop
+	apply         func()
+}
+
+var settings = []setting{
+	{"camera", func(c Config) any { return []any{c.Camera, c.SharedMemory} }, nil},
+	{"storage roots", func(c Config) any { return []any{c.ChunkRoot, NewStorage(c).VideoPaths(), c.SaveChunkSize} }, nil},
+	{"retention", func(c Config) any {
+		return []any{c.VideoRoots, c.ConvertedVideoSpace, c.SaveDirMaxSize, c.ConvertFramesBeforeDays}
+	}, prepareRetention},
+	{"pre-roll", func(c Config) any { return []any{c.ShowWhatWasBefore, c.ShowWhatWasAfter} }, nil},
+	{"archive", func(c Config) any {
+		return []any{c.ArchiveAfterDays, c.ArchiveHeight, c.ArchiveFps, c.ArchiveBitrate, c.ArchiveCodec}
+	}, prepareArchive},
+	{"timelapse", func(c Config) any { return []any{c.TimelapseSpeedup, c.TimelapseDetectionSpeedup} }, prepareTimelapse},
+	{"zones", func(c Config) any { return c.Zones }, prepareZones},
+	{"privacy masks", func(c Config) any { return c.PrivacyMasks }, preparePrivacy},
+	{"osd", func(c Config) any { return c.OSD }, prepareOSD},
+	{"motion", func(c Config) any { return []any{c.MotionDetection, c.Motion} }, prepareMotion},
+	{"health", func(c Config) any { return []any{c.HealthChecks, c.Health} }, prepareHealth},
+	{"detectors", func(c Config) any { return c.Detectors }, prepareDetectors},
+	{"schedule", func(c Config) any { return []any{c.Schedule, c.ScheduleLocation.String()} }, prepareSchedule},
+	{"watchdog", func(c Config) any { return c.WatchdogTimeout }, prepareWatchdog},
+	{"camera command", func(c Config) any { return []any{c.CameraCommand, c.CameraLogFile} }, nil},
+	{"state files", func(c Config) any {
+		return []any{c.ProviderKeyPath, c.ViewerKeyPath, c.EventsDir, c.HeatmapDir, c.ScheduleStateFile, c.HealthStateFile}
+	}, nil},
+	{"ports", func(c Config) any { return c.Ports }, nil},
+	{"server", func(c Config) any { return []any{c.ServerJpegSkipChunk, c.ServerJpegSkipFrames} }, nil},
+	{"webrtc", func(c Config) any { return []any{c.SignalingURL, c.WebRTCLive, c.ICEServers} }, nil},
+	{"encryption", func(c Config) any { return c.Encryption }, nil},
+	{"auth", func(c Config) any { return []any{c.AuthToken, c.AuthPeers} }, nil},
+}
+
+// Reloader reads the configuration again and applies it to the running
+// components set on it. A configuration that does not load or a change a
+// component rejects leaves everything as it was.
+type Reloader struct {
+	Receiver *SharedMemoryReceiver
+	Creator  *VideoCreator
+	Schedule *schedule.Controller
+	Watchdog *watchdog.Watchdog
+	Load     func() (Config, error) // LoadConfig by default
+
+	mu              sync.Mutex
+	current         Config
+	restartRequired []string
+}
+
+func NewReloader(config Config) *Reloader {
+	return &Reloader{current: config, Load: LoadConfig}
+}
+
+// Config returns the configuration in use
+func (r *Reloader) Config() Config {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.current
+}
+
+func (r *Reloader) Reload() (connection.ReloadResult, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	config, err := r.Load()
+	if err != nil {
+		log.Printf("Configuration not reloaded: %v", err)
+		return connection.ReloadResult{}, err
+	}
+	var applied, restart []string
+	var commits []commit
+	for _, s := range settings {
+		if reflect.DeepEqual(s.value(r.current), s.value(config)) {
+			continue
+		}
+		if s.prepare == nil {
+			restart = append(restart, s.name)
+			continue
+		}
+		c, err := s.prepare(r, r.current, config)
+		if errors.Is(err, ErrRestartRequired) {
+			restart = append(restart, s.name)
+			continue
+		}
+		if err != nil {
+			log.Printf("Configuration not reloaded, %s: %v", s.name, err)
+			return connection.ReloadResult{}, errors.New(s.name + ": " + err.Error())
+		}
+		applied = append(applied, s.name)
+		commits = append(commits, c)
+	}
+	if err := r.commit(commits); err != nil {
+		log.Printf("Configuration not reloaded: %v", err)
+		return connection.ReloadResult{}, err
+	}
+	r.current = config
+	for _, name := range restart {
+		if !slices.Contains(r.restartRequired, name) {
+			r.restartRequired = append(r.restartRequired, name)
+		}
+	}
+	result := connection.ReloadResult{Applied: applied, RestartRequired: slices.Clone(r.restartRequired)}
+	if result.Applied == nil {
+		result.Applied = []string{}
+	}
+	if result.RestartRequired == nil {
+		result.RestartRequired = []string{}
+	}
+	log.Printf("Configuration reloaded, applied: [%s], restart required: [%s]",
+		strings.Join(result.Applied, ", "), strings.Join(result.RestartRequired, ", "))
+	return result, nil
+}
+
+// commit swaps receiver components in one step between frames, the rest can
+// not fail and is applied after it
+func (r *Reloader) commit(commits []commit) error {
+	var betweenFrames []func(smr *SharedMemoryReceiver)
+	for _, c := range commits {
+		if c.betweenFrames != nil {
+			betweenFrames = append(betweenFrames, c.betweenFrames)
+		}
+	}
+	if len(betweenFrames) > 0 {
+		smr := r.Receiver
+		err := smr.betweenFrames(func() {
+			for _, apply := range betweenFrames {
+				apply(smr)
+			}
+		})
+		if err != nil {
+			return err
+		}
+	}
+	for _, c := range commits {
+		if c.apply != nil {
+			c.apply()
+		}
+	}
+	return nil
+}
+
+// limits of roots change live, other roots are only listed after a restart
+func prepareRetention(r *Reloader, old Config, config Config) (commit, error) {
+	if !slices.Equal(NewStorage(old).VideoPaths(), NewStorage(config).VideoPaths()) {
+		return commit{}, ErrRestartRequired
+	}
+	if r.Creator == nil {
+		return commit{}, nil
+	}
+	return commit{apply: func() { r.Creator.Converter.Reconfigure(config) }}, nil
+}
+
+func prepareArchive(r *Reloader, old Config, config Config) (commit, error) {
+	if r.Creator == nil {
+		return commit{}, nil
+	}
+	return commit{apply: func() { r.Creator.Archiver.Reconfigure(config) }}, nil
+}
+
+func prepareTimelapse(r *Reloader, old Config, config Config) (commit, error) {
+	if r.Creator == nil {
+		return commit{}, nil
+	}
+	return commit{apply: func() { r.Creator.Timelapser.Reconfigure(config) }}, nil
+}
+
+// receiverCommit builds a commit run between frames, nothing is done
+// without a receiver
+func receiverCommit(r *Reloader, apply func(smr *SharedMemoryReceiver)) (commit, error) {
+	if r.Receiver == nil {
+		return commit{}, nil
+	}
+	return commit{betweenFrames: apply}, nil
+}
+
+func prepareZones(r *Reloader, old Config, config Config) (commit, error) {
+	return receiverCommit(r, func(smr *SharedMemoryReceiver) {
+		smr.Zones = config.Zones
+		smr.Analyzer = nil
+		if len(config.Zones.Tripwires) > 0 {
+			smr.Analyzer = analytics.NewAnalyzer(config.Zones.Tripwires)
+		}
+	})
+}
+
+func preparePrivacy(r *Reloader, old Config, config Config) (commit, error) {
+	return receiverCommit(r, func(smr *SharedMemoryReceiver) {
+		smr.Privacy = nil
+		if len(config.PrivacyMasks) > 0 {
+			smr.Privacy = privacy.NewMasker(config.PrivacyMasks)
+		}
+	})
+}
+
+func prepareOSD(r *Reloader, old Config, config Config) (commit, error) {
+	var renderer *osd.Renderer
+	if config.OSD.Stage == osd.StageRecording || config.OSD.Stage == osd.StageAll {
+		var err error
+		if renderer, err = osd.NewRenderer(config.OSD); err != nil {
+			return commit{}, err
+		}
+	}
+	return receiverCommit(r, func(smr *SharedMemoryReceiver) {
+		smr.OSD = renderer
+	})
+}
+
+func prepareMotion(r *Reloader, old Config, config Config) (commit, error) {
+	return receiverCommit(r, func(smr *SharedMemoryReceiver) {
+		smr.Motion = nil
+		if config.MotionDetection {
+			smr.Motion = motion.NewDetector(config.Motion)
+		}
+	})
+}
+
+func prepareHealth(r *Reloader, old Config, config Config) (commit, error) {
+	return receiverCommit(r, func(smr *SharedMemoryReceiver) {
+		smr.Health = nil
+		if config.HealthChecks {
+			smr.Health = health.NewMonitor(config.Camera, config.Health)
+			smr.HealthStateFile = config.HealthStateFile
+		}
+	})
+}
+
+func prepareDetectors(r *Reloader, old Config, config Config) (commit, error) {
+	return receiverCommit(r, func(smr *SharedMemoryReceiver) {
+		previous := smr.Detectors
+		smr.Detectors = nil
+		if len(config.Detectors.Addresses) > 0 {
+			smr.Detectors = detector.NewScheduler(config.Detectors)
+		}
+		if previous != nil {
+			previous.Close()
+		}
+	})
+}
+
+func prepareSchedule(r *Reloader, old Config, config Config) (commit, error) {
+	if r.Schedule == nil {
+		return commit{}, nil
+	}
+	return commit{apply: func() { r.Schedule.SetSchedule(config.Schedule, config.ScheduleLocation) }}, nil
+}
+
+// the watchdog runs only when it was enabled at start
+func prepareWatchdog(r *Reloader, old Config, config Config) (commit, error) {
+	if r.Watchdog == nil || config.WatchdogTimeout <= 0 {
+		return commit{}, ErrRestartRequired
+	}
+	return commit{apply: func() { r.Watchdog.SetTimeout(config.WatchdogTimeout) }}, nil
+}
@@ -0,0 +1,73 @@
+package watcher
+
+import (
+	"errors"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"strzcam.com/broadcaster/osd"
+)
+
+func TestReload(t *testing.T) {
+	config, err := LoadConfigFile(writeConfig(t, "camera: garden\ncameras:\n  garden: {}\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	next := config
+	reloader := NewReloader(config)
+	reloader.Load = func() (Config, error) { return next, nil }
+
+	t.Run("applies live settings and lists the others", func(t *testing.T) {
+		next.Zones.MinBoxWidth = 20
+		next.Ports.Viewer = 11001
+		result, err := reloader.Reload()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !slices.Equal(result.Applied, []string{"zones"}) || !slices.Equal(result.RestartRequired, []string{"ports"}) {
+			t.Errorf("Unexpected result %+v", result)
+		}
+		if reloader.Config().Zones.MinBoxWidth != 20 {
+			t.Error("Expected the reloaded configuration in use")
+		}
+	})
+	t.Run("restart required is kept until restart", func(t *testing.T) {
+		result, err := reloader.Reload()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(result.Applied) != 0 || !slices.Equal(result.RestartRequired, []string{"ports"}) {
+			t.Errorf("Unexpected result %+v", result)
+		}
+	})
+	t.Run("new video roots need a restart", func(t *testing.T) {
+		next.VideoRoots = []StorageRoot{{Path: filepath.Join(t.TempDir(), "videos")}}
+		result, err := reloader.Reload()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !slices.Contains(result.RestartRequired, "retention") || slices.Contains(result.Applied, "retention") {
+			t.Errorf("Unexpected result %+v", result)
+		}
+	})
+	t.Run("rejected setting keeps the configuration", func(t *testing.T) {
+		next.Zones.MinBoxWidth = 40
+		next.OSD = osd.Config{Stage: osd.StageRecording, FontPath: filepath.Join(t.TempDir(), "missing.ttf")}
+		if _, err := reloader.Reload(); err == nil {
+			t.Fatal("Expected an error for a missing font")
+		}
+		if reloader.Config().Zones.MinBoxWidth != 20 {
+			t.Error("Expected nothing applied from a rejected reload")
+		}
+	})
+	t.Run("load error keeps the configuration", func(t *testing.T) {
+		reloader.Load = func() (Config, error) { return Config{}, errors.New("invalid") }
+		if _, err := reloader.Reload(); err == nil {
+			t.Fatal("Expected the load error")
+		}
+		if reloader.Config().Zones.MinBoxWidth != 20 {
+			t.Error("Expected the previous configuration in use")
+		}
+	})
+}
@@ -2,23 +2,39 @@ package watcher
 
 import (
 	"bytes"
+	"context"
+	"crypto/subtle"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
 	"mime/multipart"
+	"net"
 	"net/http"
 	"net/textproto"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/gorilla/websocket"
-	"github.com/joho/godotenv"
+	"strzcam.com/broadcaster/analytics"
 	"strzcam.com/broadcaster/connection"
 	frameUtils "strzcam.com/broadcaster/frame"
+	"strzcam.com/broadcaster/health"
+	"strzcam.com/broadcaster/schedule"
+	"strzcam.com/broadcaster/search"
 	"strzcam.com/broadcaster/video"
+	"strzcam.com/broadcaster/watchdog"
 )
 
+// requests get this long to finish on shutdown
+const serverShutdownTimeout = 5 * time.Second
+
 type connInfo struct {
 	conn     *websocket.Conn
 	writeMux sync.Mutex
@@ -31,28 +47,49 @@ const (
 	GetVideo
 )
 
+// Library answers requests about recordings and the camera, a viewer of a p2p
+// provider or connection.Local in the same process
+type Library interface {
+	GetVideoList(start time.Time, end time.Time) []video.Video
+	GetVideo(name string) []byte
+	GetSignatureChain(start time.Time, end time.Time) []video.ChainEntry
+	ExportClip(ctx context.Context, request video.ClipRequest, progress func(float64), w io.Writer) error
+	ExportEvidence(ctx context.Context, request video.ClipRequest, progress func(float64), w io.Writer) error
+	ImportVideo(ctx context.Context, request video.ImportRequest, r io.Reader, size int64) (string, error)
+	GetAnalytics(ctx context.Context, query analytics.Query) (analytics.Report, error)
+	GetActivity(ctx context.Context, query analytics.Query) (analytics.ActivityReport, error)
+	GetHeatmapOverlay(ctx context.Context, query analytics.Query) ([]byte, error)
+	Search(ctx context.Context, query search.Query) (search.Page, error)
+	Health(ctx context.Context) (health.Status, error)
+	Camera(ctx context.Context) (watchdog.State, error)
+	Mode(ctx context.Context) (schedule.Status, error)
+	SetMode(ctx context.Context, override schedule.Override) (schedule.Status, error)
+	ReloadConfig(ctx context.Context) (connection.ReloadResult, error)
+}
+
 type Server struct {
 	port           uint16
 	Viewers        []*connection.Viewer
+	Library        Library // used instead of Viewers when set
 	frames         chan []frameUtils.Frame
 	frameListeners []chan []frameUtils.Frame
 	listenerMux    sync.Mutex
 	skipChunk      int
 	skipFrames     int
+	exportJobs     *ExportJobs
+	hls            *HLSConverter
+	authToken      string
 }
 
-func NewServer(port uint16) (*Server, error) {
-	if err := godotenv.Load(); err != nil {
-		log.Printf("Warning: Error loading .env file: %v", err)
-	}
-	skipChunk := getEnvAsInt("SERVER_CONVERSION_TO_JPEG_SKIP_CHUNK", 4)
-	skipFrames := getEnvAsInt("SERVER_CONVERSION_TO_JPEG_SKIP_FRAMES", 10)
+func NewServer(port int, config Config) (*Server, error) {
 	server := &Server{
-		port:           port,
+		port:           uint16(port),
 		frames:         make(chan []frameUtils.Frame, 1),
 		frameListeners: []chan []frameUtils.Frame{},
-		skipChunk:      skipChunk,
-		skipFrames:     skipFrames,
+		skipChunk:      config.ServerJpegSkipChunk,
+		skipFrames:     config.ServerJpegSkipFrames,
+		exportJobs:     NewExportJobs(),
+		authToken:      config.AuthToken,
 	}
 	go server.broadcastFrames()
 	return server, nil
@@ -106,6 +143,48 @@ func (s *Server) GetViewer() *connection.Viewer {
 	return nil
 }
 
+// library returns the Library or the first viewer, without any it answers
+// the request itself
+func (s *Server) library(w http.ResponseWriter) (Library, bool) {
+	if s.Library != nil {
+		return s.Library, true
+	}
+	if viewer := s.GetViewer(); viewer != nil {
+		return viewer, true
+	}
+	http.Error(w, "no provider connected", http.StatusServiceUnavailable)
+	return nil, false
+}
+
+// authorized checks the bearer token of requests for secrets and changes,
+// without a configured token they are refused
+func (s *Server) authorized(w http.ResponseWriter, r *http.Request) bool {
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	return s.validToken(w, token, ok)
+}
+
+// playerAuthorized also takes the token from the query, players can not add
+// headers to the key request of a playlist
+func (s *Server) playerAuthorized(w http.ResponseWriter, r *http.Request) bool {
+	if token := r.URL.Query().Get("token"); token != "" && r.Header.Get("Authorization") == "" {
+		return s.validToken(w, token, true)
+	}
+	return s.authorized(w, r)
+}
+
+func (s *Server) validToken(w http.ResponseWriter, token string, ok bool) bool {
+	if s.authToken == "" {
+		http.Error(w, "set auth.token to use this endpoint", http.StatusForbidden)
+		return false
+	}
+	if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(s.authToken)) != 1 {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return false
+	}
+	return true
+}
+
 func (s *Server) setCORSHeaders(w http.ResponseWriter) {
 	w.Header().Set("Access-Control-Allow-Origin", "*")
 	w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
@@ -114,8 +193,16 @@ func (s *Server) setCORSHeaders(w http.ResponseWriter) {
 func (s *Server) getVideo(w http.ResponseWriter, r *http.Request) {
 	s.setCORSHeaders(w)
 	videoName := r.PathValue("name")
-	viewer := s.GetViewer()
-	videoData := viewer.GetVideo(videoName)
+	library, ok := s.library(w)
+	if !ok {
+		return
+	}
+	videoData := library.GetVideo(videoName)
+	if len(videoData) == 0 {
+		// provider does not send videos that are missing or fail verification
+		http.Error(w, "video is not available or failed integrity check", http.StatusBadGateway)
+		return
+	}
 	w.Header().Set("Content-Type", "video/mp4")
 	w.Header().Set("Content-Disposition", "inline")
 	w.Header().Set("Cache-Control", "public, max-age=3600")
@@ -129,18 +216,411 @@ func (s *Server) getVideo(w http.ResponseWriter, r *http.Request) {
 }
 func (s *Server) getVideoList(w http.ResponseWriter, r *http.Request) {
 	s.setCORSHeaders(w)
+	library, ok := s.library(w)
+	if !ok {
+		return
+	}
 	w.WriteHeader(http.StatusOK)
 	w.Header().Set("Content-Type", "application/json")
 	startParam := r.URL.Query().Get("start")
 	endParam := r.URL.Query().Get("end")
 	start, _ := time.Parse("2006-01-02", startParam)
 	end, _ := time.Parse("2006-01-02", endParam)
-	var videoList []video.Video
-	viewer := s.GetViewer()
-	videoList = viewer.GetVideoList(start, end)
+	videoList := library.GetVideoList(start, end)
 	json.NewEncoder(w).Encode(videoList)
 }
 
+func (s *Server) getSignatureChain(w http.ResponseWriter, r *http.Request) {
+	s.setCORSHeaders(w)
+	start, err := time.Parse("2006-01-02", r.URL.Query().Get("start"))
+	if err != nil {
+		http.Error(w, "invalid start date, expected YYYY-MM-DD", http.StatusBadRequest)
+		return
+	}
+	end, err := time.Parse("2006-01-02", r.URL.Query().Get("end"))
+	if err != nil {
+		http.Error(w, "invalid end date, expected YYYY-MM-DD", http.StatusBadRequest)
+		return
+	}
+	library, ok := s.library(w)
+	if !ok {
+		return
+	}
+	chain := library.GetSignatureChain(start, end)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(chain)
+}
+
+func writeJSON(w http.ResponseWriter, status int, value any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(value)
+}
+
+// parseClipRequest reads camera, start and end, times are RFC 3339 timestamps
+func parseClipRequest(r *http.Request) (video.ClipRequest, error) {
+	start, err := time.Parse(time.RFC3339, r.URL.Query().Get("start"))
+	if err != nil {
+		return video.ClipRequest{}, errors.New("invalid start, expected RFC 3339 time")
+	}
+	end, err := time.Parse(time.RFC3339, r.URL.Query().Get("end"))
+	if err != nil {
+		return video.ClipRequest{}, errors.New("invalid end, expected RFC 3339 time")
+	}
+	request := video.ClipRequest{Camera: r.URL.Query().Get("camera"), Start: start, End: end}
+	return request, request.Validate()
+}
+
+func (s *Server) startExport(w http.ResponseWriter, r *http.Request, pattern string, export func(Library, video.ClipRequest) ExportFunc) {
+	s.setCORSHeaders(w)
+	request, err := parseClipRequest(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	library, ok := s.library(w)
+	if !ok {
+		return
+	}
+	job, err := s.exportJobs.Start(pattern, export(library, request))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusAccepted, job)
+}
+
+func (s *Server) exportClip(w http.ResponseWriter, r *http.Request) {
+	s.startExport(w, r, "clip-*.mp4", func(library Library, request video.ClipRequest) ExportFunc {
+		return func(ctx context.Context, progress func(float64), out io.Writer) error {
+			return library.ExportClip(ctx, request, progress, out)
+		}
+	})
+}
+
+// exportEvidence bundles the clip with source recordings, detections and checksums
+func (s *Server) exportEvidence(w http.ResponseWriter, r *http.Request) {
+	s.startExport(w, r, "evidence-*.zip", func(library Library, request video.ClipRequest) ExportFunc {
+		return func(ctx context.Context, progress func(float64), out io.Writer) error {
+			return library.ExportEvidence(ctx, request, progress, out)
+		}
+	})
+}
+
+// importVideo stores the request body as footage of the camera, start is an RFC 3339 timestamp
+func (s *Server) importVideo(w http.ResponseWriter, r *http.Request) {
+	s.setCORSHeaders(w)
+	if !s.authorized(w, r) {
+		return
+	}
+	start, err := time.Parse(time.RFC3339, r.URL.Query().Get("start"))
+	if err != nil {
+		http.Error(w, "invalid start, expected RFC 3339 time", http.StatusBadRequest)
+		return
+	}
+	request := video.ImportRequest{
+		Camera: r.URL.Query().Get("camera"),
+		Start:  start,
+		Source: r.URL.Query().Get("name"),
+	}
+	if fps := r.URL.Query().Get("fps"); fps != "" {
+		if request.Fps, err = strconv.ParseFloat(fps, 64); err != nil {
+			http.Error(w, "invalid fps", http.StatusBadRequest)
+			return
+		}
+	}
+	if err := request.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if r.ContentLength <= 0 {
+		http.Error(w, "file size is required", http.StatusLengthRequired)
+		return
+	}
+	library, ok := s.library(w)
+	if !ok {
+		return
+	}
+	name, err := library.ImportVideo(r.Context(), request, r.Body, r.ContentLength)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	writeJSON(w, http.StatusCreated, map[string]string{"name": name})
+}
+
+// parseAnalyticsQuery reads camera, tripwire, timeZone, start and end, times are RFC 3339 timestamps
+func parseAnalyticsQuery(r *http.Request) (analytics.Query, error) {
+	start, err := time.Parse(time.RFC3339, r.URL.Query().Get("start"))
+	if err != nil {
+		return analytics.Query{}, errors.New("invalid start, expected RFC 3339 time")
+	}
+	end, err := time.Parse(time.RFC3339, r.URL.Query().Get("end"))
+	if err != nil {
+		return analytics.Query{}, errors.New("invalid end, expected RFC 3339 time")
+	}
+	query := analytics.Query{
+		Camera:   r.URL.Query().Get("camera"),
+		Tripwire: r.URL.Query().Get("tripwire"),
+		Start:    start,
+		End:      end,
+		TimeZone: r.URL.Query().Get("timeZone"),
+	}
+	return query, query.Validate()
+}
+
+// getAnalytics returns tripwire crossings and hourly counts
+func (s *Server) getAnalytics(w http.ResponseWriter, r *http.Request) {
+	s.setCORSHeaders(w)
+	query, err := parseAnalyticsQuery(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	library, ok := s.library(w)
+	if !ok {
+		return
+	}
+	report, err := library.GetAnalytics(r.Context(), query)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	writeJSON(w, http.StatusOK, report)
+}
+
+// getActivity returns detections by hour and day and the heatmap grid
+func (s *Server) getActivity(w http.ResponseWriter, r *http.Request) {
+	s.setCORSHeaders(w)
+	query, err := parseAnalyticsQuery(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	library, ok := s.library(w)
+	if !ok {
+		return
+	}
+	report, err := library.GetActivity(r.Context(), query)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	writeJSON(w, http.StatusOK, report)
+}
+
+// getHeatmap returns the heatmap drawn over a reference frame
+func (s *Server) getHeatmap(w http.ResponseWriter, r *http.Request) {
+	s.setCORSHeaders(w)
+	query, err := parseAnalyticsQuery(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	library, ok := s.library(w)
+	if !ok {
+		return
+	}
+	overlay, err := library.GetHeatmapOverlay(r.Context(), query)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	w.Header().Set("Content-Type", "image/png")
+	w.WriteHeader(http.StatusOK)
+	w.Write(overlay)
+}
+
+// parseSearchQuery reads a search query, class is a comma separated list,
+// start and end are RFC 3339 timestamps, after and before HH:MM
+func parseSearchQuery(r *http.Request) (search.Query, error) {
+	values := r.URL.Query()
+	start, err := time.Parse(time.RFC3339, values.Get("start"))
+	if err != nil {
+		return search.Query{}, errors.New("invalid start, expected RFC 3339 time")
+	}
+	end, err := time.Parse(time.RFC3339, values.Get("end"))
+	if err != nil {
+		return search.Query{}, errors.New("invalid end, expected RFC 3339 time")
+	}
+	query := search.Query{
+		Camera:   values.Get("camera"),
+		Zone:     values.Get("zone"),
+		Start:    start,
+		End:      end,
+		After:    values.Get("after"),
+		Before:   values.Get("before"),
+		TimeZone: values.Get("timeZone"),
+	}
+	if classes := values.Get("class"); classes != "" {
+		for _, class := range strings.Split(classes, ",") {
+			parsed, err := strconv.Atoi(strings.TrimSpace(class))
+			if err != nil {
+				return search.Query{}, fmt.Errorf("invalid class %q", class)
+			}
+			query.Classes = append(query.Classes, parsed)
+		}
+	}
+	numbers := []struct {
+		name  string
+		value *float64
+	}{{"minConfidence", &query.MinConfidence}, {"minDuration", &query.MinDuration}}
+	for _, number := range numbers {
+		if value := values.Get(number.name); value != "" {
+			if *number.value, err = strconv.ParseFloat(value, 64); err != nil {
+				return search.Query{}, fmt.Errorf("invalid %s", number.name)
+			}
+		}
+	}
+	if offset := values.Get("offset"); offset != "" {
+		if query.Offset, err = strconv.Atoi(offset); err != nil {
+			return search.Query{}, errors.New("invalid offset")
+		}
+	}
+	if limit := values.Get("limit"); limit != "" {
+		if query.Limit, err = strconv.Atoi(limit); err != nil {
+			return search.Query{}, errors.New("invalid limit")
+		}
+	}
+	return query, query.Validate()
+}
+
+// searchDetections returns a page of detection occurrences with their recordings
+func (s *Server) searchDetections(w http.ResponseWriter, r *http.Request) {
+	s.setCORSHeaders(w)
+	query, err := parseSearchQuery(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	library, ok := s.library(w)
+	if !ok {
+		return
+	}
+	page, err := library.Search(r.Context(), query)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	writeJSON(w, http.StatusOK, page)
+}
+
+func (s *Server) getHealth(w http.ResponseWriter, r *http.Request) {
+	s.setCORSHeaders(w)
+	library, ok := s.library(w)
+	if !ok {
+		return
+	}
+	status, err := library.Health(r.Context())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	writeJSON(w, http.StatusOK, status)
+}
+
+func (s *Server) getCamera(w http.ResponseWriter, r *http.Request) {
+	s.setCORSHeaders(w)
+	library, ok := s.library(w)
+	if !ok {
+		return
+	}
+	state, err := library.Camera(r.Context())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	writeJSON(w, http.StatusOK, state)
+}
+
+func (s *Server) getMode(w http.ResponseWriter, r *http.Request) {
+	s.setCORSHeaders(w)
+	library, ok := s.library(w)
+	if !ok {
+		return
+	}
+	status, err := library.Mode(r.Context())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	writeJSON(w, http.StatusOK, status)
+}
+
+// setMode overrides the schedule, action is arm, disarm, away or auto and
+// until an optional RFC 3339 timestamp, required for away
+func (s *Server) setMode(w http.ResponseWriter, r *http.Request) {
+	s.setCORSHeaders(w)
+	if !s.authorized(w, r) {
+		return
+	}
+	override := schedule.Override{Action: r.URL.Query().Get("action")}
+	if until := r.URL.Query().Get("until"); until != "" {
+		var err error
+		if override.Until, err = time.Parse(time.RFC3339, until); err != nil {
+			http.Error(w, "invalid until, expected RFC 3339 time", http.StatusBadRequest)
+			return
+		}
+	}
+	if err := override.Validate(time.Now()); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	library, ok := s.library(w)
+	if !ok {
+		return
+	}
+	status, err := library.SetMode(r.Context(), override)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	writeJSON(w, http.StatusOK, status)
+}
+
+// reloadConfig makes the provider read its configuration again
+func (s *Server) reloadConfig(w http.ResponseWriter, r *http.Request) {
+	s.setCORSHeaders(w)
+	if !s.authorized(w, r) {
+		return
+	}
+	library, ok := s.library(w)
+	if !ok {
+		return
+	}
+	result, err := library.ReloadConfig(r.Context())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	writeJSON(w, http.StatusOK, result)
+}
+
+func (s *Server) getExport(w http.ResponseWriter, r *http.Request) {
+	s.setCORSHeaders(w)
+	job, ok := s.exportJobs.Get(r.PathValue("id"))
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	writeJSON(w, http.StatusOK, job)
+}
+
+func (s *Server) downloadExport(w http.ResponseWriter, r *http.Request) {
+	s.setCORSHeaders(w)
+	id := r.PathValue("id")
+	file, job, err := s.exportJobs.Open(id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	defer file.Close()
+	name := fmt.Sprintf("export-%s%s", id, filepath.Ext(job.path))
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", name))
+	http.ServeContent(w, r, name, job.finished, file)
+}
+
 func (s *Server) serveStream(w http.ResponseWriter, r *http.Request) {
 	s.setCORSHeaders(w)
 	w.Header().Set("Content-Type", "multipart/x-mixed-replace; boundary=frame")
@@ -153,7 +633,17 @@ func (s *Server) serveStream(w http.ResponseWriter, r *http.Request) {
 	streamFrames := s.registerFrameListener()
 	defer s.unregisterFrameListener(streamFrames)
 	frameNumber := -1
-	for frames := range streamFrames {
+	for {
+		var frames []frameUtils.Frame
+		select {
+		case <-r.Context().Done():
+			return
+		case received, ok := <-streamFrames:
+			if !ok {
+				return
+			}
+			frames = received
+		}
 		frameNumber++
 		if frameNumber%s.skipChunk != 0 {
 			continue
@@ -193,16 +683,11 @@ func writeJpegFrame(mw *multipart.Writer, frame []byte) error {
 
 func (s *Server) PrepareEndpoints() {
 	hlsConverter, _ := NewHLSConverter("./hls_output", s.registerFrameListener())
-	hlsConverter.Start()
+	s.hls = hlsConverter
 	fileServer := http.FileServer(http.Dir("./hls_output"))
 	http.Handle("/hls/", http.StripPrefix("/hls/", func(h http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			w.Header().Set("Access-Control-Allow-Origin", "*")
-			w.Header().Set("Access-Control-Allow-Methods", "GET, OPTIONS")
-			w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Range")
-			w.Header().Set("Cache-Control", "no-cache, no-store, must-revalidate")
-			w.Header().Set("Content-Type", "application/vnd.apple.mpegurl")
-
+			setHLSHeaders(w)
 			// Handle preflight OPTIONS request
 			if r.Method == "OPTIONS" {
 				w.WriteHeader(http.StatusOK)
@@ -211,15 +696,36 @@ func (s *Server) PrepareEndpoints() {
 			h.ServeHTTP(w, r)
 		})
 	}(fileServer)))
-
+	http.HandleFunc("/hls/"+HLSPlaylistName, s.servePlaylist)
+	http.HandleFunc("/hls/"+HLSKeyName, s.serveSegmentKey)
 	http.HandleFunc("/hls", fileServer.ServeHTTP)
 	http.HandleFunc("/video-list", s.getVideoList)
 	http.HandleFunc("/video/{name}", s.getVideo)
+	http.HandleFunc("/signature-chain", s.getSignatureChain)
+	http.HandleFunc("POST /export-clip", s.exportClip)
+	http.HandleFunc("POST /export-evidence", s.exportEvidence)
+	http.HandleFunc("GET /exports/{id}", s.getExport)
+	http.HandleFunc("POST /import", s.importVideo)
+	http.HandleFunc("GET /analytics", s.getAnalytics)
+	http.HandleFunc("GET /activity", s.getActivity)
+	http.HandleFunc("GET /heatmap.png", s.getHeatmap)
+	http.HandleFunc("GET /search", s.searchDetections)
+	http.HandleFunc("GET /mode", s.getMode)
+	http.HandleFunc("GET /health", s.getHealth)
+	http.HandleFunc("GET /camera", s.getCamera)
+	http.HandleFunc("POST /mode", s.setMode)
+	http.HandleFunc("POST /config/reload", s.reloadConfig)
+	http.HandleFunc("GET /exports/{id}/download", s.downloadExport)
 	http.HandleFunc("/stream", s.serveStream)
 
 	// Serve static files for testing
 	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
 		s.setCORSHeaders(w)
+		// open the page with ?token= to play encrypted HLS
+		hlsURL := "/hls/" + HLSPlaylistName
+		if token := r.URL.Query().Get("token"); token != "" {
+			hlsURL += "?token=" + url.QueryEscape(token)
+		}
 		html := `
 <!DOCTYPE html>
 <html>
@@ -229,13 +735,94 @@ func (s *Server) PrepareEndpoints() {
 <body>
     <h1>Live Video Stream` + fmt.Sprintf("%d", s.port) + `</h1>
 	<a href="/stream">Stream</a>
-	<a href="/hls/stream.m3u8">HLS</a>
+	<a href="` + hlsURL + `">HLS</a>
 </body>
 </html>`
 		w.Header().Set("Content-Type", "text/html")
 		w.Write([]byte(html))
 	})
 }
-func (s *Server) Start() {
-	http.ListenAndServe(fmt.Sprintf(":%d", s.port), nil)
+
+func setHLSHeaders(w http.ResponseWriter) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Access-Control-Allow-Methods", "GET, OPTIONS")
+	w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Range, Authorization")
+	w.Header().Set("Cache-Control", "no-cache, no-store, must-revalidate")
+	w.Header().Set("Content-Type", "application/vnd.apple.mpegurl")
+}
+
+// servePlaylist passes the token of players opening the playlist with
+// ?token= on to the key URI, so they fetch the key with it too
+func (s *Server) servePlaylist(w http.ResponseWriter, r *http.Request) {
+	setHLSHeaders(w)
+	if r.Method == http.MethodOptions {
+		return
+	}
+	token := r.URL.Query().Get("token")
+	if token == "" {
+		http.ServeFile(w, r, s.hls.playlistPath)
+		return
+	}
+	if !s.playerAuthorized(w, r) {
+		return
+	}
+	playlist, err := os.ReadFile(s.hls.playlistPath)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	keyURI := []byte(`URI="` + HLSKeyName + `"`)
+	withToken := []byte(fmt.Sprintf(`URI="%s?token=%s"`, HLSKeyName, url.QueryEscape(token)))
+	w.Write(bytes.ReplaceAll(playlist, keyURI, withToken))
+}
+
+// segments are public, the key decrypting them needs the auth token
+func (s *Server) serveSegmentKey(w http.ResponseWriter, r *http.Request) {
+	s.setCORSHeaders(w)
+	if r.Method == http.MethodOptions {
+		return
+	}
+	if !s.playerAuthorized(w, r) {
+		return
+	}
+	key := s.hls.SegmentKey()
+	if key == nil {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Write(key)
+}
+
+// Start serves until ctx is done, requests still running then get a few
+// seconds to finish and running exports are cancelled
+func (s *Server) Start(ctx context.Context) error {
+	if s.hls != nil {
+		s.hls.Start(ctx)
+	}
+	server := &http.Server{
+		Addr: fmt.Sprintf(":%d", s.port),
+		BaseContext: func(net.Listener) context.Context {
+			return ctx
+		},
+	}
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), serverShutdownTimeout)
+		defer cancel()
+		if err := server.Shutdown(shutdownCtx); err != nil {
+			log.Printf("Server on port %d did not stop in time: %v", s.port, err)
+			server.Close()
+		}
+		s.exportJobs.Stop()
+	}()
+	err := server.ListenAndServe()
+	if errors.Is(err, http.ErrServerClosed) {
+		<-stopped
+		return nil
+	}
+	return err
 }
@@ -0,0 +1,250 @@
+package watcher
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"strzcam.com/broadcaster/connection"
+	"strzcam.com/broadcaster/schedule"
+	"strzcam.com/broadcaster/video"
+)
+
+func TestServerAuthorized(t *testing.T) {
+	tests := []struct {
+		name          string
+		token         string
+		authorization string
+		expected      int
+	}{
+		{"no token configured", "", "Bearer secret", http.StatusForbidden},
+		{"missing header", "secret", "", http.StatusUnauthorized},
+		{"wrong token", "secret", "Bearer other", http.StatusUnauthorized},
+		{"not a bearer token", "secret", "secret", http.StatusUnauthorized},
+		{"matching token", "secret", "Bearer secret", http.StatusOK},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			server, _ := NewServer(0, Config{AuthToken: test.token})
+			request := httptest.NewRequest(http.MethodGet, "/hls/"+HLSKeyName, nil)
+			if test.authorization != "" {
+				request.Header.Set("Authorization", test.authorization)
+			}
+			recorder := httptest.NewRecorder()
+			if server.authorized(recorder, request) {
+				recorder.WriteHeader(http.StatusOK)
+			}
+			if recorder.Code != test.expected {
+				t.Errorf("Expected status %d, got %d", test.expected, recorder.Code)
+			}
+		})
+	}
+}
+
+// changeLibrary records changes, other requests are not expected
+type changeLibrary struct {
+	Library
+	overrides []schedule.Override
+	imports   []video.ImportRequest
+	reloads   int
+}
+
+func (l *changeLibrary) SetMode(ctx context.Context, override schedule.Override) (schedule.Status, error) {
+	l.overrides = append(l.overrides, override)
+	return schedule.Status{Mode: schedule.ModeDisarmed}, nil
+}
+
+func (l *changeLibrary) ImportVideo(ctx context.Context, request video.ImportRequest, r io.Reader, size int64) (string, error) {
+	l.imports = append(l.imports, request)
+	return video.ImportName(request.Start), nil
+}
+
+func (l *changeLibrary) ReloadConfig(ctx context.Context) (connection.ReloadResult, error) {
+	l.reloads++
+	return connection.ReloadResult{Applied: []string{"zones"}}, nil
+}
+
+func TestSetModeRequiresToken(t *testing.T) {
+	tests := []struct {
+		name          string
+		authorization string
+		expected      int
+	}{
+		{"unauthenticated", "", http.StatusUnauthorized},
+		{"wrong token", "Bearer other", http.StatusUnauthorized},
+		{"authenticated", "Bearer secret", http.StatusOK},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			library := &changeLibrary{}
+			server, _ := NewServer(0, Config{AuthToken: "secret"})
+			server.Library = library
+			request := httptest.NewRequest(http.MethodPost, "/mode?action=disarm", nil)
+			if test.authorization != "" {
+				request.Header.Set("Authorization", test.authorization)
+			}
+			recorder := httptest.NewRecorder()
+			server.setMode(recorder, request)
+			if recorder.Code != test.expected {
+				t.Errorf("Expected status %d, got %d: %s", test.expected, recorder.Code, recorder.Body)
+			}
+			applied := len(library.overrides) > 0
+			if applied != (test.expected == http.StatusOK) {
+				t.Errorf("Expected the override applied %v, got %v", test.expected == http.StatusOK, applied)
+			}
+		})
+	}
+}
+
+func TestImportRequiresToken(t *testing.T) {
+	for _, authorization := range []string{"", "Bearer other"} {
+		library := &changeLibrary{}
+		server, _ := NewServer(0, Config{AuthToken: "secret"})
+		server.Library = library
+		request := httptest.NewRequest(http.MethodPost, "/import?camera=front&start=2025-01-02T14:02:10Z", strings.NewReader("footage"))
+		if authorization != "" {
+			request.Header.Set("Authorization", authorization)
+		}
+		recorder := httptest.NewRecorder()
+		server.importVideo(recorder, request)
+		if recorder.Code != http.StatusUnauthorized || len(library.imports) != 0 {
+			t.Errorf("Expected import with %q to be refused, got %d and %d imports", authorization, recorder.Code, len(library.imports))
+		}
+	}
+	library := &changeLibrary{}
+	server, _ := NewServer(0, Config{AuthToken: "secret"})
+	server.Library = library
+	request := httptest.NewRequest(http.MethodPost, "/import?camera=front&start=2025-01-02T14:02:10Z", strings.NewReader("footage"))
+	request.Header.Set("Authorization", "Bearer secret")
+	recorder := httptest.NewRecorder()
+	server.importVideo(recorder, request)
+	if recorder.Code != http.StatusCreated || len(library.imports) != 1 {
+		t.Errorf("Expected an authorized import, got %d: %s", recorder.Code, recorder.Body)
+	}
+}
+
+func TestReloadConfigRequiresToken(t *testing.T) {
+	for authorization, expected := range map[string]int{"": http.StatusUnauthorized, "Bearer other": http.StatusUnauthorized, "Bearer secret": http.StatusOK} {
+		library := &changeLibrary{}
+		server, _ := NewServer(0, Config{AuthToken: "secret"})
+		server.Library = library
+		request := httptest.NewRequest(http.MethodPost, "/config/reload", nil)
+		if authorization != "" {
+			request.Header.Set("Authorization", authorization)
+		}
+		recorder := httptest.NewRecorder()
+		server.reloadConfig(recorder, request)
+		if recorder.Code != expected || (library.reloads == 1) != (expected == http.StatusOK) {
+			t.Errorf("Expected status %d with %q, got %d and %d reloads", expected, authorization, recorder.Code, library.reloads)
+		}
+	}
+}
+
+func TestServerLocalLibrary(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "2025-01-02-1-30.mp4"), []byte("footage"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	provider := connection.NewProvider(nil, []string{dir})
+	provider.SetSchedule(schedule.NewController(schedule.Schedule{}, time.UTC, filepath.Join(dir, "schedule_state.json")))
+	server, _ := NewServer(0, Config{AuthToken: "secret"})
+	server.Library = connection.NewLocal(provider)
+
+	recorder := httptest.NewRecorder()
+	server.getVideoList(recorder, httptest.NewRequest(http.MethodGet, "/video-list?start=2025-01-01&end=2025-01-03", nil))
+	var videos []video.Video
+	if err := json.NewDecoder(recorder.Body).Decode(&videos); err != nil || len(videos) != 1 || videos[0].Name != "2025-01-02-1-30.mp4" {
+		t.Errorf("Expected the recording of 2025-01-02, got %+v %v", videos, err)
+	}
+
+	request := httptest.NewRequest(http.MethodGet, "/video/2025-01-03-1-30.mp4", nil)
+	request.SetPathValue("name", "2025-01-03-1-30.mp4")
+	recorder = httptest.NewRecorder()
+	server.getVideo(recorder, request)
+	if recorder.Code != http.StatusBadGateway {
+		t.Errorf("Expected a missing video to be unavailable, got %d", recorder.Code)
+	}
+
+	request = httptest.NewRequest(http.MethodPost, "/mode?action=disarm", nil)
+	request.Header.Set("Authorization", "Bearer secret")
+	recorder = httptest.NewRecorder()
+	server.setMode(recorder, request)
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("Expected the mode to change, got %d: %s", recorder.Code, recorder.Body)
+	}
+	recorder = httptest.NewRecorder()
+	server.getMode(recorder, httptest.NewRequest(http.MethodGet, "/mode", nil))
+	var status schedule.Status
+	if err := json.NewDecoder(recorder.Body).Decode(&status); err != nil || status.Mode != schedule.ModeDisarmed {
+		t.Errorf("Expected disarmed, got %+v %v", status, err)
+	}
+}
+
+// players resolve the key URI of the playlist and fetch it without headers
+func TestPlayerFetchesSegmentKey(t *testing.T) {
+	server, _ := NewServer(0, Config{AuthToken: "secret"})
+	server.hls, _ = NewHLSConverter(t.TempDir(), nil)
+	server.hls.segmentKey = []byte("0123456789abcdef")
+	playlist := "#EXTM3U\n#EXT-X-KEY:METHOD=AES-128,URI=\"" + HLSKeyName + "\",IV=0x0\n#EXTINF:2.0,\nsegment_000.ts\n"
+	if err := os.WriteFile(server.hls.playlistPath, []byte(playlist), 0644); err != nil {
+		t.Fatal(err)
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/hls/"+HLSPlaylistName, server.servePlaylist)
+	mux.HandleFunc("/hls/"+HLSKeyName, server.serveSegmentKey)
+	httpServer := httptest.NewServer(mux)
+	defer httpServer.Close()
+
+	fetchKey := func(t *testing.T, playlistURL string) int {
+		t.Helper()
+		response, err := http.Get(playlistURL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := io.ReadAll(response.Body)
+		response.Body.Close()
+		_, rest, ok := strings.Cut(string(body), `URI="`)
+		uri, _, _ := strings.Cut(rest, `"`)
+		if !ok {
+			t.Fatalf("Expected a key URI in the playlist, got %s", body)
+		}
+		base, _ := url.Parse(playlistURL)
+		keyURL, err := base.Parse(uri)
+		if err != nil {
+			t.Fatal(err)
+		}
+		response, err = http.Get(keyURL.String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer response.Body.Close()
+		key, _ := io.ReadAll(response.Body)
+		if response.StatusCode == http.StatusOK && string(key) != "0123456789abcdef" {
+			t.Errorf("Expected the segment key, got %q", key)
+		}
+		return response.StatusCode
+	}
+	playlistURL := httpServer.URL + "/hls/" + HLSPlaylistName
+	if code := fetchKey(t, playlistURL+"?token=secret"); code != http.StatusOK {
+		t.Errorf("Expected the key for a player with the token, got %d", code)
+	}
+	if code := fetchKey(t, playlistURL); code != http.StatusUnauthorized {
+		t.Errorf("Expected the key refused without the token, got %d", code)
+	}
+	response, err := http.Get(playlistURL + "?token=other")
+	if err != nil {
+		t.Fatal(err)
+	}
+	response.Body.Close()
+	if response.StatusCode != http.StatusUnauthorized {
+		t.Errorf("Expected a wrong token refused, got %d", response.StatusCode)
+	}
+}
@@ -2,15 +2,29 @@ package watcher
 
 import (
 	"bytes"
+	"context"
 	"encoding/binary"
+	"errors"
 	"fmt"
 	"log"
 	"os"
 	"path/filepath"
+	"slices"
+	"sync"
 	"time"
 
 	"github.com/fsnotify/fsnotify"
+	"strzcam.com/broadcaster/analytics"
+	"strzcam.com/broadcaster/detector"
+	"strzcam.com/broadcaster/events"
 	"strzcam.com/broadcaster/frame"
+	"strzcam.com/broadcaster/health"
+	"strzcam.com/broadcaster/motion"
+	"strzcam.com/broadcaster/osd"
+	"strzcam.com/broadcaster/privacy"
+	"strzcam.com/broadcaster/schedule"
+	"strzcam.com/broadcaster/watchdog"
+	"strzcam.com/broadcaster/zones"
 )
 
 type ConfigProvider interface {
@@ -20,16 +34,46 @@ type ConfigProvider interface {
 	GetSaveChunkSize() int
 }
 
+// MotionConfigProvider is implemented by providers that can enable the motion detector
+type MotionConfigProvider interface {
+	GetMotionConfig() (motion.Config, bool)
+}
+
+// OSDConfigProvider is implemented by providers drawing an on-screen display
+type OSDConfigProvider interface {
+	GetOSDConfig() osd.Config
+}
+
+// PrivacyConfigProvider is implemented by providers with privacy masks
+type PrivacyConfigProvider interface {
+	GetPrivacyMasks() []privacy.Mask
+}
+
+// ScheduleConfigProvider is implemented by providers with arm/disarm schedules
+type ScheduleConfigProvider interface {
+	GetSchedule() *schedule.Controller
+}
+
+// WatchdogConfigProvider is implemented by providers noticing a stopped camera
+type WatchdogConfigProvider interface {
+	GetWatchdog() *watchdog.Watchdog
+}
+
+// ZonesConfigProvider is implemented by providers with detection zones
+type ZonesConfigProvider interface {
+	GetZones() zones.CameraZones
+}
+
 type DefaultConfigProvider struct {
 	config Config
 }
 
-func NewDefaultConfigProvider() DefaultConfigProvider {
-	return DefaultConfigProvider{config: NewConfig()}
+func NewDefaultConfigProvider(config Config) DefaultConfigProvider {
+	return DefaultConfigProvider{config: config}
 }
 
 func (d DefaultConfigProvider) GetSavePath() string {
-	return SavePath
+	return d.config.ChunkRoot
 }
 func (d DefaultConfigProvider) GetShowWhatWasBefore() int {
 	return d.config.ShowWhatWasBefore
@@ -40,10 +84,29 @@ func (d DefaultConfigProvider) GetShowWhatWasAfter() int {
 func (d DefaultConfigProvider) GetSaveChunkSize() int {
 	return d.config.SaveChunkSize
 }
+func (d DefaultConfigProvider) GetMotionConfig() (motion.Config, bool) {
+	return d.config.Motion, d.config.MotionDetection
+}
+func (d DefaultConfigProvider) GetZones() zones.CameraZones {
+	return d.config.Zones
+}
+func (d DefaultConfigProvider) GetSchedule() *schedule.Controller {
+	return d.config.NewScheduleController()
+}
+func (d DefaultConfigProvider) GetPrivacyMasks() []privacy.Mask {
+	return d.config.PrivacyMasks
+}
+func (d DefaultConfigProvider) GetOSDConfig() osd.Config {
+	return d.config.OSD
+}
+func (d DefaultConfigProvider) GetWatchdog() *watchdog.Watchdog {
+	return d.config.NewWatchdog()
+}
 
 type SignificantFrame struct {
-	Frame  frame.Frame
-	Before *CircularBuffer
+	Frame    frame.Frame
+	Captured time.Time // when the frame was received
+	Before   *CircularBuffer
 }
 type SharedMemoryReceiver struct {
 	shmPath           string
@@ -55,10 +118,25 @@ type SharedMemoryReceiver struct {
 	ActualFps         float64
 	FrameWidth        uint32
 	FrameHeight       uint32
+	Events            *events.Log                // detections are recorded when set
+	Heatmap           *analytics.HeatmapRecorder // where detections were, when set
+	Motion            *motion.Detector           // fallback for sources without a detector
+	Detectors         *detector.Scheduler        // external detector plugins when set
+	Zones             zones.CameraZones
+	Analyzer          *analytics.Analyzer  // tracks objects when tripwires are set
+	Schedule          *schedule.Controller // always armed when nil
+	Privacy           *privacy.Masker      // masks frames before anything else sees them
+	OSD               *osd.Renderer        // time and camera overlay, nil when off
+	Health            *health.Monitor      // image health checks when set
+	HealthStateFile   string               // health status for other processes
+	healthSaved       time.Time
+	Watchdog          *watchdog.Watchdog // marks the camera offline when frames stop
+	reconfigure       chan func()        // run by the watch loop between frames
+	sending           sync.WaitGroup     // significant frames on their way to SignificantFrames
 }
 
 func NewSharedMemoryReceiverWithConfig(shmName string, configProvider ConfigProvider) (*SharedMemoryReceiver, error) {
-	saveFramePath := fmt.Sprintf("%s_video_frame", configProvider.GetSavePath())
+	saveFramePath := configProvider.GetSavePath()
 	if err := os.MkdirAll(saveFramePath, 0755); err != nil {
 		panic(fmt.Sprintf("Cannot create directory: %v", err))
 	}
@@ -76,6 +154,36 @@ func NewSharedMemoryReceiverWithConfig(shmName string, configProvider ConfigProv
 		ActualFps:         30,
 		FrameWidth:        0,
 		FrameHeight:       0,
+		reconfigure:       make(chan func()),
+	}
+	if provider, ok := configProvider.(MotionConfigProvider); ok {
+		if config, enabled := provider.GetMotionConfig(); enabled {
+			receiver.Motion = motion.NewDetector(config)
+		}
+	}
+	if provider, ok := configProvider.(ZonesConfigProvider); ok {
+		receiver.Zones = provider.GetZones()
+		if len(receiver.Zones.Tripwires) > 0 {
+			receiver.Analyzer = analytics.NewAnalyzer(receiver.Zones.Tripwires)
+		}
+	}
+	if provider, ok := configProvider.(PrivacyConfigProvider); ok {
+		if masks := provider.GetPrivacyMasks(); len(masks) > 0 {
+			receiver.Privacy = privacy.NewMasker(masks)
+		}
+	}
+	if provider, ok := configProvider.(OSDConfigProvider); ok {
+		if config := provider.GetOSDConfig(); config.Stage == osd.StageRecording || config.Stage == osd.StageAll {
+			if receiver.OSD, err = osd.NewRenderer(config); err != nil {
+				log.Printf("Warning: %v, OSD is off", err)
+			}
+		}
+	}
+	if provider, ok := configProvider.(ScheduleConfigProvider); ok {
+		receiver.Schedule = provider.GetSchedule()
+	}
+	if provider, ok := configProvider.(WatchdogConfigProvider); ok {
+		receiver.Watchdog = provider.GetWatchdog()
 	}
 	err = watcher.Add("/dev/shm")
 	if err != nil {
@@ -85,8 +193,9 @@ func NewSharedMemoryReceiverWithConfig(shmName string, configProvider ConfigProv
 	return receiver, nil
 }
 
-func NewSharedMemoryReceiver(shmName string) (*SharedMemoryReceiver, error) {
-	return NewSharedMemoryReceiverWithConfig(shmName, NewDefaultConfigProvider())
+// NewSharedMemoryReceiver reads frames of the configured camera
+func NewSharedMemoryReceiver(config Config) (*SharedMemoryReceiver, error) {
+	return NewSharedMemoryReceiverWithConfig(config.SharedMemory, NewDefaultConfigProvider(config))
 }
 
 func (smr *SharedMemoryReceiver) ReadFrameFromShm() (frame.Frame, error) {
@@ -99,13 +208,35 @@ func (smr *SharedMemoryReceiver) ReadFrameFromShm() (frame.Frame, error) {
 	if err != nil {
 		return frame.Frame{Detected: detected}, err
 	}
-	return frame.Frame{
+	if len(data) < 9 {
+		// writer truncated the file and has not filled it yet
+		return frame.Frame{Detected: detected}, fmt.Errorf("incomplete frame in shared memory")
+	}
+	f := frame.Frame{
 		Data:     data[9:],
 		Width:    binary.LittleEndian.Uint32(data[1:5]),
 		Height:   binary.LittleEndian.Uint32(data[5:9]),
 		Detected: int(int8(data[0])),
-	}, nil
+	}
+	// detection boxes follow the YUV 4:2:0 frame
+	frameSize := int(f.Width) * int(f.Height) * 3 / 2
+	if frameSize > 0 && len(f.Data) > frameSize {
+		f.Boxes = frame.ParseBoxes(f.Data[frameSize:])
+		f.Data = f.Data[:frameSize]
+	}
+	return f, nil
+}
+
+// sendLater sends without blocking the watch loop, which waits for these
+// sends before closing SignificantFrames
+func (smr *SharedMemoryReceiver) sendLater(sf SignificantFrame) {
+	smr.sending.Add(1)
+	go func() {
+		defer smr.sending.Done()
+		smr.SendSignificantFrame(sf)
+	}()
 }
+
 func (smr *SharedMemoryReceiver) SendSignificantFrame(sf SignificantFrame) {
 	select {
 	case smr.SignificantFrames <- sf:
@@ -133,8 +264,129 @@ func (smr *SharedMemoryReceiver) GetBaseDir() string {
 	year, month, day := time.Now().Date()
 	return fmt.Sprintf("%s/%d-%02d-%02d", smr.savePath, year, month, day)
 }
-func (smr *SharedMemoryReceiver) WatchSharedMemory(saveForLater bool) {
+
+// a detection lasting several frames is logged once per this period
+const detectionEventPeriod = time.Second
+
+func (smr *SharedMemoryReceiver) recordDetection(frame frame.Frame, last map[int]time.Time) {
+	if smr.Events == nil || frame.Detected == -1 {
+		return
+	}
+	now := time.Now()
+	if now.Sub(last[frame.Detected]) < detectionEventPeriod {
+		return
+	}
+	last[frame.Detected] = now
+	var zoneNames []string
+	confidence := 0.0
+	for _, box := range frame.Boxes {
+		if box.Class == frame.Detected {
+			confidence = max(confidence, box.Confidence)
+		}
+		for _, name := range box.Zones {
+			if !slices.Contains(zoneNames, name) {
+				zoneNames = append(zoneNames, name)
+			}
+		}
+	}
+	err := smr.Events.Record(events.Event{
+		Time:       now,
+		Type:       events.TypeDetection,
+		Class:      frame.Detected,
+		Zones:      zoneNames,
+		Confidence: confidence,
+	})
+	if err != nil {
+		log.Printf("Can not record detection: %v", err)
+	}
+}
+
+// Mode is the current schedule mode of the camera
+func (smr *SharedMemoryReceiver) Mode() string {
+	if smr.Schedule == nil {
+		return schedule.ModeArmed
+	}
+	return smr.Schedule.Mode(time.Now())
+}
+
+// health status is saved at least this often so readers can tell it is current
+const healthSavePeriod = 10 * time.Second
+
+func (smr *SharedMemoryReceiver) checkHealth(frame frame.Frame) {
+	now := time.Now()
+	changes := smr.Health.Analyze(frame, now)
+	for _, change := range changes {
+		state := "cleared"
+		if change.Active {
+			state = "raised"
+			log.Printf("Camera health alert: %s", change.Condition)
+		} else {
+			log.Printf("Camera health recovered: %s", change.Condition)
+		}
+		if smr.Events != nil {
+			err := smr.Events.Record(events.Event{Type: events.TypeHealth, Condition: change.Condition, State: state})
+			if err != nil {
+				log.Printf("Can not record health event: %v", err)
+			}
+		}
+	}
+	if smr.HealthStateFile == "" || (len(changes) == 0 && now.Sub(smr.healthSaved) < healthSavePeriod) {
+		return
+	}
+	smr.healthSaved = now
+	if err := health.SaveStatus(smr.HealthStateFile, smr.Health.Status()); err != nil {
+		log.Printf("Can not save health status: %v", err)
+	}
+}
+
+func (smr *SharedMemoryReceiver) recordCameraState() {
+	state := smr.Watchdog.State()
+	event := events.Event{Time: state.Since, Type: events.TypeCamera, State: "online"}
+	if state.Online {
+		log.Printf("Camera %s is online", state.Camera)
+	} else {
+		event.State = "offline"
+		log.Printf("Camera %s is offline, last frame at %s", state.Camera, state.LastFrame.Format(time.RFC3339))
+	}
+	if smr.Events != nil {
+		if err := smr.Events.Record(event); err != nil {
+			log.Printf("Can not record camera state: %v", err)
+		}
+	}
+}
+
+func (smr *SharedMemoryReceiver) recordCrossings(crossings []analytics.Crossing) {
+	if smr.Events == nil {
+		return
+	}
+	for _, crossing := range crossings {
+		err := smr.Events.Record(events.Event{
+			Type:      events.TypeCrossing,
+			Class:     crossing.Class,
+			Track:     crossing.Track,
+			Tripwire:  crossing.Tripwire,
+			Direction: crossing.Direction,
+		})
+		if err != nil {
+			log.Printf("Can not record crossing: %v", err)
+		}
+	}
+}
+
+// WatchSharedMemory reads frames until ctx is done or the receiver is closed,
+// then it closes SignificantFrames so SaveFrameForLater saves what is left
+// and adds pending boxes to the heatmap
+func (smr *SharedMemoryReceiver) WatchSharedMemory(ctx context.Context, saveForLater bool) {
 	log.Println("Starting shared memory watcher...")
+	defer func() {
+		smr.sending.Wait()
+		if smr.Heatmap != nil {
+			if err := smr.Heatmap.Flush(); err != nil {
+				log.Printf("Can not record heatmap: %v", err)
+			}
+		}
+		close(smr.SignificantFrames)
+	}()
 	showWhatWasAfter := smr.configProvider.GetShowWhatWasAfter()
 	var before *CircularBuffer
 	if saveForLater {
@@ -144,8 +396,24 @@ func (smr *SharedMemoryReceiver) WatchSharedMemory(saveForLater bool) {
 	var lastFrameData []byte
 	startTime := time.Now()
 	frameCount := 0
+	lastDetections := map[int]time.Time{}
+	var watchdogTicks <-chan time.Time
+	if smr.Watchdog != nil {
+		ticker := time.NewTicker(time.Second)
+		defer ticker.Stop()
+		watchdogTicks = ticker.C
+	}
 	for {
 		select {
+		case <-ctx.Done():
+			log.Println("Stopping shared memory watcher")
+			return
+		case now := <-watchdogTicks:
+			if smr.Watchdog.Check(now) {
+				smr.recordCameraState()
+			}
+		case apply := <-smr.reconfigure:
+			apply()
 		case event, ok := <-smr.watcher.Events:
 			if !ok {
 				return
@@ -158,6 +426,19 @@ func (smr *SharedMemoryReceiver) WatchSharedMemory(saveForLater bool) {
 					log.Printf("Error reading frame from shared memory: %v", err)
 					continue
 				}
+				if smr.Watchdog != nil && smr.Watchdog.Frame(time.Now()) {
+					smr.recordCameraState()
+				}
+				if smr.Privacy != nil {
+					if err := smr.Privacy.Apply(frame); err != nil {
+						log.Printf("Dropping frame: %v", err)
+						continue
+					}
+				}
+				// before skipping duplicates, a frozen camera repeats the same frame
+				if smr.Health != nil {
+					smr.checkHealth(frame)
+				}
 				// skip the same event triggered twice
 				if bytes.Equal(frame.Data, lastFrameData) {
 					continue
@@ -171,24 +452,58 @@ func (smr *SharedMemoryReceiver) WatchSharedMemory(saveForLater bool) {
 					startTime = time.Now()
 				}
 				frame.Fps = smr.ActualFps
+				if smr.Detectors != nil {
+					smr.Detectors.Submit(frame)
+					frame = smr.Detectors.Apply(frame, time.Now())
+				}
+				frame = smr.Zones.Filter(frame)
+				if smr.Analyzer != nil {
+					smr.recordCrossings(smr.Analyzer.Update(frame))
+				}
+				// the detector keeps learning the background during detections
+				if smr.Motion != nil && smr.Motion.Detect(frame) && frame.Detected == -1 {
+					frame.Detected = motion.Class
+				}
 				smr.FrameHeight = frame.Height
 				smr.FrameWidth = frame.Width
-				smr.Frames <- frame
-				if saveForLater && frame.Detected != -1 {
+				live, recorded := frame, frame
+				if smr.OSD != nil && (saveForLater || smr.OSD.Stage() == osd.StageAll) {
+					recorded = smr.OSD.Render(frame, time.Now())
+					if smr.OSD.Stage() == osd.StageAll {
+						live = recorded
+					}
+				}
+				select {
+				case smr.Frames <- live:
+				case <-ctx.Done():
+					return
+				}
+				mode := smr.Mode()
+				if mode != schedule.ModeDisarmed {
+					smr.recordDetection(frame, lastDetections)
+					if smr.Heatmap != nil {
+						if err := smr.Heatmap.Add(frame, time.Now()); err != nil {
+							log.Printf("Can not record heatmap: %v", err)
+						}
+					}
+				}
+				significant := (frame.Detected != -1 && mode != schedule.ModeDisarmed) || mode == schedule.ModeContinuous
+				if saveForLater && significant {
 					sf := SignificantFrame{
-						Frame:  frame,
-						Before: before,
+						Frame:    recorded,
+						Captured: time.Now(),
+						Before:   before,
 					}
-					go smr.SendSignificantFrame(sf)
+					smr.sendLater(sf)
 					after = showWhatWasAfter + 1
 				} else if saveForLater && after-1 <= 0 {
-					before.Add(frame.Data)
+					before.Add(recorded.Data)
 				}
 				if after != 0 {
 					after--
-					if frame.Detected == -1 {
-						sf := SignificantFrame{Frame: frame, Before: nil}
-						go smr.SendSignificantFrame(sf)
+					if !significant {
+						sf := SignificantFrame{Frame: recorded, Captured: time.Now(), Before: nil}
+						smr.sendLater(sf)
 					}
 					if after == 0 {
 						CreateNewDirIndex(smr.GetBaseDir())
@@ -205,11 +520,32 @@ func (smr *SharedMemoryReceiver) WatchSharedMemory(saveForLater bool) {
 		}
 	}
 }
+
+// Close stops watching frames and closes the detectors in use, a reload may
+// have replaced the ones set at start
 func (smr *SharedMemoryReceiver) Close() {
 	if smr.watcher != nil {
 		smr.watcher.Close()
 	}
+	if smr.Detectors != nil {
+		smr.Detectors.Close()
+	}
 }
+
+// betweenFrames runs apply in the watch loop so frames see all of its
+// changes or none of them
+func (smr *SharedMemoryReceiver) betweenFrames(apply func()) error {
+	done := make(chan struct{})
+	select {
+	case smr.reconfigure <- func() { apply(); close(done) }:
+		<-done
+		return nil
+	case <-time.After(5 * time.Second):
+		return errors.New("frames are not being processed")
+	}
+}
+
+// SaveFrameForLater saves significant frames until WatchSharedMemory stops
 func (smr *SharedMemoryReceiver) SaveFrameForLater() {
 	for detectedFrame := range smr.SignificantFrames {
 		i, path, err := TouchDirAndGetIndex(smr.GetBaseDir(), int64(smr.configProvider.GetSaveChunkSize()))
@@ -218,13 +554,15 @@ func (smr *SharedMemoryReceiver) SaveFrameForLater() {
 			return
 		}
 		if detectedFrame.Before != nil {
-			for _, frameBefore := range detectedFrame.Before.GetAll() {
-				SaveFrame(i, frameBefore, path)
+			// pre-roll frames are saved late, they keep the time they were received
+			added := detectedFrame.Before.GetAddedTimes()
+			for k, frameBefore := range detectedFrame.Before.GetAll() {
+				SaveFrame(i, frameBefore, path, added[k])
 				i += 1
 			}
 			detectedFrame.Before.Clear()
 		}
-		SaveFrame(i, detectedFrame.Frame.Data, path)
+		SaveFrame(i, detectedFrame.Frame.Data, path, detectedFrame.Captured)
 		i += 1
 		if !IsMetadataExists(path) {
 			SaveMetadata(detectedFrame.Frame.Width, detectedFrame.Frame.Height, path)
@@ -2,13 +2,18 @@ package watcher
 
 import (
 	"bytes"
+	"context"
 	"encoding/binary"
 	"fmt"
 	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 
 	"golang.org/x/sys/unix"
+	"strzcam.com/broadcaster/analytics"
+	"strzcam.com/broadcaster/frame"
 )
 
 type TestConfigProvider struct {
@@ -113,7 +118,7 @@ func TestSharedMemory(t *testing.T) {
 
 		receiver, _ := NewSharedMemoryReceiverWithConfig("test_shm", configProvider)
 		defer receiver.Close()
-		go receiver.WatchSharedMemory(true)
+		go receiver.WatchSharedMemory(context.Background(), true)
 		createFrameWithDelay(data, 1, "test_shm")
 		timeout := time.After(2 * time.Second)
 		select {
@@ -135,7 +140,7 @@ func TestSaveSignificantFrameForLaterWhenDirIsEmpty(t *testing.T) {
 	t.Run("Detected frame", func(t *testing.T) {
 		receiver, _ := NewSharedMemoryReceiverWithConfig("test_shm", configProvider)
 		defer receiver.Close()
-		go receiver.WatchSharedMemory(true)
+		go receiver.WatchSharedMemory(context.Background(), true)
 		createFrameWithDelay(data, 1, "test_shm")
 		timeout := time.After(2 * time.Second)
 		hasFrames := make(chan bool, 1)
@@ -176,7 +181,7 @@ func TestSaveSignificantFrameForLaterWhenDirIsEmpty(t *testing.T) {
 	t.Run("Send frames before when detection", func(t *testing.T) {
 		receiver, _ := NewSharedMemoryReceiverWithConfig("test_shm", configProvider)
 		defer receiver.Close()
-		go receiver.WatchSharedMemory(true)
+		go receiver.WatchSharedMemory(context.Background(), true)
 		createFrameWithDelay([]byte("nothing 1"), -1, "test_shm")
 		createFrameWithDelay([]byte("nothing 2"), -1, "test_shm")
 		createFrameWithDelay(data, 0, "test_shm")
@@ -208,7 +213,7 @@ func TestSaveSignificantFrameForLaterWhenDirIsEmpty(t *testing.T) {
 	t.Run("Send frames after when detection", func(t *testing.T) {
 		receiver, _ := NewSharedMemoryReceiverWithConfig("test_shm", configProvider)
 		defer receiver.Close()
-		go receiver.WatchSharedMemory(true)
+		go receiver.WatchSharedMemory(context.Background(), true)
 		createFrameWithDelay(data, 0, "test_shm")
 		createFrameWithDelay([]byte("nothing 1"), -1, "test_shm")
 		createFrameWithDelay([]byte("nothing 2"), -1, "test_shm")
@@ -224,7 +229,10 @@ func TestSaveSignificantFrameForLaterWhenDirIsEmpty(t *testing.T) {
 		go func() {
 			for {
 				select {
-				case sf := <-receiver.SignificantFrames:
+				case sf, ok := <-receiver.SignificantFrames:
+					if !ok {
+						return
+					}
 					switch {
 					case called == 0:
 						if !bytes.Equal(sf.Frame.Data, data) {
@@ -258,7 +266,7 @@ func TestSaveSignificantFrameForLaterWhenDirIsEmpty(t *testing.T) {
 	t.Run("Send after frames when buffer is full after detection", func(t *testing.T) {
 		receiver, _ := NewSharedMemoryReceiverWithConfig("test_shm", configProvider)
 		defer receiver.Close()
-		go receiver.WatchSharedMemory(true)
+		go receiver.WatchSharedMemory(context.Background(), true)
 		createFrameWithDelay(data, 0, "test_shm")
 		createFrameWithDelay([]byte("nothing after 1"), -1, "test_shm")
 		createFrameWithDelay([]byte("nothing after 2"), -1, "test_shm")
@@ -275,7 +283,10 @@ func TestSaveSignificantFrameForLaterWhenDirIsEmpty(t *testing.T) {
 		go func() {
 			for {
 				select {
-				case sf := <-receiver.SignificantFrames:
+				case sf, ok := <-receiver.SignificantFrames:
+					if !ok {
+						return
+					}
 					switch {
 					case called == 0:
 						if !bytes.Equal(sf.Frame.Data, data) {
@@ -301,3 +312,51 @@ func TestSaveSignificantFrameForLaterWhenDirIsEmpty(t *testing.T) {
 		}
 	})
 }
+
+func TestWatchSharedMemoryFlushesOnCancel(t *testing.T) {
+	tempPath := t.TempDir()
+	configProvider := TestConfigProvider{path: tempPath, before: 2, after: 2}
+	defer os.Remove("/dev/shm/test_shm_cancel")
+	receiver, _ := NewSharedMemoryReceiverWithConfig("test_shm_cancel", configProvider)
+	defer receiver.Close()
+	heatmapDir := t.TempDir()
+	receiver.Heatmap, _ = analytics.NewHeatmapRecorder(heatmapDir, "front")
+	// pending until the next periodic flush
+	now := time.Now()
+	receiver.Heatmap.Add(frame.Frame{Width: 64, Height: 36, Detected: 0, Boxes: []frame.Box{{Width: 32, Height: 18}}}, now)
+	ctx, cancel := context.WithCancel(context.Background())
+	watching := make(chan struct{})
+	go func() {
+		receiver.WatchSharedMemory(ctx, true)
+		close(watching)
+	}()
+	saved := make(chan struct{})
+	go func() {
+		receiver.SaveFrameForLater()
+		close(saved)
+	}()
+	createFrameWithDelay([]byte("detection"), 0, "test_shm_cancel")
+	cancel()
+	for _, done := range []chan struct{}{watching, saved} {
+		select {
+		case <-done:
+		case <-time.After(2 * time.Second):
+			t.Fatal("Timeout waiting for the receiver to stop")
+		}
+	}
+	files := 0
+	filepath.Walk(tempPath, func(path string, info os.FileInfo, err error) error {
+		if err == nil && !info.IsDir() && strings.HasPrefix(info.Name(), "frame") {
+			files++
+		}
+		return nil
+	})
+	if files != 1 {
+		t.Errorf("Expected the detected frame saved before stopping, got %d frames", files)
+	}
+	hour := now.Truncate(time.Hour)
+	heatmap, err := analytics.LoadHeatmap(heatmapDir, analytics.Query{Start: hour, End: hour.Add(time.Hour)})
+	if err != nil || heatmap.Cells[0] == 0 {
+		t.Errorf("Expected pending boxes flushed to the heatmap before stopping, got %d %v", heatmap.Cells[0], err)
+	}
+}
@@ -0,0 +1,142 @@
+package watcher
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"strzcam.com/broadcaster/video"
+)
+
+// StorageRoot is a directory holding converted videos. Videos older than
+// MaxAgeDays or exceeding MaxSize are moved to the next root, the last root
+// deletes them instead.
+type StorageRoot struct {
+	Path       string `yaml:"path"`
+	MaxSize    int    `yaml:"maxSize"`    // bytes, 0 means unlimited
+	MaxAgeDays int    `yaml:"maxAgeDays"` // 0 means keep until MaxSize is reached
+}
+
+// Storage splits raw chunks from converted videos so chunks can live on a
+// fast disk and videos on one or more large ones.
+type Storage struct {
+	ChunkRoot  string
+	VideoRoots []StorageRoot
+}
+
+func NewStorage(config Config) Storage {
+	return Storage{ChunkRoot: config.ChunkRoot, VideoRoots: config.VideoRoots}
+}
+
+// ParseStorageRoots reads roots in the form "path:maxSize:maxAgeDays" separated
+// by commas, size and age are optional.
+func ParseStorageRoots(value string) ([]StorageRoot, error) {
+	var roots []StorageRoot
+	for _, rootValue := range strings.Split(value, ",") {
+		rootValue = strings.TrimSpace(rootValue)
+		if rootValue == "" {
+			continue
+		}
+		parts := strings.Split(rootValue, ":")
+		if len(parts) > 3 {
+			return nil, fmt.Errorf("invalid storage root %q, expected path:maxSize:maxAgeDays", rootValue)
+		}
+		root := StorageRoot{Path: parts[0]}
+		if len(parts) > 1 && parts[1] != "" {
+			size, err := strconv.Atoi(parts[1])
+			if err != nil {
+				return nil, fmt.Errorf("invalid size of storage root %q: %w", rootValue, err)
+			}
+			root.MaxSize = size
+		}
+		if len(parts) > 2 && parts[2] != "" {
+			days, err := strconv.Atoi(parts[2])
+			if err != nil {
+				return nil, fmt.Errorf("invalid age of storage root %q: %w", rootValue, err)
+			}
+			root.MaxAgeDays = days
+		}
+		roots = append(roots, root)
+	}
+	return roots, nil
+}
+
+func (s Storage) VideoPaths() []string {
+	paths := make([]string, len(s.VideoRoots))
+	for i, root := range s.VideoRoots {
+		paths[i] = root.Path
+	}
+	return paths
+}
+
+// Converted videos are always written to the first root
+func (s Storage) NewVideoPath() string {
+	if len(s.VideoRoots) == 0 {
+		return s.ChunkRoot
+	}
+	return s.VideoRoots[0].Path
+}
+
+func (s Storage) CreateRoots() {
+	for _, path := range append([]string{s.ChunkRoot}, s.VideoPaths()...) {
+		if err := os.MkdirAll(path, 0755); err != nil {
+			panic(fmt.Sprintf("Cannot create directory: %v", err))
+		}
+	}
+}
+
+func (s Storage) MoveOldVideos(now time.Time) {
+	year, month, day := now.Date()
+	today := time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+	for i, root := range s.VideoRoots {
+		if i == len(s.VideoRoots)-1 || root.MaxAgeDays == 0 {
+			continue
+		}
+		olderThan := today.AddDate(0, 0, -root.MaxAgeDays)
+		files, err := os.ReadDir(root.Path)
+		if err != nil {
+			log.Printf("Can not read storage root %s: %v", root.Path, err)
+			continue
+		}
+		for _, file := range files {
+			date, _, ok := video.ParseVideoName(file.Name())
+			if file.IsDir() || !ok || !date.Before(olderThan) {
+				continue
+			}
+			nextPath := s.VideoRoots[i+1].Path
+			log.Printf("Moving %s from %s to %s", file.Name(), root.Path, nextPath)
+			if _, err := video.MoveVideo(filepath.Join(root.Path, file.Name()), nextPath); err != nil {
+				log.Printf("Can not move video %s: %v", file.Name(), err)
+			}
+		}
+	}
+}
+
+// EnforceCapacity moves the oldest videos down to the next root until each
+// root fits its limit, the last root removes them.
+func (s Storage) EnforceCapacity(skipDates []string, saveChunkSize int) {
+	extensions := []string{".mp4"}
+	for i, root := range s.VideoRoots {
+		if root.MaxSize == 0 {
+			continue
+		}
+		if i == len(s.VideoRoots)-1 {
+			RemoveOldestVideoFiles(root.Path, skipDates, root.MaxSize, saveChunkSize)
+			continue
+		}
+		for IsCloseToVideoSize(root.Path, extensions, root.MaxSize, saveChunkSize) {
+			oldest := GetOldestVideo(root.Path, extensions, []string{})
+			if oldest == "" {
+				break
+			}
+			if _, err := video.MoveVideo(filepath.Join(root.Path, oldest), s.VideoRoots[i+1].Path); err != nil {
+				log.Printf("Can not move video %s: %v", oldest, err)
+				break
+			}
+		}
+	}
+}
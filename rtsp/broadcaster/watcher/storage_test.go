@@ -0,0 +1,58 @@
+package watcher
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseStorageRoots(t *testing.T) {
+	t.Run("roots with limits", func(t *testing.T) {
+		roots, err := ParseStorageRoots("./fast:100:7, /mnt/big:2000, /mnt/cold")
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected := []StorageRoot{
+			{Path: "./fast", MaxSize: 100, MaxAgeDays: 7},
+			{Path: "/mnt/big", MaxSize: 2000},
+			{Path: "/mnt/cold"},
+		}
+		if !reflect.DeepEqual(roots, expected) {
+			t.Errorf("Expected roots %v, got %v", expected, roots)
+		}
+	})
+	t.Run("invalid size", func(t *testing.T) {
+		if _, err := ParseStorageRoots("./fast:big"); err == nil {
+			t.Error("Expected an error for invalid size")
+		}
+	})
+}
+
+func TestMoveOldVideos(t *testing.T) {
+	fast := t.TempDir()
+	big := t.TempDir()
+	for _, name := range []string{"2025-01-01-1.mp4", "2025-01-01-1.json", "2025-01-10-1.mp4"} {
+		if err := os.WriteFile(filepath.Join(fast, name), make([]byte, 10), 0644); err != nil {
+			t.Fatalf("Failed to create test file: %v", err)
+		}
+	}
+	storage := Storage{ChunkRoot: fast, VideoRoots: []StorageRoot{
+		{Path: fast, MaxAgeDays: 3},
+		{Path: big},
+	}}
+	storage.MoveOldVideos(time.Date(2025, 1, 10, 12, 0, 0, 0, time.UTC))
+
+	for _, name := range []string{"2025-01-01-1.mp4", "2025-01-01-1.json"} {
+		if _, err := os.Stat(filepath.Join(big, name)); err != nil {
+			t.Errorf("Expected %s to be moved: %v", name, err)
+		}
+		if _, err := os.Stat(filepath.Join(fast, name)); err == nil {
+			t.Errorf("Expected %s to be removed from the fast root", name)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(fast, "2025-01-10-1.mp4")); err != nil {
+		t.Errorf("Expected recent video to stay: %v", err)
+	}
+}
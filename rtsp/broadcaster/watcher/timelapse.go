@@ -0,0 +1,215 @@
+package watcher
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"strzcam.com/broadcaster/events"
+	"strzcam.com/broadcaster/video"
+)
+
+// days before today checked for a missing timelapse, older ones are left to retention
+const timelapseLookbackDays = 7
+
+// time around a detection sampled with the detection speed-up
+const timelapseDetectionPadding = 5 * time.Second
+
+// Timelapser builds a daily summary of each finished day once all its
+// chunks were converted. The timelapse is stored as a regular video so
+// tiering and retention handle it like recordings.
+type Timelapser struct {
+	Storage Storage
+	Config  Config
+	mux     sync.Mutex
+	pending atomic.Pointer[Config] // set by Reconfigure
+}
+
+func NewTimelapser(storage Storage, config Config) *Timelapser {
+	return &Timelapser{Storage: storage, Config: config}
+}
+
+func (t *Timelapser) IsEnabled() bool {
+	return t.Config.TimelapseSpeedup > 0
+}
+
+// GetPendingDays returns finished days without a timelapse, oldest first
+func (t *Timelapser) GetPendingDays(now time.Time) ([]time.Time, error) {
+	year, month, day := now.Date()
+	today := time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+	from := today.AddDate(0, 0, -timelapseLookbackDays)
+	videos, err := video.GetVideoByDateRange(t.Storage.VideoPaths(), from, today.AddDate(0, 0, -1))
+	if err != nil {
+		return nil, err
+	}
+	hasTimelapse := map[time.Time]bool{}
+	for _, v := range videos {
+		if v.Type == video.TypeTimelapse {
+			date, _, _ := video.ParseVideoName(v.Name)
+			hasTimelapse[date] = true
+		}
+	}
+	var days []time.Time
+	for _, v := range videos {
+		date, _, _ := video.ParseVideoName(v.Name)
+		if hasTimelapse[date] || slices.Contains(days, date) {
+			continue
+		}
+		// wait until the converter is done with the day
+		chunks, _ := GetChunkNames(filepath.Join(t.Storage.ChunkRoot, date.Format("2006-01-02")), []string{})
+		if len(chunks) > 0 {
+			continue
+		}
+		days = append(days, date)
+	}
+	sort.Slice(days, func(i, j int) bool { return days[i].Before(days[j]) })
+	return days, nil
+}
+
+// getSources returns recordings of the day of this camera in recording order
+func (t *Timelapser) getSources(day time.Time) ([]video.ClipSource, error) {
+	videos, err := video.GetVideoByDateRange(t.Storage.VideoPaths(), day, day)
+	if err != nil {
+		return nil, err
+	}
+	var sources []video.ClipSource
+	for _, v := range videos {
+		if !video.IsFootage(v.Type) {
+			continue
+		}
+		path, err := video.FindVideo(t.Storage.VideoPaths(), v.Name)
+		if err != nil {
+			continue
+		}
+		metadata, err := video.ReadVideoMetadata(path)
+		if err != nil {
+			log.Printf("Skipping %s from timelapse: %v", path, err)
+			continue
+		}
+		if metadata.Camera != "" && metadata.Camera != t.Config.Camera {
+			continue
+		}
+		sources = append(sources, video.ClipSource{Path: path, Metadata: metadata})
+	}
+	// listing is newest first
+	slices.Reverse(sources)
+	sort.SliceStable(sources, func(i, j int) bool {
+		return sources[i].Metadata.Start.Before(sources[j].Metadata.Start)
+	})
+	return sources, nil
+}
+
+// GetDetectionRanges merges detections of the camera into padded ranges
+func GetDetectionRanges(eventsDir string, camera string, start time.Time, end time.Time) ([]video.TimeRange, error) {
+	detections, err := events.Read(eventsDir, camera, start, end)
+	if err != nil {
+		return nil, err
+	}
+	var ranges []video.TimeRange
+	for _, detection := range detections {
+		if detection.Type != events.TypeDetection {
+			continue
+		}
+		from := detection.Time.Add(-timelapseDetectionPadding)
+		to := detection.Time.Add(timelapseDetectionPadding)
+		if len(ranges) > 0 && !from.After(ranges[len(ranges)-1].End) {
+			ranges[len(ranges)-1].End = to
+			continue
+		}
+		ranges = append(ranges, video.TimeRange{Start: from, End: to})
+	}
+	return ranges, nil
+}
+
+func (t *Timelapser) CreateTimelapse(ctx context.Context, day time.Time) error {
+	sources, err := t.getSources(day)
+	if err != nil {
+		return err
+	}
+	if len(sources) == 0 {
+		return fmt.Errorf("no recordings of %s", day.Format("2006-01-02"))
+	}
+	start, end := sources[0].Metadata.Start, sources[len(sources)-1].Metadata.End
+	var detections []video.TimeRange
+	if t.Config.TimelapseDetectionSpeedup > 0 && !start.IsZero() {
+		detections, err = GetDetectionRanges(t.Config.EventsDir, t.Config.Camera, start, end)
+		if err != nil {
+			log.Printf("Timelapse of %s without detections: %v", day.Format("2006-01-02"), err)
+		}
+	}
+	outputPath := filepath.Join(t.Storage.NewVideoPath(), video.TimelapseName(day))
+	tmpPath := outputPath + ".tmp"
+	metadata, err := video.BuildTimelapse(ctx, sources, detections, video.TimelapseOptions{
+		Speedup:          t.Config.TimelapseSpeedup,
+		DetectionSpeedup: t.Config.TimelapseDetectionSpeedup,
+	}, tmpPath)
+	if err != nil {
+		return err
+	}
+	metadata.Camera = t.Config.Camera
+	metadata.Start = start
+	metadata.End = end
+	if err := video.SetChecksums(tmpPath, &metadata); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to compute checksums: %w", err)
+	}
+	// metadata goes first so the timelapse is never read with defaults
+	if err := video.SaveVideoMetadata(outputPath, metadata); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	return os.Rename(tmpPath, outputPath)
+}
+
+// Reconfigure takes effect from the next run
+func (t *Timelapser) Reconfigure(config Config) {
+	t.pending.Store(&config)
+}
+
+func (t *Timelapser) RunUntilComplete(ctx context.Context) {
+	t.mux.Lock()
+	defer t.mux.Unlock()
+	if config := t.pending.Swap(nil); config != nil {
+		t.Config = *config
+		t.Storage = NewStorage(*config)
+	}
+	if !t.IsEnabled() {
+		return
+	}
+	days, err := t.GetPendingDays(time.Now())
+	if err != nil {
+		log.Printf("Can not list days for timelapse: %v", err)
+		return
+	}
+	for _, day := range days {
+		if ctx.Err() != nil {
+			return
+		}
+		log.Printf("Creating timelapse of %s", day.Format("2006-01-02"))
+		if err := t.CreateTimelapse(ctx, day); err != nil {
+			log.Printf("Timelapse of %s failed: %v", day.Format("2006-01-02"), err)
+		}
+	}
+}
+
+// Watch runs every interval, also when disabled so Reconfigure can enable it
+func (t *Timelapser) Watch(ctx context.Context, interval time.Duration) {
+	t.RunUntilComplete(ctx)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			t.RunUntilComplete(ctx)
+		}
+	}
+}
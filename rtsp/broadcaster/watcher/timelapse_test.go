@@ -0,0 +1,39 @@
+package watcher
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestGetPendingDays(t *testing.T) {
+	chunkRoot, videoRoot := t.TempDir(), t.TempDir()
+	for _, name := range []string{
+		"2025-01-01-1.mp4", "2025-01-01-timelapse.mp4",
+		"2025-01-07-1.mp4", "2025-01-07-2.mp4",
+		"2025-01-08-1.mp4",
+		"2025-01-09-1.mp4",
+		"2025-01-10-1.mp4",
+	} {
+		if err := os.WriteFile(filepath.Join(videoRoot, name), make([]byte, 10), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// 2025-01-09 still has frames to convert
+	os.MkdirAll(filepath.Join(chunkRoot, "2025-01-09", "3"), 0755)
+	os.MkdirAll(filepath.Join(chunkRoot, "2025-01-08"), 0755)
+	timelapser := NewTimelapser(Storage{ChunkRoot: chunkRoot, VideoRoots: []StorageRoot{{Path: videoRoot}}}, Config{TimelapseSpeedup: 60})
+	days, err := timelapser.GetPendingDays(time.Date(2025, 1, 10, 12, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []time.Time{
+		time.Date(2025, 1, 7, 0, 0, 0, 0, time.UTC),
+		time.Date(2025, 1, 8, 0, 0, 0, 0, time.UTC),
+	}
+	if !reflect.DeepEqual(days, expected) {
+		t.Errorf("Expected pending days %v, got %v", expected, days)
+	}
+}
@@ -1,9 +1,15 @@
 package watcher
 
-import "time"
+import (
+	"context"
+	"sync"
+	"time"
+)
 
 type VideoCreator struct {
 	Converter            *Converter
+	Archiver             *Archiver
+	Timelapser           *Timelapser
 	SharedMemoryReceiver *SharedMemoryReceiver
 }
 
@@ -11,32 +17,73 @@ func NewVideoCreator(
 	sharedMemoryReceiver *SharedMemoryReceiver,
 	converter *Converter,
 ) (*VideoCreator, error) {
+	archiver := NewArchiver(converter.Storage.VideoPaths(), converter.Config)
+	archiver.SigningKey = converter.SigningKey
 	return &VideoCreator{
 		Converter:            converter,
+		Archiver:             archiver,
+		Timelapser:           NewTimelapser(converter.Storage, converter.Config),
 		SharedMemoryReceiver: sharedMemoryReceiver,
 	}, nil
 }
-func (v *VideoCreator) StartWatchingFrames() {
-	v.SharedMemoryReceiver.WatchSharedMemory(true)
+
+// Run watches frames and converts them until ctx is done, it returns once the
+// pending frames are saved and the running conversions stopped
+func (v *VideoCreator) Run(ctx context.Context, actualFps *float64, width *uint32, height *uint32) {
+	var wg sync.WaitGroup
+	wg.Add(3)
+	go func() {
+		defer wg.Done()
+		v.StartWatchingFrames(ctx)
+	}()
+	go func() {
+		defer wg.Done()
+		v.SaveFramesForLater()
+	}()
+	go func() {
+		defer wg.Done()
+		v.StartConversionWorkflow(ctx, actualFps, width, height)
+	}()
+	wg.Wait()
+}
+func (v *VideoCreator) StartWatchingFrames(ctx context.Context) {
+	v.SharedMemoryReceiver.WatchSharedMemory(ctx, true)
 }
 func (v *VideoCreator) SaveFramesForLater() {
 	v.SharedMemoryReceiver.SaveFrameForLater()
 }
-func (v *VideoCreator) StartConversionWorkflow(actualFps *float64, width *uint32, height *uint32) {
+func (v *VideoCreator) StartConversionWorkflow(ctx context.Context, actualFps *float64, width *uint32, height *uint32) {
 	v.Converter.Framerate = actualFps
 	v.Converter.Width = width
 	v.Converter.Height = height
-	v.Converter.RunUntilComplete()
+	v.Converter.RunUntilComplete(ctx)
+	var wg sync.WaitGroup
+	wg.Add(3)
+	go func() {
+		defer wg.Done()
+		v.Archiver.Watch(ctx, time.Hour)
+	}()
+	go func() {
+		defer wg.Done()
+		v.Timelapser.Watch(ctx, time.Hour)
+	}()
 	go func() {
+		defer wg.Done()
 		ticker := time.NewTicker(10 * time.Minute)
 		defer ticker.Stop()
-		for range ticker.C {
-			if !v.Converter.hasJob {
-				v.Converter.RunUntilComplete()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if !v.Converter.hasJob {
+					v.Converter.RunUntilComplete(ctx)
+				}
 			}
 		}
 	}()
-	v.Converter.Watch()
+	v.Converter.Watch(ctx)
+	wg.Wait()
 }
 
 func (v *VideoCreator) Close() {
@@ -0,0 +1,217 @@
+package web_rtc
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/pion/webrtc/v3"
+	"strzcam.com/broadcaster/encryption"
+	"strzcam.com/broadcaster/video"
+)
+
+const (
+	exportChunkSize      = 16 * 1024
+	exportBufferedHigh   = 4 * 1024 * 1024
+	exportBufferedLow    = 1024 * 1024
+	exportProgressPeriod = time.Second
+)
+
+func sendExportStatus(dataChannel *webrtc.DataChannel, status ExportStatusMessage) {
+	status.Type = "exportStatus"
+	if message, err := json.Marshal(status); err == nil {
+		dataChannel.Send(message)
+	}
+}
+
+func exportRequest(dataChannel *webrtc.DataChannel, message DataChannelMessage) (string, video.ClipRequest, bool) {
+	id := message.ExportId
+	if id == "" {
+		id = fmt.Sprintf("%d", time.Now().UnixNano())
+	}
+	start, err := time.Parse(time.RFC3339, message.StartTime)
+	if err != nil {
+		sendExportStatus(dataChannel, ExportStatusMessage{ExportId: id, Error: "invalid start time"})
+		return id, video.ClipRequest{}, false
+	}
+	end, err := time.Parse(time.RFC3339, message.EndTime)
+	if err != nil {
+		sendExportStatus(dataChannel, ExportStatusMessage{ExportId: id, Error: "invalid end time"})
+		return id, video.ClipRequest{}, false
+	}
+	return id, video.ClipRequest{Camera: message.Camera, Start: start, End: end}, true
+}
+
+// exportProgress sends progress at most once per exportProgressPeriod
+func exportProgress(dataChannel *webrtc.DataChannel, id string) func(float64) {
+	lastProgress := time.Time{}
+	return func(progress float64) {
+		if time.Since(lastProgress) < exportProgressPeriod {
+			return
+		}
+		lastProgress = time.Now()
+		sendExportStatus(dataChannel, ExportStatusMessage{ExportId: id, Progress: progress})
+	}
+}
+
+// exportClip answers the exportClip command. The control channel is
+// unreliable, so the file goes through its own ordered, reliable channel
+// announced in the final status message.
+func (o *Offeror) exportClip(dataChannel *webrtc.DataChannel, message DataChannelMessage) {
+	id, request, ok := exportRequest(dataChannel, message)
+	if !ok {
+		return
+	}
+	path, err := video.ExportClipFile(o.ctx, o.savedVideoPaths, request, exportProgress(dataChannel, id))
+	if err != nil {
+		log.Printf("Clip export failed: %v", err)
+		sendExportStatus(dataChannel, ExportStatusMessage{ExportId: id, Error: err.Error()})
+		return
+	}
+	o.sendExportFile(dataChannel, id, path, true)
+}
+
+// exportEvidence answers the exportEvidence command like exportClip. The
+// bundle is written to a temporary file first, its size is announced
+// before the transfer.
+func (o *Offeror) exportEvidence(dataChannel *webrtc.DataChannel, message DataChannelMessage) {
+	id, request, ok := exportRequest(dataChannel, message)
+	if !ok {
+		return
+	}
+	path, err := writeEvidenceFile(o.ctx, o.savedVideoPaths, o.eventsDir, request, exportProgress(dataChannel, id))
+	if err != nil {
+		log.Printf("Evidence export failed: %v", err)
+		sendExportStatus(dataChannel, ExportStatusMessage{ExportId: id, Error: err.Error()})
+		return
+	}
+	o.sendExportFile(dataChannel, id, path, true)
+}
+
+func writeEvidenceFile(ctx context.Context, paths []string, eventsDir string, request video.ClipRequest, progress func(float64)) (string, error) {
+	evidence, err := video.PrepareEvidence(ctx, paths, eventsDir, request, progress)
+	if err != nil {
+		return "", err
+	}
+	defer evidence.Close()
+	// encrypted like the clip of ExportClipFile, sendExportFile reads both
+	file, path, err := encryption.CreateTemp("evidence-*.zip", encryption.Default())
+	if err != nil {
+		return "", err
+	}
+	_, err = evidence.WriteTo(file)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(path)
+		return "", err
+	}
+	return path, nil
+}
+
+// sendExportFile announces the file and streams it over a new data channel.
+// The file is closed and removed once sent, or when the channel closes or
+// fails, also when it never opened.
+func (o *Offeror) sendExportFile(dataChannel *webrtc.DataChannel, id string, path string, removeAfter bool) {
+	file, err := video.OpenVideo(path)
+	if err != nil {
+		sendExportStatus(dataChannel, ExportStatusMessage{ExportId: id, Error: err.Error()})
+		return
+	}
+	cleanup := func() {
+		file.Close()
+		if removeAfter {
+			os.Remove(path)
+		}
+	}
+	label := fmt.Sprintf("export-%s", id)
+	fileChannel, err := o.pc.CreateDataChannel(label, nil)
+	if err != nil {
+		cleanup()
+		sendExportStatus(dataChannel, ExportStatusMessage{ExportId: id, Error: err.Error()})
+		return
+	}
+
+	opened := make(chan struct{})
+	done := make(chan struct{})
+	var closeDone sync.Once
+	stop := func() {
+		closeDone.Do(func() { close(done) })
+	}
+	canSend := make(chan struct{}, 1)
+	fileChannel.SetBufferedAmountLowThreshold(exportBufferedLow)
+	fileChannel.OnBufferedAmountLow(func() {
+		select {
+		case canSend <- struct{}{}:
+		default:
+		}
+	})
+	fileChannel.OnOpen(func() {
+		close(opened)
+	})
+	fileChannel.OnClose(stop)
+	fileChannel.OnError(func(err error) {
+		log.Printf("Export %s channel failed: %v", id, err)
+		stop()
+	})
+	sendExportStatus(dataChannel, ExportStatusMessage{ExportId: id, Progress: 1, Channel: label, Size: file.Size()})
+
+	go func() {
+		defer cleanup()
+		// wait returns false when the transfer has to stop
+		wait := func(ready <-chan struct{}) bool {
+			select {
+			case <-ready:
+				return true
+			case <-done:
+				log.Printf("Export %s interrupted, channel closed", id)
+				return false
+			case <-o.ctx.Done():
+				fileChannel.Close()
+				return false
+			}
+		}
+		if !wait(opened) {
+			return
+		}
+		buffer := make([]byte, exportChunkSize)
+		for {
+			n, err := file.Read(buffer)
+			if n > 0 {
+				if sendErr := fileChannel.Send(buffer[:n]); sendErr != nil {
+					log.Printf("Export %s interrupted: %v", id, sendErr)
+					return
+				}
+				if fileChannel.BufferedAmount() > exportBufferedHigh && !wait(canSend) {
+					return
+				}
+			}
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				log.Printf("Export %s read failed: %v", id, err)
+				fileChannel.Close()
+				return
+			}
+		}
+		// closing may drop buffered data, wait until it is sent
+		for fileChannel.BufferedAmount() > 0 {
+			select {
+			case <-time.After(50 * time.Millisecond):
+			case <-done:
+				return
+			case <-o.ctx.Done():
+				fileChannel.Close()
+				return
+			}
+		}
+		fileChannel.Close()
+	}()
+}
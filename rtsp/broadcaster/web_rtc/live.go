@@ -1,25 +1,28 @@
 package web_rtc
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"log"
-	"os"
+	"sync"
+	"time"
 
 	_ "image/jpeg"
 	_ "image/png"
 
 	"github.com/gorilla/websocket"
-	"github.com/joho/godotenv"
 	"github.com/pion/webrtc/v3"
+	"strzcam.com/broadcaster/watchdog"
 	"strzcam.com/broadcaster/watcher"
 )
 
-func listen(wsClient *websocket.Conn, videoTrack *VideoTrack, savePath string) {
-	offeror, _ := NewOfferor(wsClient, savePath)
+func listen(ctx context.Context, wsClient *websocket.Conn, videoTrack *VideoTrack, settings OfferorSettings) {
+	offeror, _ := NewOfferor(ctx, wsClient, settings)
 	defer offeror.Close()
-	offeror.CreatePeerConnection(videoTrack)
-	offeror.CreateAndSendOffer()
+	if _, err := offeror.CreatePeerConnection(videoTrack); err == nil {
+		offeror.CreateAndSendOffer()
+	}
 	for {
 		_, message, err := wsClient.ReadMessage()
 		if err != nil {
@@ -69,39 +72,72 @@ func listen(wsClient *websocket.Conn, videoTrack *VideoTrack, savePath string) {
 		case "start":
 			log.Printf("Starting...")
 			defer offeror.Close()
-			offeror.CreatePeerConnection(videoTrack)
-			offeror.CreateAndSendOffer()
+			if _, err := offeror.CreatePeerConnection(videoTrack); err == nil {
+				offeror.CreateAndSendOffer()
+			}
 		}
 	}
 }
 
-func RunLive(signalingUrl string) {
-	if err := godotenv.Load(); err != nil {
-		log.Printf("Warning: Error loading .env file: %v", err)
-	}
-	videoFrame := os.Getenv("VIDEO_FRAME")
-	isLiveStream := os.Getenv("WEBRTC_STREAM_LIVE")
-	wsClient, _, err := websocket.DefaultDialer.Dial(signalingUrl, nil)
+// RunLive offers recordings, and the camera when config.WebRTCLive is set,
+// to viewers coming through the signaling server until ctx is done
+func RunLive(ctx context.Context, config watcher.Config) error {
+	signalingUrl := fmt.Sprintf("ws://%s/ws?userId=99", config.SignalingURL)
+	wsClient, _, err := websocket.DefaultDialer.DialContext(ctx, signalingUrl, nil)
 	if err != nil {
-		panic(err)
+		return fmt.Errorf("can not connect to signaling server: %w", err)
 	}
 	defer wsClient.Close()
-	savePath := fmt.Sprintf("%s_%s", watcher.SavePath, videoFrame)
+	storage := watcher.NewStorage(config)
 	var videoTrack *VideoTrack = nil
-	if isLiveStream == "true" {
-		memory, err := watcher.NewSharedMemoryReceiver(videoFrame)
+	var cameraWatchdog *watchdog.Watchdog
+	// the camera stops before the track and memory it reads are closed
+	var camera sync.WaitGroup
+	if config.WebRTCLive {
+		memory, err := watcher.NewSharedMemoryReceiver(config)
 		if err != nil {
-			panic(fmt.Sprintf("Error creating shared memory receiver: %v", err))
+			return fmt.Errorf("can not create shared memory receiver: %w", err)
 		}
-		go memory.WatchSharedMemory(false)
+		defer memory.Close()
+		cameraWatchdog = memory.Watchdog
 		videoTrack, err = NewVideoTrack()
 		if err != nil {
-			panic(err)
+			return err
 		}
 		defer videoTrack.Close()
-		go videoTrack.Start(memory)
+		defer camera.Wait()
+		camera.Add(2)
+		go func() {
+			defer camera.Done()
+			memory.WatchSharedMemory(ctx, false)
+		}()
+		go func() {
+			defer camera.Done()
+			videoTrack.Start(ctx, memory)
+		}()
 	}
 
-	go listen(wsClient, videoTrack, savePath)
-	select {}
+	listening := make(chan struct{})
+	go func() {
+		defer close(listening)
+		listen(ctx, wsClient, videoTrack, OfferorSettings{
+			SavedVideoPaths: storage.VideoPaths(),
+			EventsDir:       config.EventsDir,
+			Schedule:        config.NewScheduleController(),
+			HealthStateFile: config.HealthStateFile,
+			Watchdog:        cameraWatchdog,
+			ICEServers:      config.ICEServers,
+			AuthToken:       config.AuthToken,
+		})
+	}()
+	select {
+	case <-ctx.Done():
+		closing := websocket.FormatCloseMessage(websocket.CloseGoingAway, "shutting down")
+		wsClient.WriteControl(websocket.CloseMessage, closing, time.Now().Add(time.Second))
+		wsClient.Close()
+		<-listening
+		return nil
+	case <-listening:
+		return fmt.Errorf("signaling connection closed")
+	}
 }
@@ -1,6 +1,12 @@
 package web_rtc
 
-import "strzcam.com/broadcaster/video"
+import (
+	"strzcam.com/broadcaster/health"
+	"strzcam.com/broadcaster/schedule"
+	"strzcam.com/broadcaster/search"
+	"strzcam.com/broadcaster/video"
+	"strzcam.com/broadcaster/watchdog"
+)
 
 // signaling message used by websocket
 type SignalingMessage struct {
@@ -18,6 +24,15 @@ type DataChannelMessage struct {
 	VideoName string  `json:"videoName,omitempty"`
 	Seek      float64 `json:"seek,omitempty"`
 	IsForward bool    `json:"isForward,omitempty"`
+	Camera    string  `json:"camera,omitempty"`
+	StartTime string  `json:"startTime,omitempty"`
+	EndTime   string  `json:"endTime,omitempty"`
+	ExportId  string  `json:"exportId,omitempty"`
+	Action    string  `json:"action,omitempty"`
+	Until     string  `json:"until,omitempty"`
+	Token     string  `json:"token,omitempty"` // auth token of changes
+	// search command
+	Search *search.Query `json:"search,omitempty"`
 }
 
 // data channel outgouing messages
@@ -43,3 +58,31 @@ type StatusLoadVideoMessage struct {
 	IsLoop    bool    `json:"isLoop"`
 	Duration  float64 `json:"duration,omitempty"`
 }
+type StatusModeMessage struct {
+	Type  string          `json:"type"`
+	Mode  schedule.Status `json:"mode"`
+	Error string          `json:"error,omitempty"`
+}
+type StatusHealthMessage struct {
+	Type   string        `json:"type"`
+	Health health.Status `json:"health"`
+}
+type StatusCameraMessage struct {
+	Type   string         `json:"type"`
+	Camera watchdog.State `json:"camera"`
+}
+type SearchResultsMessage struct {
+	Type    string      `json:"type"`
+	Results search.Page `json:"results"`
+	Error   string      `json:"error,omitempty"`
+}
+
+// the file is sent over Channel once Size is set
+type ExportStatusMessage struct {
+	Type     string  `json:"type"`
+	ExportId string  `json:"exportId"`
+	Progress float64 `json:"progress"`
+	Error    string  `json:"error,omitempty"`
+	Channel  string  `json:"channel,omitempty"`
+	Size     int64   `json:"size,omitempty"`
+}
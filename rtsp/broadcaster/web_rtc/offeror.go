@@ -2,45 +2,33 @@ package web_rtc
 
 import (
 	"context"
+	"crypto/subtle"
 	"encoding/json"
 	"fmt"
 	"log"
-	"path/filepath"
 	"sync"
 	"time"
 
 	"github.com/gorilla/websocket"
 	"github.com/pion/webrtc/v3"
 	"strzcam.com/broadcaster/connection"
+	"strzcam.com/broadcaster/health"
+	"strzcam.com/broadcaster/schedule"
+	"strzcam.com/broadcaster/search"
 	"strzcam.com/broadcaster/video"
+	"strzcam.com/broadcaster/watchdog"
+	"strzcam.com/broadcaster/watcher"
 )
 
-var iceServers = []webrtc.ICEServer{
-	{
-		URLs: []string{
-			"stun:stun.l.google.com:19302",
-			"stun:stun2.l.google.com:19302",
-			"stun:stun3.l.google.com:19302",
-			"stun:stun.1und1.de:3478",
-			"stun:stun.avigora.com:3478",
-			"stun:stun.avigora.fr:3478",
-		},
-	},
-	{
-		URLs:       []string{"turn:global.turn.twilio.com:3478?transport=udp"},
-		Username:   "dc2d2894d5a9023620c467b0e71cfa6a35457e6679785ed6ae9856fe5bdfa269",
-		Credential: "tE2DajzSbc123",
-	},
-	{
-		URLs:       []string{"turn:openrelay.metered.ca:80", "turn:openrelay.metered.ca:443"},
-		Username:   "openrelayproject",
-		Credential: "openrelayproject",
-	},
-	{
-		URLs:       []string{"turn:openrelay.metered.ca:443?transport=tcp"},
-		Username:   "openrelayproject",
-		Credential: "openrelayproject",
-	},
+// OfferorSettings are shared by offerors of one process
+type OfferorSettings struct {
+	SavedVideoPaths []string
+	EventsDir       string
+	Schedule        *schedule.Controller
+	HealthStateFile string
+	Watchdog        *watchdog.Watchdog // live camera state, nil without live stream
+	ICEServers      []watcher.ICEServer
+	AuthToken       string // clients send it with changes, empty refuses them
 }
 
 type Offeror struct {
@@ -49,33 +37,58 @@ type Offeror struct {
 	wsClient         *websocket.Conn
 	videoTrack       *VideoTrack
 	staticVideoTrack *StaticVideoTrack
-	savedVideoPath   string
+	savedVideoPaths  []string
+	eventsDir        string
+	schedule         *schedule.Controller
+	healthStateFile  string
+	watchdog         *watchdog.Watchdog // live camera state, nil without live stream
+	iceServers       []webrtc.ICEServer
+	authToken        string
 	trackMutex       sync.Mutex
 	IceCandidates    []*webrtc.ICECandidate
+	// exports and status updates stop with it
+	ctx context.Context
 }
 
-func NewOfferor(wsClient *websocket.Conn, savedVideoPath string) (Offeror, error) {
+func NewOfferor(ctx context.Context, wsClient *websocket.Conn, settings OfferorSettings) (Offeror, error) {
 	log.Print("New offeror")
-	return Offeror{wsClient: wsClient, savedVideoPath: savedVideoPath, staticVideoTrack: nil}, nil
+	var iceServers []webrtc.ICEServer
+	for _, server := range settings.ICEServers {
+		iceServers = append(iceServers, webrtc.ICEServer{URLs: server.URLs, Username: server.Username, Credential: server.Credential})
+	}
+	return Offeror{
+		wsClient:         wsClient,
+		savedVideoPaths:  settings.SavedVideoPaths,
+		eventsDir:        settings.EventsDir,
+		schedule:         settings.Schedule,
+		healthStateFile:  settings.HealthStateFile,
+		watchdog:         settings.Watchdog,
+		iceServers:       iceServers,
+		authToken:        settings.AuthToken,
+		staticVideoTrack: nil,
+		ctx:              ctx,
+	}, nil
 }
 
 func (o *Offeror) CreatePeerConnection(videoTrack *VideoTrack) (*webrtc.PeerConnection, error) {
-	pc, error := webrtc.NewPeerConnection(webrtc.Configuration{
-		ICEServers: iceServers,
+	pc, err := webrtc.NewPeerConnection(webrtc.Configuration{
+		ICEServers: o.iceServers,
 	})
-	o.pc = pc
-
-	if error != nil {
-		log.Fatal(error)
+	if err != nil {
+		log.Printf("Can not create peer connection: %v", err)
+		return nil, err
 	}
+	o.pc = pc
 	o.videoTrack = videoTrack
 	o.HandlePeerConnection()
-	return o.pc, error
+	return o.pc, nil
 }
 
 func (o *Offeror) Close() {
 	o.staticVideoTrack = nil
-	o.pc.Close()
+	if o.pc != nil {
+		o.pc.Close()
+	}
 }
 
 func (o *Offeror) HandlePeerConnection() {
@@ -84,7 +97,8 @@ func (o *Offeror) HandlePeerConnection() {
 	}
 	dataChannel, err := o.CreateDataChannel()
 	if err != nil {
-		log.Fatal(err)
+		log.Printf("Can not create data channel: %v", err)
+		return
 	}
 	o.dataChannel = dataChannel
 	o.pc.OnConnectionStateChange(func(state webrtc.PeerConnectionState) {
@@ -138,12 +152,9 @@ func (o *Offeror) SendIceCandidates() {
 }
 
 func (o *Offeror) SendFlushMessageToSignaling() {
-	flushMessage, err := json.Marshal(map[string]string{"type": "flush"})
-	if err != nil {
-		log.Fatal(err)
-	}
+	flushMessage, _ := json.Marshal(map[string]string{"type": "flush"})
 	if err := o.wsClient.WriteMessage(websocket.TextMessage, flushMessage); err != nil {
-		log.Fatal(err)
+		log.Printf("Can not flush the offer: %v", err)
 	}
 }
 
@@ -157,21 +168,41 @@ func (o *Offeror) CreateDataChannel() (*webrtc.DataChannel, error) {
 	if err != nil {
 		return nil, err
 	}
-	statusContext, statusCancel := context.WithCancel(context.Background())
+	// updates sent on the channel stop with it, OnClose only cancels this
+	channelContext, closeChannel := context.WithCancel(o.ctx)
+	statusContext, statusCancel := context.WithCancel(channelContext)
+	if o.watchdog != nil {
+		// subscribed before any callback runs, the context unsubscribes
+		states, stopCameraState := o.watchdog.Subscribe()
+		context.AfterFunc(channelContext, stopCameraState)
+		go func() {
+			for state := range states {
+				SendStatusCamera(dataChannel, state)
+			}
+		}()
+	}
 	dataChannel.OnOpen(func() {
 		log.Println("Data channel opened")
 		// reset offert so it can't be reused
 		o.SendFlushMessageToSignaling()
+		if o.schedule != nil {
+			SendStatusMode(dataChannel, o.schedule.Status(time.Now()), nil)
+		}
+		o.sendHealth(dataChannel)
+		if o.watchdog != nil {
+			// changes before the channel opened were not sent
+			SendStatusCamera(dataChannel, o.watchdog.State())
+		}
 	})
 	dataChannel.OnClose(func() {
 		log.Println("Data channel closed")
-		statusCancel()
+		closeChannel()
 	})
 	dataChannel.OnMessage(func(dataChannelMessage webrtc.DataChannelMessage) {
 		//fmt.Printf("Message from data channel: %s\n", string(dataChannelMessage.Data))
 		var message DataChannelMessage
 		if err := json.Unmarshal(dataChannelMessage.Data, &message); err != nil {
-			log.Fatal("Can not parse message in data channel")
+			log.Printf("Can not parse message in data channel: %v", err)
 			return
 		}
 		switch message.Type {
@@ -179,26 +210,19 @@ func (o *Offeror) CreateDataChannel() (*webrtc.DataChannel, error) {
 			// is it needed?
 			//recreate offer
 			fmt.Print("GOT close message")
-			offer, err := o.pc.CreateOffer(nil)
+			offerData, err := o.PrepareOffer()
 			if err != nil {
-				log.Fatal(err)
-			}
-
-			if err := o.pc.SetLocalDescription(offer); err != nil {
-				log.Fatal(err)
-			}
-			offerData, err := json.Marshal(offer)
-			if err != nil {
-				log.Fatal(err)
+				log.Printf("Can not recreate offer: %v", err)
+				return
 			}
 			o.SendFlushMessageToSignaling()
 			if err := o.wsClient.WriteMessage(websocket.TextMessage, offerData); err != nil {
-				log.Fatal(err)
+				log.Printf("Can not send offer: %v", err)
 			}
 		case "videoList":
 			start, _ := time.Parse("2006-01-02", message.StartDate)
 			end, _ := time.Parse("2006-01-02", message.EndDate)
-			videoList, err := video.GetVideoByDateRange(o.savedVideoPath, start, end)
+			videoList, err := video.GetVideoByDateRange(o.savedVideoPaths, start, end)
 			if err != nil {
 				log.Printf("video list error %v", err)
 				return
@@ -209,7 +233,11 @@ func (o *Offeror) CreateDataChannel() (*webrtc.DataChannel, error) {
 				dataChannel.Send(responseMessage)
 			}
 		case "video":
-			filePath := filepath.Join(o.savedVideoPath, message.VideoName)
+			filePath, err := video.FindVideo(o.savedVideoPaths, message.VideoName)
+			if err != nil {
+				log.Printf("Error finding video: %v", err)
+				return
+			}
 
 			o.trackMutex.Lock()
 			if o.staticVideoTrack == nil {
@@ -277,7 +305,7 @@ func (o *Offeror) CreateDataChannel() (*webrtc.DataChannel, error) {
 				duration,
 			)
 			SendStatusIsPlaying(dataChannel, o.staticVideoTrack.playing)
-			statusContext, statusCancel = context.WithCancel(context.Background())
+			statusContext, statusCancel = context.WithCancel(channelContext)
 			go updateStatus(statusContext, dataChannel, o.staticVideoTrack)
 		case "answer":
 			answer := webrtc.SessionDescription{
@@ -296,7 +324,7 @@ func (o *Offeror) CreateDataChannel() (*webrtc.DataChannel, error) {
 			o.trackMutex.Unlock()
 			SendStatus(dataChannel, o.staticVideoTrack.currentPos.Seconds())
 			SendStatusIsPlaying(dataChannel, o.staticVideoTrack.playing)
-			log.Printf("Seeked requested %f=%f => %f", message.Seek, time.Duration(message.Seek), o.staticVideoTrack.currentPos.Seconds())
+			log.Printf("Seeked requested %f => %f", message.Seek, o.staticVideoTrack.currentPos.Seconds())
 		case "pause":
 			log.Printf("handle pause")
 			o.trackMutex.Lock()
@@ -314,7 +342,7 @@ func (o *Offeror) CreateDataChannel() (*webrtc.DataChannel, error) {
 			o.trackMutex.Lock()
 			o.staticVideoTrack.Play()
 			o.trackMutex.Unlock()
-			statusContext, statusCancel = context.WithCancel(context.Background())
+			statusContext, statusCancel = context.WithCancel(channelContext)
 			SendStatusLoadVideo(
 				dataChannel,
 				o.staticVideoTrack.playing,
@@ -349,16 +377,104 @@ func (o *Offeror) CreateDataChannel() (*webrtc.DataChannel, error) {
 			o.staticVideoTrack.isLoop = !o.staticVideoTrack.isLoop
 			o.trackMutex.Unlock()
 			SendStatusLoop(dataChannel, o.staticVideoTrack.isLoop)
+		case "exportClip":
+			go o.exportClip(dataChannel, message)
+		case "exportEvidence":
+			go o.exportEvidence(dataChannel, message)
+		case "mode":
+			o.handleMode(dataChannel, message)
+		case "health":
+			o.sendHealth(dataChannel)
+		case "search":
+			go o.search(dataChannel, message)
+		case "camera":
+			if o.watchdog != nil {
+				SendStatusCamera(dataChannel, o.watchdog.State())
+			}
 		}
 
 	})
 	return dataChannel, nil
 }
 
+// sendHealth reports image health saved by the provider, if it has any
+func (o *Offeror) sendHealth(dataChannel *webrtc.DataChannel) {
+	if o.healthStateFile == "" {
+		return
+	}
+	status, err := health.LoadStatus(o.healthStateFile, time.Now())
+	if err != nil {
+		log.Printf("No health status: %v", err)
+		return
+	}
+	SendStatusHealth(dataChannel, status)
+}
+
+// search answers with a page of detections, errors are sent with the page
+func (o *Offeror) search(dataChannel *webrtc.DataChannel, message DataChannelMessage) {
+	results := SearchResultsMessage{Type: "searchResults"}
+	var err error
+	if message.Search == nil {
+		err = fmt.Errorf("search query is missing")
+	} else {
+		results.Results, err = search.Search(o.eventsDir, o.savedVideoPaths, *message.Search)
+	}
+	if err != nil {
+		results.Error = err.Error()
+	}
+	if data, err := json.Marshal(results); err == nil {
+		dataChannel.Send(data)
+	}
+}
+
+// authorized checks the token of a message changing the provider, without a
+// configured token every change is refused
+func (o *Offeror) authorized(message DataChannelMessage) bool {
+	if o.authToken == "" {
+		log.Printf("Refused %s, auth.token is not set", message.Type)
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(message.Token), []byte(o.authToken)) == 1
+}
+
+// handleMode reports the mode, with an action and the auth token it
+// overrides the schedule first
+func (o *Offeror) handleMode(dataChannel *webrtc.DataChannel, message DataChannelMessage) {
+	if o.schedule == nil {
+		SendStatusMode(dataChannel, schedule.Status{}, fmt.Errorf("schedules are not enabled"))
+		return
+	}
+	now := time.Now()
+	if message.Action == "" {
+		SendStatusMode(dataChannel, o.schedule.Status(now), nil)
+		return
+	}
+	if !o.authorized(message) {
+		SendStatusMode(dataChannel, o.schedule.Status(now), fmt.Errorf("unauthorized"))
+		return
+	}
+	override := schedule.Override{Action: message.Action}
+	if message.Until != "" {
+		until, err := time.Parse(time.RFC3339, message.Until)
+		if err != nil {
+			SendStatusMode(dataChannel, o.schedule.Status(now), fmt.Errorf("invalid until time"))
+			return
+		}
+		override.Until = until
+	}
+	status, err := o.schedule.Apply(override, now)
+	if err != nil {
+		SendStatusMode(dataChannel, o.schedule.Status(now), err)
+		return
+	}
+	log.Printf("Mode changed to %s by %s", status.Mode, override.Action)
+	SendStatusMode(dataChannel, status, nil)
+}
+
 func (o *Offeror) HandleVideoTrack() error {
 	rtpSender, err := o.pc.AddTrack(o.videoTrack.track)
 	if err != nil {
-		log.Fatal(err)
+		log.Printf("Can not add live track: %v", err)
 		return err
 	}
 	o.startRTCPReader(rtpSender)
@@ -378,27 +494,20 @@ func (o *Offeror) startRTCPReader(rtpSender *webrtc.RTPSender) {
 func (o *Offeror) PrepareOffer() ([]byte, error) {
 	offer, err := o.pc.CreateOffer(nil)
 	if err != nil {
-		log.Fatal(err)
+		return nil, err
 	}
-
 	if err := o.pc.SetLocalDescription(offer); err != nil {
-		log.Fatal(err)
-	}
-	offerData, err := json.Marshal(offer)
-	if err != nil {
-		log.Fatal(err)
 		return nil, err
 	}
-	return offerData, nil
+	return json.Marshal(offer)
 }
 func (o *Offeror) CreateAndSendOffer() {
 	offerData, err := o.PrepareOffer()
 	if err != nil {
-		log.Fatal(err)
+		log.Printf("Can not prepare offer: %v", err)
 		return
 	}
-
 	if err := o.wsClient.WriteMessage(websocket.TextMessage, offerData); err != nil {
-		log.Fatal(err)
+		log.Printf("Can not send offer: %v", err)
 	}
 }
@@ -1,11 +1,14 @@
 package web_rtc
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
 	"strconv"
 	"sync"
+	"time"
 
 	"github.com/gorilla/websocket"
 )
@@ -22,7 +25,9 @@ type connInfo struct {
 	offerFrom int
 }
 
-func RunServer(port int) {
+// RunServer relays signaling messages until ctx is done, then clients are told
+// the server is going away
+func RunServer(ctx context.Context, port int) error {
 	clients = make(map[int]*connInfo)
 	upgrader = websocket.Upgrader{
 		CheckOrigin: func(r *http.Request) bool {
@@ -34,9 +39,14 @@ func RunServer(port int) {
 			log.Printf("Client %d: offer from: %d", clientId, client.offerFrom)
 		}
 	}()
-	http.HandleFunc("/ws", func(w http.ResponseWriter, r *http.Request) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/ws", func(w http.ResponseWriter, r *http.Request) {
 		userId, _ := strconv.Atoi(r.URL.Query().Get("userId"))
-		conn, _ := upgrader.Upgrade(w, r, nil)
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			log.Printf("Upgrade error: %v", err)
+			return
+		}
 		client := &connInfo{
 			conn:      conn,
 			ice:       []SignalingMessage{},
@@ -140,5 +150,34 @@ func RunServer(port int) {
 			clientsMux.RUnlock()
 		}
 	})
-	log.Fatal(http.ListenAndServe(fmt.Sprintf(":%d", port), nil))
+	server := &http.Server{Addr: fmt.Sprintf(":%d", port), Handler: mux}
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		<-ctx.Done()
+		closeClients()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := server.Shutdown(shutdownCtx); err != nil {
+			server.Close()
+		}
+	}()
+	err := server.ListenAndServe()
+	if errors.Is(err, http.ErrServerClosed) {
+		<-stopped
+		return nil
+	}
+	return err
+}
+
+// closeClients sends a close frame to every client, their handlers return once
+// the connection is closed
+func closeClients() {
+	closing := websocket.FormatCloseMessage(websocket.CloseGoingAway, "server shutting down")
+	clientsMux.RLock()
+	defer clientsMux.RUnlock()
+	for _, client := range clients {
+		client.conn.WriteControl(websocket.CloseMessage, closing, time.Now().Add(time.Second))
+		client.conn.Close()
+	}
 }
@@ -5,13 +5,13 @@ import (
 	"fmt"
 	"io"
 	"log"
-	"os"
 	"sync"
 	"time"
 
 	"github.com/pion/webrtc/v3"
 	"github.com/pion/webrtc/v3/pkg/media"
 	"github.com/pion/webrtc/v3/pkg/media/h264reader"
+	"strzcam.com/broadcaster/video"
 )
 
 type StaticVideoTrack struct {
@@ -22,7 +22,7 @@ type StaticVideoTrack struct {
 	playCtx    context.Context
 	playCancel context.CancelFunc
 	reader     *h264reader.H264Reader
-	file       *os.File
+	file       io.ReadSeekCloser
 	frameDur   time.Duration
 	totalDur   time.Duration
 	playing    bool
@@ -70,7 +70,15 @@ func (vt *StaticVideoTrack) LoadVideo(filePath string) error {
 		vt.reader = nil
 	}
 
-	file, err := os.Open(filePath)
+	metadata, err := video.ReadVideoMetadata(filePath)
+	if err != nil {
+		return err
+	}
+	if metadata.Codec != video.CodecH264 {
+		return fmt.Errorf("can not stream %s video over WebRTC", metadata.Codec)
+	}
+
+	file, err := video.OpenVideo(filePath)
 	if err != nil {
 		return fmt.Errorf("failed to open video file: %w", err)
 	}
@@ -85,15 +93,16 @@ func (vt *StaticVideoTrack) LoadVideo(filePath string) error {
 	vt.reader = reader
 	vt.currentPos = 0
 	vt.frameCount = 0
-	frameDur, err := GetFrameDuration(filePath)
-	if err != nil {
+	if metadata.Fps > 0 {
+		// ffprobe can not read encrypted recordings, metadata has the framerate
+		vt.frameDur = time.Duration(float64(time.Second) / metadata.Fps)
+	} else if frameDur, err := GetFrameDuration(filePath); err != nil {
 		log.Printf("Warning: could not detect framerate, using default: %v", err)
 		vt.frameDur = time.Second / 30 // fallback
 	} else {
 		vt.frameDur = frameDur
 		log.Printf("Detected frame duration: %v (%.2f fps)", frameDur, float64(time.Second)/float64(frameDur))
 	}
-	vt.frameDur = frameDur
 	if err := vt.ReadDuration(filePath); err != nil {
 		return fmt.Errorf("Can not read file duration %w", err)
 	}
@@ -103,7 +112,7 @@ func (vt *StaticVideoTrack) LoadVideo(filePath string) error {
 
 func (vt *StaticVideoTrack) ReadDuration(filePath string) error {
 	// Count total frames to calculate duration
-	fileDuration, err := os.Open(filePath)
+	fileDuration, err := video.OpenVideo(filePath)
 	if err != nil {
 		return err
 	}
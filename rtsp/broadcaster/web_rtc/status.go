@@ -7,6 +7,9 @@ import (
 	"time"
 
 	"github.com/pion/webrtc/v3"
+	"strzcam.com/broadcaster/health"
+	"strzcam.com/broadcaster/schedule"
+	"strzcam.com/broadcaster/watchdog"
 )
 
 func updateStatus(ctx context.Context, dataChannel *webrtc.DataChannel, staticVideoTrack *StaticVideoTrack) {
@@ -20,7 +23,7 @@ func updateStatus(ctx context.Context, dataChannel *webrtc.DataChannel, staticVi
 			if dataChannel == nil || staticVideoTrack == nil {
 				return
 			}
-			log.Printf("updateSeek %s", staticVideoTrack.currentPos.Seconds())
+			log.Printf("updateSeek %f", staticVideoTrack.currentPos.Seconds())
 			if err := SendStatus(dataChannel, staticVideoTrack.currentPos.Seconds()); err == nil {
 				if !staticVideoTrack.playing {
 					return
@@ -41,6 +44,36 @@ func SendStatus(dataChannel *webrtc.DataChannel, position float64) error {
 	return err
 }
 
+func SendStatusMode(dataChannel *webrtc.DataChannel, status schedule.Status, modeError error) error {
+	message := StatusModeMessage{Type: "status", Mode: status}
+	if modeError != nil {
+		message.Error = modeError.Error()
+	}
+	statusMessage, err := json.Marshal(message)
+	if err == nil {
+		dataChannel.Send(statusMessage)
+	}
+	return err
+}
+
+func SendStatusHealth(dataChannel *webrtc.DataChannel, status health.Status) error {
+	statusMessage, err := json.Marshal(StatusHealthMessage{Type: "status", Health: status})
+	if err == nil {
+		dataChannel.Send(statusMessage)
+	}
+	return err
+}
+
+func SendStatusCamera(dataChannel *webrtc.DataChannel, state watchdog.State) error {
+	statusMessage, err := json.Marshal(StatusCameraMessage{
+		Type:   "status",
+		Camera: state,
+	})
+	if err == nil {
+		dataChannel.Send(statusMessage)
+	}
+	return err
+}
 func SendStatusLoadVideo(dataChannel *webrtc.DataChannel, isPlaying bool, position float64, isLoop bool, duration *float64) error {
 	var durationValue float64
 	if duration != nil {
@@ -58,7 +58,6 @@ func NewVideoTrack() (*VideoTrack, error) {
 		"video_frame_live",
 	)
 	if err != nil {
-		log.Fatal(err)
 		return nil, err
 	}
 	ctx, cancel := context.WithCancel(context.Background())
@@ -115,10 +114,20 @@ func (vt *VideoTrack) SendFrame(frame image.Image) error {
 
 	return nil
 }
-func (vt *VideoTrack) Start(memory *watcher.SharedMemoryReceiver) {
-	for frame := range memory.Frames {
-		img := frameUtils.BytesToYCbCr(frame.Data, int(frame.Width), int(frame.Height))
-		vt.SendFrame(img)
+
+// Start encodes frames of memory until ctx is done
+func (vt *VideoTrack) Start(ctx context.Context, memory *watcher.SharedMemoryReceiver) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case frame, ok := <-memory.Frames:
+			if !ok {
+				return
+			}
+			img := frameUtils.BytesToYCbCr(frame.Data, int(frame.Width), int(frame.Height))
+			vt.SendFrame(img)
+		}
 	}
 }
 func (vt *VideoTrack) Close() error {
@@ -0,0 +1,206 @@
+package zones
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+
+	"strzcam.com/broadcaster/frame"
+)
+
+// Point is relative to the frame, 0-1 on both axes, so zones survive
+// resolution changes
+type Point struct {
+	X float64 `json:"x"`
+	Y float64 `json:"y"`
+}
+
+type Zone struct {
+	Name    string  `json:"name"`
+	Points  []Point `json:"points"`
+	Exclude bool    `json:"exclude,omitempty"` // mask, detections overlapping it are ignored
+}
+
+const (
+	DirectionIn  = "in"
+	DirectionOut = "out"
+)
+
+// Tripwire is a line from From to To. Crossing it from its left side to its
+// right side, looking from From to To, is "in", the opposite way is "out".
+type Tripwire struct {
+	Name      string `json:"name"`
+	From      Point  `json:"from"`
+	To        Point  `json:"to"`
+	Direction string `json:"direction,omitempty"` // counted direction, both when empty
+}
+
+// CameraZones decides which detections of a camera are significant. Without
+// include zones the whole frame counts.
+type CameraZones struct {
+	Zones        []Zone     `json:"zones"`
+	MinBoxWidth  int        `json:"minBoxWidth,omitempty" yaml:"minBoxWidth"`
+	MinBoxHeight int        `json:"minBoxHeight,omitempty" yaml:"minBoxHeight"`
+	Tripwires    []Tripwire `json:"tripwires,omitempty"`
+}
+
+// Load reads zones of all cameras keyed by camera id, a missing file means no zones
+func Load(path string) (map[string]CameraZones, error) {
+	cameras := map[string]CameraZones{}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return cameras, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &cameras); err != nil {
+		return nil, fmt.Errorf("invalid zones file %s: %w", path, err)
+	}
+	for camera, config := range cameras {
+		if err := config.Validate(); err != nil {
+			return nil, fmt.Errorf("zones of %s: %w", camera, err)
+		}
+	}
+	return cameras, nil
+}
+
+func (c CameraZones) Validate() error {
+	for _, zone := range c.Zones {
+		if len(zone.Points) < 3 {
+			return fmt.Errorf("zone %q needs at least 3 points", zone.Name)
+		}
+	}
+	if c.MinBoxWidth < 0 || c.MinBoxHeight < 0 {
+		return errors.New("minimum box size must not be negative")
+	}
+	for _, tripwire := range c.Tripwires {
+		if tripwire.Name == "" {
+			return errors.New("tripwire needs a name")
+		}
+		if tripwire.Direction != "" && tripwire.Direction != DirectionIn && tripwire.Direction != DirectionOut {
+			return fmt.Errorf("tripwire %q has invalid direction %q", tripwire.Name, tripwire.Direction)
+		}
+	}
+	return nil
+}
+
+// IsEmpty tells if detections are kept as they are, tripwires do not filter
+func (c CameraZones) IsEmpty() bool {
+	return len(c.Zones) == 0 && c.MinBoxWidth == 0 && c.MinBoxHeight == 0
+}
+
+// Match returns the include zones the box overlaps and whether it is significant
+func (c CameraZones) Match(box frame.Box, width uint32, height uint32) ([]string, bool) {
+	if box.Width < c.MinBoxWidth || box.Height < c.MinBoxHeight {
+		return nil, false
+	}
+	rect := [4]float64{
+		float64(box.X) / float64(width),
+		float64(box.Y) / float64(height),
+		float64(box.X+box.Width) / float64(width),
+		float64(box.Y+box.Height) / float64(height),
+	}
+	var names []string
+	hasInclude := false
+	for _, zone := range c.Zones {
+		overlaps := overlapsRect(zone.Points, rect)
+		if zone.Exclude {
+			if overlaps {
+				return nil, false
+			}
+			continue
+		}
+		hasInclude = true
+		if overlaps {
+			names = append(names, zone.Name)
+		}
+	}
+	return names, !hasInclude || len(names) > 0
+}
+
+// Filter keeps significant boxes with their zones. Frames without boxes are
+// kept as they are, the source could not tell where the detection was.
+func (c CameraZones) Filter(f frame.Frame) frame.Frame {
+	if len(f.Boxes) == 0 || c.IsEmpty() {
+		return f
+	}
+	var boxes []frame.Box
+	for _, box := range f.Boxes {
+		if names, ok := c.Match(box, f.Width, f.Height); ok {
+			box.Zones = names
+			boxes = append(boxes, box)
+		}
+	}
+	f.Boxes = boxes
+	if len(boxes) == 0 {
+		f.Detected = -1
+	} else {
+		f.Detected = boxes[0].Class
+	}
+	return f
+}
+
+// Crossed returns the direction of a move from a to b across the tripwire.
+// Moves in a direction the tripwire does not count are ignored.
+func (t Tripwire) Crossed(a Point, b Point) (string, bool) {
+	if !segmentsIntersect(t.From, t.To, a, b) {
+		return "", false
+	}
+	direction := DirectionOut
+	if side(t.From, t.To, b) > 0 {
+		direction = DirectionIn
+	}
+	if t.Direction != "" && t.Direction != direction {
+		return "", false
+	}
+	return direction, true
+}
+
+// side is negative on the left of the line from a to b in image coordinates
+func side(a Point, b Point, p Point) float64 {
+	return (b.X-a.X)*(p.Y-a.Y) - (b.Y-a.Y)*(p.X-a.X)
+}
+
+// overlapsRect tells if the polygon and the rectangle (x0, y0, x1, y1) intersect
+func overlapsRect(polygon []Point, rect [4]float64) bool {
+	corners := []Point{{rect[0], rect[1]}, {rect[2], rect[1]}, {rect[2], rect[3]}, {rect[0], rect[3]}}
+	for _, corner := range corners {
+		if Contains(polygon, corner) {
+			return true
+		}
+	}
+	for _, point := range polygon {
+		if point.X >= rect[0] && point.X <= rect[2] && point.Y >= rect[1] && point.Y <= rect[3] {
+			return true
+		}
+	}
+	for i := range polygon {
+		a, b := polygon[i], polygon[(i+1)%len(polygon)]
+		for j := range corners {
+			if segmentsIntersect(a, b, corners[j], corners[(j+1)%len(corners)]) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Contains tells if the point is inside the polygon, it uses ray casting
+func Contains(polygon []Point, p Point) bool {
+	inside := false
+	for i, j := 0, len(polygon)-1; i < len(polygon); j, i = i, i+1 {
+		a, b := polygon[i], polygon[j]
+		if (a.Y > p.Y) != (b.Y > p.Y) && p.X < (b.X-a.X)*(p.Y-a.Y)/(b.Y-a.Y)+a.X {
+			inside = !inside
+		}
+	}
+	return inside
+}
+
+func segmentsIntersect(a Point, b Point, c Point, d Point) bool {
+	d1, d2 := side(c, d, a), side(c, d, b)
+	d3, d4 := side(a, b, c), side(a, b, d)
+	return ((d1 > 0) != (d2 > 0)) && ((d3 > 0) != (d4 > 0))
+}
@@ -0,0 +1,87 @@
+package zones
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"strzcam.com/broadcaster/frame"
+)
+
+var (
+	leftHalf   = Zone{Name: "left", Points: []Point{{0, 0}, {0.5, 0}, {0.5, 1}, {0, 1}}}
+	bottomMask = Zone{Name: "street", Points: []Point{{0, 0.8}, {1, 0.8}, {1, 1}, {0, 1}}, Exclude: true}
+)
+
+func TestMatch(t *testing.T) {
+	cameraZones := CameraZones{Zones: []Zone{leftHalf, bottomMask}, MinBoxWidth: 10, MinBoxHeight: 10}
+	tests := []struct {
+		name  string
+		box   frame.Box
+		zones []string
+		ok    bool
+	}{
+		{"inside include zone", frame.Box{X: 10, Y: 10, Width: 20, Height: 20}, []string{"left"}, true},
+		{"crossing include zone edge", frame.Box{X: 40, Y: 10, Width: 20, Height: 20}, []string{"left"}, true},
+		{"outside include zone", frame.Box{X: 70, Y: 10, Width: 20, Height: 20}, nil, false},
+		{"overlapping mask", frame.Box{X: 10, Y: 70, Width: 20, Height: 20}, nil, false},
+		{"too small", frame.Box{X: 10, Y: 10, Width: 5, Height: 20}, nil, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			zones, ok := cameraZones.Match(test.box, 100, 100)
+			if ok != test.ok || !slices.Equal(zones, test.zones) {
+				t.Errorf("Expected %v %v, got %v %v", test.zones, test.ok, zones, ok)
+			}
+		})
+	}
+	t.Run("mask only counts the rest of the frame", func(t *testing.T) {
+		masked := CameraZones{Zones: []Zone{bottomMask}}
+		if _, ok := masked.Match(frame.Box{X: 70, Y: 10, Width: 20, Height: 20}, 100, 100); !ok {
+			t.Error("Expected a box outside the mask to be significant")
+		}
+	})
+}
+
+func TestFilter(t *testing.T) {
+	cameraZones := CameraZones{Zones: []Zone{leftHalf}}
+	t.Run("keeps boxes in zones", func(t *testing.T) {
+		f := frame.Frame{Width: 100, Height: 100, Detected: 2, Boxes: []frame.Box{
+			{Class: 2, X: 70, Y: 10, Width: 20, Height: 20},
+			{Class: 0, X: 10, Y: 10, Width: 20, Height: 20},
+		}}
+		f = cameraZones.Filter(f)
+		if len(f.Boxes) != 1 || f.Detected != 0 {
+			t.Fatalf("Expected only the person box, got %+v detected %d", f.Boxes, f.Detected)
+		}
+		if !slices.Equal(f.Boxes[0].Zones, []string{"left"}) {
+			t.Errorf("Expected zone left, got %v", f.Boxes[0].Zones)
+		}
+	})
+	t.Run("drops detection outside zones", func(t *testing.T) {
+		f := frame.Frame{Width: 100, Height: 100, Detected: 2, Boxes: []frame.Box{{Class: 2, X: 70, Y: 10, Width: 20, Height: 20}}}
+		if f = cameraZones.Filter(f); f.Detected != -1 {
+			t.Errorf("Expected no detection, got %d", f.Detected)
+		}
+	})
+	t.Run("frame without boxes", func(t *testing.T) {
+		f := frame.Frame{Width: 100, Height: 100, Detected: 2}
+		if f = cameraZones.Filter(f); f.Detected != 2 {
+			t.Errorf("Expected detection to be kept, got %d", f.Detected)
+		}
+	})
+}
+
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+	cameras, err := Load(filepath.Join(dir, "missing.json"))
+	if err != nil || len(cameras) != 0 {
+		t.Fatalf("Expected no zones for a missing file, got %v %v", cameras, err)
+	}
+	path := filepath.Join(dir, "zones.json")
+	os.WriteFile(path, []byte(`{"front": {"zones": [{"name": "line", "points": [{"x": 0, "y": 0}, {"x": 1, "y": 1}]}]}}`), 0644)
+	if _, err := Load(path); err == nil {
+		t.Error("Expected an error for a zone with 2 points")
+	}
+}
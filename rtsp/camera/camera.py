@@ -17,16 +17,18 @@ SHOW_NOW_LABEL = bool(os.getenv("SHOW_NOW_LABEL", ""))
 
 def process_frame(frame, detector, is_motion_detected, cached_detection=None):
     type_detected = -1
+    boxes = []
     drawer = Drawer(frame)
     if is_motion_detected or cached_detection:
         height, width = frame.shape[:2]
         scaled_frame = cv2.resize(frame, (int(width * 0.99), int(height * 0.99)))
         detection = cached_detection or detector.detect_yolo_with_nms(scaled_frame)
-        for x0, y0, w, h, type_detected, scale in detection:
+        for x0, y0, w, h, type_detected, confidence in detection:
             drawer.rectangle(detector.yolo_class_id_to_verbose[type_detected], x0, y0, w, h)
+            boxes.append((type_detected, x0, y0, w, h, confidence))
 
     drawer.label(is_motion_detected)
-    return frame, type_detected
+    return frame, type_detected, boxes
 
 
 def main():
@@ -78,12 +80,12 @@ def main():
                 print("Failed to grab frame")
                 continue
             if not fps.should_process():
-                frame, type_detected = process_frame(
+                frame, type_detected, boxes = process_frame(
                     camera_frame, detector, False, detector.last_detection
                 )
                 continue
             is_motion_detected = motion.detected_long(camera_frame)
-            frame, type_detected = process_frame(
+            frame, type_detected, boxes = process_frame(
                 camera_frame, detector, is_motion_detected
             )
             if RESIZE_WIDTH != 1 or RESIZE_HEIGHT != 1:
@@ -93,7 +95,15 @@ def main():
             if SAVE_TO_SHM:
                 buffer = cv2.cvtColor(frame, cv2.COLOR_BGR2YUV_I420)
                 write_frame_to_shared_memory(
-                    buffer, type_detected, width, height, shm_name=f"video_frame"
+                    buffer,
+                    type_detected,
+                    width,
+                    height,
+                    shm_name=f"video_frame",
+                    boxes=[
+                        (box_type, x * RESIZE_WIDTH, y * RESIZE_HEIGHT, w * RESIZE_WIDTH, h * RESIZE_HEIGHT, confidence)
+                        for box_type, x, y, w, h, confidence in boxes
+                    ],
                 )
             if SAVE_VIDEO:
                 found_object = type_detected != -1
@@ -0,0 +1,56 @@
+"""Detector plugin for the broadcaster, see its README.
+
+Frames come over a unix socket as length prefixed YUV 4:2:0 requests and
+are answered with the boxes found by the YOLO detector.
+"""
+import os
+import socketserver
+import struct
+
+import cv2
+import numpy as np
+from dotenv import load_dotenv
+
+from detector.detector import Detector
+
+load_dotenv()
+SOCKET_PATH = os.getenv("DETECTOR_SOCKET", "/tmp/detector.sock")
+detector = Detector()
+
+
+def read_exact(stream, size):
+    data = stream.read(size)
+    if len(data) != size:
+        raise EOFError
+    return data
+
+
+class DetectorHandler(socketserver.StreamRequestHandler):
+    def handle(self):
+        while True:
+            try:
+                (length,) = struct.unpack("<I", read_exact(self.rfile, 4))
+                message = read_exact(self.rfile, length)
+            except EOFError:
+                return
+            request_id, width, height = struct.unpack("<III", message[:12])
+            yuv = np.frombuffer(message[12:], dtype=np.uint8).reshape(height * 3 // 2, width)
+            frame = cv2.cvtColor(yuv, cv2.COLOR_YUV2BGR_I420)
+            boxes = list(detector.detect_yolo_with_nms(frame))
+            response = struct.pack("<IH", request_id, len(boxes))
+            for x, y, w, h, class_id, confidence in boxes:
+                response += struct.pack(
+                    "<bHHHHB",
+                    class_id,
+                    *(max(0, min(int(value), 0xFFFF)) for value in (x, y, w, h)),
+                    max(0, min(round(confidence * 100), 100)),
+                )
+            self.wfile.write(struct.pack("<I", len(response)) + response)
+
+
+if __name__ == "__main__":
+    if os.path.exists(SOCKET_PATH):
+        os.remove(SOCKET_PATH)
+    with socketserver.ThreadingUnixStreamServer(SOCKET_PATH, DetectorHandler) as server:
+        print(f"Detector plugin listening on {SOCKET_PATH}")
+        server.serve_forever()
@@ -4,8 +4,14 @@ import cv2
 import time
 
 
-def write_frame_to_shared_memory(buffer, type_, width, height, shm_name="video_frame"):
-    """Save frame buffer to shared memory."""
+def write_frame_to_shared_memory(
+    buffer, type_, width, height, shm_name="video_frame", boxes=()
+):
+    """Save frame buffer to shared memory.
+
+    Boxes (type, x, y, w, h, confidence) follow the frame as a count and one
+    record each, confidence is sent in percent.
+    """
     data = buffer.tobytes()
     header = struct.pack("<bII", type_, width, height)
     shm_path = f"/dev/shm/{shm_name}"
@@ -13,6 +19,16 @@ def write_frame_to_shared_memory(buffer, type_, width, height, shm_name="video_f
     with open(temp_path, "wb") as f:
         f.write(header)
         f.write(data)
+        f.write(struct.pack("<H", len(boxes)))
+        for box_type, x, y, w, h, confidence in boxes:
+            f.write(
+                struct.pack(
+                    "<bHHHHB",
+                    box_type,
+                    *(max(0, min(int(value), 0xFFFF)) for value in (x, y, w, h)),
+                    max(0, min(round(confidence * 100), 100)),
+                )
+            )
     # Atomic rename
     os.rename(temp_path, shm_path)
 
@@ -21,7 +37,7 @@ def read_frame_from_shared_memory(shm_name="video_frame"):
     with open(f"/dev/shm/{shm_name}", "rb") as f:
         header = f.read(9)
         type_, width, height = struct.unpack("<bII", header)
-        data = f.read()
+        data = f.read(width * height * 3 // 2)
     return data, type_
 
 